@@ -0,0 +1,36 @@
+package apitypes
+
+import "github.com/yiran15/api-server/model"
+
+type AccessTokenCreateRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	// ExpireDays 有效天数
+	ExpireDays int `json:"expireDays" binding:"required,min=1,max=365"`
+	// RolesID 令牌可使用的角色, 必须是当前用户拥有的角色, 为空时使用用户的全部角色
+	RolesID []int64 `json:"rolesID"`
+}
+
+type AccessTokenUpdateRequest struct {
+	*IDRequest
+	// Name 为空时不修改
+	Name string `json:"name" binding:"omitempty,max=100"`
+	// ExpireDays 从现在开始的有效天数, 为 0 时不修改
+	ExpireDays int `json:"expireDays" binding:"omitempty,min=1,max=365"`
+	// RolesID 为 nil 时不修改, 必须是当前用户拥有的角色, 为空数组时使用用户的全部角色
+	RolesID *[]int64 `json:"rolesID"`
+}
+
+type AccessTokenCreateResponse struct {
+	// Token 令牌明文, 只在创建时返回一次
+	Token       string                     `json:"token"`
+	AccessToken *model.PersonalAccessToken `json:"accessToken"`
+}
+
+type AccessTokenListRequest struct {
+	*Pagination
+}
+
+type AccessTokenListResponse struct {
+	*ListResponse
+	List []*model.PersonalAccessToken `json:"list"`
+}
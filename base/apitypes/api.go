@@ -7,6 +7,8 @@ type ApiCreateRequest struct {
 	Path        string `json:"path" binding:"required,uri"`
 	Method      string `json:"method" binding:"required,oneof=GET POST PUT DELETE *"`
 	Description string `json:"description"`
+	// Effect 分配给角色时的效果, 默认为 allow, 创建后不能修改
+	Effect string `json:"effect" binding:"omitempty,oneof=allow deny"`
 }
 
 type ApiUpdateRequest struct {
@@ -0,0 +1,25 @@
+package apitypes
+
+import (
+	"time"
+
+	"github.com/yiran15/api-server/model"
+)
+
+type AuditListRequest struct {
+	*Pagination
+	ActorID    int64  `form:"actorId"`
+	Action     string `form:"action"`
+	TargetType string `form:"targetType" binding:"omitempty,oneof=user role api"`
+	TargetID   string `form:"targetId"`
+	Result     string `form:"result" binding:"omitempty,oneof=success failure"`
+	// StartTime EndTime 按操作时间过滤, RFC3339 格式
+	StartTime *time.Time `form:"startTime" time_format:"2006-01-02T15:04:05Z07:00"`
+	EndTime   *time.Time `form:"endTime" time_format:"2006-01-02T15:04:05Z07:00"`
+	Direction string     `form:"direction" binding:"omitempty,oneof=asc desc"`
+}
+
+type AuditListResponse struct {
+	*ListResponse
+	List []*model.AuditLog `json:"list"`
+}
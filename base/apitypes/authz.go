@@ -0,0 +1,28 @@
+package apitypes
+
+// AuthzCheckRequest 检查用户或角色能否调用接口, userId 和 role 二选一
+type AuthzCheckRequest struct {
+	UserID int64  `json:"userId" binding:"required_without=Role"`
+	Role   string `json:"role" binding:"required_without=UserID"`
+	Path   string `json:"path" binding:"required,startswith=/"`
+	Method string `json:"method" binding:"required,oneof=GET POST PUT PATCH DELETE"`
+}
+
+type AuthzCheckResponse struct {
+	Allowed bool `json:"allowed"`
+	// Roles 参与检查的角色, 父角色由 casbin 按继承关系检查
+	Roles []string `json:"roles"`
+	// Policy 决定结果的策略 (角色, 租户, 路径, 方法, 效果), 没有匹配的策略时为空, 默认拒绝
+	Policy []string `json:"policy"`
+}
+
+type AuthzPermission struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+type AuthzPermissionsResponse struct {
+	// Roles 当前 token 在当前租户可以使用的角色
+	Roles []string           `json:"roles"`
+	Apis  []*AuthzPermission `json:"apis"`
+}
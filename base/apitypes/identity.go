@@ -0,0 +1,10 @@
+package apitypes
+
+type IdentityLinkRequest struct {
+	Provider string `form:"provider" binding:"required"`
+}
+
+type IdentityLinkResponse struct {
+	// URL provider 授权页面地址, 前端跳转到该地址完成授权
+	URL string `json:"url"`
+}
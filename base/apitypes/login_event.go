@@ -0,0 +1,26 @@
+package apitypes
+
+import (
+	"time"
+
+	"github.com/yiran15/api-server/model"
+)
+
+type LoginHistoryFilter struct {
+	*Pagination
+	Event  string `form:"event" binding:"omitempty,oneof=login login_mfa oauth2_callback oauth2_activate logout authz_denied"`
+	Result string `form:"result" binding:"omitempty,oneof=success failure"`
+	// StartTime EndTime 按事件时间过滤, RFC3339 格式
+	StartTime *time.Time `form:"startTime" time_format:"2006-01-02T15:04:05Z07:00"`
+	EndTime   *time.Time `form:"endTime" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+type LoginHistoryRequest struct {
+	ID int64 `uri:"id" binding:"required"`
+	LoginHistoryFilter
+}
+
+type LoginHistoryResponse struct {
+	*ListResponse
+	List []*model.LoginEvent `json:"list"`
+}
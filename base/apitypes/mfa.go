@@ -0,0 +1,23 @@
+package apitypes
+
+type MfaEnrollResponse struct {
+	// Secret base32 编码的 TOTP 密钥, 无法扫码时手动输入
+	Secret string `json:"secret"`
+	// URI otpauth URI, 用于生成二维码
+	URI string `json:"uri"`
+}
+
+type MfaConfirmRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type MfaConfirmResponse struct {
+	// RecoveryCodes 一次性恢复码, 只在启用时返回一次, 无法使用验证器时代替验证码登录
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type UserLoginMfaRequest struct {
+	MfaToken string `json:"mfaToken" binding:"required"`
+	// Code 验证器生成的验证码或恢复码
+	Code string `json:"code" binding:"required"`
+}
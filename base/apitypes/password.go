@@ -0,0 +1,11 @@
+package apitypes
+
+type PasswordForgotRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type PasswordResetRequest struct {
+	Token           string `json:"token" binding:"required"`
+	Password        string `json:"password" binding:"required,password"`
+	ConfirmPassword string `json:"confirmPassword" binding:"required,eqfield=Password"`
+}
@@ -6,12 +6,20 @@ type RoleCreateRequest struct {
 	Name        string  `json:"name" binding:"required,ascii"`
 	Description string  `json:"description"`
 	Apis        []int64 `json:"apis"`
+	// DenyApis 禁止访问的接口 id, 优先于角色自身和继承的 allow 权限
+	DenyApis []int64 `json:"denyApis"`
+	// ParentRoles 继承的角色 id
+	ParentRoles []int64 `json:"parentRoles"`
 }
 
 type RoleUpdateRequest struct {
 	*IDRequest
 	Description string  `json:"description"`
 	Apis        []int64 `json:"apis"`
+	// DenyApis 禁止访问的接口 id, 不传时不修改, 传空数组时取消
+	DenyApis []int64 `json:"denyApis"`
+	// ParentRoles 继承的角色 id, 不传时不修改, 传空数组时取消继承
+	ParentRoles []int64 `json:"parentRoles"`
 }
 
 type RoleListRequest struct {
@@ -25,3 +33,18 @@ type RoleListResponse struct {
 	*ListResponse
 	List []*model.Role `json:"list"`
 }
+
+// RoleEffectiveApi 角色最终拥有的接口, GrantedBy 和 DeniedBy 为以 allow 和 deny 分配该接口的角色
+// 任一角色为 deny 时 Effect 为 deny
+type RoleEffectiveApi struct {
+	*model.Api
+	Effect    string   `json:"effect"`
+	GrantedBy []string `json:"grantedBy"`
+	DeniedBy  []string `json:"deniedBy"`
+}
+
+type RoleEffectiveApisResponse struct {
+	// Roles 角色及其直接或间接继承的角色
+	Roles []string            `json:"roles"`
+	Apis  []*RoleEffectiveApi `json:"apis"`
+}
@@ -0,0 +1,10 @@
+package apitypes
+
+type SessionIDRequest struct {
+	ID string `uri:"id" binding:"required"`
+}
+
+type UserSessionRequest struct {
+	ID        int64  `uri:"id" binding:"required"`
+	SessionID string `uri:"sid" binding:"required"`
+}
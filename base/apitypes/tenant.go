@@ -0,0 +1,36 @@
+package apitypes
+
+import "github.com/yiran15/api-server/model"
+
+type TenantCreateRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+type TenantUpdateRequest struct {
+	*IDRequest
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type TenantListRequest struct {
+	*Pagination
+	Name      string `form:"name"`
+	Sort      string `form:"sort" binding:"omitempty,oneof=id name created_at updated_at"`
+	Direction string `form:"direction" binding:"omitempty,oneof=asc desc"`
+}
+
+type TenantListResponse struct {
+	*ListResponse
+	List []*model.Tenant `json:"list"`
+}
+
+type TenantAddUsersRequest struct {
+	*IDRequest
+	UsersID []int64 `json:"usersId" binding:"required,min=1"`
+}
+
+type TenantRemoveUserRequest struct {
+	ID     int64 `uri:"id" binding:"required"`
+	UserID int64 `uri:"userId" binding:"required"`
+}
@@ -5,20 +5,33 @@ import (
 )
 
 type UserLoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
+	// Email 本地用户使用邮箱登录, 启用 LDAP 时也可以使用 LDAP 用户名
+	Email    string `json:"email" binding:"required,max=255"`
 	Password string `json:"password" binding:"required,min=8"`
 }
 
 type UserLoginResponse struct {
-	User  *model.User `json:"user"`
-	Token string      `json:"token"`
+	User         *model.User `json:"user"`
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refreshToken,omitempty"`
+	// ExpiresIn access token 剩余有效期, 单位秒
+	ExpiresIn int64 `json:"expiresIn,omitempty"`
+	// MfaRequired 用户启用了两步验证, 需要使用 MfaToken 和验证码调用 /user/login/mfa 完成登录
+	MfaRequired bool   `json:"mfaRequired,omitempty"`
+	MfaToken    string `json:"mfaToken,omitempty"`
+}
+
+type UserRefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+	// TenantID 切换到指定租户, 为空时保持当前租户
+	TenantID *int64 `json:"tenantId"`
 }
 
 type UserCreateRequest struct {
 	Name     string   `json:"name" binding:"required"`
 	NickName string   `json:"nickName"`
 	Email    string   `json:"email" binding:"required,email"`
-	Password string   `json:"password" binding:"required,min=8"`
+	Password string   `json:"password" binding:"required,password"`
 	Avatar   string   `json:"avatar"`
 	Mobile   string   `json:"mobile" binding:"omitempty,mobile"`
 	RolesID  *[]int64 `json:"rolesID"`
@@ -36,7 +49,7 @@ type UserUpdateSelfRequest struct {
 	NickName    string `json:"nickName"`
 	Email       string `json:"email" binding:"omitempty,email"`
 	OldPassword string `json:"oldPassword" binding:"omitempty,min=8"`
-	Password    string `json:"password" binding:"omitempty,min=8"`
+	Password    string `json:"password" binding:"omitempty,password"`
 	Avatar      string `json:"avatar"`
 	Mobile      string `json:"mobile" binding:"omitempty,mobile"`
 }
@@ -82,6 +95,6 @@ type OauthLoginResponse struct {
 
 type OAuthActivateRequest struct {
 	ID              string `uri:"id" binding:"required"`
-	Password        string `json:"password" binding:"required,min=8"`
+	Password        string `json:"password" binding:"required,password"`
 	ConfirmPassword string `json:"confirmPassword" binding:"required,min=8"`
 }
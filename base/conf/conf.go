@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -11,12 +12,29 @@ import (
 )
 
 const (
-	defaultLoglevel        = "info"
-	defaultServerBind      = "0.0.0.0:8080"
-	defaultServerTimeZone  = "Asia/Shanghai"
-	defaultJwtIssuer       = "api-server"
-	defaultJwtExpireTime   = "1h"
-	defaultRedisExpireTime = "1h"
+	defaultLoglevel             = "info"
+	defaultServerBind           = "0.0.0.0:8080"
+	defaultServerTimeZone       = "Asia/Shanghai"
+	defaultJwtIssuer            = "api-server"
+	defaultJwtExpireTime        = "1h"
+	defaultJwtRefreshExpireTime = "168h"
+	defaultJwtAlgorithm         = "HS256"
+	defaultJwtRotationInterval  = "720h"
+	defaultRedisExpireTime      = "1h"
+	defaultMfaTokenExpireTime   = "5m"
+	defaultMfaMaxAttempts       = 5
+
+	defaultLoginFailureWindow      = "15m"
+	defaultLoginMaxEmailFailures   = 5
+	defaultLoginMaxIPFailures      = 50
+	defaultLoginLockoutDuration    = "5m"
+	defaultLoginMaxLockoutDuration = "24h"
+
+	defaultPasswordResetExpireTime = "30m"
+
+	minScimTokenLength = 32
+
+	defaultCasbinReloadInterval = "10m"
 )
 
 // 加载配置
@@ -101,6 +119,167 @@ func GetJwtExpirationTime() (time.Duration, error) {
 	return expireTime, nil
 }
 
+func GetJwtRefreshExpirationTime() (time.Duration, error) {
+	expireTime := viper.GetDuration("jwt.refreshExpireTime")
+	if expireTime == 0 {
+		expire, err := time.ParseDuration(defaultJwtRefreshExpireTime)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parser jwt.refreshExpireTime err: %v", err)
+		}
+		return expire, nil
+	}
+	return expireTime, nil
+}
+
+// GetJwtAlgorithm jwt 签名算法, 支持 HS256 RS256 ES256 EdDSA
+func GetJwtAlgorithm() (string, error) {
+	algorithm := viper.GetString("jwt.algorithm")
+	if algorithm == "" {
+		return defaultJwtAlgorithm, nil
+	}
+	switch algorithm {
+	case "HS256", "RS256", "ES256", "EdDSA":
+		return algorithm, nil
+	default:
+		return "", fmt.Errorf("jwt.algorithm %s is not supported", algorithm)
+	}
+}
+
+// GetJwtRotationInterval 非对称密钥轮换周期
+func GetJwtRotationInterval() (time.Duration, error) {
+	interval := viper.GetDuration("jwt.rotationInterval")
+	if interval == 0 {
+		duration, err := time.ParseDuration(defaultJwtRotationInterval)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parser jwt.rotationInterval err: %v", err)
+		}
+		return duration, nil
+	}
+	return interval, nil
+}
+
+// GetMfaIssuer 验证器中显示的签发者, 默认使用 jwt.issuer
+func GetMfaIssuer() string {
+	issuer := viper.GetString("mfa.issuer")
+	if issuer == "" {
+		return GetJwtIssuer()
+	}
+	return issuer
+}
+
+// GetMfaTokenExpireTime 密码校验通过后, 等待输入两步验证码的 mfa token 过期时间
+func GetMfaTokenExpireTime() (time.Duration, error) {
+	expireTime := viper.GetDuration("mfa.tokenExpireTime")
+	if expireTime == 0 {
+		expire, err := time.ParseDuration(defaultMfaTokenExpireTime)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parser mfa.tokenExpireTime err: %v", err)
+		}
+		return expire, nil
+	}
+	return expireTime, nil
+}
+
+// GetMfaMaxAttempts 每个 mfa token 允许尝试验证码的次数
+func GetMfaMaxAttempts() int {
+	attempts := viper.GetInt("mfa.maxAttempts")
+	if attempts <= 0 {
+		return defaultMfaMaxAttempts
+	}
+	return attempts
+}
+
+// GetMfaRequiredRoles 必须通过两步验证才能使用的角色
+func GetMfaRequiredRoles() []string {
+	return viper.GetStringSlice("mfa.requiredRoles")
+}
+
+// GetServerTrustedProxies 信任的反向代理, 只有来自这些地址的请求才会使用 X-Forwarded-For 获取客户端 IP
+// 默认信任本机和内网地址
+func GetServerTrustedProxies() []string {
+	proxies := viper.GetStringSlice("server.trustedProxies")
+	if len(proxies) == 0 {
+		return []string{"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "::1/128", "fc00::/7"}
+	}
+	return proxies
+}
+
+// GetLoginFailureWindow 统计登录失败次数的滑动窗口
+func GetLoginFailureWindow() (time.Duration, error) {
+	return getDuration("login.failureWindow", defaultLoginFailureWindow)
+}
+
+// GetLoginMaxEmailFailures 窗口内同一邮箱允许的失败次数, 超过后锁定该邮箱
+func GetLoginMaxEmailFailures() int {
+	if n := viper.GetInt("login.maxEmailFailures"); n > 0 {
+		return n
+	}
+	return defaultLoginMaxEmailFailures
+}
+
+// GetLoginMaxIPFailures 窗口内同一 IP 允许的失败次数, 超过后拒绝该 IP 的登录请求
+func GetLoginMaxIPFailures() int {
+	if n := viper.GetInt("login.maxIPFailures"); n > 0 {
+		return n
+	}
+	return defaultLoginMaxIPFailures
+}
+
+// GetLoginLockoutDuration 第一次锁定的时长, 之后每次锁定时长翻倍
+func GetLoginLockoutDuration() (time.Duration, error) {
+	return getDuration("login.lockoutDuration", defaultLoginLockoutDuration)
+}
+
+// GetLoginMaxLockoutDuration 锁定时长上限
+func GetLoginMaxLockoutDuration() (time.Duration, error) {
+	return getDuration("login.maxLockoutDuration", defaultLoginMaxLockoutDuration)
+}
+
+// GetPasswordResetExpireTime 密码重置链接的有效期
+func GetPasswordResetExpireTime() (time.Duration, error) {
+	return getDuration("password.resetExpireTime", defaultPasswordResetExpireTime)
+}
+
+// GetPasswordResetURL 邮件中的密码重置链接, {token} 会被替换为重置 token
+func GetPasswordResetURL() (string, error) {
+	url := viper.GetString("password.resetURL")
+	if url == "" {
+		return "", fmt.Errorf("password.resetURL is empty")
+	}
+	if !strings.Contains(url, "{token}") {
+		return "", fmt.Errorf("password.resetURL must contain {token}")
+	}
+	return url, nil
+}
+
+// GetScimToken SCIM 客户端使用的 bearer token, 未启用 scim 时返回空字符串
+func GetScimToken() (string, error) {
+	if !viper.GetBool("scim.enable") {
+		return "", nil
+	}
+	token := viper.GetString("scim.token")
+	if len(token) < minScimTokenLength {
+		return "", fmt.Errorf("scim.token must be at least %d characters", minScimTokenLength)
+	}
+	return token, nil
+}
+
+// GetCasbinReloadInterval 定期从数据库重新加载 casbin 策略的周期, 兜底 redis 通知丢失的情况
+func GetCasbinReloadInterval() (time.Duration, error) {
+	return getDuration("casbin.reloadInterval", defaultCasbinReloadInterval)
+}
+
+func getDuration(key, defaultValue string) (time.Duration, error) {
+	if duration := viper.GetDuration(key); duration > 0 {
+		return duration, nil
+	}
+	duration, err := time.ParseDuration(defaultValue)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parser %s err: %v", key, err)
+	}
+	return duration, nil
+}
+
 // mysql 配置
 func GetMysqlDsn() (dsn string, err error) {
 	user := viper.GetString("mysql.username")
@@ -8,9 +8,12 @@ type providerContextKey struct{}
 
 type requestIDContextKey struct{}
 
+type clientInfoContextKey struct{}
+
 var UserContextKey = userContextKey{}
 var ProviderContextKey = providerContextKey{}
 var RequestIDContextKey = requestIDContextKey{}
+var ClientInfoContextKey = clientInfoContextKey{}
 
 var ApiData apitypes.ServerApiData
 
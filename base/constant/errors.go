@@ -8,4 +8,18 @@ var (
 	ErrAuthFailed   = errors.New("auth failed")
 	ErrNoPermission = errors.New("access forbidden")
 	ErrLoginFailed  = errors.New("incorrect username or password")
+	// refresh token 无效、过期或已被使用
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	// 两步验证码错误、mfa token 无效或尝试次数过多
+	ErrMfaFailed = errors.New("invalid mfa code")
+	// 登录失败次数过多, 账号或 IP 被临时锁定, 不区分用户是否存在
+	ErrTooManyLoginAttempts = errors.New("too many failed login attempts, please try again later")
+	// 请求频率超过限流配置
+	ErrTooManyRequests = errors.New("too many requests")
+	// 密码重置 token 无效、过期或已被使用
+	ErrPasswordResetTokenInvalid = errors.New("invalid or expired password reset token")
+	// 外部账号已关联其他用户
+	ErrIdentityLinked = errors.New("external account is already linked to another user")
+	// OAuth2 登录的邮箱未经 provider 验证, 且已被本地用户使用, 需要登录后手动关联
+	ErrIdentityEmailConflict = errors.New("email is already used by another user, please login and link this account")
 )
@@ -5,3 +5,14 @@ import "slices"
 func InArray[T comparable](arr []T, val T) bool {
 	return slices.Contains(arr, val)
 }
+
+// Intersect 返回同时存在于 a 和 b 中的元素, 保持 a 中的顺序
+func Intersect[T comparable](a, b []T) []T {
+	res := make([]T, 0, len(a))
+	for _, v := range a {
+		if slices.Contains(b, v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,28 @@
+package helper
+
+import (
+	"context"
+
+	"github.com/yiran15/api-server/base/constant"
+)
+
+// ClientInfo 发起请求的客户端信息
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+func GetClientInfoFromContext(ctx context.Context) ClientInfo {
+	if info, ok := ctx.Value(constant.ClientInfoContextKey).(ClientInfo); ok {
+		return info
+	}
+	return ClientInfo{}
+}
+
+func GetClientIPFromContext(ctx context.Context) string {
+	return GetClientInfoFromContext(ctx).IP
+}
+
+func GetUserAgentFromContext(ctx context.Context) string {
+	return GetClientInfoFromContext(ctx).UserAgent
+}
@@ -0,0 +1,50 @@
+package helper
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// SecretBox 使用 AES-GCM 加密保存在数据库中的敏感数据, 密钥由配置的 secret 派生
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+func NewSecretBox(secret string) (*SecretBox, error) {
+	sum := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal 加密数据, 返回 base64 编码的 nonce+密文
+func (b *SecretBox) Seal(plain []byte) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b.aead.Seal(nonce, nonce, plain, nil)), nil
+}
+
+// Open 解密 Seal 生成的数据
+func (b *SecretBox) Open(encrypted string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < b.aead.NonceSize() {
+		return nil, errors.New("encrypted data too short")
+	}
+	nonce, ciphertext := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	return b.aead.Open(nil, nonce, ciphertext, nil)
+}
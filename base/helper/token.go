@@ -0,0 +1,33 @@
+package helper
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// AccessTokenPrefix 个人访问令牌前缀, 用于和 JWT 区分
+const AccessTokenPrefix = "aps_"
+
+// GenerateAccessToken 生成个人访问令牌, 返回明文令牌和用于存储的哈希值
+func GenerateAccessToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken 计算令牌的 sha256 哈希
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAccessToken 判断 bearer token 是否为个人访问令牌
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
@@ -2,16 +2,28 @@ package middleware
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-contrib/requestid"
 	"github.com/gin-gonic/gin"
 	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/store"
 	"go.uber.org/zap"
 )
 
-// Auth 是一个基于 JWT 的认证中间件
+// accessTokenTouchInterval 个人访问令牌最后使用时间的更新间隔
+const accessTokenTouchInterval = time.Minute
+
+// accessTokenClaimsKey 已校验的个人访问令牌 claims 在 gin.Context 中的 key
+const accessTokenClaimsKey = "accessTokenClaims"
+
+// Auth 是一个基于 JWT 的认证中间件, 同时支持个人访问令牌
 func (m *Middleware) Auth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.Request.Header.Get("Authorization")
@@ -29,14 +41,91 @@ func (m *Middleware) Auth() gin.HandlerFunc {
 		}
 
 		tokenString := parts[1]
+		if helper.IsAccessToken(tokenString) {
+			mc, err := m.accessTokenClaims(c, tokenString)
+			if err != nil {
+				zap.L().Error("auth failed, invalid personal access token", zap.String("request-id", requestid.Get(c)), zap.Error(err))
+				m.Abort(c, http.StatusUnauthorized, constant.ErrAuthFailed)
+				return
+			}
+			ctx := context.WithValue(c.Request.Context(), constant.UserContextKey, mc)
+			c.Request = c.Request.WithContext(ctx)
+			c.Next()
+			return
+		}
+
 		mc, err := m.jwtImpl.ParseToken(tokenString)
 		if err != nil {
 			zap.L().Error("auth failed, parse token failed", zap.String("request-id", requestid.Get(c)), zap.Error(err))
 			m.Abort(c, http.StatusUnauthorized, constant.ErrAuthFailed)
 			return
 		}
+
+		revoked, err := m.revoker.IsRevoked(c.Request.Context(), mc)
+		if err != nil {
+			zap.L().Error("auth failed, check token revoked failed", zap.String("request-id", requestid.Get(c)), zap.Error(err))
+			m.Abort(c, http.StatusUnauthorized, constant.ErrAuthFailed)
+			return
+		}
+		if revoked {
+			zap.L().Error("auth failed, token has been revoked", zap.String("request-id", requestid.Get(c)), zap.Int64("userID", mc.UserID), zap.String("jti", mc.ID))
+			m.Abort(c, http.StatusUnauthorized, constant.ErrAuthFailed)
+			return
+		}
+		// 会话被注销后, 该会话签发的 token 立即失效
+		if mc.SessionID != "" {
+			active, err := m.sessions.Touch(c.Request.Context(), mc.UserID, mc.SessionID, c.ClientIP(), c.Request.UserAgent())
+			if err != nil {
+				zap.L().Error("auth failed, check session failed", zap.String("request-id", requestid.Get(c)), zap.Error(err))
+				m.Abort(c, http.StatusUnauthorized, constant.ErrAuthFailed)
+				return
+			}
+			if !active {
+				zap.L().Error("auth failed, session has been revoked", zap.String("request-id", requestid.Get(c)), zap.Int64("userID", mc.UserID), zap.String("sid", mc.SessionID))
+				m.Abort(c, http.StatusUnauthorized, constant.ErrAuthFailed)
+				return
+			}
+		}
 		ctx := context.WithValue(c.Request.Context(), constant.UserContextKey, mc)
 		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
+
+// accessTokenClaims 校验个人访问令牌, 同一请求中限流和认证共用校验结果, 避免重复查询
+func (m *Middleware) accessTokenClaims(c *gin.Context, token string) (*jwt.JwtClaims, error) {
+	if v, ok := c.Get(accessTokenClaimsKey); ok {
+		return v.(*jwt.JwtClaims), nil
+	}
+	mc, err := m.parseAccessToken(c.Request.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(accessTokenClaimsKey, mc)
+	return mc, nil
+}
+
+// parseAccessToken 校验个人访问令牌, 并根据令牌记录构造 claims
+func (m *Middleware) parseAccessToken(ctx context.Context, token string) (*jwt.JwtClaims, error) {
+	pat, err := m.tokenStore.Query(ctx, store.Where("token_hash", helper.HashToken(token)), store.Preload("User"), store.Preload(model.PreloadRoles))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !pat.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("personal access token %d expired", pat.ID)
+	}
+	if pat.User == nil || pat.User.Status == nil || *pat.User.Status != model.UserStatusActive {
+		return nil, fmt.Errorf("user %d not found or disabled", pat.UserID)
+	}
+
+	// 降低写库频率, 最后使用时间只需要大致准确
+	if pat.LastUsedAt == nil || now.Sub(*pat.LastUsedAt) > accessTokenTouchInterval {
+		if err := m.tokenStore.Update(ctx, &model.PersonalAccessToken{ID: pat.ID, LastUsedAt: &now}); err != nil {
+			zap.L().Warn("update personal access token last used time failed", zap.Int64("tokenID", pat.ID), zap.Error(err))
+		}
+	}
+
+	return jwt.NewPersonalAccessClaims(pat)
+}
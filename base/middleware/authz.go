@@ -2,13 +2,16 @@ package middleware
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-contrib/requestid"
 	"github.com/gin-gonic/gin"
 	"github.com/yiran15/api-server/base/constant"
 	"github.com/yiran15/api-server/model"
 	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/pkg/loginevent"
 	"github.com/yiran15/api-server/store"
 	"go.uber.org/zap"
 )
@@ -24,6 +27,9 @@ func (m *Middleware) AuthZ() gin.HandlerFunc {
 		}
 
 		roles, err := m.getRolesByUser(c, claims, requestID)
+		if err == nil {
+			roles = claims.UsableRoles(roles, m.mfaRequiredRoles)
+		}
 		if err != nil || len(roles) == 0 {
 			if err != nil {
 				zap.L().Error("get user roles error", zap.String("request-id", requestID), zap.Error(err))
@@ -31,12 +37,20 @@ func (m *Middleware) AuthZ() gin.HandlerFunc {
 			if len(roles) == 0 {
 				zap.L().Error("user has no roles", zap.String("request-id", requestID), zap.String("userName", claims.UserName))
 			}
+			m.recordDenied(c, claims, loginevent.ReasonNoRoles, err)
 			m.Abort(c, http.StatusForbidden, constant.ErrNoPermission)
 			return
 		}
 
-		if !m.checkPermission(c.Request.Context(), roles, c.Request.URL.Path, c.Request.Method, requestID) {
-			zap.L().Error("user has no permission", zap.String("request-id", requestID), zap.String("userName", claims.UserName), zap.Strings("roles", roles), zap.String("path", c.Request.URL.Path), zap.String("method", c.Request.Method))
+		allowed, rule := m.checkPermission(c.Request.Context(), roles, model.TenantDomain(claims.Tenant()), c.Request.URL.Path, c.Request.Method, requestID)
+		if !allowed {
+			zap.L().Error("user has no permission", zap.String("request-id", requestID), zap.String("userName", claims.UserName), zap.Strings("roles", roles), zap.String("path", c.Request.URL.Path), zap.String("method", c.Request.Method), zap.Strings("rule", rule))
+			m.recordDenied(c, claims, loginevent.ReasonNoPermission, nil)
+			// 被 deny 规则拒绝时返回该规则, 便于前端展示
+			if len(rule) > 0 {
+				m.Abort(c, http.StatusForbidden, fmt.Errorf("%w: denied by policy %s", constant.ErrNoPermission, strings.Join(rule, ", ")))
+				return
+			}
 			m.Abort(c, http.StatusForbidden, constant.ErrNoPermission)
 			return
 		}
@@ -45,6 +59,24 @@ func (m *Middleware) AuthZ() gin.HandlerFunc {
 	}
 }
 
+// recordDenied 记录授权失败事件, 查询角色出错时 reason 为错误信息, 否则为 reason 和请求的接口
+func (m *Middleware) recordDenied(c *gin.Context, claims *jwt.JwtClaims, reason string, err error) {
+	if err != nil {
+		reason = err.Error()
+	} else {
+		reason = fmt.Sprintf("%s: %s %s", reason, c.Request.Method, c.Request.URL.Path)
+	}
+	m.loginEvents.Record(c.Request.Context(), &model.LoginEvent{
+		UserID:    claims.UserID,
+		Account:   claims.UserName,
+		Event:     loginevent.EventAuthZDenied,
+		Reason:    reason,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: requestid.Get(c),
+	}, constant.ErrNoPermission)
+}
+
 // 从上下文获取 JWT claims
 func (m *Middleware) getClaimsFromCtx(c *gin.Context, requestID string) (*jwt.JwtClaims, error) {
 	claims, err := m.jwtImpl.GetUser(c.Request.Context())
@@ -55,7 +87,8 @@ func (m *Middleware) getClaimsFromCtx(c *gin.Context, requestID string) (*jwt.Jw
 	return claims, nil
 }
 
-// 获取用户角色（缓存优先，缓存 miss 则查询 DB 并回填缓存）
+// 获取用户在当前租户的角色（缓存优先，缓存 miss 则查询 DB 并回填缓存）
+// 缓存中保存用户在全部租户的角色, 格式为 "租户id:角色名称"
 func (m *Middleware) getRolesByUser(c *gin.Context, claims *jwt.JwtClaims, requestID string) ([]string, error) {
 	ctx := c.Request.Context()
 
@@ -69,7 +102,7 @@ func (m *Middleware) getRolesByUser(c *gin.Context, claims *jwt.JwtClaims, reque
 		if len(roles) == 1 && roles[0] == constant.EmptyRoleSentinel {
 			return []string{}, nil
 		}
-		return roles, nil
+		return model.RolesInTenant(roles, claims.Tenant()), nil
 	}
 
 	user, err := m.userStore.Query(ctx, store.Where("id", claims.UserID), store.Preload(model.PreloadRoles))
@@ -89,8 +122,8 @@ func (m *Middleware) getRolesByUser(c *gin.Context, claims *jwt.JwtClaims, reque
 	roles = make([]string, len(user.Roles))
 	roleNames := make([]any, len(user.Roles))
 	for i, r := range user.Roles {
-		roles[i] = r.Name
-		roleNames[i] = r.Name
+		roles[i] = r.ScopedName()
+		roleNames[i] = r.ScopedName()
 	}
 
 	if err := m.cacheImpl.SetSet(ctx, store.RoleType, claims.UserID, roleNames, nil); err != nil {
@@ -98,20 +131,15 @@ func (m *Middleware) getRolesByUser(c *gin.Context, claims *jwt.JwtClaims, reque
 		return nil, err
 	}
 
-	return roles, nil
+	return model.RolesInTenant(roles, claims.Tenant()), nil
 }
 
-// 权限校验
-func (m *Middleware) checkPermission(_ context.Context, roles []string, path, method, requestID string) bool {
-	for _, role := range roles {
-		allow, err := m.authZImpl.Enforce(role, path, method)
-		if err != nil {
-			zap.L().Error("authz enforce failed", zap.String("request-id", requestID), zap.Error(err), zap.String("role", role), zap.String("path", path), zap.String("method", method))
-			return false
-		}
-		if allow {
-			return true
-		}
+// 权限校验, 返回决定结果的规则, 没有匹配任何规则时为空
+func (m *Middleware) checkPermission(_ context.Context, roles []string, dom, path, method, requestID string) (bool, []string) {
+	allowed, rule, err := m.authZImpl.EnforceRoles(roles, dom, path, method)
+	if err != nil {
+		zap.L().Error("authz enforce failed", zap.String("request-id", requestID), zap.Error(err), zap.Strings("roles", roles), zap.String("domain", dom), zap.String("path", path), zap.String("method", method))
+		return false, nil
 	}
-	return false
+	return allowed, rule
 }
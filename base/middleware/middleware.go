@@ -1,10 +1,16 @@
 package middleware
 
 import (
+	"crypto/sha256"
+
 	"github.com/gin-gonic/gin"
 	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/conf"
 	"github.com/yiran15/api-server/pkg/casbin"
 	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/pkg/loginevent"
+	"github.com/yiran15/api-server/pkg/ratelimit"
+	"github.com/yiran15/api-server/pkg/session"
 	"github.com/yiran15/api-server/store"
 )
 
@@ -12,22 +18,50 @@ type MiddlewareInterface interface {
 	Auth() gin.HandlerFunc
 	AuthZ() gin.HandlerFunc
 	Session() gin.HandlerFunc
+	RateLimit() gin.HandlerFunc
+	ScimAuth() gin.HandlerFunc
 }
 
 type Middleware struct {
-	jwtImpl   jwt.JwtInterface
-	authZImpl casbin.AuthChecker
-	cacheImpl store.CacheStorer
-	userStore store.UserStorer
+	jwtImpl    jwt.JwtInterface
+	revoker    jwt.Revoker
+	authZImpl  casbin.AuthChecker
+	cacheImpl  store.CacheStorer
+	userStore  store.UserStorer
+	tokenStore store.PersonalAccessTokenStorer
+	limiter    *ratelimit.RateLimiter
+	sessions   session.Manager
+	// loginEvents 记录授权失败事件
+	loginEvents loginevent.Recorder
+	// mfaRequiredRoles 必须通过两步验证才能使用的角色
+	mfaRequiredRoles []string
+	// scimToken SCIM bearer token 的 SHA-256 摘要, 未启用 scim 时为 nil
+	scimToken []byte
 }
 
-func NewMiddleware(jwtImpl jwt.JwtInterface, authZImpl casbin.AuthChecker, cacheImpl store.CacheStorer, userStore store.UserStorer) *Middleware {
-	return &Middleware{
-		jwtImpl:   jwtImpl,
-		authZImpl: authZImpl,
-		cacheImpl: cacheImpl,
-		userStore: userStore,
+func NewMiddleware(jwtImpl jwt.JwtInterface, revoker jwt.Revoker, authZImpl casbin.AuthChecker, cacheImpl store.CacheStorer, userStore store.UserStorer, tokenStore store.PersonalAccessTokenStorer, limiter *ratelimit.RateLimiter, sessions session.Manager, loginEvents loginevent.Recorder) (*Middleware, error) {
+	scimToken, err := conf.GetScimToken()
+	if err != nil {
+		return nil, err
+	}
+	m := &Middleware{
+		jwtImpl:     jwtImpl,
+		revoker:     revoker,
+		authZImpl:   authZImpl,
+		cacheImpl:   cacheImpl,
+		userStore:   userStore,
+		tokenStore:  tokenStore,
+		limiter:     limiter,
+		sessions:    sessions,
+		loginEvents: loginEvents,
+
+		mfaRequiredRoles: conf.GetMfaRequiredRoles(),
+	}
+	if scimToken != "" {
+		digest := sha256.Sum256([]byte(scimToken))
+		m.scimToken = digest[:]
 	}
+	return m, nil
 }
 
 func (m *Middleware) Abort(c *gin.Context, code int, err error) {
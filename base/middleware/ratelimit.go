@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/pkg/ratelimit"
+	"go.uber.org/zap"
+)
+
+// RateLimit 按配置的路由分组限流, 超过限制时返回 429
+// 响应头 X-RateLimit-Reset 和 Retry-After 均为距离窗口释放的秒数
+func (m *Middleware) RateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule := m.limiter.Match(c.Request.URL.Path)
+		if rule == nil {
+			c.Next()
+			return
+		}
+
+		identity := m.rateLimitIdentity(c, rule)
+		res, err := m.limiter.Allow(c.Request.Context(), rule, identity)
+		if err != nil {
+			// 限流器故障时放行, 避免影响正常请求
+			zap.L().Error("rate limit failed", zap.String("request-id", requestid.Get(c)), zap.Error(err))
+			c.Next()
+			return
+		}
+
+		resetSeconds := strconv.Itoa(int(math.Ceil(res.ResetAfter.Seconds())))
+		c.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("X-RateLimit-Reset", resetSeconds)
+		if !res.Allowed {
+			c.Header("Retry-After", resetSeconds)
+			zap.L().Warn("rate limit exceeded", zap.String("request-id", requestid.Get(c)), zap.String("prefix", rule.Prefix), zap.String("identity", identity))
+			m.Abort(c, http.StatusTooManyRequests, constant.ErrTooManyRequests)
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitIdentity 返回限流维度, 按用户限流时优先使用已认证的用户, 其次解析 Authorization 头, 都没有时使用客户端 IP
+// 个人访问令牌校验通过后才按令牌计数, 否则伪造的令牌每次都能获得新的计数
+func (m *Middleware) rateLimitIdentity(c *gin.Context, rule *ratelimit.Rule) string {
+	if rule.Key == ratelimit.KeyUser {
+		if claims, err := m.jwtImpl.GetUser(c.Request.Context()); err == nil {
+			return fmt.Sprintf("user:%d", claims.UserID)
+		}
+		if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			if helper.IsAccessToken(token) {
+				if _, err := m.accessTokenClaims(c, token); err == nil {
+					return "key:" + helper.HashToken(token)
+				}
+				return "ip:" + c.ClientIP()
+			}
+			if claims, err := m.jwtImpl.ParseToken(token); err == nil {
+				return fmt.Sprintf("user:%d", claims.UserID)
+			}
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/yiran15/api-server/base/middleware"
 	"github.com/yiran15/api-server/controller"
 	_ "github.com/yiran15/api-server/docs"
+	"github.com/yiran15/api-server/pkg/scim"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -23,29 +24,62 @@ type RouterInterface interface {
 }
 
 type Router struct {
-	userRouter controller.UserController
-	roleRouter controller.RoleController
-	apiRouter  controller.ApiController
-	middleware middleware.MiddlewareInterface
+	userRouter      controller.UserController
+	roleRouter      controller.RoleController
+	apiRouter       controller.ApiController
+	wellKnownRouter controller.WellKnownController
+	tokenRouter     controller.AccessTokenController
+	mfaRouter       controller.MfaController
+	passwordRouter  controller.PasswordController
+	sessionRouter   controller.SessionController
+	auditRouter     controller.AuditController
+	historyRouter   controller.LoginHistoryController
+	identityRouter  controller.IdentityController
+	scimRouter      controller.ScimController
+	tenantRouter    controller.TenantController
+	authzRouter     controller.AuthzController
+	middleware      middleware.MiddlewareInterface
 }
 
 func NewRouter(
 	userRouter controller.UserController,
 	roleRouter controller.RoleController,
 	apiRouter controller.ApiController,
+	wellKnownRouter controller.WellKnownController,
+	tokenRouter controller.AccessTokenController,
+	mfaRouter controller.MfaController,
+	passwordRouter controller.PasswordController,
+	sessionRouter controller.SessionController,
+	auditRouter controller.AuditController,
+	historyRouter controller.LoginHistoryController,
+	identityRouter controller.IdentityController,
+	scimRouter controller.ScimController,
+	tenantRouter controller.TenantController,
+	authzRouter controller.AuthzController,
 	middleware middleware.MiddlewareInterface) *Router {
 	return &Router{
-		userRouter: userRouter,
-		roleRouter: roleRouter,
-		apiRouter:  apiRouter,
-		middleware: middleware,
+		userRouter:      userRouter,
+		roleRouter:      roleRouter,
+		apiRouter:       apiRouter,
+		wellKnownRouter: wellKnownRouter,
+		tokenRouter:     tokenRouter,
+		mfaRouter:       mfaRouter,
+		passwordRouter:  passwordRouter,
+		sessionRouter:   sessionRouter,
+		auditRouter:     auditRouter,
+		historyRouter:   historyRouter,
+		identityRouter:  identityRouter,
+		scimRouter:      scimRouter,
+		tenantRouter:    tenantRouter,
+		authzRouter:     authzRouter,
+		middleware:      middleware,
 	}
 }
 
 func (r *Router) RegisterRouter(engine *gin.Engine) {
 	engine.Use(cors.New(cors.Config{
 		AllowAllOrigins:  true,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -63,6 +97,7 @@ func (r *Router) RegisterRouter(engine *gin.Engine) {
 
 	engine.Use(ginzap.RecoveryWithZap(zap.L(), true))
 	engine.Use(requestid.New())
+	engine.Use(r.middleware.RateLimit())
 
 	apiGroup := engine.Group("/api/v1")
 	apiGroup.GET("/healthz", func(c *gin.Context) {
@@ -72,23 +107,53 @@ func (r *Router) RegisterRouter(engine *gin.Engine) {
 	})
 
 	engine.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	engine.GET("/.well-known/jwks.json", r.wellKnownRouter.JWKS)
 	r.registerOAuthRouter(apiGroup)
 	r.registerUserRouter(apiGroup)
 	r.registerRoleRouter(apiGroup)
 	r.registerApiRouter(apiGroup)
+	r.registerAuditRouter(apiGroup)
+	r.registerTenantRouter(apiGroup)
+	r.registerAuthzRouter(apiGroup)
+	r.registerScimRouter(engine)
 }
 
 func (r *Router) registerUserRouter(apiGroup *gin.RouterGroup) {
 	userGroup := apiGroup.Group("/user")
 	{
 		userGroup.POST("/login", r.userRouter.UserLoginController)
+		userGroup.POST("/login/mfa", r.userRouter.UserLoginMfaController)
+		userGroup.POST("/refresh", r.userRouter.UserRefreshTokenController)
+		userGroup.POST("/password/forgot", r.passwordRouter.ForgotPassword)
+		userGroup.POST("/password/reset", r.passwordRouter.ResetPassword)
 		userGroup.Use(r.middleware.Auth())
 		userGroup.POST("/logout", r.userRouter.UserLogoutController)
 		userGroup.GET("/info", r.userRouter.UserInfoController)
+		userGroup.GET("/tenants", r.userRouter.UserTenantsController)
+		userGroup.GET("/permissions", r.authzRouter.Permissions)
 		userGroup.PUT("/self", r.userRouter.UserUpdateBySelfController)
+		userGroup.POST("/tokens", r.tokenRouter.CreateAccessToken)
+		userGroup.GET("/tokens", r.tokenRouter.ListAccessToken)
+		userGroup.PUT("/tokens/:id", r.tokenRouter.UpdateAccessToken)
+		userGroup.DELETE("/tokens/:id", r.tokenRouter.DeleteAccessToken)
+		userGroup.GET("/sessions", r.sessionRouter.ListSessions)
+		userGroup.DELETE("/sessions/:id", r.sessionRouter.RevokeSession)
+		userGroup.GET("/self/login-history", r.historyRouter.ListSelfLoginHistory)
+		userGroup.GET("/identities", r.identityRouter.ListIdentities)
+		userGroup.POST("/identities/link", r.middleware.Session(), r.identityRouter.LinkIdentity)
+		userGroup.POST("/identities/callback", r.middleware.Session(), r.identityRouter.LinkIdentityCallback)
+		userGroup.DELETE("/identities/:id", r.identityRouter.UnlinkIdentity)
+		userGroup.POST("/mfa/enroll", r.mfaRouter.EnrollMfa)
+		userGroup.POST("/mfa/confirm", r.mfaRouter.ConfirmMfa)
 		userGroup.Use(r.middleware.AuthZ())
 		userGroup.POST("/register", r.userRouter.UserCreateController)
 		userGroup.PUT("/:id", r.userRouter.UserUpdateByAdminController)
+		userGroup.POST("/:id/revoke", r.userRouter.UserRevokeTokensController)
+		userGroup.POST("/:id/unlock", r.userRouter.UserUnlockController)
+		userGroup.DELETE("/:id/mfa", r.mfaRouter.ResetMfa)
+		userGroup.GET("/:id/sessions", r.sessionRouter.ListUserSessions)
+		userGroup.DELETE("/:id/sessions/:sid", r.sessionRouter.RevokeUserSession)
+		userGroup.GET("/:id/login-history", r.historyRouter.ListLoginHistory)
 		userGroup.GET("/:id", r.userRouter.UserQueryController)
 		userGroup.GET("", r.userRouter.UserListController)
 		userGroup.DELETE("/:id", r.userRouter.UserDeleteController)
@@ -103,6 +168,7 @@ func (r *Router) registerRoleRouter(apiGroup *gin.RouterGroup) {
 		roleGroup.PUT("/:id", r.roleRouter.UpdateRole)
 		roleGroup.DELETE("/:id", r.roleRouter.DeleteRole)
 		roleGroup.GET("/:id", r.roleRouter.QueryRole)
+		roleGroup.GET("/:id/effective-apis", r.roleRouter.EffectiveApis)
 		roleGroup.GET("", r.roleRouter.ListRole)
 	}
 }
@@ -131,3 +197,52 @@ func (r *Router) registerOAuthRouter(apiGroup *gin.RouterGroup) {
 		oauthGroup.POST("/:id", r.userRouter.OAuth2ActivateController)
 	}
 }
+
+func (r *Router) registerAuditRouter(apiGroup *gin.RouterGroup) {
+	auditGroup := apiGroup.Group("/audit")
+	{
+		auditGroup.Use(r.middleware.Auth(), r.middleware.AuthZ())
+		auditGroup.GET("", r.auditRouter.ListAudit)
+	}
+}
+
+func (r *Router) registerTenantRouter(apiGroup *gin.RouterGroup) {
+	tenantGroup := apiGroup.Group("/tenant")
+	{
+		tenantGroup.Use(r.middleware.Auth(), r.middleware.AuthZ())
+		tenantGroup.POST("", r.tenantRouter.CreateTenant)
+		tenantGroup.PUT("/:id", r.tenantRouter.UpdateTenant)
+		tenantGroup.GET("", r.tenantRouter.ListTenant)
+		tenantGroup.POST("/:id/users", r.tenantRouter.AddUsers)
+		tenantGroup.DELETE("/:id/users/:userId", r.tenantRouter.RemoveUser)
+	}
+}
+
+func (r *Router) registerAuthzRouter(apiGroup *gin.RouterGroup) {
+	authzGroup := apiGroup.Group("/authz")
+	{
+		authzGroup.Use(r.middleware.Auth(), r.middleware.AuthZ())
+		authzGroup.POST("/check", r.authzRouter.Check)
+	}
+}
+
+// registerScimRouter SCIM 2.0 接口, 使用 scim.token 认证, 不经过用户的鉴权
+func (r *Router) registerScimRouter(engine *gin.Engine) {
+	scimGroup := engine.Group(scim.BasePath)
+	{
+		scimGroup.Use(r.middleware.ScimAuth())
+		scimGroup.GET("/ServiceProviderConfig", r.scimRouter.ServiceProviderConfig)
+		scimGroup.GET("/Users", r.scimRouter.ListUsers)
+		scimGroup.POST("/Users", r.scimRouter.CreateUser)
+		scimGroup.GET("/Users/:id", r.scimRouter.GetUser)
+		scimGroup.PUT("/Users/:id", r.scimRouter.ReplaceUser)
+		scimGroup.PATCH("/Users/:id", r.scimRouter.PatchUser)
+		scimGroup.DELETE("/Users/:id", r.scimRouter.DeleteUser)
+		scimGroup.GET("/Groups", r.scimRouter.ListGroups)
+		scimGroup.POST("/Groups", r.scimRouter.CreateGroup)
+		scimGroup.GET("/Groups/:id", r.scimRouter.GetGroup)
+		scimGroup.PUT("/Groups/:id", r.scimRouter.ReplaceGroup)
+		scimGroup.PATCH("/Groups/:id", r.scimRouter.PatchGroup)
+		scimGroup.DELETE("/Groups/:id", r.scimRouter.DeleteGroup)
+	}
+}
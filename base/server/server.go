@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -13,6 +14,8 @@ import (
 	"github.com/yiran15/api-server/base/constant"
 	"github.com/yiran15/api-server/base/router"
 	"github.com/yiran15/api-server/controller"
+	"github.com/yiran15/api-server/pkg/password"
+	"github.com/yiran15/api-server/pkg/scim"
 	"go.uber.org/zap"
 )
 
@@ -54,20 +57,26 @@ func (s *Server) Stop() error {
 	return s.server.Shutdown(ctx)
 }
 
-func NewHttpServer(r router.RouterInterface) (*gin.Engine, error) {
+func NewHttpServer(r router.RouterInterface, passwordPolicy *password.Policy) (*gin.Engine, error) {
 	if conf.GetLogLevel() == "debug" {
 		gin.SetMode(gin.DebugMode)
 	} else {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	engine := gin.New()
-	controller.NewValidator()
+	if err := engine.SetTrustedProxies(conf.GetServerTrustedProxies()); err != nil {
+		return nil, fmt.Errorf("set trusted proxies error: %w", err)
+	}
+	if err := controller.NewValidator(passwordPolicy); err != nil {
+		return nil, fmt.Errorf("init validator error: %w", err)
+	}
 
 	r.RegisterRouter(engine)
 	var apiData apitypes.ServerApiData
 	apiData.ApiInfo = make(map[string][]apitypes.ApiInfo)
 	for _, v := range engine.Routes() {
-		if v.Path == "/swagger/*any" || v.Path == "/oauth2/login" || v.Path == "/oauth2/callback" || v.Path == "/oauth2/provider" {
+		// SCIM 接口使用独立的 bearer token 认证, 不经过 casbin 鉴权
+		if v.Path == "/swagger/*any" || strings.HasPrefix(v.Path, "/.well-known/") || strings.HasPrefix(v.Path, scim.BasePath+"/") || v.Path == "/oauth2/login" || v.Path == "/oauth2/callback" || v.Path == "/oauth2/provider" {
 			continue
 		}
 		api := strings.TrimPrefix(v.Path, "/")
@@ -11,8 +11,10 @@ import (
 	"github.com/yiran15/api-server/base/constant"
 	"github.com/yiran15/api-server/base/data"
 	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/audit"
 	"github.com/yiran15/api-server/pkg/casbin"
 	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/pkg/password"
 	v1 "github.com/yiran15/api-server/service/v1"
 	"github.com/yiran15/api-server/store"
 	"go.uber.org/zap"
@@ -75,7 +77,7 @@ func getService() (*service, func(), error) {
 		return nil, nil, err
 	}
 
-	generateToken, err := jwt.NewGenerateToken()
+	generateToken, cleanup3, err := jwt.NewGenerateToken(store.NewJwtKeyStore(provider))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -84,11 +86,29 @@ func getService() (*service, func(), error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	casbinManager := casbin.NewCasbinManager(casbinEnforcer)
+	// 初始化在服务启动前执行, 不需要通知其他副本
+	casbinManager := casbin.NewCasbinManager(casbinEnforcer, nil)
 
-	userServicer := v1.NewUserService(userRepo, roleRepo, cacheStore, txManager, generateToken, nil, nil, nil)
-	roleServicer := v1.NewRoleService(roleRepo, apiRepo, casbinStore, casbinManager, txManager)
-	apiServicer := v1.NewApiServicer(apiRepo)
+	revoker, err := jwt.NewRevoker(cacheStore)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	passwordPolicy, err := password.NewPolicy()
+	if err != nil {
+		return nil, nil, err
+	}
+	passwordHasher, err := password.NewHasher()
+	if err != nil {
+		return nil, nil, err
+	}
+	passwordChecker := password.NewChecker(passwordPolicy, passwordHasher, store.NewPasswordHistoryStore(provider))
+
+	auditRecorder := audit.NewRecorder(store.NewAuditLogStore(provider))
+
+	userServicer := v1.NewUserService(userRepo, roleRepo, store.NewTenantStore(provider), cacheStore, txManager, generateToken, revoker, store.NewPersonalAccessTokenStore(provider), nil, nil, passwordChecker, passwordHasher, nil, auditRecorder, nil, nil, nil, nil, nil, nil)
+	roleServicer := v1.NewRoleService(roleRepo, apiRepo, casbinStore, casbinManager, txManager, auditRecorder)
+	apiServicer := v1.NewApiServicer(apiRepo, auditRecorder)
 	return &service{
 			db:          db,
 			userService: userServicer,
@@ -97,6 +117,7 @@ func getService() (*service, func(), error) {
 		}, func() {
 			cleanup1()
 			cleanup2()
+			cleanup3()
 		}, nil
 }
 
@@ -108,6 +129,12 @@ func initApplication(_ *cobra.Command, _ []string) error {
 	}
 	defer cleanup()
 
+	// 默认租户, 初始化的角色属于默认租户
+	defaultTenant := model.Tenant{ID: model.DefaultTenantID, Name: "default", Description: "默认租户"}
+	if err = service.db.Where("id = ?", defaultTenant.ID).FirstOrCreate(&defaultTenant).Error; err != nil {
+		return err
+	}
+
 	apis := []apitypes.ApiCreateRequest{
 		{
 			Name:        "admin",
@@ -170,7 +197,7 @@ func initApplication(_ *cobra.Command, _ []string) error {
 	}
 	for _, roleCreateRequest := range roleCreateRequest {
 		var dbRole model.Role
-		if err = service.db.Model(&model.Role{}).Where("name = ?", roleCreateRequest.Name).First(&dbRole).Error; err != nil {
+		if err = service.db.Model(&model.Role{}).Where("tenant_id = ? and name = ?", model.DefaultTenantID, roleCreateRequest.Name).First(&dbRole).Error; err != nil {
 			if !errors.Is(err, gorm.ErrRecordNotFound) {
 				return err
 			}
@@ -179,7 +206,7 @@ func initApplication(_ *cobra.Command, _ []string) error {
 			if err = service.roleService.CreateRole(ctx, &roleCreateRequest); err != nil {
 				return err
 			}
-			if err = service.db.Model(&model.Role{}).Where("name = ?", roleCreateRequest.Name).First(&dbRole).Error; err != nil {
+			if err = service.db.Model(&model.Role{}).Where("tenant_id = ? and name = ?", model.DefaultTenantID, roleCreateRequest.Name).First(&dbRole).Error; err != nil {
 				return err
 			}
 		}
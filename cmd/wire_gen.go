@@ -13,10 +13,18 @@ import (
 	"github.com/yiran15/api-server/base/router"
 	"github.com/yiran15/api-server/base/server"
 	"github.com/yiran15/api-server/controller"
+	"github.com/yiran15/api-server/pkg/audit"
 	"github.com/yiran15/api-server/pkg/casbin"
 	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/pkg/ldap"
 	"github.com/yiran15/api-server/pkg/local_cache"
+	"github.com/yiran15/api-server/pkg/loginevent"
+	"github.com/yiran15/api-server/pkg/loginguard"
+	"github.com/yiran15/api-server/pkg/mailer"
 	"github.com/yiran15/api-server/pkg/oauth"
+	"github.com/yiran15/api-server/pkg/password"
+	"github.com/yiran15/api-server/pkg/ratelimit"
+	"github.com/yiran15/api-server/pkg/session"
 	"github.com/yiran15/api-server/service/v1"
 	"github.com/yiran15/api-server/store"
 )
@@ -31,6 +39,7 @@ func InitApplication() (*app.Application, func(), error) {
 	dbProvider := store.NewDBProvider(db)
 	userStorer := store.NewUserStore(dbProvider)
 	roleStorer := store.NewRoleStore(dbProvider)
+	tenantStorer := store.NewTenantStore(dbProvider)
 	client, err := data.NewRDB()
 	if err != nil {
 		cleanup()
@@ -42,46 +51,164 @@ func InitApplication() (*app.Application, func(), error) {
 		return nil, nil, err
 	}
 	txManager := store.NewTxManager(db)
-	generateToken, err := jwt.NewGenerateToken()
+	jwtKeyStorer := store.NewJwtKeyStore(dbProvider)
+	generateToken, cleanup3, err := jwt.NewGenerateToken(jwtKeyStorer)
 	if err != nil {
 		cleanup2()
 		cleanup()
 		return nil, nil, err
 	}
+	revoker, err := jwt.NewRevoker(cacheStore)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	personalAccessTokenStorer := store.NewPersonalAccessTokenStore(dbProvider)
+	userMfaStorer := store.NewUserMfaStore(dbProvider)
+	auditLogStorer := store.NewAuditLogStore(dbProvider)
+	recorder := audit.NewRecorder(auditLogStorer)
+	mfaServicer, err := v1.NewMfaService(userMfaStorer, userStorer, generateToken, recorder)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	guard, err := loginguard.NewGuard(cacheStore)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	policy, err := password.NewPolicy()
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	hasher, err := password.NewHasher()
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	passwordHistoryStorer := store.NewPasswordHistoryStore(dbProvider)
+	checker := password.NewChecker(policy, hasher, passwordHistoryStorer)
+	manager, err := session.NewManager(cacheStore)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	loginEventStorer := store.NewLoginEventStore(dbProvider)
+	logineventRecorder := loginevent.NewRecorder(loginEventStorer)
 	oAuth2, err := oauth.NewOAuth2()
 	if err != nil {
+		cleanup3()
 		cleanup2()
 		cleanup()
 		return nil, nil, err
 	}
 	feiShuUserStorer := store.NewFeiShuUserStore(dbProvider)
+	userIdentityStorer := store.NewUserIdentityStore(dbProvider)
+	ldapLDAP, err := ldap.NewLDAP()
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
 	cacher := localcache.NewCacher(oAuth2)
-	userServicer := v1.NewUserService(userStorer, roleStorer, cacheStore, txManager, generateToken, oAuth2, feiShuUserStorer, cacher)
+	userServicer := v1.NewUserService(userStorer, roleStorer, tenantStorer, cacheStore, txManager, generateToken, revoker, personalAccessTokenStorer, mfaServicer, guard, checker, hasher, manager, recorder, logineventRecorder, oAuth2, feiShuUserStorer, userIdentityStorer, ldapLDAP, cacher)
 	userController := controller.NewUserController(userServicer)
 	apiStorer := store.NewApiStore(dbProvider)
 	casbinStorer := store.NewCasbinStore(dbProvider)
-	enforcer, err := casbin.NewEnforcer(db)
+	syncedEnforcer, err := casbin.NewEnforcer(db)
 	if err != nil {
+		cleanup3()
 		cleanup2()
 		cleanup()
 		return nil, nil, err
 	}
-	casbinManager := casbin.NewCasbinManager(enforcer)
-	roleServicer := v1.NewRoleService(roleStorer, apiStorer, casbinStorer, casbinManager, txManager)
+	watcher, cleanup4, err := casbin.NewWatcher(client, syncedEnforcer)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	casbinManager := casbin.NewCasbinManager(syncedEnforcer, watcher)
+	roleServicer := v1.NewRoleService(roleStorer, apiStorer, casbinStorer, casbinManager, txManager, recorder)
 	roleController := controller.NewRoleController(roleServicer)
-	apiServicer := v1.NewApiServicer(apiStorer)
+	apiServicer := v1.NewApiServicer(apiStorer, recorder)
 	apiController := controller.NewApiController(apiServicer)
-	authChecker := casbin.NewAuthChecker(enforcer)
-	middlewareMiddleware := middleware.NewMiddleware(generateToken, authChecker, cacheStore, userStorer)
-	routerRouter := router.NewRouter(userController, roleController, apiController, middlewareMiddleware)
-	engine, err := server.NewHttpServer(routerRouter)
+	wellKnownController := controller.NewWellKnownController(generateToken)
+	accessTokenServicer := v1.NewAccessTokenService(personalAccessTokenStorer, userStorer, txManager, generateToken)
+	accessTokenController := controller.NewAccessTokenController(accessTokenServicer)
+	mfaController := controller.NewMfaController(mfaServicer)
+	mailerMailer, err := mailer.NewMailer()
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	passwordServicer := v1.NewPasswordService(userStorer, cacheStore, revoker, guard, mailerMailer, checker, hasher, manager, txManager)
+	passwordController := controller.NewPasswordController(passwordServicer)
+	sessionServicer := v1.NewSessionService(manager, userStorer, generateToken, recorder)
+	sessionController := controller.NewSessionController(sessionServicer)
+	auditServicer := v1.NewAuditService(auditLogStorer)
+	auditController := controller.NewAuditController(auditServicer)
+	loginHistoryServicer := v1.NewLoginHistoryService(loginEventStorer, userStorer, generateToken)
+	loginHistoryController := controller.NewLoginHistoryController(loginHistoryServicer)
+	identityServicer := v1.NewIdentityService(userIdentityStorer, userStorer, oAuth2, generateToken)
+	identityController := controller.NewIdentityController(identityServicer)
+	scimServicer := v1.NewScimService(userStorer, roleStorer, userIdentityStorer, casbinStorer, casbinManager, cacheStore, txManager, userServicer, roleServicer, recorder)
+	scimController := controller.NewScimController(scimServicer)
+	tenantServicer := v1.NewTenantService(tenantStorer, userStorer, roleStorer, cacheStore, txManager, generateToken, roleServicer, userServicer, recorder)
+	tenantController := controller.NewTenantController(tenantServicer)
+	authChecker := casbin.NewAuthChecker(syncedEnforcer)
+	authzServicer := v1.NewAuthzService(userStorer, roleStorer, authChecker, generateToken)
+	authzController := controller.NewAuthzController(authzServicer)
+	rateLimiter, cleanup5, err := ratelimit.NewRateLimiter(cacheStore)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	middlewareMiddleware, err := middleware.NewMiddleware(generateToken, revoker, authChecker, cacheStore, userStorer, personalAccessTokenStorer, rateLimiter, manager, logineventRecorder)
+	if err != nil {
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	routerRouter := router.NewRouter(userController, roleController, apiController, wellKnownController, accessTokenController, mfaController, passwordController, sessionController, auditController, loginHistoryController, identityController, scimController, tenantController, authzController, middlewareMiddleware)
+	engine, err := server.NewHttpServer(routerRouter, policy)
 	if err != nil {
+		cleanup5()
+		cleanup4()
+		cleanup3()
 		cleanup2()
 		cleanup()
 		return nil, nil, err
 	}
 	application := app.NewApplication(engine)
 	return application, func() {
+		cleanup5()
+		cleanup4()
+		cleanup3()
 		cleanup2()
 		cleanup()
 	}, nil
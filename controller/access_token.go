@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	v1 "github.com/yiran15/api-server/service/v1"
+)
+
+type AccessTokenController interface {
+	CreateAccessToken(c *gin.Context)
+	ListAccessToken(c *gin.Context)
+	UpdateAccessToken(c *gin.Context)
+	DeleteAccessToken(c *gin.Context)
+}
+
+type accessTokenController struct {
+	accessTokenService v1.AccessTokenServicer
+}
+
+func NewAccessTokenController(accessTokenService v1.AccessTokenServicer) AccessTokenController {
+	return &accessTokenController{
+		accessTokenService: accessTokenService,
+	}
+}
+
+// CreateAccessToken 创建个人访问令牌
+// @Summary 创建个人访问令牌
+// @Description 为当前用户创建个人访问令牌, 供 CI、机器人等客户端使用, 令牌明文只在创建时返回一次
+// @Tags 个人访问令牌
+// @Accept json
+// @Produce json
+// @Param data body apitypes.AccessTokenCreateRequest true "创建请求参数"
+// @Success 200 {object} apitypes.Response{data=apitypes.AccessTokenCreateResponse} "创建成功"
+// @Router /api/v1/user/tokens [post]
+func (receiver *accessTokenController) CreateAccessToken(c *gin.Context) {
+	ResponseWithData(c, receiver.accessTokenService.CreateAccessToken, bindTypeJson)
+}
+
+// ListAccessToken 个人访问令牌列表
+// @Summary 个人访问令牌列表
+// @Description 分页查询当前用户的个人访问令牌
+// @Tags 个人访问令牌
+// @Accept json
+// @Produce json
+// @Param data query apitypes.AccessTokenListRequest true "查询请求参数"
+// @Success 200 {object} apitypes.Response{data=apitypes.AccessTokenListResponse} "查询成功"
+// @Router /api/v1/user/tokens [get]
+func (receiver *accessTokenController) ListAccessToken(c *gin.Context) {
+	ResponseWithData(c, receiver.accessTokenService.ListAccessToken, bindTypeQuery)
+}
+
+// UpdateAccessToken 修改个人访问令牌
+// @Summary 修改个人访问令牌
+// @Description 修改当前用户的个人访问令牌的名称、有效期或限定的角色, 有效期从修改时开始计算, 不能使用个人访问令牌调用
+// @Tags 个人访问令牌
+// @Accept json
+// @Produce json
+// @Param id path int true "令牌id"
+// @Param data body apitypes.AccessTokenUpdateRequest true "修改请求参数"
+// @Success 200 {object} apitypes.Response "修改成功"
+// @Router /api/v1/user/tokens/{id} [put]
+func (receiver *accessTokenController) UpdateAccessToken(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.accessTokenService.UpdateAccessToken, bindTypeUri, bindTypeJson)
+}
+
+// DeleteAccessToken 吊销个人访问令牌
+// @Summary 吊销个人访问令牌
+// @Description 删除当前用户的个人访问令牌, 删除后令牌立即失效
+// @Tags 个人访问令牌
+// @Accept json
+// @Produce json
+// @Param data body apitypes.IDRequest true "删除请求参数"
+// @Success 200 {object} apitypes.Response "删除成功"
+// @Router /api/v1/user/tokens/:id [delete]
+func (receiver *accessTokenController) DeleteAccessToken(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.accessTokenService.DeleteAccessToken, bindTypeUri)
+}
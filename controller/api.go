@@ -30,7 +30,7 @@ func NewApiController(apiService v1.ApiServicer) ApiController {
 
 // CreateApi 创建 API
 // @Summary 创建 API
-// @Description 创建 API
+// @Description 创建 API, effect 为分配给角色时的效果, 默认为 allow
 // @Tags API管理
 // @Accept json
 // @Produce json
@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	v1 "github.com/yiran15/api-server/service/v1"
+)
+
+type AuditController interface {
+	ListAudit(c *gin.Context)
+}
+
+type auditController struct {
+	auditService v1.AuditServicer
+}
+
+func NewAuditController(auditService v1.AuditServicer) AuditController {
+	return &auditController{
+		auditService: auditService,
+	}
+}
+
+// ListAudit 审计日志列表
+// @Summary 审计日志列表
+// @Description 分页查询管理操作的审计日志, 支持按操作人、操作、操作对象、结果和时间过滤
+// @Tags 审计日志
+// @Accept json
+// @Produce json
+// @Param data query apitypes.AuditListRequest true "查询请求参数"
+// @Success 200 {object} apitypes.Response{data=apitypes.AuditListResponse} "查询成功"
+// @Router /api/v1/audit [get]
+func (receiver *auditController) ListAudit(c *gin.Context) {
+	ResponseWithData(c, receiver.auditService.ListAudit, bindTypeQuery)
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	v1 "github.com/yiran15/api-server/service/v1"
+)
+
+type AuthzController interface {
+	Check(c *gin.Context)
+	Permissions(c *gin.Context)
+}
+
+type authzController struct {
+	authzService v1.AuthzServicer
+}
+
+func NewAuthzController(authzService v1.AuthzServicer) AuthzController {
+	return &authzController{
+		authzService: authzService,
+	}
+}
+
+// Check 权限检查
+// @Summary 权限检查
+// @Description 检查用户或角色在当前租户能否调用接口, 返回参与检查的角色和决定结果的策略, 没有匹配的策略时默认拒绝; 检查用户时不考虑个人访问令牌限定的角色和两步验证的要求
+// @Tags 权限检查
+// @Accept json
+// @Produce json
+// @Param data body apitypes.AuthzCheckRequest true "检查请求参数"
+// @Success 200 {object} apitypes.Response{data=apitypes.AuthzCheckResponse} "检查成功"
+// @Router /api/v1/authz/check [post]
+func (receiver *authzController) Check(c *gin.Context) {
+	ResponseWithData(c, receiver.authzService.Check, bindTypeJson)
+}
+
+// Permissions 当前用户的权限
+// @Summary 当前用户的权限
+// @Description 列出当前用户在当前租户可以调用的全部接口, 用于前端隐藏没有权限的菜单
+// @Tags 权限检查
+// @Produce json
+// @Success 200 {object} apitypes.Response{data=apitypes.AuthzPermissionsResponse} "查询成功"
+// @Router /api/v1/user/permissions [get]
+func (receiver *authzController) Permissions(c *gin.Context) {
+	ResponseWithDataNoBind(c, receiver.authzService.Permissions)
+}
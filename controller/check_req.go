@@ -1,7 +1,9 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"regexp"
 	"strings"
 
@@ -11,14 +13,17 @@ import (
 	"github.com/go-playground/validator/v10"
 	zh_translations "github.com/go-playground/validator/v10/translations/zh"
 	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/pkg/password"
 )
 
 var (
-	trans ut.Translator
+	trans          ut.Translator
+	passwordPolicy *password.Policy
 )
 
 // NewValidator 初始化自定义验证器和翻译器
-func NewValidator() error {
+func NewValidator(policy *password.Policy) error {
+	passwordPolicy = policy
 	zhTrans := zh.New()
 	uni := ut.New(zhTrans, zhTrans)
 	trans, _ = uni.GetTranslator("zh")
@@ -35,6 +40,11 @@ func NewValidator() error {
 
 // translateErrors 将验证错误翻译成更友好的格式
 func translateErrors(err error) string {
+	var policyErr *password.PolicyError
+	if errors.As(err, &policyErr) {
+		return translatePasswordError("Password", policyErr)
+	}
+
 	errs, ok := err.(validator.ValidationErrors)
 	if !ok {
 		return err.Error()
@@ -55,6 +65,9 @@ func registerValidator(v *validator.Validate) error {
 	if err := registerMobile(v); err != nil {
 		return err
 	}
+	if err := registerPassword(v); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -121,3 +134,76 @@ func registerMobile(v *validator.Validate) error {
 	}
 	return nil
 }
+
+// passwordTranslations 密码策略各规则的提示, key 为 password_ 加规则名
+var passwordTranslations = map[string]string{
+	password.RuleMinLength: "{0}长度不能少于{1}个字符",
+	password.RuleMaxLength: "{0}长度不能超过{1}个字节",
+	password.RuleUpper:     "{0}必须包含大写字母",
+	password.RuleLower:     "{0}必须包含小写字母",
+	password.RuleDigit:     "{0}必须包含数字",
+	password.RuleSymbol:    "{0}必须包含特殊字符",
+	password.RuleUserInfo:  "{0}不能包含邮箱或用户名",
+	password.RuleCommon:    "{0}过于常见, 请使用更复杂的密码",
+	password.RuleHistory:   "{0}不能与最近{1}次使用过的密码相同",
+}
+
+// passwordValidator 按密码策略校验, 同一结构体中有 Email 或 Name 字段时一并检查密码是否包含它们
+var passwordValidator validator.Func = func(fl validator.FieldLevel) bool {
+	return passwordPolicy.Validate(fl.Field().String(), userInfoFields(fl.Parent())...) == nil
+}
+
+func userInfoFields(parent reflect.Value) []string {
+	for parent.Kind() == reflect.Pointer {
+		if parent.IsNil() {
+			return nil
+		}
+		parent = parent.Elem()
+	}
+	if parent.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var info []string
+	for _, name := range []string{"Email", "Name"} {
+		if f := parent.FieldByName(name); f.IsValid() && f.Kind() == reflect.String {
+			info = append(info, f.String())
+		}
+	}
+	return info
+}
+
+func registerPassword(v *validator.Validate) error {
+	if err := v.RegisterValidation("password", passwordValidator); err != nil {
+		return fmt.Errorf("register password validator failed: %w", err)
+	}
+
+	if err := v.RegisterTranslation("password", trans,
+		func(ut ut.Translator) error {
+			for rule, text := range passwordTranslations {
+				if err := ut.Add("password_"+rule, text, true); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		func(ut ut.Translator, fe validator.FieldError) string {
+			// FieldError 不携带违反的规则, 不带用户信息重新校验一次, 通过时说明是包含了邮箱或用户名
+			policyErr := &password.PolicyError{Rule: password.RuleUserInfo}
+			value, _ := fe.Value().(string)
+			errors.As(passwordPolicy.Validate(value), &policyErr)
+			return translatePasswordError(fe.Field(), policyErr)
+		},
+	); err != nil {
+		return fmt.Errorf("register password translation failed: %w", err)
+	}
+	return nil
+}
+
+func translatePasswordError(field string, err *password.PolicyError) string {
+	t, terr := trans.T("password_"+err.Rule, field, err.Param)
+	if terr != nil {
+		return err.Error()
+	}
+	return t
+}
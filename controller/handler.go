@@ -11,6 +11,8 @@ import (
 	"github.com/go-sql-driver/mysql"
 	"github.com/yiran15/api-server/base/apitypes"
 	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/pkg/password"
 	"gorm.io/gorm"
 )
 
@@ -48,15 +50,23 @@ func bindWithSources(c *gin.Context, req any, sources ...bindType) (success bool
 		}
 	}
 
-	requestID := requestid.Get(c)
-	if requestID != "" {
-		ctx := context.WithValue(c.Request.Context(), constant.RequestIDContextKey, requestID)
-		c.Request = c.Request.WithContext(ctx)
-	}
-
+	withRequestContext(c)
 	return true
 }
 
+// withRequestContext 将请求 ID 和客户端信息写入 context, 供 service 层使用
+func withRequestContext(c *gin.Context) {
+	ctx := c.Request.Context()
+	if requestID := requestid.Get(c); requestID != "" {
+		ctx = context.WithValue(ctx, constant.RequestIDContextKey, requestID)
+	}
+	ctx = context.WithValue(ctx, constant.ClientInfoContextKey, helper.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	c.Request = c.Request.WithContext(ctx)
+}
+
 type HandlerData[T any, R any] func(ctx context.Context, req *T) (R, error)
 
 func ResponseWithData[T any, R any](c *gin.Context, handler HandlerData[T, R], bindType ...bindType) {
@@ -100,6 +110,7 @@ func ResponseWithDataNoBind[R any](c *gin.Context, handler Handler[R]) {
 		data R
 		err  error
 	)
+	withRequestContext(c)
 	if data, err = handler(c.Request.Context()); err != nil {
 		responseError(c, err)
 		return
@@ -111,6 +122,7 @@ func ResponseWithDataNoBind[R any](c *gin.Context, handler Handler[R]) {
 type HandlerErrNoBind func(ctx context.Context) error
 
 func ResponseNoBind(c *gin.Context, handler HandlerErrNoBind) {
+	withRequestContext(c)
 	if err := handler(c.Request.Context()); err != nil {
 		responseError(c, err)
 		return
@@ -119,6 +131,12 @@ func ResponseNoBind(c *gin.Context, handler HandlerErrNoBind) {
 }
 
 func responseError(c *gin.Context, err error) {
+	// 密码不符合策略和参数校验失败一样返回翻译后的提示
+	var policyErr *password.PolicyError
+	if errors.As(err, &policyErr) {
+		responseParamError(c, err, translateErrors(err))
+		return
+	}
 	code, err := getErr(err)
 	c.JSON(code, apitypes.NewResponseWithOpts(code, apitypes.WithError(err.Error())))
 	c.Error(err)
@@ -138,6 +156,26 @@ func getErr(err error) (int, error) {
 		return http.StatusNotFound, errors.New("object not found")
 	}
 
+	if errors.Is(err, constant.ErrRefreshTokenInvalid) || errors.Is(err, constant.ErrMfaFailed) {
+		return http.StatusUnauthorized, err
+	}
+
+	if errors.Is(err, constant.ErrNoPermission) {
+		return http.StatusForbidden, err
+	}
+
+	if errors.Is(err, constant.ErrPasswordResetTokenInvalid) {
+		return http.StatusBadRequest, err
+	}
+
+	if errors.Is(err, constant.ErrIdentityLinked) || errors.Is(err, constant.ErrIdentityEmailConflict) {
+		return http.StatusConflict, err
+	}
+
+	if errors.Is(err, constant.ErrTooManyLoginAttempts) {
+		return http.StatusTooManyRequests, err
+	}
+
 	if code, ok, err := mysqlErr(err); ok {
 		return code, err
 	}
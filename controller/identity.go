@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/pkg/oauth"
+	v1 "github.com/yiran15/api-server/service/v1"
+)
+
+// linkUserSessionKey 发起关联的用户 id, 回调时必须与当前用户一致, 避免登录流程的 state 用于关联
+const linkUserSessionKey = "link_user"
+
+type IdentityController interface {
+	ListIdentities(c *gin.Context)
+	LinkIdentity(c *gin.Context)
+	LinkIdentityCallback(c *gin.Context)
+	UnlinkIdentity(c *gin.Context)
+}
+
+type identityController struct {
+	identityService v1.IdentityServicer
+}
+
+func NewIdentityController(identityService v1.IdentityServicer) IdentityController {
+	return &identityController{
+		identityService: identityService,
+	}
+}
+
+// ListIdentities 外部身份列表
+// @Summary 外部身份列表
+// @Description 查询当前用户关联的外部身份
+// @Tags 外部身份
+// @Accept json
+// @Produce json
+// @Success 200 {object} apitypes.Response{data=[]model.UserIdentity} "查询成功"
+// @Router /api/v1/user/identities [get]
+func (receiver *identityController) ListIdentities(c *gin.Context) {
+	ResponseWithDataNoBind(c, receiver.identityService.ListIdentities)
+}
+
+// LinkIdentity 关联外部身份
+// @Summary 关联外部身份
+// @Description 返回 provider 的授权地址, 前端跳转授权后使用回调参数调用关联回调接口
+// @Tags 外部身份
+// @Accept json
+// @Produce json
+// @Param data query apitypes.IdentityLinkRequest true "关联请求参数"
+// @Success 200 {object} apitypes.Response{data=apitypes.IdentityLinkResponse} "获取成功"
+// @Router /api/v1/user/identities/link [post]
+func (receiver *identityController) LinkIdentity(c *gin.Context) {
+	claims, ok := c.Request.Context().Value(constant.UserContextKey).(*jwt.JwtClaims)
+	if !ok {
+		responseError(c, constant.ErrAuthFailed)
+		return
+	}
+	provider := c.Query("provider")
+	if provider == "" {
+		responseError(c, errors.New("provider is empty"))
+		return
+	}
+
+	session := sessions.Default(c)
+	params := oauth.NewAuthParams()
+	session.Set("state", params.State)
+	session.Set("nonce", params.Nonce)
+	session.Set("verifier", params.CodeVerifier)
+	session.Set("provider", provider)
+	session.Set(linkUserSessionKey, claims.UserID)
+	if err := session.Save(); err != nil {
+		responseError(c, fmt.Errorf("save session failed: %w", err))
+		return
+	}
+	data, err := receiver.identityService.LinkIdentity(c.Request.Context(), provider, params)
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	responseSuccess(c, data)
+}
+
+// LinkIdentityCallback 关联外部身份回调
+// @Summary 关联外部身份回调
+// @Description 使用授权回调的 code 和 state 将外部身份关联到当前用户
+// @Tags 外部身份
+// @Accept json
+// @Produce json
+// @Param data query apitypes.OAuthLoginRequest true "回调请求参数"
+// @Success 200 {object} apitypes.Response{data=model.UserIdentity} "关联成功"
+// @Router /api/v1/user/identities/callback [post]
+func (receiver *identityController) LinkIdentityCallback(c *gin.Context) {
+	claims, ok := c.Request.Context().Value(constant.UserContextKey).(*jwt.JwtClaims)
+	if !ok {
+		responseError(c, constant.ErrAuthFailed)
+		return
+	}
+	session := sessions.Default(c)
+	state := c.Query("state")
+	if state == "" {
+		responseError(c, errors.New("state is empty"))
+		return
+	}
+	if state != session.Get("state") {
+		responseError(c, errors.New("state invalid"))
+		return
+	}
+	if linkUser, _ := session.Get(linkUserSessionKey).(int64); linkUser != claims.UserID {
+		responseError(c, errors.New("state invalid"))
+		return
+	}
+	provider, _ := session.Get("provider").(string)
+	if provider == "" {
+		responseError(c, errors.New("provider is empty"))
+		return
+	}
+	nonce, _ := session.Get("nonce").(string)
+	verifier, _ := session.Get("verifier").(string)
+	// state 只能使用一次
+	session.Delete("state")
+	session.Delete(linkUserSessionKey)
+	if err := session.Save(); err != nil {
+		responseError(c, fmt.Errorf("save session failed: %w", err))
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), constant.ProviderContextKey, provider)
+	ctx = oauth.WithAuthParams(ctx, &oauth.AuthParams{State: state, Nonce: nonce, CodeVerifier: verifier})
+	c.Request = c.Request.WithContext(ctx)
+	ResponseWithData(c, receiver.identityService.LinkIdentityCallback, bindTypeQuery)
+}
+
+// UnlinkIdentity 解除外部身份关联
+// @Summary 解除外部身份关联
+// @Description 解除当前用户的外部身份关联, 没有设置密码时不能解除最后一个身份
+// @Tags 外部身份
+// @Accept json
+// @Produce json
+// @Param id path int true "外部身份id"
+// @Success 200 {object} apitypes.Response "解除成功"
+// @Router /api/v1/user/identities/:id [delete]
+func (receiver *identityController) UnlinkIdentity(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.identityService.UnlinkIdentity, bindTypeUri)
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	v1 "github.com/yiran15/api-server/service/v1"
+)
+
+type LoginHistoryController interface {
+	ListLoginHistory(c *gin.Context)
+	ListSelfLoginHistory(c *gin.Context)
+}
+
+type loginHistoryController struct {
+	loginHistoryService v1.LoginHistoryServicer
+}
+
+func NewLoginHistoryController(loginHistoryService v1.LoginHistoryServicer) LoginHistoryController {
+	return &loginHistoryController{
+		loginHistoryService: loginHistoryService,
+	}
+}
+
+// ListLoginHistory 用户登录历史
+// @Summary 用户登录历史
+// @Description 管理员分页查询指定用户的登录和认证事件, 包括登录成功失败、OAuth2 登录、激活、登出和授权失败
+// @Tags 登录历史
+// @Accept json
+// @Produce json
+// @Param id path int true "用户id"
+// @Param data query apitypes.LoginHistoryFilter true "查询请求参数"
+// @Success 200 {object} apitypes.Response{data=apitypes.LoginHistoryResponse} "查询成功"
+// @Router /api/v1/user/:id/login-history [get]
+func (receiver *loginHistoryController) ListLoginHistory(c *gin.Context) {
+	ResponseWithData(c, receiver.loginHistoryService.ListLoginHistory, bindTypeUri, bindTypeQuery)
+}
+
+// ListSelfLoginHistory 我的登录历史
+// @Summary 我的登录历史
+// @Description 分页查询当前用户的登录和认证事件, 用于发现不是本人的登录
+// @Tags 登录历史
+// @Accept json
+// @Produce json
+// @Param data query apitypes.LoginHistoryFilter true "查询请求参数"
+// @Success 200 {object} apitypes.Response{data=apitypes.LoginHistoryResponse} "查询成功"
+// @Router /api/v1/user/self/login-history [get]
+func (receiver *loginHistoryController) ListSelfLoginHistory(c *gin.Context) {
+	ResponseWithData(c, receiver.loginHistoryService.ListSelfLoginHistory, bindTypeQuery)
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	v1 "github.com/yiran15/api-server/service/v1"
+)
+
+type MfaController interface {
+	EnrollMfa(c *gin.Context)
+	ConfirmMfa(c *gin.Context)
+	ResetMfa(c *gin.Context)
+}
+
+type mfaController struct {
+	mfaService v1.MfaServicer
+}
+
+func NewMfaController(mfaService v1.MfaServicer) MfaController {
+	return &mfaController{
+		mfaService: mfaService,
+	}
+}
+
+// EnrollMfa 绑定两步验证
+// @Summary 绑定两步验证
+// @Description 为当前用户生成 TOTP 密钥, 返回密钥和 otpauth URI, 需要使用验证码确认后才会启用
+// @Tags 两步验证
+// @Accept json
+// @Produce json
+// @Success 200 {object} apitypes.Response{data=apitypes.MfaEnrollResponse} "绑定成功"
+// @Router /api/v1/user/mfa/enroll [post]
+func (receiver *mfaController) EnrollMfa(c *gin.Context) {
+	ResponseWithDataNoBind(c, receiver.mfaService.EnrollMfa)
+}
+
+// ConfirmMfa 启用两步验证
+// @Summary 启用两步验证
+// @Description 使用验证器生成的第一个验证码确认启用两步验证, 返回一次性恢复码
+// @Tags 两步验证
+// @Accept json
+// @Produce json
+// @Param data body apitypes.MfaConfirmRequest true "确认请求参数"
+// @Success 200 {object} apitypes.Response{data=apitypes.MfaConfirmResponse} "启用成功"
+// @Router /api/v1/user/mfa/confirm [post]
+func (receiver *mfaController) ConfirmMfa(c *gin.Context) {
+	ResponseWithData(c, receiver.mfaService.ConfirmMfa, bindTypeJson)
+}
+
+// ResetMfa 重置两步验证
+// @Summary 重置两步验证
+// @Description 删除用户的两步验证配置, 用户丢失验证器和恢复码时使用, 只能管理员操作
+// @Tags 两步验证
+// @Accept json
+// @Produce json
+// @Param data body apitypes.IDRequest true "重置请求参数"
+// @Success 200 {object} apitypes.Response "重置成功"
+// @Router /api/v1/user/:id/mfa [delete]
+func (receiver *mfaController) ResetMfa(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.mfaService.ResetMfa, bindTypeUri)
+}
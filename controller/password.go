@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	v1 "github.com/yiran15/api-server/service/v1"
+)
+
+type PasswordController interface {
+	ForgotPassword(c *gin.Context)
+	ResetPassword(c *gin.Context)
+}
+
+type passwordController struct {
+	passwordService v1.PasswordServicer
+}
+
+func NewPasswordController(passwordService v1.PasswordServicer) PasswordController {
+	return &passwordController{
+		passwordService: passwordService,
+	}
+}
+
+// ForgotPassword 忘记密码
+// @Summary 忘记密码
+// @Description 向用户邮箱发送一次性的密码重置链接, 无论邮箱是否存在都返回成功
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param data body apitypes.PasswordForgotRequest true "请求参数"
+// @Success 200 {object} apitypes.Response "发送成功"
+// @Router /api/v1/user/password/forgot [post]
+func (receiver *passwordController) ForgotPassword(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.passwordService.ForgotPassword, bindTypeJson)
+}
+
+// ResetPassword 重置密码
+// @Summary 重置密码
+// @Description 使用邮件中的重置 token 设置新密码, token 只能使用一次, 重置后用户需要重新登录
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param data body apitypes.PasswordResetRequest true "重置请求参数"
+// @Success 200 {object} apitypes.Response "重置成功"
+// @Router /api/v1/user/password/reset [post]
+func (receiver *passwordController) ResetPassword(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.passwordService.ResetPassword, bindTypeJson)
+}
@@ -6,4 +6,15 @@ var ControllerProviderSet = wire.NewSet(
 	NewUserController,
 	NewRoleController,
 	NewApiController,
+	NewWellKnownController,
+	NewAccessTokenController,
+	NewMfaController,
+	NewPasswordController,
+	NewSessionController,
+	NewAuditController,
+	NewLoginHistoryController,
+	NewIdentityController,
+	NewScimController,
+	NewTenantController,
+	NewAuthzController,
 )
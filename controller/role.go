@@ -11,6 +11,7 @@ type RoleController interface {
 	DeleteRole(c *gin.Context)
 	QueryRole(c *gin.Context)
 	ListRole(c *gin.Context)
+	EffectiveApis(c *gin.Context)
 }
 
 type roleController struct {
@@ -25,7 +26,7 @@ func NewRoleController(roleService v1.RoleServicer) RoleController {
 
 // CreateRole 创建角色
 // @Summary 创建角色
-// @Description 创建角色
+// @Description 创建角色, denyApis 中的接口以 deny 写入策略, 优先于 allow 的权限
 // @Tags 角色管理
 // @Accept json
 // @Produce json
@@ -38,7 +39,7 @@ func (receiver *roleController) CreateRole(c *gin.Context) {
 
 // UpdateRole 更新角色
 // @Summary 更新角色
-// @Description 更新角色, 并且可以更新角色的权限
+// @Description 更新角色, 并且可以更新角色的权限、禁止访问的接口和继承的角色, denyApis 和 parentRoles 不传时不修改
 // @Tags 角色管理
 // @Accept json
 // @Produce json
@@ -51,7 +52,7 @@ func (receiver *roleController) UpdateRole(c *gin.Context) {
 
 // DeleteRole 删除角色
 // @Summary 删除角色
-// @Description 删除角色, 不能删除有用户的角色
+// @Description 删除角色, 不能删除有用户或被其他角色继承的角色
 // @Tags 角色管理
 // @Accept json
 // @Produce json
@@ -87,3 +88,15 @@ func (receiver *roleController) QueryRole(c *gin.Context) {
 func (receiver *roleController) ListRole(c *gin.Context) {
 	ResponseWithData(c, receiver.roleService.ListRole, bindTypeUri, bindTypeQuery)
 }
+
+// EffectiveApis 角色的有效权限
+// @Summary 角色的有效权限
+// @Description 查询角色直接拥有和从父角色继承的全部接口, effect 为最终的效果, grantedBy 和 deniedBy 为允许和禁止该接口的角色
+// @Tags 角色管理
+// @Produce json
+// @Param id path int true "角色id"
+// @Success 200 {object} apitypes.Response{data=apitypes.RoleEffectiveApisResponse} "查询成功"
+// @Router /api/v1/role/{id}/effective-apis [get]
+func (receiver *roleController) EffectiveApis(c *gin.Context) {
+	ResponseWithData(c, receiver.roleService.EffectiveApis, bindTypeUri)
+}
@@ -0,0 +1,304 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yiran15/api-server/pkg/scim"
+	v1 "github.com/yiran15/api-server/service/v1"
+	"gorm.io/gorm"
+)
+
+// ScimController SCIM 2.0 接口, 请求和响应使用 SCIM 的格式, 不使用统一响应结构
+type ScimController interface {
+	ServiceProviderConfig(c *gin.Context)
+	ListUsers(c *gin.Context)
+	GetUser(c *gin.Context)
+	CreateUser(c *gin.Context)
+	ReplaceUser(c *gin.Context)
+	PatchUser(c *gin.Context)
+	DeleteUser(c *gin.Context)
+	ListGroups(c *gin.Context)
+	GetGroup(c *gin.Context)
+	CreateGroup(c *gin.Context)
+	ReplaceGroup(c *gin.Context)
+	PatchGroup(c *gin.Context)
+	DeleteGroup(c *gin.Context)
+}
+
+type scimController struct {
+	scimService v1.ScimServicer
+}
+
+func NewScimController(scimService v1.ScimServicer) ScimController {
+	return &scimController{
+		scimService: scimService,
+	}
+}
+
+// ServiceProviderConfig SCIM 服务端配置
+// @Summary SCIM 服务端配置
+// @Description 返回支持的 SCIM 功能, 使用 scim.token 认证
+// @Tags SCIM
+// @Produce json
+// @Success 200 {object} scim.ServiceProviderConfig "查询成功"
+// @Router /scim/v2/ServiceProviderConfig [get]
+func (receiver *scimController) ServiceProviderConfig(c *gin.Context) {
+	scimResponse(c, http.StatusOK, scim.NewServiceProviderConfig())
+}
+
+// ListUsers SCIM 用户列表
+// @Summary SCIM 用户列表
+// @Description 支持 filter、startIndex 和 count 参数
+// @Tags SCIM
+// @Produce json
+// @Param data query scim.ListRequest false "查询参数"
+// @Success 200 {object} scim.ListResponse{Resources=[]scim.User} "查询成功"
+// @Router /scim/v2/Users [get]
+func (receiver *scimController) ListUsers(c *gin.Context) {
+	req := &scim.ListRequest{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		scimResponseError(c, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "%v", err))
+		return
+	}
+	withRequestContext(c)
+	res, err := receiver.scimService.ListUsers(c.Request.Context(), req)
+	scimResult(c, http.StatusOK, res, err)
+}
+
+// GetUser 查询 SCIM 用户
+// @Summary 查询 SCIM 用户
+// @Tags SCIM
+// @Produce json
+// @Param id path string true "用户id"
+// @Success 200 {object} scim.User "查询成功"
+// @Router /scim/v2/Users/{id} [get]
+func (receiver *scimController) GetUser(c *gin.Context) {
+	withRequestContext(c)
+	res, err := receiver.scimService.GetUser(c.Request.Context(), c.Param("id"))
+	scimResult(c, http.StatusOK, res, err)
+}
+
+// CreateUser 创建 SCIM 用户
+// @Summary 创建 SCIM 用户
+// @Description 创建没有本地密码的用户, userName、邮箱或 externalId 重复时返回 409
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param data body scim.User true "用户"
+// @Success 201 {object} scim.User "创建成功"
+// @Router /scim/v2/Users [post]
+func (receiver *scimController) CreateUser(c *gin.Context) {
+	req := &scim.User{}
+	if !scimBind(c, req) {
+		return
+	}
+	res, err := receiver.scimService.CreateUser(c.Request.Context(), req)
+	scimResult(c, http.StatusCreated, res, err)
+}
+
+// ReplaceUser 替换 SCIM 用户
+// @Summary 替换 SCIM 用户
+// @Description active 为 false 时禁用用户并吊销其 token 和会话
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param id path string true "用户id"
+// @Param data body scim.User true "用户"
+// @Success 200 {object} scim.User "修改成功"
+// @Router /scim/v2/Users/{id} [put]
+func (receiver *scimController) ReplaceUser(c *gin.Context) {
+	req := &scim.User{}
+	if !scimBind(c, req) {
+		return
+	}
+	res, err := receiver.scimService.ReplaceUser(c.Request.Context(), c.Param("id"), req)
+	scimResult(c, http.StatusOK, res, err)
+}
+
+// PatchUser 修改 SCIM 用户
+// @Summary 修改 SCIM 用户
+// @Description 支持 add、replace 和 remove 操作
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param id path string true "用户id"
+// @Param data body scim.PatchRequest true "修改操作"
+// @Success 200 {object} scim.User "修改成功"
+// @Router /scim/v2/Users/{id} [patch]
+func (receiver *scimController) PatchUser(c *gin.Context) {
+	req := &scim.PatchRequest{}
+	if !scimBind(c, req) {
+		return
+	}
+	res, err := receiver.scimService.PatchUser(c.Request.Context(), c.Param("id"), req)
+	scimResult(c, http.StatusOK, res, err)
+}
+
+// DeleteUser 删除 SCIM 用户
+// @Summary 删除 SCIM 用户
+// @Tags SCIM
+// @Param id path string true "用户id"
+// @Success 204 "删除成功"
+// @Router /scim/v2/Users/{id} [delete]
+func (receiver *scimController) DeleteUser(c *gin.Context) {
+	withRequestContext(c)
+	err := receiver.scimService.DeleteUser(c.Request.Context(), c.Param("id"))
+	scimResult(c, http.StatusNoContent, nil, err)
+}
+
+// ListGroups SCIM 组列表
+// @Summary SCIM 组列表
+// @Description 组对应本地角色, 支持 filter、startIndex、count 和 excludedAttributes=members 参数
+// @Tags SCIM
+// @Produce json
+// @Param data query scim.ListRequest false "查询参数"
+// @Success 200 {object} scim.ListResponse{Resources=[]scim.Group} "查询成功"
+// @Router /scim/v2/Groups [get]
+func (receiver *scimController) ListGroups(c *gin.Context) {
+	req := &scim.ListRequest{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		scimResponseError(c, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "%v", err))
+		return
+	}
+	withRequestContext(c)
+	res, err := receiver.scimService.ListGroups(c.Request.Context(), req)
+	scimResult(c, http.StatusOK, res, err)
+}
+
+// GetGroup 查询 SCIM 组
+// @Summary 查询 SCIM 组
+// @Tags SCIM
+// @Produce json
+// @Param id path string true "角色id"
+// @Success 200 {object} scim.Group "查询成功"
+// @Router /scim/v2/Groups/{id} [get]
+func (receiver *scimController) GetGroup(c *gin.Context) {
+	withRequestContext(c)
+	res, err := receiver.scimService.GetGroup(c.Request.Context(), c.Param("id"))
+	if err == nil && (&scim.ListRequest{ExcludedAttributes: c.Query("excludedAttributes")}).Excluded("members") {
+		res.Members = nil
+	}
+	scimResult(c, http.StatusOK, res, err)
+}
+
+// CreateGroup 创建 SCIM 组
+// @Summary 创建 SCIM 组
+// @Description 创建没有接口权限的角色并添加成员
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param data body scim.Group true "组"
+// @Success 201 {object} scim.Group "创建成功"
+// @Router /scim/v2/Groups [post]
+func (receiver *scimController) CreateGroup(c *gin.Context) {
+	req := &scim.Group{}
+	if !scimBind(c, req) {
+		return
+	}
+	res, err := receiver.scimService.CreateGroup(c.Request.Context(), req)
+	scimResult(c, http.StatusCreated, res, err)
+}
+
+// ReplaceGroup 替换 SCIM 组
+// @Summary 替换 SCIM 组
+// @Description 修改角色名称和成员, 刷新 casbin 策略和成员的角色缓存
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param id path string true "角色id"
+// @Param data body scim.Group true "组"
+// @Success 200 {object} scim.Group "修改成功"
+// @Router /scim/v2/Groups/{id} [put]
+func (receiver *scimController) ReplaceGroup(c *gin.Context) {
+	req := &scim.Group{}
+	if !scimBind(c, req) {
+		return
+	}
+	res, err := receiver.scimService.ReplaceGroup(c.Request.Context(), c.Param("id"), req)
+	scimResult(c, http.StatusOK, res, err)
+}
+
+// PatchGroup 修改 SCIM 组
+// @Summary 修改 SCIM 组
+// @Description 支持 add、replace 和 remove 操作, 如 {"op":"remove","path":"members[value eq \"2\"]"}
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param id path string true "角色id"
+// @Param data body scim.PatchRequest true "修改操作"
+// @Success 200 {object} scim.Group "修改成功"
+// @Router /scim/v2/Groups/{id} [patch]
+func (receiver *scimController) PatchGroup(c *gin.Context) {
+	req := &scim.PatchRequest{}
+	if !scimBind(c, req) {
+		return
+	}
+	res, err := receiver.scimService.PatchGroup(c.Request.Context(), c.Param("id"), req)
+	scimResult(c, http.StatusOK, res, err)
+}
+
+// DeleteGroup 删除 SCIM 组
+// @Summary 删除 SCIM 组
+// @Description 移除成员的角色后删除角色
+// @Tags SCIM
+// @Param id path string true "角色id"
+// @Success 204 "删除成功"
+// @Router /scim/v2/Groups/{id} [delete]
+func (receiver *scimController) DeleteGroup(c *gin.Context) {
+	withRequestContext(c)
+	err := receiver.scimService.DeleteGroup(c.Request.Context(), c.Param("id"))
+	scimResult(c, http.StatusNoContent, nil, err)
+}
+
+func scimBind(c *gin.Context, req any) bool {
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		var scimErr *scim.Error
+		if !errors.As(err, &scimErr) {
+			scimErr = scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, "invalid request body: %v", err)
+		}
+		scimResponseError(c, scimErr)
+		return false
+	}
+	withRequestContext(c)
+	return true
+}
+
+func scimResult(c *gin.Context, code int, data any, err error) {
+	if err != nil {
+		scimResponseError(c, err)
+		return
+	}
+	if code == http.StatusNoContent {
+		c.Status(code)
+		return
+	}
+	scimResponse(c, code, data)
+}
+
+func scimResponseError(c *gin.Context, err error) {
+	var scimErr *scim.Error
+	if !errors.As(err, &scimErr) {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			scimErr = scim.NewError(http.StatusNotFound, "", "resource not found")
+		default:
+			code, e := getErr(err)
+			scimErr = scim.NewError(code, "", "%s", e.Error())
+		}
+	}
+	scimResponse(c, scimErr.Code(), scimErr)
+	c.Error(err)
+}
+
+func scimResponse(c *gin.Context, code int, data any) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(code, scim.ContentType, body)
+}
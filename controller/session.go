@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	v1 "github.com/yiran15/api-server/service/v1"
+)
+
+type SessionController interface {
+	ListSessions(c *gin.Context)
+	RevokeSession(c *gin.Context)
+	ListUserSessions(c *gin.Context)
+	RevokeUserSession(c *gin.Context)
+}
+
+type sessionController struct {
+	sessionService v1.SessionServicer
+}
+
+func NewSessionController(sessionService v1.SessionServicer) SessionController {
+	return &sessionController{
+		sessionService: sessionService,
+	}
+}
+
+// ListSessions 登录会话列表
+// @Summary 登录会话列表
+// @Description 查询当前用户的登录会话, current 为 true 的是发起请求的会话
+// @Tags 登录会话
+// @Accept json
+// @Produce json
+// @Success 200 {object} apitypes.Response{data=[]session.Session} "查询成功"
+// @Router /api/v1/user/sessions [get]
+func (receiver *sessionController) ListSessions(c *gin.Context) {
+	ResponseWithDataNoBind(c, receiver.sessionService.ListSessions)
+}
+
+// RevokeSession 注销登录会话
+// @Summary 注销登录会话
+// @Description 注销当前用户的指定会话, 该会话的 access token 和 refresh token 立即失效
+// @Tags 登录会话
+// @Accept json
+// @Produce json
+// @Param data body apitypes.SessionIDRequest true "注销请求参数"
+// @Success 200 {object} apitypes.Response "注销成功"
+// @Router /api/v1/user/sessions/:id [delete]
+func (receiver *sessionController) RevokeSession(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.sessionService.RevokeSession, bindTypeUri)
+}
+
+// ListUserSessions 用户登录会话列表
+// @Summary 用户登录会话列表
+// @Description 管理员查询指定用户的登录会话
+// @Tags 登录会话
+// @Accept json
+// @Produce json
+// @Param data body apitypes.IDRequest true "查询请求参数"
+// @Success 200 {object} apitypes.Response{data=[]session.Session} "查询成功"
+// @Router /api/v1/user/:id/sessions [get]
+func (receiver *sessionController) ListUserSessions(c *gin.Context) {
+	ResponseWithData(c, receiver.sessionService.ListUserSessions, bindTypeUri)
+}
+
+// RevokeUserSession 注销用户登录会话
+// @Summary 注销用户登录会话
+// @Description 管理员注销指定用户的指定会话
+// @Tags 登录会话
+// @Accept json
+// @Produce json
+// @Param data body apitypes.UserSessionRequest true "注销请求参数"
+// @Success 200 {object} apitypes.Response "注销成功"
+// @Router /api/v1/user/:id/sessions/:sid [delete]
+func (receiver *sessionController) RevokeUserSession(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.sessionService.RevokeUserSession, bindTypeUri)
+}
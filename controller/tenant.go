@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	v1 "github.com/yiran15/api-server/service/v1"
+)
+
+type TenantController interface {
+	CreateTenant(c *gin.Context)
+	UpdateTenant(c *gin.Context)
+	ListTenant(c *gin.Context)
+	AddUsers(c *gin.Context)
+	RemoveUser(c *gin.Context)
+}
+
+type tenantController struct {
+	tenantService v1.TenantServicer
+}
+
+func NewTenantController(tenantService v1.TenantServicer) TenantController {
+	return &tenantController{
+		tenantService: tenantService,
+	}
+}
+
+// CreateTenant 创建租户
+// @Summary 创建租户
+// @Description 创建租户, 创建者加入租户并获得与默认租户 admin 角色权限相同的 admin 角色
+// @Tags 租户管理
+// @Accept json
+// @Produce json
+// @Param data body apitypes.TenantCreateRequest true "创建请求参数"
+// @Success 200 {object} apitypes.Response "创建成功"
+// @Router /api/v1/tenant [post]
+func (receiver *tenantController) CreateTenant(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.tenantService.CreateTenant, bindTypeJson)
+}
+
+// UpdateTenant 更新租户
+// @Summary 更新租户
+// @Description 更新租户的名称和描述
+// @Tags 租户管理
+// @Accept json
+// @Produce json
+// @Param id path int true "租户id"
+// @Param data body apitypes.TenantUpdateRequest true "更新请求参数"
+// @Success 200 {object} apitypes.Response "更新成功"
+// @Router /api/v1/tenant/{id} [put]
+func (receiver *tenantController) UpdateTenant(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.tenantService.UpdateTenant, bindTypeUri, bindTypeJson)
+}
+
+// ListTenant 租户列表
+// @Summary 租户列表
+// @Description 使用分页查询租户, 支持根据 name 查询
+// @Tags 租户管理
+// @Produce json
+// @Param data query apitypes.TenantListRequest true "查询请求参数"
+// @Success 200 {object} apitypes.Response{data=apitypes.TenantListResponse} "查询成功"
+// @Router /api/v1/tenant [get]
+func (receiver *tenantController) ListTenant(c *gin.Context) {
+	ResponseWithData(c, receiver.tenantService.ListTenant, bindTypeQuery)
+}
+
+// AddUsers 租户添加用户
+// @Summary 租户添加用户
+// @Description 将用户加入租户, 所有用户都属于默认租户, 不能修改默认租户的用户
+// @Tags 租户管理
+// @Accept json
+// @Produce json
+// @Param id path int true "租户id"
+// @Param data body apitypes.TenantAddUsersRequest true "用户id"
+// @Success 200 {object} apitypes.Response "添加成功"
+// @Router /api/v1/tenant/{id}/users [post]
+func (receiver *tenantController) AddUsers(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.tenantService.AddUsers, bindTypeUri, bindTypeJson)
+}
+
+// RemoveUser 租户移除用户
+// @Summary 租户移除用户
+// @Description 将用户移出租户, 同时删除用户在该租户中的角色
+// @Tags 租户管理
+// @Produce json
+// @Param id path int true "租户id"
+// @Param userId path int true "用户id"
+// @Success 200 {object} apitypes.Response "移除成功"
+// @Router /api/v1/tenant/{id}/users/{userId} [delete]
+func (receiver *tenantController) RemoveUser(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.tenantService.RemoveUser, bindTypeUri)
+}
@@ -8,14 +8,18 @@ import (
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/pkg/oauth"
 	v1 "github.com/yiran15/api-server/service/v1"
 )
 
 type UserController interface {
 	UserLoginController(c *gin.Context)
+	UserLoginMfaController(c *gin.Context)
+	UserRefreshTokenController(c *gin.Context)
 	UserLogoutController(c *gin.Context)
+	UserRevokeTokensController(c *gin.Context)
+	UserUnlockController(c *gin.Context)
 	UserCreateController(c *gin.Context)
 	UserUpdateByAdminController(c *gin.Context)
 	UserUpdateBySelfController(c *gin.Context)
@@ -23,6 +27,7 @@ type UserController interface {
 	UserQueryController(c *gin.Context)
 	UserListController(c *gin.Context)
 	UserInfoController(c *gin.Context)
+	UserTenantsController(c *gin.Context)
 	OAuth2LoginController(c *gin.Context)
 	OAuth2CallbackController(c *gin.Context)
 	OAuth2ProviderController(c *gin.Context)
@@ -52,6 +57,32 @@ func (receiver *UserControllerImpl) UserLoginController(c *gin.Context) {
 	ResponseWithData(c, receiver.userServicer.Login, bindTypeJson)
 }
 
+// UserLoginMfaController 两步验证登录
+// @Summary 两步验证登录
+// @Description 启用两步验证的用户登录后返回 mfaToken, 使用 mfaToken 和验证器生成的验证码或恢复码完成登录
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param data body apitypes.UserLoginMfaRequest true "登录请求参数"
+// @Success 200 {object} apitypes.Response{data=apitypes.UserLoginResponse} "登录成功"
+// @Router /api/v1/user/login/mfa [post]
+func (receiver *UserControllerImpl) UserLoginMfaController(c *gin.Context) {
+	ResponseWithData(c, receiver.userServicer.LoginMfa, bindTypeJson)
+}
+
+// UserRefreshTokenController 刷新 Token
+// @Summary 刷新 Token
+// @Description 使用 refresh token 换取新的 access token 和 refresh token, refresh token 只能使用一次, 传 tenantId 时切换到用户所属的其他租户
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param data body apitypes.UserRefreshTokenRequest true "刷新请求参数"
+// @Success 200 {object} apitypes.Response{data=apitypes.UserLoginResponse} "刷新成功"
+// @Router /api/v1/user/refresh [post]
+func (receiver *UserControllerImpl) UserRefreshTokenController(c *gin.Context) {
+	ResponseWithData(c, receiver.userServicer.RefreshToken, bindTypeJson)
+}
+
 // UserLogoutController 用户注销
 // @Summary 用户注销
 // @Description 用户注销，清空 Token
@@ -64,6 +95,32 @@ func (receiver *UserControllerImpl) UserLogoutController(c *gin.Context) {
 	ResponseNoBind(c, receiver.userServicer.Logout)
 }
 
+// UserRevokeTokensController 吊销用户 Token
+// @Summary 吊销用户 Token
+// @Description 吊销用户所有已签发的 Token, 用户需要重新登录, 只能管理员操作
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param data body apitypes.IDRequest true "吊销请求参数"
+// @Success 200 {object} apitypes.Response "吊销成功"
+// @Router /api/v1/user/:id/revoke [post]
+func (receiver *UserControllerImpl) UserRevokeTokensController(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.userServicer.RevokeUserTokens, bindTypeUri)
+}
+
+// UserUnlockController 解锁用户
+// @Summary 解锁用户
+// @Description 解除用户因登录失败次数过多导致的临时锁定, 只能管理员操作
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param data body apitypes.IDRequest true "解锁请求参数"
+// @Success 200 {object} apitypes.Response "解锁成功"
+// @Router /api/v1/user/:id/unlock [post]
+func (receiver *UserControllerImpl) UserUnlockController(c *gin.Context) {
+	ResponseOnlySuccess(c, receiver.userServicer.UnlockUser, bindTypeUri)
+}
+
 // UserCreateController 用户创建
 // @Summary 用户创建
 // @Description 创建用户同时可以设置角色
@@ -141,6 +198,17 @@ func (receiver *UserControllerImpl) UserInfoController(c *gin.Context) {
 	ResponseWithDataNoBind(c, receiver.userServicer.Info)
 }
 
+// UserTenantsController 用户所属的租户
+// @Summary 用户所属的租户
+// @Description 查询当前用户所属的租户, 所有用户都属于默认租户, 可以通过刷新 token 切换租户
+// @Tags 用户管理
+// @Produce json
+// @Success 200 {object} apitypes.Response{data=[]model.Tenant} "查询成功"
+// @Router /api/v1/user/tenants [get]
+func (receiver *UserControllerImpl) UserTenantsController(c *gin.Context) {
+	ResponseWithDataNoBind(c, receiver.userServicer.ListTenants)
+}
+
 // UserListController 用户列表
 // @Summary 用户列表
 // @Description 使用分页查询用户的信息, 支持根据 name, email, mobile, department 查询
@@ -164,8 +232,10 @@ func (receiver *UserControllerImpl) UserListController(c *gin.Context) {
 // @Router /api/v1/oauth2/login [get]
 func (receiver *UserControllerImpl) OAuth2LoginController(c *gin.Context) {
 	session := sessions.Default(c)
-	state := uuid.New().String()
-	session.Set("state", state)
+	params := oauth.NewAuthParams()
+	session.Set("state", params.State)
+	session.Set("nonce", params.Nonce)
+	session.Set("verifier", params.CodeVerifier)
 	provider := c.Query("provider")
 	if provider != "" {
 		session.Set("provider", provider)
@@ -175,7 +245,7 @@ func (receiver *UserControllerImpl) OAuth2LoginController(c *gin.Context) {
 		responseError(c, fmt.Errorf("save session failed: %w", err))
 		return
 	}
-	url, err := receiver.userServicer.OAuth2Login(provider, state)
+	url, err := receiver.userServicer.OAuth2Login(c.Request.Context(), provider, params)
 	if err != nil {
 		responseError(c, err)
 		return
@@ -216,6 +286,9 @@ func (receiver *UserControllerImpl) OAuth2CallbackController(c *gin.Context) {
 		return
 	}
 	ctx := context.WithValue(c.Request.Context(), constant.ProviderContextKey, providerStr)
+	nonce, _ := session.Get("nonce").(string)
+	verifier, _ := session.Get("verifier").(string)
+	ctx = oauth.WithAuthParams(ctx, &oauth.AuthParams{State: state, Nonce: nonce, CodeVerifier: verifier})
 	c.Request = c.Request.WithContext(ctx)
 	ResponseWithData(c, receiver.userServicer.OAuth2Callback, bindTypeQuery)
 }
@@ -253,5 +326,10 @@ func (receiver *UserControllerImpl) OAuth2ActivateController(c *gin.Context) {
 		responseError(c, errors.New("state invalid"))
 		return
 	}
+	// provider 只用于记录登录事件, 不存在时不影响激活
+	if provider, ok := session.Get("provider").(string); ok {
+		ctx := context.WithValue(c.Request.Context(), constant.ProviderContextKey, provider)
+		c.Request = c.Request.WithContext(ctx)
+	}
 	ResponseWithData(c, receiver.userServicer.OAuth2Activate, bindTypeUri, bindTypeJson)
 }
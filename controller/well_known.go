@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yiran15/api-server/pkg/jwt"
+)
+
+type WellKnownController interface {
+	JWKS(c *gin.Context)
+}
+
+type wellKnownController struct {
+	jwt jwt.JwtInterface
+}
+
+func NewWellKnownController(jwt jwt.JwtInterface) WellKnownController {
+	return &wellKnownController{
+		jwt: jwt,
+	}
+}
+
+// JWKS 验签公钥
+// @Summary 验签公钥
+// @Description 获取 JWT 验签公钥 (RFC 7517), 返回标准 JWKS 格式, 不使用统一响应结构
+// @Tags 认证
+// @Produce json
+// @Success 200 {object} jwt.JSONWebKeySet "查询成功"
+// @Router /.well-known/jwks.json [get]
+func (receiver *wellKnownController) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, receiver.jwt.JWKS())
+}
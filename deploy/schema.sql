@@ -15,16 +15,39 @@ CREATE TABLE `users` (
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 CREATE INDEX `idx_users_deleted_at` ON `users` (`deleted_at`);
 
--- 角色表
+-- 租户表, id 为 1 的默认租户包含所有用户
+CREATE TABLE `tenants` (
+  `id` BIGINT UNSIGNED PRIMARY KEY auto_increment,
+  `created_at` DATETIME NOT NULL,
+  `updated_at` DATETIME NOT NULL,
+  `deleted_at` DATETIME,
+  `name` VARCHAR(50) NOT NULL,
+  `description` VARCHAR(255),
+  UNIQUE KEY `idx_tenants_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE INDEX `idx_tenants_deleted_at` ON `tenants` (`deleted_at`);
+INSERT INTO `tenants` (`id`, `created_at`, `updated_at`, `name`, `description`) VALUES (1, NOW(), NOW(), 'default', '默认租户');
+
+-- 用户租户多对多关联表, 只记录默认租户以外的租户
+CREATE TABLE `user_tenants` (
+  `tenant_id` BIGINT UNSIGNED NOT NULL,
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (`tenant_id`, `user_id`),
+  KEY `idx_user_tenants_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 角色表, 角色名称在租户内唯一
 CREATE TABLE `roles` (
   `id` BIGINT UNSIGNED PRIMARY KEY auto_increment,
   `created_at` DATETIME NOT NULL,
   `updated_at` DATETIME NOT NULL,
   `deleted_at` DATETIME,
+  `tenant_id` BIGINT UNSIGNED NOT NULL DEFAULT 1,
   `name` VARCHAR(50) NOT NULL,
   `description` VARCHAR(255)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 CREATE INDEX `idx_roles_deleted_at` ON `roles` (`deleted_at`);
+CREATE INDEX `idx_roles_tenant_id` ON `roles` (`tenant_id`);
 
 -- 用户角色多对多关联表
 CREATE TABLE `user_roles` (
@@ -33,6 +56,14 @@ CREATE TABLE `user_roles` (
   PRIMARY KEY (`user_id`, `role_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+-- 角色继承关联表, 子角色拥有父角色的全部接口权限
+CREATE TABLE `role_parents` (
+  `role_id` BIGINT UNSIGNED NOT NULL,
+  `parent_id` BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (`role_id`, `parent_id`),
+  KEY `idx_role_parents_parent_id` (`parent_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
 -- 接口信息表
 CREATE TABLE `apis` (
   `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
@@ -43,6 +74,7 @@ CREATE TABLE `apis` (
   `path` VARCHAR(255) NOT NULL,
   `method` VARCHAR(10) NOT NULL,
   `description` TEXT,
+  `effect` VARCHAR(10) NOT NULL DEFAULT 'allow' comment '分配给角色时的效果, allow 或 deny',
   INDEX `idx_deleted_at` (`deleted_at`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 CREATE INDEX `idx_apis_deleted_at` ON `apis` (`deleted_at`);
@@ -54,17 +86,25 @@ CREATE TABLE `role_apis` (
   PRIMARY KEY (`role_id`, `api_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+-- 角色禁止访问的接口多对多关联表
+CREATE TABLE `role_deny_apis` (
+  `role_id` BIGINT UNSIGNED NOT NULL,
+  `api_id` BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (`role_id`, `api_id`),
+  KEY `idx_role_deny_apis_api_id` (`api_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
 -- casbin 规则表
 CREATE TABLE `casbin_rule`
 (
     id    bigint unsigned primary key auto_increment,
     ptype varchar(100) null COMMENT "p or g",
     v0    varchar(100) null COMMENT "subject",
-    v1    varchar(100) null COMMENT "object",
-    v2    varchar(100) null COMMENT "action",
-    v3    varchar(100) null COMMENT "domain",
-    v4    varchar(100) null COMMENT "resource",
-    v5    varchar(100) null COMMENT "effect",
+    v1    varchar(100) null COMMENT "domain",
+    v2    varchar(100) null COMMENT "object",
+    v3    varchar(100) null COMMENT "action",
+    v4    varchar(100) null COMMENT "effect",
+    v5    varchar(100) null COMMENT "unused, reserved by casbin adapter",
     constraint idx_casbin_rule
         unique (ptype, v0, v1, v2, v3, v4, v5)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -93,4 +133,122 @@ CREATE TABLE `feishu_users`
     user_id          varchar(255)      null comment '飞书用户ID'
 );
 
-CREATE INDEX `idx_feishu_users_deleted_at` ON `feishu_users` (`deleted_at`);
\ No newline at end of file
+CREATE INDEX `idx_feishu_users_deleted_at` ON `feishu_users` (`deleted_at`);
+-- jwt 非对称签名密钥
+CREATE TABLE `jwt_keys`
+(
+    id          bigint unsigned primary key auto_increment,
+    created_at  datetime(3)  null,
+    updated_at  datetime(3)  null,
+    kid         varchar(64)  not null comment '密钥ID',
+    algorithm   varchar(16)  not null comment '签名算法',
+    private_key text         not null comment '加密后的私钥',
+    public_key  text         not null comment 'PEM格式公钥',
+    constraint idx_jwt_keys_kid
+        unique (kid)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 个人访问令牌
+CREATE TABLE `personal_access_tokens`
+(
+    id           bigint unsigned primary key auto_increment,
+    created_at   datetime(3)  null,
+    updated_at   datetime(3)  null,
+    deleted_at   datetime(3)  null,
+    user_id      bigint       not null comment '所属用户id',
+    name         varchar(100) not null comment '令牌名称',
+    token_prefix varchar(16)  not null comment '令牌前缀,用于识别令牌',
+    token_hash   varchar(64)  not null comment '令牌sha256哈希',
+    expires_at   datetime(3)  not null comment '过期时间',
+    last_used_at datetime(3)  null comment '最后使用时间',
+    restricted   tinyint(1)   not null default 0 comment '是否限定角色',
+    constraint idx_personal_access_tokens_token_hash
+        unique (token_hash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE INDEX `idx_personal_access_tokens_user_id` ON `personal_access_tokens` (`user_id`);
+CREATE INDEX `idx_personal_access_tokens_deleted_at` ON `personal_access_tokens` (`deleted_at`);
+
+-- 个人访问令牌可使用的角色
+CREATE TABLE `personal_access_token_roles`
+(
+    personal_access_token_id bigint unsigned not null,
+    role_id                  bigint unsigned not null,
+    PRIMARY KEY (`personal_access_token_id`, `role_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 用户两步验证
+CREATE TABLE `user_mfas`
+(
+    id             bigint unsigned primary key auto_increment,
+    created_at     datetime(3)  null,
+    updated_at     datetime(3)  null,
+    user_id        bigint       not null comment '用户id',
+    secret         varchar(255) not null comment '加密后的TOTP密钥',
+    enabled        tinyint(1)   not null default 0 comment '是否已确认启用',
+    recovery_codes text         null comment '恢复码哈希',
+    last_used_step bigint       not null default 0 comment '最后使用的TOTP周期',
+    constraint idx_user_mfas_user_id
+        unique (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 历史密码
+CREATE TABLE `password_histories`
+(
+    id         bigint unsigned primary key auto_increment,
+    created_at datetime(3)  null,
+    user_id    bigint       not null comment '用户id',
+    password   varchar(255) not null comment '密码哈希',
+    index idx_password_histories_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 审计日志
+CREATE TABLE `audit_logs`
+(
+    id          bigint unsigned primary key auto_increment,
+    created_at  datetime(3)   null,
+    actor_id    bigint        not null default 0 comment '操作人id',
+    actor_name  varchar(50)   not null default '' comment '操作人名称',
+    request_id  varchar(64)   not null default '' comment '请求id',
+    action      varchar(64)   not null comment '操作',
+    target_type varchar(32)   not null comment '操作对象类型',
+    target_id   varchar(64)   not null default '' comment '操作对象id',
+    diff        text          null comment '变更内容',
+    ip          varchar(64)   not null default '' comment '客户端IP',
+    result      varchar(16)   not null comment '操作结果',
+    error       varchar(1024) not null default '' comment '失败原因',
+    index idx_audit_logs_created_at (created_at),
+    index idx_audit_logs_actor_id (actor_id),
+    index idx_audit_logs_action (action),
+    index idx_audit_logs_target (target_type, target_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `login_events`
+(
+    id         bigint unsigned primary key auto_increment,
+    created_at datetime(3)  null,
+    user_id    bigint       not null default 0 comment '用户id',
+    account    varchar(100) not null default '' comment '登录账号',
+    event      varchar(32)  not null comment '事件',
+    provider   varchar(50)  not null default '' comment '认证方式',
+    result     varchar(16)  not null comment '结果',
+    reason     varchar(255) not null default '' comment '失败原因或说明',
+    ip         varchar(64)  not null default '' comment '客户端IP',
+    user_agent varchar(255) not null default '' comment '客户端User-Agent',
+    request_id varchar(64)  not null default '' comment '请求id',
+    index idx_login_events_created_at (created_at),
+    index idx_login_events_user_id (user_id),
+    index idx_login_events_event (event)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `user_identities`
+(
+    id         bigint unsigned primary key auto_increment,
+    user_id    bigint       not null comment '用户id',
+    provider   varchar(50)  not null comment 'provider名称',
+    subject    varchar(255) not null comment 'provider用户标识',
+    profile    text         null comment 'provider用户信息',
+    linked_at  datetime(3)  null comment '关联时间',
+    updated_at datetime(3)  null,
+    unique index idx_user_identities_provider_subject (provider, subject),
+    index idx_user_identities_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
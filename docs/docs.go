@@ -15,9 +15,29 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "获取 JWT 验签公钥 (RFC 7517), 返回标准 JWKS 格式, 不使用统一响应结构",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "认证"
+                ],
+                "summary": "验签公钥",
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "$ref": "#/definitions/jwt.JSONWebKeySet"
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/api": {
             "post": {
-                "description": "创建 API",
+                "description": "创建 API, effect 为分配给角色时的效果, 默认为 allow",
                 "consumes": [
                     "application/json"
                 ],
@@ -89,11 +109,14 @@ const docTemplate = `{
                         "in": "query"
                     },
                     {
+                        "minimum": 1,
                         "type": "integer",
                         "name": "page",
                         "in": "query"
                     },
                     {
+                        "maximum": 100,
+                        "minimum": 1,
                         "type": "integer",
                         "name": "pageSize",
                         "in": "query"
@@ -284,6 +307,156 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/v1/audit": {
+            "get": {
+                "description": "分页查询管理操作的审计日志, 支持按操作人、操作、操作对象、结果和时间过滤",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "审计日志"
+                ],
+                "summary": "审计日志列表",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "name": "actorId",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "name": "direction",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "name": "endTime",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "success",
+                            "failure"
+                        ],
+                        "type": "string",
+                        "name": "result",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "StartTime EndTime 按操作时间过滤, RFC3339 格式",
+                        "name": "startTime",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "name": "targetId",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "user",
+                            "role",
+                            "api"
+                        ],
+                        "type": "string",
+                        "name": "targetType",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/apitypes.AuditListResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/authz/check": {
+            "post": {
+                "description": "检查用户或角色在当前租户能否调用接口, 返回参与检查的角色和决定结果的策略, 没有匹配的策略时默认拒绝; 检查用户时不考虑个人访问令牌限定的角色和两步验证的要求",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "权限检查"
+                ],
+                "summary": "权限检查",
+                "parameters": [
+                    {
+                        "description": "检查请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.AuthzCheckRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "检查成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/apitypes.AuthzCheckResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/oauth2/:id": {
             "post": {
                 "description": "使用 OAuth2 激活，返回用户信息和 Token",
@@ -436,7 +609,7 @@ const docTemplate = `{
         },
         "/api/v1/role": {
             "post": {
-                "description": "创建角色",
+                "description": "创建角色, denyApis 中的接口以 deny 写入策略, 优先于 allow 的权限",
                 "consumes": [
                     "application/json"
                 ],
@@ -497,11 +670,14 @@ const docTemplate = `{
                         "in": "query"
                     },
                     {
+                        "minimum": 1,
                         "type": "integer",
                         "name": "page",
                         "in": "query"
                     },
                     {
+                        "maximum": 100,
+                        "minimum": 1,
                         "type": "integer",
                         "name": "pageSize",
                         "in": "query"
@@ -586,7 +762,7 @@ const docTemplate = `{
                 }
             },
             "put": {
-                "description": "更新角色, 并且可以更新角色的权限",
+                "description": "更新角色, 并且可以更新角色的权限、禁止访问的接口和继承的角色, denyApis 和 parentRoles 不传时不修改",
                 "consumes": [
                     "application/json"
                 ],
@@ -618,7 +794,7 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "删除角色, 不能删除有用户的角色",
+                "description": "删除角色, 不能删除有用户或被其他角色继承的角色",
                 "consumes": [
                     "application/json"
                 ],
@@ -650,25 +826,58 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/user/": {
+        "/api/v1/role/{id}/effective-apis": {
             "get": {
-                "description": "使用分页查询用户的信息, 支持根据 name, email, mobile, department 查询",
-                "consumes": [
+                "description": "查询角色直接拥有和从父角色继承的全部接口, effect 为最终的效果, grantedBy 和 deniedBy 为允许和禁止该接口的角色",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "角色管理"
+                ],
+                "summary": "角色的有效权限",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "角色id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/apitypes.RoleEffectiveApisResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tenant": {
+            "get": {
+                "description": "使用分页查询租户, 支持根据 name 查询",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "用户管理"
+                    "租户管理"
                 ],
-                "summary": "用户列表",
+                "summary": "租户列表",
                 "parameters": [
-                    {
-                        "type": "string",
-                        "name": "department",
-                        "in": "query"
-                    },
                     {
                         "enum": [
                             "asc",
@@ -678,27 +887,20 @@ const docTemplate = `{
                         "name": "direction",
                         "in": "query"
                     },
-                    {
-                        "type": "string",
-                        "name": "email",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "name": "mobile",
-                        "in": "query"
-                    },
                     {
                         "type": "string",
                         "name": "name",
                         "in": "query"
                     },
                     {
+                        "minimum": 1,
                         "type": "integer",
                         "name": "page",
                         "in": "query"
                     },
                     {
+                        "maximum": 100,
+                        "minimum": 1,
                         "type": "integer",
                         "name": "pageSize",
                         "in": "query"
@@ -708,29 +910,16 @@ const docTemplate = `{
                             "id",
                             "name",
                             "created_at",
-                            "updated_at",
-                            "nick_name",
-                            "email",
-                            "mobile"
+                            "updated_at"
                         ],
                         "type": "string",
                         "name": "sort",
                         "in": "query"
-                    },
-                    {
-                        "enum": [
-                            0,
-                            1,
-                            2
-                        ],
-                        "type": "integer",
-                        "name": "status",
-                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "登录成功",
+                        "description": "查询成功",
                         "schema": {
                             "allOf": [
                                 {
@@ -740,7 +929,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/apitypes.UserListResponse"
+                                            "$ref": "#/definitions/apitypes.TenantListResponse"
                                         }
                                     }
                                 }
@@ -748,11 +937,9 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/api/v1/user/:id": {
-            "get": {
-                "description": "使用 id 查询用户的信息和用户的角色",
+            },
+            "post": {
+                "description": "创建租户, 创建者加入租户并获得与默认租户 admin 角色权限相同的 admin 角色",
                 "consumes": [
                     "application/json"
                 ],
@@ -760,43 +947,33 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "用户管理"
+                    "租户管理"
                 ],
-                "summary": "用户查询",
+                "summary": "创建租户",
                 "parameters": [
                     {
-                        "description": "查询请求参数",
+                        "description": "创建请求参数",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/apitypes.IDRequest"
+                            "$ref": "#/definitions/apitypes.TenantCreateRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "查询成功",
+                        "description": "创建成功",
                         "schema": {
-                            "allOf": [
-                                {
-                                    "$ref": "#/definitions/apitypes.Response"
-                                },
-                                {
-                                    "type": "object",
-                                    "properties": {
-                                        "data": {
-                                            "$ref": "#/definitions/model.User"
-                                        }
-                                    }
-                                }
-                            ]
+                            "$ref": "#/definitions/apitypes.Response"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/api/v1/tenant/{id}": {
             "put": {
-                "description": "更新用户信息，可以更新角色",
+                "description": "更新租户的名称和描述",
                 "consumes": [
                     "application/json"
                 ],
@@ -804,17 +981,24 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "用户管理"
+                    "租户管理"
                 ],
-                "summary": "用户更新",
+                "summary": "更新租户",
                 "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "租户id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
                     {
                         "description": "更新请求参数",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/apitypes.UserUpdateAdminRequest"
+                            "$ref": "#/definitions/apitypes.TenantUpdateRequest"
                         }
                     }
                 ],
@@ -826,9 +1010,11 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "删除用户，只能管理员删除",
+            }
+        },
+        "/api/v1/tenant/{id}/users": {
+            "post": {
+                "description": "将用户加入租户, 所有用户都属于默认租户, 不能修改默认租户的用户",
                 "consumes": [
                     "application/json"
                 ],
@@ -836,23 +1022,30 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "用户管理"
+                    "租户管理"
                 ],
-                "summary": "用户删除",
+                "summary": "租户添加用户",
                 "parameters": [
                     {
-                        "description": "删除请求参数",
-                        "name": "data",
-                        "in": "body",
+                        "type": "integer",
+                        "description": "租户id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "用户id",
+                        "name": "data",
+                        "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/apitypes.IDRequest"
+                            "$ref": "#/definitions/apitypes.TenantAddUsersRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "删除成功",
+                        "description": "添加成功",
                         "schema": {
                             "$ref": "#/definitions/apitypes.Response"
                         }
@@ -860,9 +1053,45 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/user/info": {
+        "/api/v1/tenant/{id}/users/{userId}": {
+            "delete": {
+                "description": "将用户移出租户, 同时删除用户在该租户中的角色",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "租户管理"
+                ],
+                "summary": "租户移除用户",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "租户id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "用户id",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "移除成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/": {
             "get": {
-                "description": "使用 id 查询用户的信息和用户的角色",
+                "description": "使用分页查询用户的信息, 支持根据 name, email, mobile, department 查询",
                 "consumes": [
                     "application/json"
                 ],
@@ -872,10 +1101,78 @@ const docTemplate = `{
                 "tags": [
                     "用户管理"
                 ],
-                "summary": "用户获取自己的信息",
+                "summary": "用户列表",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "department",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "name": "direction",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "name": "mobile",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "name",
+                            "created_at",
+                            "updated_at",
+                            "nick_name",
+                            "email",
+                            "mobile"
+                        ],
+                        "type": "string",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1,
+                            2
+                        ],
+                        "type": "integer",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
-                        "description": "查询成功",
+                        "description": "登录成功",
                         "schema": {
                             "allOf": [
                                 {
@@ -885,7 +1182,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/model.User"
+                                            "$ref": "#/definitions/apitypes.UserListResponse"
                                         }
                                     }
                                 }
@@ -895,9 +1192,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/user/logout": {
-            "post": {
-                "description": "用户注销，清空 Token",
+        "/api/v1/user/:id": {
+            "get": {
+                "description": "使用 id 查询用户的信息和用户的角色",
                 "consumes": [
                     "application/json"
                 ],
@@ -907,20 +1204,41 @@ const docTemplate = `{
                 "tags": [
                     "用户管理"
                 ],
-                "summary": "用户注销",
+                "summary": "用户查询",
+                "parameters": [
+                    {
+                        "description": "查询请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.IDRequest"
+                        }
+                    }
+                ],
                 "responses": {
                     "200": {
-                        "description": "注销成功",
+                        "description": "查询成功",
                         "schema": {
-                            "$ref": "#/definitions/apitypes.Response"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.User"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 }
-            }
-        },
-        "/api/v1/user/register": {
-            "post": {
-                "description": "创建用户同时可以设置角色",
+            },
+            "put": {
+                "description": "更新用户信息，可以更新角色",
                 "consumes": [
                     "application/json"
                 ],
@@ -930,31 +1248,29 @@ const docTemplate = `{
                 "tags": [
                     "用户管理"
                 ],
-                "summary": "用户创建",
+                "summary": "用户更新",
                 "parameters": [
                     {
-                        "description": "创建请求参数",
+                        "description": "更新请求参数",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/apitypes.UserCreateRequest"
+                            "$ref": "#/definitions/apitypes.UserUpdateAdminRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "创建成功",
+                        "description": "更新成功",
                         "schema": {
                             "$ref": "#/definitions/apitypes.Response"
                         }
                     }
                 }
-            }
-        },
-        "/api/v1/user/self": {
-            "put": {
-                "description": "更新用户信息，不能更新角色",
+            },
+            "delete": {
+                "description": "删除用户，只能管理员删除",
                 "consumes": [
                     "application/json"
                 ],
@@ -964,21 +1280,21 @@ const docTemplate = `{
                 "tags": [
                     "用户管理"
                 ],
-                "summary": "用户更新自己的信息",
+                "summary": "用户删除",
                 "parameters": [
                     {
-                        "description": "更新请求参数",
+                        "description": "删除请求参数",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/apitypes.UserUpdateSelfRequest"
+                            "$ref": "#/definitions/apitypes.IDRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "更新成功",
+                        "description": "删除成功",
                         "schema": {
                             "$ref": "#/definitions/apitypes.Response"
                         }
@@ -986,9 +1302,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users/login": {
-            "post": {
-                "description": "使用邮箱和密码登录，返回用户信息和 Token",
+        "/api/v1/user/:id/login-history": {
+            "get": {
+                "description": "管理员分页查询指定用户的登录和认证事件, 包括登录成功失败、OAuth2 登录、激活、登出和授权失败",
                 "consumes": [
                     "application/json"
                 ],
@@ -996,23 +1312,67 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "用户管理"
+                    "登录历史"
                 ],
-                "summary": "用户登录",
+                "summary": "用户登录历史",
                 "parameters": [
                     {
-                        "description": "登录请求参数",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/apitypes.UserLoginRequest"
-                        }
+                        "type": "integer",
+                        "description": "用户id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "name": "endTime",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "login",
+                            "login_mfa",
+                            "oauth2_callback",
+                            "oauth2_activate",
+                            "logout",
+                            "authz_denied"
+                        ],
+                        "type": "string",
+                        "name": "event",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "success",
+                            "failure"
+                        ],
+                        "type": "string",
+                        "name": "result",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "StartTime EndTime 按事件时间过滤, RFC3339 格式",
+                        "name": "startTime",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "登录成功",
+                        "description": "查询成功",
                         "schema": {
                             "allOf": [
                                 {
@@ -1022,7 +1382,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/apitypes.UserLoginResponse"
+                                            "$ref": "#/definitions/apitypes.LoginHistoryResponse"
                                         }
                                     }
                                 }
@@ -1031,472 +1391,3192 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "apitypes.ApiCreateRequest": {
-            "type": "object",
-            "required": [
-                "method",
-                "name",
-                "path"
-            ],
-            "properties": {
-                "description": {
-                    "type": "string"
-                },
-                "method": {
-                    "type": "string",
-                    "enum": [
-                        "GET",
-                        "POST",
-                        "PUT",
-                        "DELETE",
-                        "*"
-                    ]
-                },
-                "name": {
-                    "type": "string"
-                },
-                "path": {
-                    "type": "string"
-                }
-            }
         },
-        "apitypes.ApiInfo": {
-            "type": "object",
+        "/api/v1/user/:id/mfa": {
+            "delete": {
+                "description": "删除用户的两步验证配置, 用户丢失验证器和恢复码时使用, 只能管理员操作",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "两步验证"
+                ],
+                "summary": "重置两步验证",
+                "parameters": [
+                    {
+                        "description": "重置请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.IDRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "重置成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/:id/revoke": {
+            "post": {
+                "description": "吊销用户所有已签发的 Token, 用户需要重新登录, 只能管理员操作",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "吊销用户 Token",
+                "parameters": [
+                    {
+                        "description": "吊销请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.IDRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "吊销成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/:id/sessions": {
+            "get": {
+                "description": "管理员查询指定用户的登录会话",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "登录会话"
+                ],
+                "summary": "用户登录会话列表",
+                "parameters": [
+                    {
+                        "description": "查询请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.IDRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/session.Session"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/:id/sessions/:sid": {
+            "delete": {
+                "description": "管理员注销指定用户的指定会话",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "登录会话"
+                ],
+                "summary": "注销用户登录会话",
+                "parameters": [
+                    {
+                        "description": "注销请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.UserSessionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "注销成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/:id/unlock": {
+            "post": {
+                "description": "解除用户因登录失败次数过多导致的临时锁定, 只能管理员操作",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "解锁用户",
+                "parameters": [
+                    {
+                        "description": "解锁请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.IDRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "解锁成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/identities": {
+            "get": {
+                "description": "查询当前用户关联的外部身份",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "外部身份"
+                ],
+                "summary": "外部身份列表",
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/model.UserIdentity"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/identities/:id": {
+            "delete": {
+                "description": "解除当前用户的外部身份关联, 没有设置密码时不能解除最后一个身份",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "外部身份"
+                ],
+                "summary": "解除外部身份关联",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "外部身份id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "解除成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/identities/callback": {
+            "post": {
+                "description": "使用授权回调的 code 和 state 将外部身份关联到当前用户",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "外部身份"
+                ],
+                "summary": "关联外部身份回调",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "关联成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.UserIdentity"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/identities/link": {
+            "post": {
+                "description": "返回 provider 的授权地址, 前端跳转授权后使用回调参数调用关联回调接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "外部身份"
+                ],
+                "summary": "关联外部身份",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "provider",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "获取成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/apitypes.IdentityLinkResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/info": {
+            "get": {
+                "description": "使用 id 查询用户的信息和用户的角色",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "用户获取自己的信息",
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.User"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/login/mfa": {
+            "post": {
+                "description": "启用两步验证的用户登录后返回 mfaToken, 使用 mfaToken 和验证器生成的验证码或恢复码完成登录",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "两步验证登录",
+                "parameters": [
+                    {
+                        "description": "登录请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.UserLoginMfaRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "登录成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/apitypes.UserLoginResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/logout": {
+            "post": {
+                "description": "用户注销，清空 Token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "用户注销",
+                "responses": {
+                    "200": {
+                        "description": "注销成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/mfa/confirm": {
+            "post": {
+                "description": "使用验证器生成的第一个验证码确认启用两步验证, 返回一次性恢复码",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "两步验证"
+                ],
+                "summary": "启用两步验证",
+                "parameters": [
+                    {
+                        "description": "确认请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.MfaConfirmRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "启用成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/apitypes.MfaConfirmResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/mfa/enroll": {
+            "post": {
+                "description": "为当前用户生成 TOTP 密钥, 返回密钥和 otpauth URI, 需要使用验证码确认后才会启用",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "两步验证"
+                ],
+                "summary": "绑定两步验证",
+                "responses": {
+                    "200": {
+                        "description": "绑定成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/apitypes.MfaEnrollResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/password/forgot": {
+            "post": {
+                "description": "向用户邮箱发送一次性的密码重置链接, 无论邮箱是否存在都返回成功",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "忘记密码",
+                "parameters": [
+                    {
+                        "description": "请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.PasswordForgotRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "发送成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/password/reset": {
+            "post": {
+                "description": "使用邮件中的重置 token 设置新密码, token 只能使用一次, 重置后用户需要重新登录",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "重置密码",
+                "parameters": [
+                    {
+                        "description": "重置请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.PasswordResetRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "重置成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/permissions": {
+            "get": {
+                "description": "列出当前用户在当前租户可以调用的全部接口, 用于前端隐藏没有权限的菜单",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "权限检查"
+                ],
+                "summary": "当前用户的权限",
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/apitypes.AuthzPermissionsResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/refresh": {
+            "post": {
+                "description": "使用 refresh token 换取新的 access token 和 refresh token, refresh token 只能使用一次, 传 tenantId 时切换到用户所属的其他租户",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "刷新 Token",
+                "parameters": [
+                    {
+                        "description": "刷新请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.UserRefreshTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "刷新成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/apitypes.UserLoginResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/register": {
+            "post": {
+                "description": "创建用户同时可以设置角色",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "用户创建",
+                "parameters": [
+                    {
+                        "description": "创建请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.UserCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "创建成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/self": {
+            "put": {
+                "description": "更新用户信息，不能更新角色",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "用户更新自己的信息",
+                "parameters": [
+                    {
+                        "description": "更新请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.UserUpdateSelfRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "更新成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/self/login-history": {
+            "get": {
+                "description": "分页查询当前用户的登录和认证事件, 用于发现不是本人的登录",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "登录历史"
+                ],
+                "summary": "我的登录历史",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "endTime",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "login",
+                            "login_mfa",
+                            "oauth2_callback",
+                            "oauth2_activate",
+                            "logout",
+                            "authz_denied"
+                        ],
+                        "type": "string",
+                        "name": "event",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "success",
+                            "failure"
+                        ],
+                        "type": "string",
+                        "name": "result",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "StartTime EndTime 按事件时间过滤, RFC3339 格式",
+                        "name": "startTime",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/apitypes.LoginHistoryResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/sessions": {
+            "get": {
+                "description": "查询当前用户的登录会话, current 为 true 的是发起请求的会话",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "登录会话"
+                ],
+                "summary": "登录会话列表",
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/session.Session"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/sessions/:id": {
+            "delete": {
+                "description": "注销当前用户的指定会话, 该会话的 access token 和 refresh token 立即失效",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "登录会话"
+                ],
+                "summary": "注销登录会话",
+                "parameters": [
+                    {
+                        "description": "注销请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.SessionIDRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "注销成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/tenants": {
+            "get": {
+                "description": "查询当前用户所属的租户, 所有用户都属于默认租户, 可以通过刷新 token 切换租户",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "用户所属的租户",
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/model.Tenant"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/tokens": {
+            "get": {
+                "description": "分页查询当前用户的个人访问令牌",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "个人访问令牌"
+                ],
+                "summary": "个人访问令牌列表",
+                "parameters": [
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/apitypes.AccessTokenListResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "为当前用户创建个人访问令牌, 供 CI、机器人等客户端使用, 令牌明文只在创建时返回一次",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "个人访问令牌"
+                ],
+                "summary": "创建个人访问令牌",
+                "parameters": [
+                    {
+                        "description": "创建请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.AccessTokenCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "创建成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/apitypes.AccessTokenCreateResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/tokens/:id": {
+            "delete": {
+                "description": "删除当前用户的个人访问令牌, 删除后令牌立即失效",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "个人访问令牌"
+                ],
+                "summary": "吊销个人访问令牌",
+                "parameters": [
+                    {
+                        "description": "删除请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.IDRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "删除成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/tokens/{id}": {
+            "put": {
+                "description": "修改当前用户的个人访问令牌的名称、有效期或限定的角色, 有效期从修改时开始计算, 不能使用个人访问令牌调用",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "个人访问令牌"
+                ],
+                "summary": "修改个人访问令牌",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "令牌id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "修改请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.AccessTokenUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "修改成功",
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/login": {
+            "post": {
+                "description": "使用邮箱和密码登录，返回用户信息和 Token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "用户登录",
+                "parameters": [
+                    {
+                        "description": "登录请求参数",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apitypes.UserLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "登录成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/apitypes.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/apitypes.UserLoginResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/scim/v2/Groups": {
+            "get": {
+                "description": "组对应本地角色, 支持 filter、startIndex、count 和 excludedAttributes=members 参数",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "SCIM"
+                ],
+                "summary": "SCIM 组列表",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "count",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "name": "excludedAttributes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "name": "startIndex",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/scim.ListResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "Resources": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/scim.Group"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "创建没有接口权限的角色并添加成员",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "SCIM"
+                ],
+                "summary": "创建 SCIM 组",
+                "parameters": [
+                    {
+                        "description": "组",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/scim.Group"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "创建成功",
+                        "schema": {
+                            "$ref": "#/definitions/scim.Group"
+                        }
+                    }
+                }
+            }
+        },
+        "/scim/v2/Groups/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "SCIM"
+                ],
+                "summary": "查询 SCIM 组",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "角色id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "$ref": "#/definitions/scim.Group"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "修改角色名称和成员, 刷新 casbin 策略和成员的角色缓存",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "SCIM"
+                ],
+                "summary": "替换 SCIM 组",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "角色id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "组",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/scim.Group"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "修改成功",
+                        "schema": {
+                            "$ref": "#/definitions/scim.Group"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "移除成员的角色后删除角色",
+                "tags": [
+                    "SCIM"
+                ],
+                "summary": "删除 SCIM 组",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "角色id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "删除成功"
+                    }
+                }
+            },
+            "patch": {
+                "description": "支持 add、replace 和 remove 操作, 如 {\"op\":\"remove\",\"path\":\"members[value eq \\\"2\\\"]\"}",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "SCIM"
+                ],
+                "summary": "修改 SCIM 组",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "角色id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "修改操作",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/scim.PatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "修改成功",
+                        "schema": {
+                            "$ref": "#/definitions/scim.Group"
+                        }
+                    }
+                }
+            }
+        },
+        "/scim/v2/ServiceProviderConfig": {
+            "get": {
+                "description": "返回支持的 SCIM 功能, 使用 scim.token 认证",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "SCIM"
+                ],
+                "summary": "SCIM 服务端配置",
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "$ref": "#/definitions/scim.ServiceProviderConfig"
+                        }
+                    }
+                }
+            }
+        },
+        "/scim/v2/Users": {
+            "get": {
+                "description": "支持 filter、startIndex 和 count 参数",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "SCIM"
+                ],
+                "summary": "SCIM 用户列表",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "count",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "name": "excludedAttributes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "name": "startIndex",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/scim.ListResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "Resources": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/scim.User"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "创建没有本地密码的用户, userName、邮箱或 externalId 重复时返回 409",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "SCIM"
+                ],
+                "summary": "创建 SCIM 用户",
+                "parameters": [
+                    {
+                        "description": "用户",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/scim.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "创建成功",
+                        "schema": {
+                            "$ref": "#/definitions/scim.User"
+                        }
+                    }
+                }
+            }
+        },
+        "/scim/v2/Users/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "SCIM"
+                ],
+                "summary": "查询 SCIM 用户",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "用户id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "查询成功",
+                        "schema": {
+                            "$ref": "#/definitions/scim.User"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "active 为 false 时禁用用户并吊销其 token 和会话",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "SCIM"
+                ],
+                "summary": "替换 SCIM 用户",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "用户id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "用户",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/scim.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "修改成功",
+                        "schema": {
+                            "$ref": "#/definitions/scim.User"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "tags": [
+                    "SCIM"
+                ],
+                "summary": "删除 SCIM 用户",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "用户id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "删除成功"
+                    }
+                }
+            },
+            "patch": {
+                "description": "支持 add、replace 和 remove 操作",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "SCIM"
+                ],
+                "summary": "修改 SCIM 用户",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "用户id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "修改操作",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/scim.PatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "修改成功",
+                        "schema": {
+                            "$ref": "#/definitions/scim.User"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "apitypes.AccessTokenCreateRequest": {
+            "type": "object",
+            "required": [
+                "expireDays",
+                "name"
+            ],
+            "properties": {
+                "expireDays": {
+                    "description": "ExpireDays 有效天数",
+                    "type": "integer",
+                    "maximum": 365,
+                    "minimum": 1
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "rolesID": {
+                    "description": "RolesID 令牌可使用的角色, 必须是当前用户拥有的角色, 为空时使用用户的全部角色",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "apitypes.AccessTokenCreateResponse": {
+            "type": "object",
+            "properties": {
+                "accessToken": {
+                    "$ref": "#/definitions/model.PersonalAccessToken"
+                },
+                "token": {
+                    "description": "Token 令牌明文, 只在创建时返回一次",
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.AccessTokenListResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.PersonalAccessToken"
+                    }
+                },
+                "page": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "pageSize": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apitypes.AccessTokenUpdateRequest": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "expireDays": {
+                    "description": "ExpireDays 从现在开始的有效天数, 为 0 时不修改",
+                    "type": "integer",
+                    "maximum": 365,
+                    "minimum": 1
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "Name 为空时不修改",
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "rolesID": {
+                    "description": "RolesID 为 nil 时不修改, 必须是当前用户拥有的角色, 为空数组时使用用户的全部角色",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "apitypes.ApiCreateRequest": {
+            "type": "object",
+            "required": [
+                "method",
+                "name",
+                "path"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "effect": {
+                    "description": "Effect 分配给角色时的效果, 默认为 allow, 创建后不能修改",
+                    "type": "string",
+                    "enum": [
+                        "allow",
+                        "deny"
+                    ]
+                },
+                "method": {
+                    "type": "string",
+                    "enum": [
+                        "GET",
+                        "POST",
+                        "PUT",
+                        "DELETE",
+                        "*"
+                    ]
+                },
+                "name": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.ApiInfo": {
+            "type": "object",
+            "properties": {
+                "handler": {
+                    "type": "string"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.ApiListResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Api"
+                    }
+                },
+                "page": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "pageSize": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apitypes.ApiUpdateRequest": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apitypes.AuditListResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.AuditLog"
+                    }
+                },
+                "page": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "pageSize": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apitypes.AuthzCheckRequest": {
+            "type": "object",
+            "required": [
+                "method",
+                "path"
+            ],
+            "properties": {
+                "method": {
+                    "type": "string",
+                    "enum": [
+                        "GET",
+                        "POST",
+                        "PUT",
+                        "PATCH",
+                        "DELETE"
+                    ]
+                },
+                "path": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apitypes.AuthzCheckResponse": {
+            "type": "object",
+            "properties": {
+                "allowed": {
+                    "type": "boolean"
+                },
+                "policy": {
+                    "description": "Policy 决定结果的策略 (角色, 租户, 路径, 方法, 效果), 没有匹配的策略时为空, 默认拒绝",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "roles": {
+                    "description": "Roles 参与检查的角色, 父角色由 casbin 按继承关系检查",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "apitypes.AuthzPermission": {
+            "type": "object",
+            "properties": {
+                "method": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.AuthzPermissionsResponse": {
+            "type": "object",
+            "properties": {
+                "apis": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apitypes.AuthzPermission"
+                    }
+                },
+                "roles": {
+                    "description": "Roles 当前 token 在当前租户可以使用的角色",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "apitypes.IDRequest": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apitypes.IdentityLinkResponse": {
+            "type": "object",
+            "properties": {
+                "url": {
+                    "description": "URL provider 授权页面地址, 前端跳转到该地址完成授权",
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.LoginHistoryResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.LoginEvent"
+                    }
+                },
+                "page": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "pageSize": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apitypes.MfaConfirmRequest": {
+            "type": "object",
+            "required": [
+                "code"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.MfaConfirmResponse": {
+            "type": "object",
+            "properties": {
+                "recoveryCodes": {
+                    "description": "RecoveryCodes 一次性恢复码, 只在启用时返回一次, 无法使用验证器时代替验证码登录",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "apitypes.MfaEnrollResponse": {
+            "type": "object",
+            "properties": {
+                "secret": {
+                    "description": "Secret base32 编码的 TOTP 密钥, 无法扫码时手动输入",
+                    "type": "string"
+                },
+                "uri": {
+                    "description": "URI otpauth URI, 用于生成二维码",
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.OAuthActivateRequest": {
+            "type": "object",
+            "required": [
+                "confirmPassword",
+                "id",
+                "password"
+            ],
+            "properties": {
+                "confirmPassword": {
+                    "type": "string",
+                    "minLength": 8
+                },
+                "id": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.PasswordForgotRequest": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.PasswordResetRequest": {
+            "type": "object",
+            "required": [
+                "confirmPassword",
+                "password",
+                "token"
+            ],
+            "properties": {
+                "confirmPassword": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.Response": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer"
+                },
+                "data": {},
+                "error": {
+                    "type": "string"
+                },
+                "msg": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.RoleCreateRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "apis": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "denyApis": {
+                    "description": "DenyApis 禁止访问的接口 id, 优先于角色自身和继承的 allow 权限",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "parentRoles": {
+                    "description": "ParentRoles 继承的角色 id",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "apitypes.RoleEffectiveApi": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "deniedBy": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "denyRoles": {
+                    "description": "DenyRoles 通过 denyApis 禁止该接口的角色",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Role"
+                    }
+                },
+                "description": {
+                    "type": "string"
+                },
+                "effect": {
+                    "type": "string"
+                },
+                "grantedBy": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Role"
+                    }
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.RoleEffectiveApisResponse": {
+            "type": "object",
+            "properties": {
+                "apis": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apitypes.RoleEffectiveApi"
+                    }
+                },
+                "roles": {
+                    "description": "Roles 角色及其直接或间接继承的角色",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "apitypes.RoleListResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Role"
+                    }
+                },
+                "page": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "pageSize": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apitypes.RoleUpdateRequest": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "apis": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "denyApis": {
+                    "description": "DenyApis 禁止访问的接口 id, 不传时不修改, 传空数组时取消",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "parentRoles": {
+                    "description": "ParentRoles 继承的角色 id, 不传时不修改, 传空数组时取消继承",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "apitypes.ServerApiData": {
+            "type": "object",
+            "properties": {
+                "apiInfo": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/apitypes.ApiInfo"
+                        }
+                    }
+                },
+                "apiType": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "apitypes.SessionIDRequest": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "id": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.TenantAddUsersRequest": {
+            "type": "object",
+            "required": [
+                "id",
+                "usersId"
+            ],
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "usersId": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "apitypes.TenantCreateRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.TenantListResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Tenant"
+                    }
+                },
+                "page": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "pageSize": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apitypes.TenantUpdateRequest": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.UserCreateRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "name",
+                "password"
+            ],
+            "properties": {
+                "avatar": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "mobile": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nickName": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "rolesID": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "apitypes.UserListResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.User"
+                    }
+                },
+                "page": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "pageSize": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apitypes.UserLoginMfaRequest": {
+            "type": "object",
+            "required": [
+                "code",
+                "mfaToken"
+            ],
+            "properties": {
+                "code": {
+                    "description": "Code 验证器生成的验证码或恢复码",
+                    "type": "string"
+                },
+                "mfaToken": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.UserLoginRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "password"
+            ],
+            "properties": {
+                "email": {
+                    "description": "Email 本地用户使用邮箱登录, 启用 LDAP 时也可以使用 LDAP 用户名",
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "password": {
+                    "type": "string",
+                    "minLength": 8
+                }
+            }
+        },
+        "apitypes.UserLoginResponse": {
+            "type": "object",
+            "properties": {
+                "expiresIn": {
+                    "description": "ExpiresIn access token 剩余有效期, 单位秒",
+                    "type": "integer"
+                },
+                "mfaRequired": {
+                    "description": "MfaRequired 用户启用了两步验证, 需要使用 MfaToken 和验证码调用 /user/login/mfa 完成登录",
+                    "type": "boolean"
+                },
+                "mfaToken": {
+                    "type": "string"
+                },
+                "refreshToken": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                },
+                "user": {
+                    "$ref": "#/definitions/model.User"
+                }
+            }
+        },
+        "apitypes.UserRefreshTokenRequest": {
+            "type": "object",
+            "required": [
+                "refreshToken"
+            ],
+            "properties": {
+                "refreshToken": {
+                    "type": "string"
+                },
+                "tenantId": {
+                    "description": "TenantID 切换到指定租户, 为空时保持当前租户",
+                    "type": "integer"
+                }
+            }
+        },
+        "apitypes.UserSessionRequest": {
+            "type": "object",
+            "required": [
+                "id",
+                "sessionID"
+            ],
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "sessionID": {
+                    "type": "string"
+                }
+            }
+        },
+        "apitypes.UserUpdateAdminRequest": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "avatar": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "mobile": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nickName": {
+                    "type": "string"
+                },
+                "oldPassword": {
+                    "type": "string",
+                    "minLength": 8
+                },
+                "password": {
+                    "type": "string"
+                },
+                "rolesID": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "status": {
+                    "type": "integer",
+                    "enum": [
+                        1,
+                        2
+                    ]
+                }
+            }
+        },
+        "apitypes.UserUpdateSelfRequest": {
+            "type": "object",
+            "properties": {
+                "avatar": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "mobile": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nickName": {
+                    "type": "string"
+                },
+                "oldPassword": {
+                    "type": "string",
+                    "minLength": 8
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "jwt.JSONWebKey": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "type": "string"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "string"
+                },
+                "y": {
+                    "type": "string"
+                }
+            }
+        },
+        "jwt.JSONWebKeySet": {
+            "type": "object",
             "properties": {
-                "handler": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jwt.JSONWebKey"
+                    }
+                }
+            }
+        },
+        "model.Api": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "denyRoles": {
+                    "description": "DenyRoles 通过 denyApis 禁止该接口的角色",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Role"
+                    }
+                },
+                "description": {
+                    "type": "string"
+                },
+                "effect": {
+                    "description": "Effect 通过 apis 分配给角色时的效果, 为空时为 allow",
                     "type": "string"
                 },
+                "id": {
+                    "type": "integer"
+                },
                 "method": {
                     "type": "string"
                 },
+                "name": {
+                    "type": "string"
+                },
                 "path": {
                     "type": "string"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Role"
+                    }
+                },
+                "updatedAt": {
+                    "type": "string"
                 }
             }
         },
-        "apitypes.ApiListResponse": {
+        "model.AuditChange": {
             "type": "object",
             "properties": {
-                "list": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/model.Api"
+                "after": {},
+                "before": {}
+            }
+        },
+        "model.AuditLog": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "actorId": {
+                    "type": "integer"
+                },
+                "actorName": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "diff": {
+                    "description": "Diff 变更的字段, 创建时只有 after, 删除时只有 before",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/model.AuditChange"
                     }
                 },
-                "page": {
+                "error": {
+                    "type": "string"
+                },
+                "id": {
                     "type": "integer"
                 },
-                "pageSize": {
+                "ip": {
+                    "type": "string"
+                },
+                "requestId": {
+                    "type": "string"
+                },
+                "result": {
+                    "type": "string"
+                },
+                "targetId": {
+                    "type": "string"
+                },
+                "targetType": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.LoginEvent": {
+            "type": "object",
+            "properties": {
+                "account": {
+                    "description": "Account 登录使用的账号, 密码登录为邮箱, 其余为用户名",
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string"
+                },
+                "id": {
                     "type": "integer"
                 },
-                "total": {
+                "ip": {
+                    "type": "string"
+                },
+                "provider": {
+                    "description": "Provider 认证方式, 如 pwd、otp 或 OAuth2 provider 名称",
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "requestId": {
+                    "type": "string"
+                },
+                "result": {
+                    "type": "string"
+                },
+                "userAgent": {
+                    "type": "string"
+                },
+                "userId": {
+                    "description": "UserID 用户不存在时为 0",
                     "type": "integer"
                 }
             }
         },
-        "apitypes.ApiUpdateRequest": {
+        "model.PersonalAccessToken": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "restricted": {
+                    "description": "Restricted 创建时限定了角色, 限定的角色全部删除后令牌不能再使用",
+                    "type": "boolean"
+                },
+                "roles": {
+                    "description": "Roles 令牌可使用的角色, 未限定角色时使用用户的全部角色",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Role"
+                    }
+                },
+                "tokenPrefix": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.Role": {
             "type": "object",
-            "required": [
-                "id"
-            ],
             "properties": {
+                "apis": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Api"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "denyApis": {
+                    "description": "DenyApis 禁止角色访问的接口, 不论接口本身的效果都以 deny 写入策略",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Api"
+                    }
+                },
                 "description": {
                     "type": "string"
                 },
                 "id": {
                     "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "parents": {
+                    "description": "Parents 继承的角色, 拥有父角色的全部接口权限",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Role"
+                    }
+                },
+                "tenantId": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.User"
+                    }
                 }
             }
         },
-        "apitypes.IDRequest": {
+        "model.Tenant": {
             "type": "object",
-            "required": [
-                "id"
-            ],
             "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.User"
+                    }
                 }
             }
         },
-        "apitypes.OAuthActivateRequest": {
+        "model.User": {
             "type": "object",
-            "required": [
-                "confirmPassword",
-                "id",
-                "password"
-            ],
             "properties": {
-                "confirmPassword": {
-                    "type": "string",
-                    "minLength": 8
+                "avatar": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "department": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
                 },
                 "id": {
+                    "type": "integer"
+                },
+                "mobile": {
                     "type": "string"
                 },
-                "password": {
-                    "type": "string",
-                    "minLength": 8
+                "name": {
+                    "type": "string"
+                },
+                "nickName": {
+                    "type": "string"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Role"
+                    }
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "tenants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Tenant"
+                    }
+                },
+                "updatedAt": {
+                    "type": "string"
                 }
             }
         },
-        "apitypes.Response": {
+        "model.UserIdentity": {
             "type": "object",
             "properties": {
-                "code": {
+                "id": {
                     "type": "integer"
                 },
-                "data": {},
-                "msg": {
+                "linkedAt": {
                     "type": "string"
                 },
-                "requestId": {
+                "profile": {
+                    "description": "Profile 最近一次登录时 provider 返回的用户信息",
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "provider": {
+                    "description": "Provider OAuth2 provider 名称, 与配置中的 key 一致",
                     "type": "string"
-                }
-            }
-        },
-        "apitypes.RoleCreateRequest": {
-            "type": "object",
-            "required": [
-                "name"
-            ],
-            "properties": {
-                "apis": {
-                    "type": "array",
-                    "items": {
-                        "type": "integer"
-                    }
                 },
-                "description": {
+                "subject": {
+                    "description": "Subject 用户在 provider 中的唯一标识, OIDC 为 sub, 飞书为 user_id",
                     "type": "string"
                 },
-                "name": {
+                "updatedAt": {
                     "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
                 }
             }
         },
-        "apitypes.RoleListResponse": {
+        "scim.Bulk": {
             "type": "object",
             "properties": {
-                "list": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/model.Role"
-                    }
-                },
-                "page": {
+                "maxOperations": {
                     "type": "integer"
                 },
-                "pageSize": {
+                "maxPayloadSize": {
                     "type": "integer"
                 },
-                "total": {
-                    "type": "integer"
+                "supported": {
+                    "type": "boolean"
                 }
             }
         },
-        "apitypes.RoleUpdateRequest": {
+        "scim.EnterpriseUser": {
             "type": "object",
-            "required": [
-                "id"
-            ],
             "properties": {
-                "apis": {
-                    "type": "array",
-                    "items": {
-                        "type": "integer"
-                    }
+                "department": {
+                    "type": "string"
                 },
-                "description": {
+                "employeeNumber": {
                     "type": "string"
                 },
-                "id": {
-                    "type": "integer"
+                "organization": {
+                    "type": "string"
                 }
             }
         },
-        "apitypes.ServerApiData": {
+        "scim.Filter": {
             "type": "object",
             "properties": {
-                "apiInfo": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "array",
-                        "items": {
-                            "$ref": "#/definitions/apitypes.ApiInfo"
-                        }
-                    }
+                "maxResults": {
+                    "type": "integer"
                 },
-                "apiType": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "supported": {
+                    "type": "boolean"
                 }
             }
         },
-        "apitypes.UserCreateRequest": {
+        "scim.Group": {
             "type": "object",
-            "required": [
-                "email",
-                "name",
-                "password"
-            ],
             "properties": {
-                "avatar": {
+                "displayName": {
                     "type": "string"
                 },
-                "email": {
-                    "type": "string"
-                },
-                "mobile": {
+                "externalId": {
                     "type": "string"
                 },
-                "name": {
+                "id": {
                     "type": "string"
                 },
-                "nickName": {
-                    "type": "string"
+                "members": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/scim.Reference"
+                    }
                 },
-                "password": {
-                    "type": "string",
-                    "minLength": 8
+                "meta": {
+                    "$ref": "#/definitions/scim.Meta"
                 },
-                "rolesID": {
+                "schemas": {
                     "type": "array",
                     "items": {
-                        "type": "integer"
+                        "type": "string"
                     }
                 }
             }
         },
-        "apitypes.UserListResponse": {
+        "scim.ListResponse": {
             "type": "object",
             "properties": {
-                "list": {
+                "Resources": {},
+                "itemsPerPage": {
+                    "type": "integer"
+                },
+                "schemas": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/model.User"
+                        "type": "string"
                     }
                 },
-                "page": {
-                    "type": "integer"
-                },
-                "pageSize": {
+                "startIndex": {
                     "type": "integer"
                 },
-                "total": {
+                "totalResults": {
                     "type": "integer"
                 }
             }
         },
-        "apitypes.UserLoginRequest": {
+        "scim.Meta": {
             "type": "object",
-            "required": [
-                "email",
-                "password"
-            ],
             "properties": {
-                "email": {
+                "created": {
                     "type": "string"
                 },
-                "password": {
-                    "type": "string",
-                    "minLength": 8
+                "lastModified": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "resourceType": {
+                    "type": "string"
                 }
             }
         },
-        "apitypes.UserLoginResponse": {
+        "scim.MultiValue": {
             "type": "object",
             "properties": {
-                "token": {
+                "primary": {
+                    "type": "boolean"
+                },
+                "type": {
                     "type": "string"
                 },
-                "user": {
-                    "$ref": "#/definitions/model.User"
+                "value": {
+                    "type": "string"
                 }
             }
         },
-        "apitypes.UserUpdateAdminRequest": {
+        "scim.Name": {
             "type": "object",
-            "required": [
-                "id"
-            ],
             "properties": {
-                "avatar": {
-                    "type": "string"
-                },
-                "email": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "mobile": {
+                "familyName": {
                     "type": "string"
                 },
-                "name": {
+                "formatted": {
                     "type": "string"
                 },
-                "nickName": {
+                "givenName": {
                     "type": "string"
-                },
-                "oldPassword": {
-                    "type": "string",
-                    "minLength": 8
-                },
-                "password": {
-                    "type": "string",
-                    "minLength": 8
-                },
-                "rolesID": {
-                    "type": "array",
-                    "items": {
-                        "type": "integer"
-                    }
-                },
-                "status": {
-                    "type": "integer",
-                    "enum": [
-                        1,
-                        2
-                    ]
                 }
             }
         },
-        "apitypes.UserUpdateSelfRequest": {
+        "scim.PatchRequest": {
+            "type": "object"
+        },
+        "scim.Reference": {
             "type": "object",
             "properties": {
-                "avatar": {
+                "$ref": {
                     "type": "string"
                 },
-                "email": {
+                "display": {
                     "type": "string"
                 },
-                "mobile": {
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "scim.Scheme": {
+            "type": "object",
+            "properties": {
+                "description": {
                     "type": "string"
                 },
                 "name": {
                     "type": "string"
                 },
-                "nickName": {
+                "type": {
                     "type": "string"
-                },
-                "oldPassword": {
-                    "type": "string",
-                    "minLength": 8
-                },
-                "password": {
-                    "type": "string",
-                    "minLength": 8
                 }
             }
         },
-        "model.Api": {
+        "scim.ServiceProviderConfig": {
             "type": "object",
             "properties": {
-                "createdAt": {
-                    "type": "string"
+                "authenticationSchemes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/scim.Scheme"
+                    }
                 },
-                "description": {
-                    "type": "string"
+                "bulk": {
+                    "$ref": "#/definitions/scim.Bulk"
                 },
-                "id": {
-                    "type": "integer"
+                "changePassword": {
+                    "$ref": "#/definitions/scim.Supported"
                 },
-                "method": {
-                    "type": "string"
+                "etag": {
+                    "$ref": "#/definitions/scim.Supported"
                 },
-                "name": {
-                    "type": "string"
+                "filter": {
+                    "$ref": "#/definitions/scim.Filter"
                 },
-                "path": {
-                    "type": "string"
+                "patch": {
+                    "$ref": "#/definitions/scim.Supported"
                 },
-                "roles": {
+                "schemas": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/model.Role"
+                        "type": "string"
                     }
                 },
-                "updatedAt": {
-                    "type": "string"
+                "sort": {
+                    "$ref": "#/definitions/scim.Supported"
                 }
             }
         },
-        "model.Role": {
+        "scim.Supported": {
             "type": "object",
             "properties": {
-                "apis": {
+                "supported": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "scim.User": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "displayName": {
+                    "type": "string"
+                },
+                "emails": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/model.Api"
+                        "$ref": "#/definitions/scim.MultiValue"
                     }
                 },
-                "createdAt": {
+                "externalId": {
                     "type": "string"
                 },
-                "description": {
-                    "type": "string"
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/scim.Reference"
+                    }
                 },
                 "id": {
-                    "type": "integer"
+                    "type": "string"
+                },
+                "meta": {
+                    "$ref": "#/definitions/scim.Meta"
                 },
                 "name": {
-                    "type": "string"
+                    "$ref": "#/definitions/scim.Name"
                 },
-                "updatedAt": {
-                    "type": "string"
+                "phoneNumbers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/scim.MultiValue"
+                    }
                 },
-                "users": {
+                "photos": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/model.User"
+                        "$ref": "#/definitions/scim.MultiValue"
+                    }
+                },
+                "schemas": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
                     }
+                },
+                "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {
+                    "$ref": "#/definitions/scim.EnterpriseUser"
+                },
+                "userName": {
+                    "type": "string"
                 }
             }
         },
-        "model.User": {
+        "session.Session": {
             "type": "object",
             "properties": {
-                "avatar": {
-                    "type": "string"
+                "authMethods": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
                 "createdAt": {
                     "type": "string"
                 },
-                "department": {
-                    "type": "string"
+                "current": {
+                    "description": "Current 是否为发起请求的会话, 只在列表中返回",
+                    "type": "boolean"
                 },
-                "email": {
+                "expiresAt": {
+                    "description": "ExpiresAt 会话 refresh token 的过期时间, 过期后会话自动删除",
                     "type": "string"
                 },
                 "id": {
-                    "type": "integer"
+                    "description": "ID 会话 id, 即 token 中的 sid",
+                    "type": "string"
                 },
-                "mobile": {
+                "ip": {
                     "type": "string"
                 },
-                "name": {
+                "jti": {
+                    "description": "JTI 会话当前 access token 的 jti, 刷新 token 后更新",
                     "type": "string"
                 },
-                "nickName": {
+                "lastSeenAt": {
                     "type": "string"
                 },
-                "roles": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/model.Role"
-                    }
+                "userAgent": {
+                    "type": "string"
                 },
-                "status": {
+                "userId": {
                     "type": "integer"
-                },
-                "updatedAt": {
-                    "type": "string"
                 }
             }
         }
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PersonalAccessToken 个人访问令牌, 供 CI、机器人等非交互客户端调用接口, 只保存令牌的哈希值
+type PersonalAccessToken struct {
+	ID          int64          `gorm:"column:id;primarykey;autoIncrement" json:"id"`
+	CreatedAt   time.Time      `gorm:"column:created_at" json:"createdAt"`
+	UpdatedAt   time.Time      `gorm:"column:updated_at" json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `gorm:"column:deleted_at;index" json:"-"`
+	UserID      int64          `gorm:"column:user_id;index;comment:所属用户id" json:"userId"`
+	User        *User          `gorm:"foreignKey:UserID;references:ID" json:"-"`
+	Name        string         `gorm:"column:name;size:100;comment:令牌名称" json:"name"`
+	TokenPrefix string         `gorm:"column:token_prefix;size:16;comment:令牌前缀,用于识别令牌" json:"tokenPrefix"`
+	TokenHash   string         `gorm:"column:token_hash;size:64;uniqueIndex;comment:令牌sha256哈希" json:"-"`
+	ExpiresAt   time.Time      `gorm:"column:expires_at;comment:过期时间" json:"expiresAt"`
+	LastUsedAt  *time.Time     `gorm:"column:last_used_at;comment:最后使用时间" json:"lastUsedAt"`
+	// Restricted 创建时限定了角色, 限定的角色全部删除后令牌不能再使用
+	Restricted bool `gorm:"column:restricted;not null;default:false;comment:是否限定角色" json:"restricted"`
+	// Roles 令牌可使用的角色, 未限定角色时使用用户的全部角色
+	Roles []*Role `gorm:"many2many:personal_access_token_roles" json:"roles,omitempty"`
+}
+
+func (*PersonalAccessToken) TableName() string {
+	return "personal_access_tokens"
+}
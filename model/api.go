@@ -7,7 +7,15 @@ import (
 )
 
 const (
-	PreloadApis = "Apis"
+	PreloadApis      = "Apis"
+	PreloadDenyApis  = "DenyApis"
+	PreloadDenyRoles = "DenyRoles"
+)
+
+// 接口权限的效果, 匹配到 deny 的请求即使同时匹配 allow 也会被拒绝
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
 )
 
 type Api struct {
@@ -19,7 +27,19 @@ type Api struct {
 	Path        string         `gorm:"column:path" json:"path,omitempty"`
 	Method      string         `gorm:"column:method" json:"method,omitempty"`
 	Description string         `gorm:"column:description" json:"description,omitempty"`
-	Roles       []*Role        `gorm:"many2many:role_apis" json:"roles,omitempty"`
+	// Effect 通过 apis 分配给角色时的效果, 为空时为 allow
+	Effect string  `gorm:"column:effect;default:allow" json:"effect,omitempty"`
+	Roles  []*Role `gorm:"many2many:role_apis" json:"roles,omitempty"`
+	// DenyRoles 通过 denyApis 禁止该接口的角色
+	DenyRoles []*Role `gorm:"many2many:role_deny_apis" json:"denyRoles,omitempty"`
+}
+
+// PolicyEffect 接口在 casbin 策略中的效果
+func (receiver *Api) PolicyEffect() string {
+	if receiver.Effect == "" {
+		return EffectAllow
+	}
+	return receiver.Effect
 }
 
 func (*Api) TableName() string {
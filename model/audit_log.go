@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+const (
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+)
+
+// AuditChange 审计日志中单个字段的变更
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditLog 管理操作的审计日志
+type AuditLog struct {
+	ID         int64     `gorm:"column:id;primarykey;autoIncrement" json:"id"`
+	CreatedAt  time.Time `gorm:"column:created_at;index" json:"createdAt"`
+	ActorID    int64     `gorm:"column:actor_id;index;comment:操作人id" json:"actorId"`
+	ActorName  string    `gorm:"column:actor_name;size:50;comment:操作人名称" json:"actorName"`
+	RequestID  string    `gorm:"column:request_id;size:64;comment:请求id" json:"requestId"`
+	Action     string    `gorm:"column:action;size:64;index;comment:操作" json:"action"`
+	TargetType string    `gorm:"column:target_type;size:32;index:idx_audit_logs_target;comment:操作对象类型" json:"targetType"`
+	TargetID   string    `gorm:"column:target_id;size:64;index:idx_audit_logs_target;comment:操作对象id" json:"targetId"`
+	// Diff 变更的字段, 创建时只有 after, 删除时只有 before
+	Diff   map[string]*AuditChange `gorm:"column:diff;type:text;serializer:json;comment:变更内容" json:"diff"`
+	IP     string                  `gorm:"column:ip;size:64;comment:客户端IP" json:"ip"`
+	Result string                  `gorm:"column:result;size:16;comment:操作结果" json:"result"`
+	Error  string                  `gorm:"column:error;size:1024;comment:失败原因" json:"error,omitempty"`
+}
+
+func (*AuditLog) TableName() string {
+	return "audit_logs"
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// JwtKey jwt 非对称签名密钥, 多副本共享同一组密钥
+type JwtKey struct {
+	ID         int64     `gorm:"column:id;primarykey;autoIncrement" json:"id"`
+	CreatedAt  time.Time `gorm:"column:created_at" json:"createdAt"`
+	UpdatedAt  time.Time `gorm:"column:updated_at" json:"updatedAt"`
+	Kid        string    `gorm:"column:kid;size:64;uniqueIndex;comment:密钥ID" json:"kid"`
+	Algorithm  string    `gorm:"column:algorithm;size:16;comment:签名算法" json:"algorithm"`
+	PrivateKey string    `gorm:"column:private_key;type:text;comment:加密后的私钥" json:"-"`
+	PublicKey  string    `gorm:"column:public_key;type:text;comment:PEM格式公钥" json:"publicKey"`
+}
+
+func (*JwtKey) TableName() string {
+	return "jwt_keys"
+}
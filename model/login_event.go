@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+const (
+	LoginResultSuccess = "success"
+	LoginResultFailure = "failure"
+)
+
+// LoginEvent 登录和认证事件, 用于用户查看登录历史
+type LoginEvent struct {
+	ID        int64     `gorm:"column:id;primarykey;autoIncrement" json:"id"`
+	CreatedAt time.Time `gorm:"column:created_at;index" json:"createdAt"`
+	// UserID 用户不存在时为 0
+	UserID int64 `gorm:"column:user_id;index;comment:用户id" json:"userId"`
+	// Account 登录使用的账号, 密码登录为邮箱, 其余为用户名
+	Account string `gorm:"column:account;size:100;comment:登录账号" json:"account"`
+	Event   string `gorm:"column:event;size:32;index;comment:事件" json:"event"`
+	// Provider 认证方式, 如 pwd、otp 或 OAuth2 provider 名称
+	Provider  string `gorm:"column:provider;size:50;comment:认证方式" json:"provider"`
+	Result    string `gorm:"column:result;size:16;comment:结果" json:"result"`
+	Reason    string `gorm:"column:reason;size:255;comment:失败原因或说明" json:"reason,omitempty"`
+	IP        string `gorm:"column:ip;size:64;comment:客户端IP" json:"ip"`
+	UserAgent string `gorm:"column:user_agent;size:255;comment:客户端User-Agent" json:"userAgent"`
+	RequestID string `gorm:"column:request_id;size:64;comment:请求id" json:"requestId"`
+}
+
+func (*LoginEvent) TableName() string {
+	return "login_events"
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// PasswordHistory 用户使用过的密码哈希, 用于禁止重复使用最近的密码
+type PasswordHistory struct {
+	ID        int64     `gorm:"column:id;primarykey;autoIncrement" json:"id"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"createdAt"`
+	UserID    int64     `gorm:"column:user_id;index;comment:用户id" json:"userId"`
+	Password  string    `gorm:"column:password;size:255;comment:密码哈希" json:"-"`
+}
+
+func (*PasswordHistory) TableName() string {
+	return "password_histories"
+}
@@ -6,17 +6,30 @@ import (
 	"gorm.io/gorm"
 )
 
-const PreloadRoles = "Roles"
+const (
+	PreloadRoles   = "Roles"
+	PreloadParents = "Parents"
+)
 
 type Role struct {
 	ID          int64          `gorm:"column:id;primarykey" json:"id,omitempty"`
 	CreatedAt   time.Time      `gorm:"column:created_at" json:"createdAt,omitempty"`
 	UpdatedAt   time.Time      `gorm:"column:updated_at" json:"updatedAt,omitempty"`
 	DeletedAt   gorm.DeletedAt `gorm:"column:deleted_at;index" json:"-"`
+	TenantID    int64          `gorm:"column:tenant_id;default:1" json:"tenantId,omitempty"`
 	Name        string         `gorm:"column:name" json:"name,omitempty"`
 	Description string         `gorm:"column:description" json:"description,omitempty"`
 	Users       []*User        `gorm:"many2many:user_roles" json:"users,omitempty"`
 	Apis        []*Api         `gorm:"many2many:role_apis" json:"apis,omitempty"`
+	// DenyApis 禁止角色访问的接口, 不论接口本身的效果都以 deny 写入策略
+	DenyApis []*Api `gorm:"many2many:role_deny_apis" json:"denyApis,omitempty"`
+	// Parents 继承的角色, 拥有父角色的全部接口权限
+	Parents []*Role `gorm:"many2many:role_parents;joinForeignKey:RoleID;joinReferences:ParentID" json:"parents,omitempty"`
+}
+
+// ScopedName 角色缓存中保存的带租户的角色名称
+func (receiver *Role) ScopedName() string {
+	return ScopedRoleName(receiver.TenantID, receiver.Name)
 }
 
 func (receiver *Role) TableName() string {
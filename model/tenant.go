@@ -0,0 +1,59 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const PreloadTenants = "Tenants"
+
+// DefaultTenantID 默认租户, 启用多租户前的用户和角色都属于默认租户
+const DefaultTenantID int64 = 1
+
+type Tenant struct {
+	ID          int64          `gorm:"column:id;primarykey" json:"id,omitempty"`
+	CreatedAt   time.Time      `gorm:"column:created_at" json:"createdAt,omitempty"`
+	UpdatedAt   time.Time      `gorm:"column:updated_at" json:"updatedAt,omitempty"`
+	DeletedAt   gorm.DeletedAt `gorm:"column:deleted_at;index" json:"-"`
+	Name        string         `gorm:"column:name;size:50" json:"name,omitempty"`
+	Description string         `gorm:"column:description;size:255" json:"description,omitempty"`
+	Users       []*User        `gorm:"many2many:user_tenants" json:"users,omitempty"`
+}
+
+func (*Tenant) TableName() string {
+	return "tenants"
+}
+
+// TenantDomain 租户在 casbin 策略中的 domain
+func TenantDomain(tenantID int64) string {
+	return strconv.FormatInt(tenantID, 10)
+}
+
+// ScopedRoleName 角色缓存中保存的 "租户id:角色名称"
+func ScopedRoleName(tenantID int64, name string) string {
+	return TenantDomain(tenantID) + ":" + name
+}
+
+// ParseScopedRoleName 解析角色缓存中的角色, 没有租户前缀时属于默认租户
+func ParseScopedRoleName(value string) (tenantID int64, name string) {
+	if prefix, name, ok := strings.Cut(value, ":"); ok {
+		if id, err := strconv.ParseInt(prefix, 10, 64); err == nil {
+			return id, name
+		}
+	}
+	return DefaultTenantID, value
+}
+
+// RolesInTenant 从角色缓存中取出属于租户的角色名称
+func RolesInTenant(scoped []string, tenantID int64) []string {
+	roles := make([]string, 0, len(scoped))
+	for _, value := range scoped {
+		if id, name := ParseScopedRoleName(value); id == tenantID {
+			roles = append(roles, name)
+		}
+	}
+	return roles
+}
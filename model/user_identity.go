@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// UserIdentity 用户关联的外部身份, 同一个 provider 的 subject 只能关联一个用户
+type UserIdentity struct {
+	ID     int64 `gorm:"column:id;primarykey;autoIncrement" json:"id"`
+	UserID int64 `gorm:"column:user_id;index;comment:用户id" json:"userId"`
+	// Provider OAuth2 provider 名称, 与配置中的 key 一致
+	Provider string `gorm:"column:provider;size:50;uniqueIndex:idx_user_identities_provider_subject,priority:1;comment:provider名称" json:"provider"`
+	// Subject 用户在 provider 中的唯一标识, OIDC 为 sub, 飞书为 user_id
+	Subject string `gorm:"column:subject;size:255;uniqueIndex:idx_user_identities_provider_subject,priority:2;comment:provider用户标识" json:"subject"`
+	// Profile 最近一次登录时 provider 返回的用户信息
+	Profile   map[string]any `gorm:"column:profile;type:text;serializer:json;comment:provider用户信息" json:"profile"`
+	LinkedAt  time.Time      `gorm:"column:linked_at;comment:关联时间" json:"linkedAt"`
+	UpdatedAt time.Time      `gorm:"column:updated_at" json:"updatedAt"`
+}
+
+func (*UserIdentity) TableName() string {
+	return "user_identities"
+}
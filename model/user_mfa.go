@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// UserMfa 用户的 TOTP 两步验证配置, 确认后才会在登录时启用
+type UserMfa struct {
+	ID        int64     `gorm:"column:id;primarykey;autoIncrement" json:"id"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updatedAt"`
+	UserID    int64     `gorm:"column:user_id;uniqueIndex;comment:用户id" json:"userId"`
+	Secret    string    `gorm:"column:secret;size:255;comment:加密后的TOTP密钥" json:"-"`
+	Enabled   bool      `gorm:"column:enabled;comment:是否已确认启用" json:"enabled"`
+	// RecoveryCodes 未使用的恢复码的 sha256 哈希, 每个恢复码只能使用一次
+	RecoveryCodes []string `gorm:"column:recovery_codes;type:text;serializer:json;comment:恢复码哈希" json:"-"`
+	// LastUsedStep 最后一次验证通过的 TOTP 周期, 防止验证码重放
+	LastUsedStep int64 `gorm:"column:last_used_step;comment:最后使用的TOTP周期" json:"-"`
+}
+
+func (*UserMfa) TableName() string {
+	return "user_mfas"
+}
@@ -0,0 +1,160 @@
+// Package audit 记录管理操作的审计日志, 包括操作人、操作对象、字段变更和操作结果
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/base/log"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/store"
+	"go.uber.org/zap"
+)
+
+// 操作对象类型
+const (
+	TargetUser   = "user"
+	TargetRole   = "role"
+	TargetApi    = "api"
+	TargetTenant = "tenant"
+)
+
+// 操作
+const (
+	ActionUserCreate        = "user.create"
+	ActionUserUpdate        = "user.update"
+	ActionUserDelete        = "user.delete"
+	ActionUserAssignRoles   = "user.assign_roles"
+	ActionUserSyncRoles     = "user.sync_roles"
+	ActionUserRevokeTokens  = "user.revoke_tokens"
+	ActionUserUnlock        = "user.unlock"
+	ActionUserResetMfa      = "user.reset_mfa"
+	ActionUserRevokeSession = "user.revoke_session"
+	ActionRoleCreate        = "role.create"
+	ActionRoleUpdate        = "role.update"
+	ActionRoleDelete        = "role.delete"
+	ActionApiCreate         = "api.create"
+	ActionApiUpdate         = "api.update"
+	ActionApiDelete         = "api.delete"
+	ActionTenantCreate      = "tenant.create"
+	ActionTenantUpdate      = "tenant.update"
+	ActionTenantAddUsers    = "tenant.add_users"
+	ActionTenantRemoveUser  = "tenant.remove_user"
+)
+
+const (
+	// maxErrorLength 失败原因的最大长度, 与表字段一致
+	maxErrorLength = 1024
+	systemActor    = "system"
+)
+
+// Entry 一次操作的审计信息, Before 和 After 为操作前后的快照, 通常是 map[string]any
+type Entry struct {
+	Action     string
+	TargetType string
+	TargetID   any
+	Before     any
+	After      any
+}
+
+// Recorder 审计日志记录接口
+type Recorder interface {
+	// Record 记录一次操作, err 为操作的结果
+	// 操作人、请求 id 和客户端 IP 从 ctx 中获取, 写入失败只记录日志, 不影响业务操作
+	Record(ctx context.Context, entry *Entry, err error)
+}
+
+type recorder struct {
+	auditStore store.AuditLogStorer
+}
+
+func NewRecorder(auditStore store.AuditLogStorer) Recorder {
+	return &recorder{
+		auditStore: auditStore,
+	}
+}
+
+func (r *recorder) Record(ctx context.Context, entry *Entry, err error) {
+	auditLog := &model.AuditLog{
+		RequestID:  helper.GetRequestIDFromContext(ctx),
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   targetID(entry.TargetID),
+		Diff:       Diff(entry.Before, entry.After),
+		IP:         helper.GetClientIPFromContext(ctx),
+		Result:     model.AuditResultSuccess,
+	}
+	// 没有登录用户时为命令行等系统操作
+	auditLog.ActorName = systemActor
+	if claims, ok := ctx.Value(constant.UserContextKey).(*jwt.JwtClaims); ok {
+		auditLog.ActorID = claims.UserID
+		auditLog.ActorName = claims.UserName
+	}
+	if err != nil {
+		auditLog.Result = model.AuditResultFailure
+		auditLog.Error = err.Error()
+		if len(auditLog.Error) > maxErrorLength {
+			auditLog.Error = auditLog.Error[:maxErrorLength]
+		}
+	}
+
+	if err := r.auditStore.Create(ctx, auditLog); err != nil {
+		log.WithRequestID(ctx).Error("write audit log failed", zap.String("action", entry.Action), zap.String("targetID", auditLog.TargetID), zap.Error(err))
+	}
+}
+
+func targetID(id any) string {
+	switch v := id.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// Diff 比较操作前后的快照, 返回发生变化的字段, 快照按 JSON 序列化后的顶层字段比较
+func Diff(before, after any) map[string]*model.AuditChange {
+	beforeFields := toFields(before)
+	afterFields := toFields(after)
+
+	diff := make(map[string]*model.AuditChange)
+	for k, v := range beforeFields {
+		if other, ok := afterFields[k]; !ok || !reflect.DeepEqual(v, other) {
+			diff[k] = &model.AuditChange{Before: v, After: afterFields[k]}
+		}
+	}
+	for k, v := range afterFields {
+		if _, ok := beforeFields[k]; !ok {
+			diff[k] = &model.AuditChange{After: v}
+		}
+	}
+	if len(diff) == 0 {
+		return nil
+	}
+	return diff
+}
+
+func toFields(snapshot any) map[string]any {
+	if snapshot == nil {
+		return nil
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
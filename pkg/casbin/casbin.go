@@ -12,25 +12,35 @@ import (
 
 const casbinModel = `
 [request_definition]
-r = sub, obj, act
+r = sub, dom, obj, act
 
 [policy_definition]
-p = sub, obj, act
+p = sub, dom, obj, act, eft
 
 [role_definition]
-g = _, _
+g = _, _, _
 
 [policy_effect]
-e = some(where (p.eft == allow))
+e = some(where (p.eft == allow)) && !some(where (p.eft == deny))
 
 [matchers]
-m = g(r.sub, p.sub) && keyMatch2(r.obj, p.obj) && keyMatch(r.act, p.act)`
+m = g(r.sub, p.sub, r.dom) && r.dom == p.dom && keyMatch2(r.obj, p.obj) && keyMatch(r.act, p.act)`
 
-func NewEnforcer(db *gorm.DB) (enforcer *casbin.Enforcer, err error) {
-	model, err := model.NewModelFromString(casbinModel)
+// NewModel 鉴权使用的 casbin 模型, 按租户隔离, deny 规则优先于 allow 规则
+func NewModel() (model.Model, error) {
+	m, err := model.NewModelFromString(casbinModel)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load model, %w", err)
 	}
+	return m, nil
+}
+
+// NewEnforcer 创建并发安全的 enforcer, 多个副本之间通过 Watcher 同步策略
+func NewEnforcer(db *gorm.DB) (enforcer *casbin.SyncedEnforcer, err error) {
+	model, err := NewModel()
+	if err != nil {
+		return nil, err
+	}
 
 	// 加载策略
 	adapter, err := gormadapter.NewAdapterByDB(db)
@@ -39,7 +49,7 @@ func NewEnforcer(db *gorm.DB) (enforcer *casbin.Enforcer, err error) {
 	}
 
 	// 初始化casbin
-	enforcer, err = casbin.NewEnforcer(model, adapter)
+	enforcer, err = casbin.NewSyncedEnforcer(model, adapter)
 	if err != nil {
 		return nil, err
 	}
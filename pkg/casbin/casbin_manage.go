@@ -5,65 +5,106 @@ import (
 
 	"github.com/casbin/casbin/v2"
 	"github.com/yiran15/api-server/model"
+	"go.uber.org/zap"
 )
 
-// AuthChecker 授权检查接口
+// AuthChecker 授权检查接口, dom 为租户的 domain
 type AuthChecker interface {
-	Enforce(sub, obj, act string) (bool, error)
+	Enforce(sub, dom, obj, act string) (bool, error)
+	// EnforceEx 同时返回决定结果的策略 (角色, 租户, 路径, 方法, 效果), 没有匹配的策略时为空
+	EnforceEx(sub, dom, obj, act string) (bool, []string, error)
+	// EnforceRoles 使用多个角色检查, 任一角色匹配 deny 策略时拒绝, 否则任一角色匹配 allow 策略时允许
+	EnforceRoles(roles []string, dom, obj, act string) (bool, []string, error)
 }
 
 // CasbinManager 策略和角色管理接口
 type CasbinManager interface {
-	// 角色 CRUD
-	AddRolePolicy(role string, api *model.Api) (bool, error)
-	GetRolePolicies(role string) ([]*model.Api, error)
-	UpdateRolePolicy(role string, oldApi *model.Api, newApi *model.Api) (bool, error) // 修正：更新时只需要role, 不需要oldRole
-	DeleteRolePolicy(role string, api *model.Api) (bool, error)
-	DeleteAllRolePolicies(role string) (bool, error)
+	// 角色 CRUD, dom 为角色所属租户的 domain
+	AddRolePolicy(role, dom string, api *model.Api) (bool, error)
+	GetRolePolicies(role, dom string) ([]*model.Api, error)
+	UpdateRolePolicy(role, dom string, oldApi *model.Api, newApi *model.Api) (bool, error) // 修正：更新时只需要role, 不需要oldRole
+	DeleteRolePolicy(role, dom string, api *model.Api) (bool, error)
+	DeleteAllRolePolicies(role, dom string) (bool, error)
 
 	// 角色用户 CRUD
-	AddUserToRole(user, role string) (bool, error)
-	DeleteUserFromRole(user, role string) (bool, error)
-	GetUsersInRole(role string) ([]string, error)
-	GetRolesForUser(user string) ([]string, error)
-	DeleteUserAllRoles(user string) (bool, error)
+	AddUserToRole(user, role, dom string) (bool, error)
+	DeleteUserFromRole(user, role, dom string) (bool, error)
+	GetUsersInRole(role, dom string) ([]string, error)
+	GetRolesForUser(user, dom string) ([]string, error)
+	DeleteUserAllRoles(user, dom string) (bool, error)
 
+	// UpdatePolicies 已写入数据库的策略变更, 应用到内存并通知其他副本
+	UpdatePolicies(removed, added []*model.CasbinRule) error
+	// LoadPolicy 从数据库重新加载全部策略并通知其他副本
 	LoadPolicy() error
 }
 
 // casbinManager 实现结构体
 type casbinManager struct {
-	enforcer *casbin.Enforcer
+	enforcer *casbin.SyncedEnforcer
+	watcher  *Watcher
 }
 
-// NewCasbinManager 创建 CasbinManager 实例
-func NewCasbinManager(enforcer *casbin.Enforcer) CasbinManager {
+// NewCasbinManager 创建 CasbinManager 实例, watcher 为 nil 时不通知其他副本
+func NewCasbinManager(enforcer *casbin.SyncedEnforcer, watcher *Watcher) CasbinManager {
 	return &casbinManager{
 		enforcer: enforcer,
+		watcher:  watcher,
 	}
 }
 
 // NewAuthChecker 创建 AuthChecker 实例
-func NewAuthChecker(enforcer *casbin.Enforcer) AuthChecker {
+func NewAuthChecker(enforcer *casbin.SyncedEnforcer) AuthChecker {
 	return &casbinManager{ // casbinManager 结构体同时实现了 AuthChecker 和 CasbinManager 接口
 		enforcer: enforcer,
 	}
 }
 
 // Enforce 实现 AuthChecker 接口的授权检查方法
-func (m *casbinManager) Enforce(sub, obj, act string) (bool, error) {
-	ok, err := m.enforcer.Enforce(sub, obj, act)
+func (m *casbinManager) Enforce(sub, dom, obj, act string) (bool, error) {
+	ok, err := m.enforcer.Enforce(sub, dom, obj, act)
 	if err != nil {
 		return false, fmt.Errorf("casbin enforce failed: %w", err)
 	}
 	return ok, nil
 }
 
+// EnforceEx 实现 AuthChecker 接口的授权检查方法, 匹配到 deny 策略时返回该策略
+func (m *casbinManager) EnforceEx(sub, dom, obj, act string) (bool, []string, error) {
+	ok, explain, err := m.enforcer.EnforceEx(sub, dom, obj, act)
+	if err != nil {
+		return false, nil, fmt.Errorf("casbin enforce failed: %w", err)
+	}
+	return ok, explain, nil
+}
+
+// EnforceRoles 依次检查每个角色, 返回决定结果的策略, 没有匹配任何策略时为空
+func (m *casbinManager) EnforceRoles(roles []string, dom, obj, act string) (bool, []string, error) {
+	var (
+		allowed bool
+		rule    []string
+	)
+	for _, role := range roles {
+		allow, explain, err := m.EnforceEx(role, dom, obj, act)
+		if err != nil {
+			return false, nil, fmt.Errorf("role %s: %w", role, err)
+		}
+		// 没有允许时匹配到的策略是 deny 策略
+		if !allow && len(explain) > 0 {
+			return false, explain, nil
+		}
+		if allow && !allowed {
+			allowed, rule = true, explain
+		}
+	}
+	return allowed, rule, nil
+}
+
 // --- CasbinManager 接口方法的具体实现 ---
 
 // AddRolePolicy 为指定角色添加一个 API 权限策略
-func (m *casbinManager) AddRolePolicy(role string, api *model.Api) (bool, error) {
-	ok, err := m.enforcer.AddPolicy(role, api.Path, api.Method)
+func (m *casbinManager) AddRolePolicy(role, dom string, api *model.Api) (bool, error) {
+	ok, err := m.enforcer.AddPolicy(role, dom, api.Path, api.Method, api.PolicyEffect())
 	if err != nil {
 		return false, fmt.Errorf("failed to add policy for role %s, api %s %s: %w", role, api.Method, api.Path, err)
 	}
@@ -71,18 +112,19 @@ func (m *casbinManager) AddRolePolicy(role string, api *model.Api) (bool, error)
 }
 
 // GetRolePolicies 获取指定角色的所有 API 权限策略
-func (m *casbinManager) GetRolePolicies(role string) ([]*model.Api, error) {
-	policies, err := m.enforcer.GetFilteredPolicy(0, role)
+func (m *casbinManager) GetRolePolicies(role, dom string) ([]*model.Api, error) {
+	policies, err := m.enforcer.GetFilteredPolicy(0, role, dom)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get policies for role %s: %w", role, err)
 	}
 
 	var apis []*model.Api
 	for _, p := range policies {
-		if len(p) >= 3 {
+		if len(p) >= 5 {
 			apis = append(apis, &model.Api{
-				Path:   p[1],
-				Method: p[2],
+				Path:   p[2],
+				Method: p[3],
+				Effect: p[4],
 			})
 		}
 	}
@@ -92,22 +134,22 @@ func (m *casbinManager) GetRolePolicies(role string) ([]*model.Api, error) {
 // UpdateRolePolicy 更新一个角色的特定 API 权限策略
 // 修正：UpdateRolePolicy 接收 role 而不是 oldRole，因为我们是在更新某个角色的策略。
 // 同时，旧策略的删除和新策略的添加都围绕这个 role。
-func (m *casbinManager) UpdateRolePolicy(role string, oldApi *model.Api, newApi *model.Api) (bool, error) {
-	deleted, err := m.enforcer.RemovePolicy(role, oldApi.Path, oldApi.Method)
+func (m *casbinManager) UpdateRolePolicy(role, dom string, oldApi *model.Api, newApi *model.Api) (bool, error) {
+	deleted, err := m.enforcer.RemovePolicy(role, dom, oldApi.Path, oldApi.Method, oldApi.PolicyEffect())
 	if err != nil {
 		return false, fmt.Errorf("failed to remove old policy for role %s, api %s %s: %w", role, oldApi.Method, oldApi.Path, err)
 	}
 	if !deleted {
 		// 如果旧策略不存在，则直接添加新策略，并返回 false 表示未删除任何策略
 		// 但这里我们认为如果旧策略不存在就不是一个真正的“更新”操作
-		_, err = m.enforcer.AddPolicy(role, newApi.Path, newApi.Method)
+		_, err = m.enforcer.AddPolicy(role, dom, newApi.Path, newApi.Method, newApi.PolicyEffect())
 		if err != nil {
 			return false, fmt.Errorf("failed to add new policy after old not found for role %s, api %s %s: %w", role, newApi.Method, newApi.Path, err)
 		}
 		return false, nil // 表示没有旧策略被删除
 	}
 
-	added, err := m.enforcer.AddPolicy(role, newApi.Path, newApi.Method)
+	added, err := m.enforcer.AddPolicy(role, dom, newApi.Path, newApi.Method, newApi.PolicyEffect())
 	if err != nil {
 		return false, fmt.Errorf("failed to add new policy for role %s, api %s %s: %w", role, newApi.Method, newApi.Path, err)
 	}
@@ -115,8 +157,8 @@ func (m *casbinManager) UpdateRolePolicy(role string, oldApi *model.Api, newApi
 }
 
 // DeleteRolePolicy 删除指定角色的一个 API 权限策略
-func (m *casbinManager) DeleteRolePolicy(role string, api *model.Api) (bool, error) {
-	ok, err := m.enforcer.RemovePolicy(role, api.Path, api.Method)
+func (m *casbinManager) DeleteRolePolicy(role, dom string, api *model.Api) (bool, error) {
+	ok, err := m.enforcer.RemovePolicy(role, dom, api.Path, api.Method, api.PolicyEffect())
 	if err != nil {
 		return false, fmt.Errorf("failed to delete policy for role %s, api %s %s: %w", role, api.Method, api.Path, err)
 	}
@@ -124,8 +166,8 @@ func (m *casbinManager) DeleteRolePolicy(role string, api *model.Api) (bool, err
 }
 
 // DeleteAllRolePolicies 删除一个角色的所有权限策略
-func (m *casbinManager) DeleteAllRolePolicies(role string) (bool, error) {
-	ok, err := m.enforcer.RemoveFilteredPolicy(0, role)
+func (m *casbinManager) DeleteAllRolePolicies(role, dom string) (bool, error) {
+	ok, err := m.enforcer.RemoveFilteredPolicy(0, role, dom)
 	if err != nil {
 		return false, fmt.Errorf("failed to delete all policies for role %s: %w", role, err)
 	}
@@ -133,8 +175,8 @@ func (m *casbinManager) DeleteAllRolePolicies(role string) (bool, error) {
 }
 
 // AddUserToRole 将用户添加到角色
-func (m *casbinManager) AddUserToRole(user, role string) (bool, error) {
-	ok, err := m.enforcer.AddGroupingPolicy(user, role)
+func (m *casbinManager) AddUserToRole(user, role, dom string) (bool, error) {
+	ok, err := m.enforcer.AddGroupingPolicy(user, role, dom)
 	if err != nil {
 		return false, fmt.Errorf("failed to add user %s to role %s: %w", user, role, err)
 	}
@@ -142,8 +184,8 @@ func (m *casbinManager) AddUserToRole(user, role string) (bool, error) {
 }
 
 // DeleteUserFromRole 将用户从角色中移除
-func (m *casbinManager) DeleteUserFromRole(user, role string) (bool, error) {
-	ok, err := m.enforcer.RemoveGroupingPolicy(user, role)
+func (m *casbinManager) DeleteUserFromRole(user, role, dom string) (bool, error) {
+	ok, err := m.enforcer.RemoveGroupingPolicy(user, role, dom)
 	if err != nil {
 		return false, fmt.Errorf("failed to delete user %s from role %s: %w", user, role, err)
 	}
@@ -151,8 +193,8 @@ func (m *casbinManager) DeleteUserFromRole(user, role string) (bool, error) {
 }
 
 // GetUsersInRole 获取某个角色的所有用户
-func (m *casbinManager) GetUsersInRole(role string) ([]string, error) {
-	users, err := m.enforcer.GetUsersForRole(role)
+func (m *casbinManager) GetUsersInRole(role, dom string) ([]string, error) {
+	users, err := m.enforcer.GetUsersForRole(role, dom)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get users for role %s: %w", role, err)
 	}
@@ -160,8 +202,8 @@ func (m *casbinManager) GetUsersInRole(role string) ([]string, error) {
 }
 
 // GetRolesForUser 获取某个用户拥有的所有角色
-func (m *casbinManager) GetRolesForUser(user string) ([]string, error) {
-	roles, err := m.enforcer.GetRolesForUser(user)
+func (m *casbinManager) GetRolesForUser(user, dom string) ([]string, error) {
+	roles, err := m.enforcer.GetRolesForUser(user, dom)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get roles for user %s: %w", user, err)
 	}
@@ -169,18 +211,68 @@ func (m *casbinManager) GetRolesForUser(user string) ([]string, error) {
 }
 
 // DeleteUserAllRoles 删除用户拥有的所有角色
-func (m *casbinManager) DeleteUserAllRoles(user string) (bool, error) {
-	ok, err := m.enforcer.RemoveFilteredGroupingPolicy(0, user)
+func (m *casbinManager) DeleteUserAllRoles(user, dom string) (bool, error) {
+	ok, err := m.enforcer.RemoveFilteredGroupingPolicy(0, user, "", dom)
 	if err != nil {
 		return false, fmt.Errorf("failed to delete all roles for user %s: %w", user, err)
 	}
 	return ok, nil
 }
 
+// UpdatePolicies 按 ptype 增量修改内存中的策略, 失败时重新加载全部策略
+func (m *casbinManager) UpdatePolicies(removed, added []*model.CasbinRule) error {
+	for _, ptype := range []string{"p", "g"} {
+		msg := &Message{Op: OpUpdate, Sec: ptype, PType: ptype, Removed: rulePolicies(removed, ptype), Added: rulePolicies(added, ptype)}
+		if len(msg.Removed) == 0 && len(msg.Added) == 0 {
+			continue
+		}
+		if err := msg.Apply(m.enforcer); err != nil {
+			zap.L().Warn("casbin policy incremental update failed, reload", zap.Error(err))
+			return m.LoadPolicy()
+		}
+		m.publish(msg)
+	}
+	return nil
+}
+
 // LoadPolicy 加载策略
 func (m *casbinManager) LoadPolicy() error {
 	if err := m.enforcer.LoadPolicy(); err != nil {
 		return fmt.Errorf("failed to load casbin policy: %w", err)
 	}
+	m.publish(&Message{Op: OpReload})
 	return nil
 }
+
+// publish 数据库和本地策略已经修改, 通知失败时其他副本在定期加载时同步
+func (m *casbinManager) publish(msg *Message) {
+	if m.watcher == nil {
+		return
+	}
+	if err := m.watcher.Publish(msg); err != nil {
+		zap.L().Error("casbin policy change not broadcast", zap.String("op", msg.Op), zap.Error(err))
+	}
+}
+
+// rulePolicies 将 ptype 的 casbin_rule 记录转换为策略, ptype 为空的记录视为 p
+func rulePolicies(rules []*model.CasbinRule, ptype string) [][]string {
+	var policies [][]string
+	for _, rule := range rules {
+		if rule.PType != nil && *rule.PType != ptype || rule.PType == nil && ptype != "p" {
+			continue
+		}
+		values := []*string{rule.V0, rule.V1, rule.V2, rule.V3, rule.V4, rule.V5}
+		n := len(values)
+		for n > 0 && (values[n-1] == nil || *values[n-1] == "") {
+			n--
+		}
+		policy := make([]string, n)
+		for i, v := range values[:n] {
+			if v != nil {
+				policy[i] = *v
+			}
+		}
+		policies = append(policies, policy)
+	}
+	return policies
+}
@@ -0,0 +1,371 @@
+package casbin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/redis/go-redis/v9"
+	"github.com/yiran15/api-server/base/conf"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// 策略变更消息的操作
+const (
+	// OpReload 其他副本从数据库重新加载全部策略
+	OpReload = "reload"
+	// OpUpdate 先删除 Removed 再添加 Added
+	OpUpdate = "update"
+	// OpRemoveFiltered 按字段删除策略
+	OpRemoveFiltered = "remove_filtered"
+)
+
+// 同步结果, 用于 metrics
+const (
+	syncIncremental = "incremental"
+	syncReload      = "reload"
+	syncFailed      = "failed"
+)
+
+const watcherChannel = "casbin:policy"
+
+// Message 通过 redis 广播的策略变更
+type Message struct {
+	// Source 发送消息的副本, 副本忽略自己发送的消息
+	Source string `json:"source"`
+	// Seq 每个副本递增的序号, 不连续说明丢失了消息, 需要全量加载
+	Seq         uint64     `json:"seq"`
+	Op          string     `json:"op"`
+	Sec         string     `json:"sec,omitempty"`
+	PType       string     `json:"ptype,omitempty"`
+	Removed     [][]string `json:"removed,omitempty"`
+	Added       [][]string `json:"added,omitempty"`
+	FieldIndex  int        `json:"fieldIndex,omitempty"`
+	FieldValues []string   `json:"fieldValues,omitempty"`
+	Time        time.Time  `json:"time"`
+}
+
+// Apply 将变更应用到 enforcer 的内存策略, 不写入数据库
+func (msg *Message) Apply(enforcer *casbin.SyncedEnforcer) error {
+	sec, ptype := msg.Sec, msg.PType
+	if sec == "" {
+		sec = "p"
+	}
+	if ptype == "" {
+		ptype = sec
+	}
+
+	lock := enforcer.GetLock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	m := enforcer.GetModel()
+	var (
+		removed, added [][]string
+		err            error
+	)
+	switch msg.Op {
+	case OpUpdate:
+		if removed, err = m.RemovePoliciesWithAffected(sec, ptype, msg.Removed); err != nil {
+			return err
+		}
+		if added, err = m.AddPoliciesWithAffected(sec, ptype, msg.Added); err != nil {
+			return err
+		}
+	case OpRemoveFiltered:
+		if _, removed, err = m.RemoveFilteredPolicy(sec, ptype, msg.FieldIndex, msg.FieldValues...); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported casbin policy op %s", msg.Op)
+	}
+
+	if sec != "g" {
+		return nil
+	}
+	if len(removed) > 0 {
+		if err := enforcer.BuildIncrementalRoleLinks(model.PolicyRemove, ptype, removed); err != nil {
+			return err
+		}
+	}
+	if len(added) > 0 {
+		if err := enforcer.BuildIncrementalRoleLinks(model.PolicyAdd, ptype, added); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Watcher 通过 redis pub/sub 在副本之间同步 casbin 策略, 实现 persist.WatcherEx
+//
+// 修改策略的副本先写数据库再广播变更, 其他副本只修改内存中的策略;
+// 变更无法增量应用、序号不连续或重新订阅后全量加载, 并定期全量加载兜底。
+type Watcher struct {
+	rdb      *redis.Client
+	channel  string
+	id       string
+	seq      atomic.Uint64
+	enforcer *casbin.SyncedEnforcer
+
+	mu       sync.Mutex
+	lastSeq  map[string]uint64
+	callback func(string)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	lag      metric.Float64Histogram
+	received metric.Int64Counter
+	failures metric.Int64Counter
+}
+
+// NewWatcher 创建 Watcher, 设置为 enforcer 的 watcher 并开始订阅, cleanup 停止订阅和定期加载
+func NewWatcher(rdb *redis.Client, enforcer *casbin.SyncedEnforcer) (*Watcher, func(), error) {
+	prefix, err := conf.GetRedisKeyPrefix()
+	if err != nil {
+		return nil, nil, err
+	}
+	interval, err := conf.GetCasbinReloadInterval()
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := replicaID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := &Watcher{
+		rdb:      rdb,
+		channel:  prefix + ":" + watcherChannel,
+		id:       id,
+		enforcer: enforcer,
+		lastSeq:  make(map[string]uint64),
+		done:     make(chan struct{}),
+	}
+	if err := w.initMetrics(); err != nil {
+		return nil, nil, err
+	}
+	if err := enforcer.SetWatcher(w); err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	pubsub := rdb.Subscribe(ctx, w.channel)
+	go w.run(ctx, pubsub)
+	enforcer.StartAutoLoadPolicy(interval)
+
+	zap.S().Infof("casbin watcher started, channel %s, replica %s, reload interval %s", w.channel, w.id, interval)
+	return w, w.Close, nil
+}
+
+func replicaID() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate casbin watcher id: %w", err)
+	}
+	return hostname + "-" + hex.EncodeToString(b), nil
+}
+
+func (w *Watcher) initMetrics() (err error) {
+	meter := otel.Meter("github.com/yiran15/api-server/pkg/casbin")
+	if w.lag, err = meter.Float64Histogram("casbin.policy.sync.lag",
+		metric.WithDescription("Delay between a policy change on one replica and its application on another"),
+		metric.WithUnit("s")); err != nil {
+		return err
+	}
+	if w.received, err = meter.Int64Counter("casbin.policy.sync.messages",
+		metric.WithDescription("Policy change messages received from other replicas")); err != nil {
+		return err
+	}
+	if w.failures, err = meter.Int64Counter("casbin.policy.sync.publish.failures",
+		metric.WithDescription("Policy change messages that failed to publish")); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Publish 广播策略变更
+func (w *Watcher) Publish(msg *Message) error {
+	msg.Source = w.id
+	msg.Seq = w.seq.Add(1)
+	msg.Time = time.Now()
+	data, err := json.Marshal(msg)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		err = w.rdb.Publish(ctx, w.channel, data).Err()
+	}
+	if err != nil {
+		// 序号已经增加, 其他副本收到下一条消息时发现不连续会全量加载
+		w.failures.Add(context.Background(), 1, metric.WithAttributes(attribute.String("op", msg.Op)))
+		return fmt.Errorf("failed to publish casbin policy change: %w", err)
+	}
+	return nil
+}
+
+func (w *Watcher) run(ctx context.Context, pubsub *redis.PubSub) {
+	defer close(w.done)
+	defer pubsub.Close()
+
+	subscribed := false
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// 下次 Receive 时 go-redis 自动重连并重新订阅
+			zap.L().Warn("casbin watcher receive failed", zap.String("channel", w.channel), zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if msg.Kind != "subscribe" {
+				continue
+			}
+			// 断开期间的消息已经丢失
+			if subscribed {
+				w.reload("resubscribed")
+			}
+			subscribed = true
+		case *redis.Message:
+			w.handle(ctx, msg.Payload)
+		}
+	}
+}
+
+func (w *Watcher) handle(ctx context.Context, payload string) {
+	msg := &Message{}
+	if err := json.Unmarshal([]byte(payload), msg); err != nil {
+		zap.L().Error("invalid casbin policy message", zap.String("payload", payload), zap.Error(err))
+		w.reload("invalid message")
+		return
+	}
+	if msg.Source == w.id {
+		return
+	}
+
+	lag := time.Since(msg.Time)
+	fields := []zap.Field{
+		zap.String("source", msg.Source),
+		zap.Uint64("seq", msg.Seq),
+		zap.String("op", msg.Op),
+		zap.Duration("lag", lag),
+	}
+	result := syncIncremental
+	switch {
+	case w.gap(msg):
+		result = syncReload
+		w.reload("missed messages", fields...)
+	case msg.Op == OpReload:
+		result = syncReload
+		w.reload("requested", fields...)
+	default:
+		if err := msg.Apply(w.enforcer); err != nil {
+			result = syncFailed
+			zap.L().Error("casbin policy apply failed", append(fields, zap.Error(err))...)
+			w.reload("apply failed", fields...)
+			break
+		}
+		zap.L().Info("casbin policy synced", fields...)
+	}
+
+	attrs := metric.WithAttributes(attribute.String("op", msg.Op), attribute.String("result", result))
+	w.lag.Record(ctx, lag.Seconds(), attrs)
+	w.received.Add(ctx, 1, attrs)
+}
+
+// gap 判断是否丢失了来自同一副本的消息, 第一次收到的副本不判断
+func (w *Watcher) gap(msg *Message) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	last, ok := w.lastSeq[msg.Source]
+	w.lastSeq[msg.Source] = msg.Seq
+	return ok && msg.Seq != last+1
+}
+
+func (w *Watcher) reload(reason string, fields ...zap.Field) {
+	w.mu.Lock()
+	callback := w.callback
+	w.mu.Unlock()
+
+	fields = append(fields, zap.String("reason", reason))
+	if callback != nil {
+		callback(reason)
+		zap.L().Info("casbin policy reloaded", fields...)
+		return
+	}
+	if err := w.enforcer.LoadPolicy(); err != nil {
+		zap.L().Error("casbin policy reload failed", append(fields, zap.Error(err))...)
+		return
+	}
+	zap.L().Info("casbin policy reloaded", fields...)
+}
+
+// SetUpdateCallback 设置全量加载时的回调, 默认调用 enforcer 的 LoadPolicy
+func (w *Watcher) SetUpdateCallback(callback func(string)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callback = callback
+	return nil
+}
+
+// Update 通知其他副本全量加载
+func (w *Watcher) Update() error {
+	return w.Publish(&Message{Op: OpReload})
+}
+
+// Close 停止订阅和定期加载
+func (w *Watcher) Close() {
+	w.enforcer.StopAutoLoadPolicy()
+	w.cancel()
+	<-w.done
+}
+
+// 以下方法由 enforcer 修改策略后调用
+
+func (w *Watcher) UpdateForAddPolicy(sec, ptype string, params ...string) error {
+	return w.Publish(&Message{Op: OpUpdate, Sec: sec, PType: ptype, Added: [][]string{params}})
+}
+
+func (w *Watcher) UpdateForRemovePolicy(sec, ptype string, params ...string) error {
+	return w.Publish(&Message{Op: OpUpdate, Sec: sec, PType: ptype, Removed: [][]string{params}})
+}
+
+func (w *Watcher) UpdateForRemoveFilteredPolicy(sec, ptype string, fieldIndex int, fieldValues ...string) error {
+	return w.Publish(&Message{Op: OpRemoveFiltered, Sec: sec, PType: ptype, FieldIndex: fieldIndex, FieldValues: fieldValues})
+}
+
+func (w *Watcher) UpdateForSavePolicy(model.Model) error {
+	return w.Update()
+}
+
+func (w *Watcher) UpdateForAddPolicies(sec string, ptype string, rules ...[]string) error {
+	return w.Publish(&Message{Op: OpUpdate, Sec: sec, PType: ptype, Added: rules})
+}
+
+func (w *Watcher) UpdateForRemovePolicies(sec string, ptype string, rules ...[]string) error {
+	return w.Publish(&Message{Op: OpUpdate, Sec: sec, PType: ptype, Removed: rules})
+}
@@ -4,60 +4,257 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	jwtv5 "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/yiran15/api-server/base/conf"
 	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/store"
+	"go.uber.org/zap"
+)
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	// TokenTypePersonalAccess 个人访问令牌, 由认证中间件根据数据库记录构造 claims, 不是 JWT
+	TokenTypePersonalAccess = "pat"
+	// TokenTypeMfa 密码校验通过后等待两步验证的临时 token, 只能用于提交验证码
+	TokenTypeMfa = "mfa"
+)
+
+// amr 认证方式, 参考 RFC 8176
+const (
+	AuthMethodPassword = "pwd"
+	AuthMethodOTP      = "otp"
+	AuthMethodOAuth    = "oauth"
 )
 
 type JwtInterface interface {
 	GenerateToken(id int64, userName string) (token string, err error)
+	GenerateTokenPair(id int64, userName string, opts ...ClaimsOption) (pair *TokenPair, err error)
 	ParseToken(tokenString string) (jwtClaims *JwtClaims, err error)
+	ParseRefreshToken(tokenString string) (jwtClaims *JwtClaims, err error)
+	GenerateMfaToken(id int64, userName string, opts ...ClaimsOption) (token string, jwtClaims *JwtClaims, err error)
+	ParseMfaToken(tokenString string) (jwtClaims *JwtClaims, err error)
 	GetUser(ctx context.Context) (*JwtClaims, error)
+	JWKS() *JSONWebKeySet
 }
 
 type GenerateToken struct {
-	secret string
-	expire time.Duration
-	issuer string
+	secret        string
+	method        jwtv5.SigningMethod
+	keys          *KeySet // 非对称算法的签名密钥, HS256 时为 nil
+	expire        time.Duration
+	refreshExpire time.Duration
+	mfaExpire     time.Duration
+	issuer        string
 }
 
-func NewGenerateToken() (*GenerateToken, error) {
+// NewGenerateToken 创建 token 签发器, 非对称算法时从数据库加载签名密钥并启动定期轮换
+func NewGenerateToken(keyStore store.JwtKeyStorer) (*GenerateToken, func(), error) {
 	var (
-		secret string
-		expire time.Duration
-		issuer string
-		err    error
+		secret        string
+		algorithm     string
+		rotation      time.Duration
+		expire        time.Duration
+		refreshExpire time.Duration
+		mfaExpire     time.Duration
+		issuer        string
+		err           error
 	)
 	if secret, err = conf.GetJwtSecret(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	issuer = conf.GetJwtIssuer()
 
 	if expire, err = conf.GetJwtExpirationTime(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return &GenerateToken{
-		secret: secret,
-		expire: expire,
-		issuer: issuer,
-	}, nil
+
+	if refreshExpire, err = conf.GetJwtRefreshExpirationTime(); err != nil {
+		return nil, nil, err
+	}
+
+	if mfaExpire, err = conf.GetMfaTokenExpireTime(); err != nil {
+		return nil, nil, err
+	}
+
+	if algorithm, err = conf.GetJwtAlgorithm(); err != nil {
+		return nil, nil, err
+	}
+
+	g := &GenerateToken{
+		secret:        secret,
+		method:        jwtv5.GetSigningMethod(algorithm),
+		expire:        expire,
+		refreshExpire: refreshExpire,
+		mfaExpire:     mfaExpire,
+		issuer:        issuer,
+	}
+	if g.method == jwtv5.SigningMethodHS256 {
+		return g, func() {}, nil
+	}
+
+	if rotation, err = conf.GetJwtRotationInterval(); err != nil {
+		return nil, nil, err
+	}
+	if g.keys, err = newKeySet(g.method, keyStore, secret, rotation, max(expire, refreshExpire)); err != nil {
+		return nil, nil, err
+	}
+	if err = g.keys.Rotate(context.Background()); err != nil {
+		return nil, nil, fmt.Errorf("init jwt signing keys error: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go g.keys.Run(ctx)
+	zap.S().Infof("jwt signing method %s, key rotation interval %s", algorithm, rotation)
+	return g, cancel, nil
 }
 
 type JwtClaims struct {
-	UserID   int64  `json:"userId"`
-	UserName string `json:"userName"`
+	UserID    int64  `json:"userId"`
+	UserName  string `json:"userName"`
+	TokenType string `json:"typ,omitempty"`
+	// SessionID 同一次登录签发的 access token 和 refresh token 共享同一个 sid, 刷新时保持不变
+	SessionID string `json:"sid,omitempty"`
+	// AuthMethods 签发 token 时用户通过的认证方式
+	AuthMethods []string `json:"amr,omitempty"`
+	// TenantID 当前选择的租户, 为空时使用默认租户, 刷新 token 时可以切换
+	TenantID int64 `json:"tid,omitempty"`
+	// Account 登录时使用的账号, 仅 mfa token 使用, 两步验证码错误时计入该账号的登录失败
+	Account string `json:"acct,omitempty"`
+	// Roles 限定本次请求可使用的角色, 为 nil 时使用用户的全部角色, 仅个人访问令牌使用, 不会写入 JWT
+	Roles []string `json:"-"`
 	*jwtv5.RegisteredClaims
 }
 
-func newJwtClaims(userID int64, userName, issuer string, expire time.Duration) *JwtClaims {
+// ClaimsOption 用于在签发 token 时设置额外的 claims
+type ClaimsOption func(*JwtClaims)
+
+// WithSessionID 指定 token 所属的会话, 刷新 token 时用于保持会话不变
+func WithSessionID(sessionID string) ClaimsOption {
+	return func(c *JwtClaims) {
+		c.SessionID = sessionID
+	}
+}
+
+// WithAuthMethods 记录用户通过的认证方式, 刷新 token 时保持不变
+func WithAuthMethods(methods ...string) ClaimsOption {
+	return func(c *JwtClaims) {
+		c.AuthMethods = methods
+	}
+}
+
+// WithTenant 指定 token 当前选择的租户
+func WithTenant(tenantID int64) ClaimsOption {
+	return func(c *JwtClaims) {
+		c.TenantID = tenantID
+	}
+}
+
+// WithAccount 记录登录时使用的账号
+func WithAccount(account string) ClaimsOption {
+	return func(c *JwtClaims) {
+		c.Account = account
+	}
+}
+
+// Tenant 当前选择的租户, 没有选择时为默认租户
+func (c *JwtClaims) Tenant() int64 {
+	if c.TenantID == 0 {
+		return model.DefaultTenantID
+	}
+	return c.TenantID
+}
+
+// TenantFromContext 请求上下文中当前选择的租户, 没有登录信息时为默认租户
+func TenantFromContext(ctx context.Context) int64 {
+	if claims, ok := ctx.Value(constant.UserContextKey).(*JwtClaims); ok {
+		return claims.Tenant()
+	}
+	return model.DefaultTenantID
+}
+
+// ContextWithTenant 复制请求上下文中的登录信息并切换到指定租户, 用于在其他租户中执行操作
+func ContextWithTenant(ctx context.Context, tenantID int64) context.Context {
+	claims := &JwtClaims{TenantID: tenantID}
+	if current, ok := ctx.Value(constant.UserContextKey).(*JwtClaims); ok {
+		copied := *current
+		copied.TenantID = tenantID
+		claims = &copied
+	}
+	return context.WithValue(ctx, constant.UserContextKey, claims)
+}
+
+// UsableRoles 本次请求可以使用的角色
+// 个人访问令牌限定了角色时, 只能使用令牌角色和用户当前角色的交集; 未通过两步验证的 token 不能使用 mfaRequired 中的角色
+func (c *JwtClaims) UsableRoles(roles, mfaRequired []string) []string {
+	if c.Roles != nil {
+		roles = helper.Intersect(roles, c.Roles)
+	}
+	if len(mfaRequired) > 0 && !c.HasAuthMethod(AuthMethodOTP) {
+		roles = slices.DeleteFunc(roles, func(role string) bool {
+			return slices.Contains(mfaRequired, role)
+		})
+	}
+	return roles
+}
+
+// HasAuthMethod 判断签发 token 时用户是否通过了指定的认证方式
+func (c *JwtClaims) HasAuthMethod(method string) bool {
+	return slices.Contains(c.AuthMethods, method)
+}
+
+// NewPersonalAccessClaims 根据个人访问令牌构造 claims, 令牌需要预加载 User 和 Roles
+// 未限定角色时使用用户的全部角色; 限定的角色全部删除后拒绝使用令牌, 避免令牌获得用户的全部角色
+func NewPersonalAccessClaims(pat *model.PersonalAccessToken) (*JwtClaims, error) {
+	claims := &JwtClaims{
+		UserID:    pat.UserID,
+		TokenType: TokenTypePersonalAccess,
+		RegisteredClaims: &jwtv5.RegisteredClaims{
+			IssuedAt:  jwtv5.NewNumericDate(pat.CreatedAt),
+			ExpiresAt: jwtv5.NewNumericDate(pat.ExpiresAt),
+		},
+	}
+	if pat.User != nil {
+		claims.UserName = pat.User.Name
+	}
+	if !pat.Restricted {
+		return claims, nil
+	}
+	if len(pat.Roles) == 0 {
+		return nil, fmt.Errorf("personal access token %d has no available roles", pat.ID)
+	}
+
+	claims.Roles = make([]string, 0, len(pat.Roles))
+	for _, role := range pat.Roles {
+		claims.Roles = append(claims.Roles, role.Name)
+	}
+	// 限定了角色的令牌只能访问角色所属的租户
+	claims.TenantID = pat.Roles[0].TenantID
+	return claims, nil
+}
+
+// TokenPair 一次登录签发的 access token 和 refresh token
+type TokenPair struct {
+	AccessToken   string
+	RefreshToken  string
+	AccessClaims  *JwtClaims
+	RefreshClaims *JwtClaims
+}
+
+func newJwtClaims(userID int64, userName, issuer, tokenType string, expire time.Duration) *JwtClaims {
 	now := time.Now()
 	return &JwtClaims{
-		UserID:   userID,
-		UserName: userName,
+		UserID:    userID,
+		UserName:  userName,
+		TokenType: tokenType,
 		RegisteredClaims: &jwtv5.RegisteredClaims{
+			ID:        uuid.New().String(),
 			Issuer:    issuer,
 			ExpiresAt: jwtv5.NewNumericDate(now.Add(expire)),
 			IssuedAt:  jwtv5.NewNumericDate(now),
@@ -67,21 +264,119 @@ func newJwtClaims(userID int64, userName, issuer string, expire time.Duration) *
 }
 
 func (j *GenerateToken) GenerateToken(id int64, userName string) (token string, err error) {
-	jwtClaims := newJwtClaims(id, userName, j.issuer, j.expire)
-	claims := jwtv5.NewWithClaims(jwtv5.SigningMethodHS256, jwtClaims)
-	token, err = claims.SignedString([]byte(j.secret))
+	jwtClaims := newJwtClaims(id, userName, j.issuer, TokenTypeAccess, j.expire)
+	return j.sign(jwtClaims)
+}
+
+// GenerateTokenPair 签发一对 access token 和 refresh token, 未指定会话时生成新的 sid
+func (j *GenerateToken) GenerateTokenPair(id int64, userName string, opts ...ClaimsOption) (pair *TokenPair, err error) {
+	accessClaims := newJwtClaims(id, userName, j.issuer, TokenTypeAccess, j.expire)
+	refreshClaims := newJwtClaims(id, userName, j.issuer, TokenTypeRefresh, j.refreshExpire)
+	for _, opt := range opts {
+		opt(accessClaims)
+		opt(refreshClaims)
+	}
+	if accessClaims.SessionID == "" {
+		sessionID := uuid.New().String()
+		accessClaims.SessionID = sessionID
+		refreshClaims.SessionID = sessionID
+	}
+
+	pair = &TokenPair{
+		AccessClaims:  accessClaims,
+		RefreshClaims: refreshClaims,
+	}
+	if pair.AccessToken, err = j.sign(accessClaims); err != nil {
+		return nil, err
+	}
+	if pair.RefreshToken, err = j.sign(refreshClaims); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// GenerateMfaToken 签发等待两步验证的临时 token
+func (j *GenerateToken) GenerateMfaToken(id int64, userName string, opts ...ClaimsOption) (token string, jwtClaims *JwtClaims, err error) {
+	jwtClaims = newJwtClaims(id, userName, j.issuer, TokenTypeMfa, j.mfaExpire)
+	for _, opt := range opts {
+		opt(jwtClaims)
+	}
+	if token, err = j.sign(jwtClaims); err != nil {
+		return "", nil, err
+	}
+	return token, jwtClaims, nil
+}
+
+func (j *GenerateToken) sign(jwtClaims *JwtClaims) (string, error) {
+	claims := jwtv5.NewWithClaims(j.method, jwtClaims)
+	var signKey any = []byte(j.secret)
+	if j.keys != nil {
+		key, err := j.keys.current()
+		if err != nil {
+			return "", fmt.Errorf("generate token error: %v", err)
+		}
+		claims.Header["kid"] = key.kid
+		signKey = key.private
+	}
+	token, err := claims.SignedString(signKey)
 	if err != nil {
 		return "", fmt.Errorf("generate token error: %v", err)
 	}
 	return token, nil
 }
 
-// ParseToken 解析token
+// ParseToken 解析 access token, refresh token 不能用于访问接口
 func (j *GenerateToken) ParseToken(tokenString string) (jwtClaims *JwtClaims, err error) {
+	if jwtClaims, err = j.parse(tokenString); err != nil {
+		return nil, err
+	}
+	// 兼容未携带 typ 的旧 token
+	if jwtClaims.TokenType != "" && jwtClaims.TokenType != TokenTypeAccess {
+		return nil, fmt.Errorf("parse token error: unexpected token type %s", jwtClaims.TokenType)
+	}
+	return jwtClaims, nil
+}
+
+// ParseRefreshToken 解析 refresh token
+func (j *GenerateToken) ParseRefreshToken(tokenString string) (jwtClaims *JwtClaims, err error) {
+	if jwtClaims, err = j.parse(tokenString); err != nil {
+		return nil, err
+	}
+	if jwtClaims.TokenType != TokenTypeRefresh {
+		return nil, fmt.Errorf("parse token error: unexpected token type %s", jwtClaims.TokenType)
+	}
+	if jwtClaims.SessionID == "" || jwtClaims.ID == "" {
+		return nil, errors.New("parse token error: refresh token missing sid or jti")
+	}
+	return jwtClaims, nil
+}
+
+// ParseMfaToken 解析等待两步验证的临时 token
+func (j *GenerateToken) ParseMfaToken(tokenString string) (jwtClaims *JwtClaims, err error) {
+	if jwtClaims, err = j.parse(tokenString); err != nil {
+		return nil, err
+	}
+	if jwtClaims.TokenType != TokenTypeMfa {
+		return nil, fmt.Errorf("parse token error: unexpected token type %s", jwtClaims.TokenType)
+	}
+	if jwtClaims.ID == "" {
+		return nil, errors.New("parse token error: mfa token missing jti")
+	}
+	return jwtClaims, nil
+}
+
+func (j *GenerateToken) parse(tokenString string) (jwtClaims *JwtClaims, err error) {
 	jwtClaims = &JwtClaims{}
 	token, err := jwtv5.ParseWithClaims(tokenString, jwtClaims, func(token *jwtv5.Token) (interface{}, error) {
-		return []byte(j.secret), nil
-	})
+		if j.keys == nil {
+			return []byte(j.secret), nil
+		}
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token missing kid header")
+		}
+		return j.keys.lookup(context.Background(), kid)
+	}, jwtv5.WithValidMethods([]string{j.method.Alg()}))
 	if err != nil {
 		return nil, fmt.Errorf("parse token error: %v", err)
 	}
@@ -91,6 +386,14 @@ func (j *GenerateToken) ParseToken(tokenString string) (jwtClaims *JwtClaims, er
 	return nil, fmt.Errorf("parse token error: type not match")
 }
 
+// JWKS 返回验签公钥, 供其他服务在不共享密钥的情况下校验 token
+func (j *GenerateToken) JWKS() *JSONWebKeySet {
+	if j.keys == nil {
+		return &JSONWebKeySet{Keys: []JSONWebKey{}}
+	}
+	return j.keys.JWKS()
+}
+
 func (j *GenerateToken) GetUser(ctx context.Context) (*JwtClaims, error) {
 	cl := ctx.Value(constant.UserContextKey)
 	if cl == nil {
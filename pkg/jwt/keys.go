@@ -0,0 +1,263 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/store"
+	"go.uber.org/zap"
+)
+
+const (
+	// keyCheckInterval 检查密钥是否需要轮换以及同步其他副本生成的密钥的周期
+	keyCheckInterval = time.Minute
+	// keyReloadBackoff 遇到未知 kid 时重新加载密钥的最小间隔
+	keyReloadBackoff = 10 * time.Second
+)
+
+type signingKey struct {
+	kid       string
+	private   crypto.Signer
+	public    crypto.PublicKey
+	createdAt time.Time
+}
+
+// KeySet 非对称签名密钥集合, 最新的密钥用于签名, 已轮换的密钥在其签发的 token 全部过期前仍可用于验签
+// 密钥保存在数据库中, 私钥使用 jwt.secret 加密, 多个副本通过数据库共享密钥
+type KeySet struct {
+	mu       sync.RWMutex
+	method   jwtv5.SigningMethod
+	keys     []*signingKey
+	keyStore store.JwtKeyStorer
+	cipher   *helper.SecretBox
+	rotation time.Duration
+	maxTTL   time.Duration
+	// lastReload 上次因未知 kid 重新加载密钥的时间, 避免伪造的 kid 频繁查询数据库
+	lastReload time.Time
+}
+
+func newKeySet(method jwtv5.SigningMethod, keyStore store.JwtKeyStorer, secret string, rotation, maxTTL time.Duration) (*KeySet, error) {
+	if keyStore == nil {
+		return nil, errors.New("jwt key store is nil")
+	}
+	box, err := helper.NewSecretBox(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &KeySet{
+		method:   method,
+		keyStore: keyStore,
+		cipher:   box,
+		rotation: rotation,
+		maxTTL:   maxTTL,
+	}, nil
+}
+
+// Run 定期检查密钥轮换, ctx 取消后退出
+func (k *KeySet) Run(ctx context.Context) {
+	ticker := time.NewTicker(keyCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Rotate(ctx); err != nil {
+				zap.L().Error("jwt key rotate failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Rotate 从数据库同步密钥, 当前签名密钥超过轮换周期时生成新密钥, 并清理不再需要验签的旧密钥
+func (k *KeySet) Rotate(ctx context.Context) error {
+	keys, err := k.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if len(keys) == 0 || now.Sub(keys[len(keys)-1].createdAt) >= k.rotation {
+		key, err := k.generate(ctx)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		zap.L().Info("jwt signing key rotated", zap.String("kid", key.kid), zap.String("alg", k.method.Alg()))
+	}
+
+	// 第 i 个密钥在第 i+1 个密钥生效后不再签名, 其签发的 token 最晚在 maxTTL 后过期
+	active := make([]*signingKey, 0, len(keys))
+	for i, key := range keys {
+		if i < len(keys)-1 && now.Sub(keys[i+1].createdAt) > k.maxTTL {
+			if err := k.keyStore.Delete(ctx, &model.JwtKey{}, store.Where("kid", key.kid)); err != nil {
+				return err
+			}
+			zap.L().Info("jwt signing key retired", zap.String("kid", key.kid))
+			continue
+		}
+		active = append(active, key)
+	}
+
+	k.mu.Lock()
+	k.keys = active
+	k.mu.Unlock()
+	return nil
+}
+
+// current 当前用于签名的密钥
+func (k *KeySet) current() (*signingKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if len(k.keys) == 0 {
+		return nil, errors.New("no jwt signing key available")
+	}
+	return k.keys[len(k.keys)-1], nil
+}
+
+// lookup 根据 kid 查找验签公钥, 未找到时从数据库重新加载, 以识别其他副本刚生成的密钥
+func (k *KeySet) lookup(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if key := k.find(kid); key != nil {
+		return key.public, nil
+	}
+
+	k.mu.Lock()
+	reloadable := time.Since(k.lastReload) > keyReloadBackoff
+	if reloadable {
+		k.lastReload = time.Now()
+	}
+	k.mu.Unlock()
+	if reloadable {
+		keys, err := k.load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		k.mu.Lock()
+		k.keys = keys
+		k.mu.Unlock()
+		if key := k.find(kid); key != nil {
+			return key.public, nil
+		}
+	}
+	return nil, fmt.Errorf("jwt key %s not found", kid)
+}
+
+func (k *KeySet) find(kid string) *signingKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.kid == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+func (k *KeySet) load(ctx context.Context) ([]*signingKey, error) {
+	_, objs, err := k.keyStore.List(ctx, 0, 0, "", "", store.Where("algorithm", k.method.Alg()))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*signingKey, 0, len(objs))
+	for _, obj := range objs {
+		key, err := k.decode(obj)
+		if err != nil {
+			return nil, fmt.Errorf("decode jwt key %s error: %w", obj.Kid, err)
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].createdAt.Before(keys[j].createdAt)
+	})
+	return keys, nil
+}
+
+func (k *KeySet) generate(ctx context.Context) (*signingKey, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+	switch k.method {
+	case jwtv5.SigningMethodRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwtv5.SigningMethodES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwtv5.SigningMethodEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported jwt signing method %s", k.method.Alg())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("generate jwt key error: %w", err)
+	}
+
+	privateDer, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDer, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := k.cipher.Seal(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDer}))
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &model.JwtKey{
+		Kid:        uuid.New().String(),
+		Algorithm:  k.method.Alg(),
+		PrivateKey: encrypted,
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer})),
+	}
+	if err := k.keyStore.Create(ctx, obj); err != nil {
+		return nil, err
+	}
+	return &signingKey{
+		kid:       obj.Kid,
+		private:   private,
+		public:    private.Public(),
+		createdAt: obj.CreatedAt,
+	}, nil
+}
+
+func (k *KeySet) decode(obj *model.JwtKey) (*signingKey, error) {
+	plain, err := k.cipher.Open(obj.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt private key error, jwt.secret may have changed: %w", err)
+	}
+	block, _ := pem.Decode(plain)
+	if block == nil {
+		return nil, errors.New("invalid private key pem")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key is not a signer")
+	}
+	return &signingKey{
+		kid:       obj.Kid,
+		private:   private,
+		public:    private.Public(),
+		createdAt: obj.CreatedAt,
+	}, nil
+}
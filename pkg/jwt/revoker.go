@@ -0,0 +1,125 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+	"github.com/yiran15/api-server/base/conf"
+	"github.com/yiran15/api-server/store"
+)
+
+// Revoker token 吊销接口, 吊销记录保存在 redis 中, 过期时间与 token 的剩余有效期一致
+type Revoker interface {
+	// RevokeToken 吊销单个 token
+	RevokeToken(ctx context.Context, claims *JwtClaims) error
+	// ConsumeToken 原子地吊销只能使用一次的 token, token 已被吊销时返回 false
+	ConsumeToken(ctx context.Context, claims *JwtClaims) (bool, error)
+	// ReleaseToken 撤销 ConsumeToken, token 可以再次使用
+	ReleaseToken(ctx context.Context, claims *JwtClaims) error
+	// RevokeUser 吊销用户在此之前签发的所有 token
+	RevokeUser(ctx context.Context, userID int64) error
+	// IsRevoked 判断 token 是否已被吊销
+	IsRevoked(ctx context.Context, claims *JwtClaims) (bool, error)
+}
+
+type revoker struct {
+	cacheStore store.CacheStorer
+	// maxTTL token 的最长有效期, 用户级别的吊销记录保留到此前签发的 token 全部过期
+	maxTTL time.Duration
+}
+
+func NewRevoker(cacheStore store.CacheStorer) (Revoker, error) {
+	expire, err := conf.GetJwtExpirationTime()
+	if err != nil {
+		return nil, err
+	}
+	refreshExpire, err := conf.GetJwtRefreshExpirationTime()
+	if err != nil {
+		return nil, err
+	}
+	return &revoker{
+		cacheStore: cacheStore,
+		maxTTL:     max(expire, refreshExpire),
+	}, nil
+}
+
+func (r *revoker) RevokeToken(ctx context.Context, claims *JwtClaims) error {
+	if claims.RegisteredClaims == nil || claims.ID == "" || claims.ExpiresAt == nil {
+		return fmt.Errorf("revoke token error: token missing jti or exp")
+	}
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+	return r.cacheStore.SetString(ctx, store.RevokedTokenType, claims.ID, strconv.FormatInt(claims.UserID, 10), &ttl)
+}
+
+func (r *revoker) ConsumeToken(ctx context.Context, claims *JwtClaims) (bool, error) {
+	if claims.RegisteredClaims == nil || claims.ID == "" || claims.ExpiresAt == nil {
+		return false, fmt.Errorf("consume token error: token missing jti or exp")
+	}
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return false, nil
+	}
+	return r.cacheStore.SetStringNX(ctx, store.RevokedTokenType, claims.ID, strconv.FormatInt(claims.UserID, 10), &ttl)
+}
+
+func (r *revoker) ReleaseToken(ctx context.Context, claims *JwtClaims) error {
+	if claims.RegisteredClaims == nil || claims.ID == "" {
+		return nil
+	}
+	return r.cacheStore.DelKey(ctx, store.RevokedTokenType, claims.ID)
+}
+
+// revokedSecondsLimit 小于该值的吊销时间是旧版本按秒保存的记录
+const revokedSecondsLimit = 1e11
+
+func init() {
+	// 签发时间精确到毫秒, 吊销用户后同一秒内重新登录签发的 token 不会被误判为已吊销
+	jwtv5.TimePrecision = time.Millisecond
+}
+
+func (r *revoker) RevokeUser(ctx context.Context, userID int64) error {
+	return r.cacheStore.SetString(ctx, store.RevokedUserType, userID, strconv.FormatInt(time.Now().UnixMilli(), 10), &r.maxTTL)
+}
+
+func (r *revoker) IsRevoked(ctx context.Context, claims *JwtClaims) (bool, error) {
+	if claims.RegisteredClaims == nil {
+		return false, nil
+	}
+
+	if claims.ID != "" {
+		userID, err := r.cacheStore.GetString(ctx, store.RevokedTokenType, claims.ID)
+		if err != nil {
+			return false, err
+		}
+		if userID != "" {
+			return true, nil
+		}
+	}
+
+	revokedAt, err := r.cacheStore.GetString(ctx, store.RevokedUserType, claims.UserID)
+	if err != nil {
+		return false, err
+	}
+	if revokedAt == "" {
+		return false, nil
+	}
+	revoked, err := strconv.ParseInt(revokedAt, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("parse user revoked time error: %w", err)
+	}
+	if claims.IssuedAt == nil {
+		return true, nil
+	}
+	// 旧版本按秒保存的记录, 吊销时间之前 (含同一秒) 签发的 token 全部失效
+	if revoked < revokedSecondsLimit {
+		return claims.IssuedAt.Unix() <= revoked, nil
+	}
+	// 吊销时间之前签发的 token 全部失效, 之后签发的 token 不受影响
+	return claims.IssuedAt.UnixMilli() < revoked, nil
+}
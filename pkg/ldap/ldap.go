@@ -0,0 +1,288 @@
+// Package ldap 使用 LDAP / Active Directory 校验用户名和密码, 并将目录中的用户转换为外部身份
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/pkg/oauth"
+)
+
+// Provider 外部身份和登录事件中使用的 provider 名称
+const Provider = "ldap"
+
+// 登录时校验密码的后端
+const (
+	BackendLocal = "local"
+	BackendLDAP  = "ldap"
+)
+
+const usernamePlaceholder = "{username}"
+
+var (
+	// ErrUserNotFound 目录中没有与用户名匹配的用户
+	ErrUserNotFound = errors.New("ldap user not found")
+	// ErrInvalidCredentials 用户密码错误
+	ErrInvalidCredentials = errors.New("ldap invalid credentials")
+)
+
+// Config LDAP 配置
+type Config struct {
+	// URL ldap://host:389 或 ldaps://host:636
+	URL string `mapstructure:"url"`
+	// StartTLS 使用 ldap:// 时通过 StartTLS 升级为加密连接
+	StartTLS bool `mapstructure:"startTLS"`
+	// InsecureSkipVerify 不校验服务端证书, 只用于测试环境
+	InsecureSkipVerify bool `mapstructure:"insecureSkipVerify"`
+	// CAFile 校验服务端证书的 CA, 为空时使用系统 CA
+	CAFile  string        `mapstructure:"caFile"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	// BindDN 查询用户使用的账号, 为空时匿名查询
+	// 包含 {username} 时直接使用登录用户的凭证绑定, 如 {username}@corp.example.com, 不需要服务账号
+	BindDN       string `mapstructure:"bindDN"`
+	BindPassword string `mapstructure:"bindPassword"`
+	BaseDN       string `mapstructure:"baseDN"`
+	// Filter 查询用户的过滤器, {username} 替换为转义后的登录用户名
+	Filter string `mapstructure:"filter"`
+	// SubjectAttribute 用户唯一标识的属性, AD 使用 objectGUID, 属性不存在时使用 DN
+	SubjectAttribute string `mapstructure:"subjectAttribute"`
+	// GroupAttribute 用户所属组的属性
+	GroupAttribute string `mapstructure:"groupAttribute"`
+	// Precedence 先校验的后端, local 先校验本地密码, ldap 先校验 LDAP, 默认 local
+	Precedence string `mapstructure:"precedence"`
+	// Mapping 用户属性到本地用户和角色的映射, 与 oauth2 provider 的 mapping 相同, claim 为属性名称
+	Mapping oauth.MappingConfig `mapstructure:"mapping"`
+}
+
+type LDAP struct {
+	config    *Config
+	tlsConfig *tls.Config
+	mapper    *oauth.Mapper
+}
+
+// NewLDAP 读取 ldap 配置, 未启用时返回 nil
+func NewLDAP() (*LDAP, error) {
+	if !viper.GetBool("ldap.enable") {
+		return nil, nil
+	}
+	config := &Config{}
+	if err := viper.UnmarshalKey("ldap", config); err != nil {
+		return nil, fmt.Errorf("unmarshal ldap config failed: %w", err)
+	}
+	return New(config)
+}
+
+// New 按配置创建 LDAP 认证, 未配置的项使用默认值
+func New(config *Config) (*LDAP, error) {
+	if config.URL == "" || config.BaseDN == "" {
+		return nil, errors.New("ldap url and baseDN are required")
+	}
+	switch config.Precedence {
+	case "":
+		config.Precedence = BackendLocal
+	case BackendLocal, BackendLDAP:
+	default:
+		return nil, fmt.Errorf("invalid ldap precedence %s, must be local or ldap", config.Precedence)
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.Filter == "" {
+		config.Filter = "(&(objectClass=person)(|(uid={username})(mail={username})))"
+	}
+	if !strings.Contains(config.Filter, usernamePlaceholder) {
+		return nil, fmt.Errorf("ldap filter must contain %s", usernamePlaceholder)
+	}
+	if config.SubjectAttribute == "" {
+		config.SubjectAttribute = "entryUUID"
+	}
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = "memberOf"
+	}
+	if config.Mapping.User.Department == "" {
+		config.Mapping.User.Department = "department"
+	}
+	if len(config.Mapping.Roles.Rules) > 0 && config.Mapping.Roles.Claim == "" {
+		config.Mapping.Roles.Claim = config.GroupAttribute
+	}
+	mapper, err := oauth.NewMapper(config.Mapping)
+	if err != nil {
+		return nil, fmt.Errorf("ldap %w", err)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CAFile != "" {
+		data, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ldap caFile failed: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("ldap caFile contains no certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return &LDAP{config: config, tlsConfig: tlsConfig, mapper: mapper}, nil
+}
+
+// Mapper 返回用户属性的映射
+func (l *LDAP) Mapper() *oauth.Mapper {
+	return l.mapper
+}
+
+// Backends 按校验顺序返回登录后端
+func (l *LDAP) Backends() []string {
+	if l.config.Precedence == BackendLDAP {
+		return []string{BackendLDAP, BackendLocal}
+	}
+	return []string{BackendLocal, BackendLDAP}
+}
+
+// Authenticate 查询用户并使用用户的 DN 和密码绑定, 成功时返回用户的外部身份
+// 用户不存在时返回 ErrUserNotFound, 密码错误时返回 ErrInvalidCredentials
+func (l *LDAP) Authenticate(_ context.Context, username, password string) (*oauth.Identity, error) {
+	// 空密码的绑定是匿名绑定, 大多数服务端会返回成功
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	direct := strings.Contains(l.config.BindDN, usernamePlaceholder)
+	if direct {
+		bindDN := strings.ReplaceAll(l.config.BindDN, usernamePlaceholder, goldap.EscapeDN(username))
+		if err := bind(conn, bindDN, password); err != nil {
+			return nil, err
+		}
+	} else if l.config.BindDN != "" {
+		if err := conn.Bind(l.config.BindDN, l.config.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap service account bind failed: %w", err)
+		}
+	}
+
+	entry, err := l.search(conn, username)
+	if err != nil {
+		return nil, err
+	}
+	if !direct {
+		if err := bind(conn, entry.DN, password); err != nil {
+			return nil, err
+		}
+	}
+	return l.identity(entry), nil
+}
+
+func (l *LDAP) dial() (*goldap.Conn, error) {
+	conn, err := goldap.DialURL(l.config.URL,
+		goldap.DialWithDialer(&net.Dialer{Timeout: l.config.Timeout}),
+		goldap.DialWithTLSConfig(l.tlsConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ldap connect failed: %w", err)
+	}
+	conn.SetTimeout(l.config.Timeout)
+	if l.config.StartTLS {
+		if err := conn.StartTLS(l.tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap start tls failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (l *LDAP) search(conn *goldap.Conn, username string) (*goldap.Entry, error) {
+	filter := strings.ReplaceAll(l.config.Filter, usernamePlaceholder, goldap.EscapeFilter(username))
+	req := goldap.NewSearchRequest(l.config.BaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 2, int(l.config.Timeout.Seconds()), false,
+		filter, []string{"*", l.config.SubjectAttribute, l.config.GroupAttribute}, nil)
+	res, err := conn.Search(req)
+	if err != nil && !goldap.IsErrorWithCode(err, goldap.LDAPResultSizeLimitExceeded) {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultNoSuchObject) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("ldap search failed: %w", err)
+	}
+	if res == nil || len(res.Entries) == 0 {
+		return nil, ErrUserNotFound
+	}
+	// 用户名匹配多个用户时无法确定登录的是哪个用户
+	if len(res.Entries) > 1 {
+		return nil, fmt.Errorf("ldap filter matched multiple users for %s", username)
+	}
+	return res.Entries[0], nil
+}
+
+// bind 使用用户凭证绑定, 凭证错误时返回 ErrInvalidCredentials
+func bind(conn *goldap.Conn, dn, password string) error {
+	err := conn.Bind(dn, password)
+	if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+		return ErrInvalidCredentials
+	}
+	if err != nil {
+		return fmt.Errorf("ldap bind failed: %w", err)
+	}
+	return nil
+}
+
+// identity 将目录中的用户转换为外部身份, 单值属性为字符串, 多值属性和用户组为字符串数组
+func (l *LDAP) identity(entry *goldap.Entry) *oauth.Identity {
+	claims := map[string]any{"dn": entry.DN}
+	for _, attr := range entry.Attributes {
+		if len(attr.Values) == 0 || !utf8.ValidString(attr.Values[0]) {
+			continue
+		}
+		if len(attr.Values) == 1 && !strings.EqualFold(attr.Name, l.config.GroupAttribute) {
+			claims[attr.Name] = attr.Values[0]
+			continue
+		}
+		values := make([]any, len(attr.Values))
+		for i, v := range attr.Values {
+			values[i] = v
+		}
+		claims[attr.Name] = values
+	}
+
+	subject := entry.DN
+	if raw := entry.GetEqualFoldRawAttributeValue(l.config.SubjectAttribute); len(raw) > 0 {
+		subject = string(raw)
+		if !utf8.Valid(raw) {
+			// AD 的 objectGUID 为二进制
+			subject = hex.EncodeToString(raw)
+		}
+	}
+
+	first := func(names ...string) string {
+		for _, name := range names {
+			if v := entry.GetEqualFoldAttributeValue(name); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	return &oauth.Identity{
+		Provider: Provider,
+		Type:     Provider,
+		Subject:  subject,
+		Email:    first("mail"),
+		// 目录中的邮箱由管理员维护, 视为已验证
+		EmailVerified: true,
+		Name:          first("uid", "sAMAccountName", "cn"),
+		NickName:      first("displayName", "cn"),
+		Mobile:        first("mobile", "telephoneNumber"),
+		Claims:        claims,
+	}
+}
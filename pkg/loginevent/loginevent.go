@@ -0,0 +1,90 @@
+// Package loginevent 记录登录和认证事件, 与管理操作的审计日志分开保存, 供用户查看登录历史
+package loginevent
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/base/log"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/store"
+	"go.uber.org/zap"
+)
+
+// 事件
+const (
+	EventLogin          = "login"
+	EventLoginMfa       = "login_mfa"
+	EventOAuth2Callback = "oauth2_callback"
+	EventOAuth2Activate = "oauth2_activate"
+	EventLogout         = "logout"
+	EventAuthZDenied    = "authz_denied"
+)
+
+// 失败原因或说明, 其余失败原因为错误信息
+const (
+	ReasonUserNotFound    = "user_not_found"
+	ReasonInvalidPassword = "invalid_password"
+	ReasonInvalidCode     = "invalid_code"
+	ReasonMfaRequired     = "mfa_required"
+	ReasonInactive        = "inactive"
+	ReasonNoRoles         = "no_roles"
+	ReasonNoPermission    = "no_permission"
+)
+
+const (
+	maxReasonLength    = 255
+	maxUserAgentLength = 255
+)
+
+// Recorder 登录事件记录接口
+type Recorder interface {
+	// Record 记录一次事件, err 为事件的结果, event.Reason 为空时使用 err 作为失败原因
+	// 请求 id、客户端 IP 和 User-Agent 为空时从 ctx 中获取, 写入失败只记录日志
+	Record(ctx context.Context, event *model.LoginEvent, err error)
+}
+
+type recorder struct {
+	eventStore store.LoginEventStorer
+}
+
+func NewRecorder(eventStore store.LoginEventStorer) Recorder {
+	return &recorder{
+		eventStore: eventStore,
+	}
+}
+
+func (r *recorder) Record(ctx context.Context, event *model.LoginEvent, err error) {
+	if event.RequestID == "" {
+		event.RequestID = helper.GetRequestIDFromContext(ctx)
+	}
+	if event.IP == "" {
+		event.IP = helper.GetClientIPFromContext(ctx)
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = helper.GetUserAgentFromContext(ctx)
+	}
+	event.UserAgent = truncate(event.UserAgent, maxUserAgentLength)
+
+	event.Result = model.LoginResultSuccess
+	if err != nil {
+		event.Result = model.LoginResultFailure
+		if event.Reason == "" {
+			event.Reason = err.Error()
+		}
+	}
+	event.Reason = truncate(event.Reason, maxReasonLength)
+
+	if err := r.eventStore.Create(ctx, event); err != nil {
+		log.WithRequestID(ctx).Error("write login event failed", zap.String("event", event.Event), zap.Int64("userID", event.UserID), zap.Error(err))
+	}
+}
+
+// truncate 按字符截断, 表字段长度按字符计算
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,154 @@
+// Package loginguard 防止密码登录被暴力破解, 失败记录和锁定状态保存在 redis 中, 多副本共享
+package loginguard
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yiran15/api-server/base/conf"
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/base/log"
+	"github.com/yiran15/api-server/store"
+	"go.uber.org/zap"
+)
+
+// Guard 登录保护接口
+// 同一邮箱在窗口内失败次数过多时临时锁定该邮箱, 连续锁定的时长指数增长;
+// 同一 IP 在窗口内失败次数过多时拒绝该 IP 的登录请求
+// 计数与邮箱是否存在无关, 返回的错误也相同, 避免被用于探测用户是否存在
+type Guard interface {
+	// Check 登录前检查邮箱和 IP 是否被限制, 被限制时返回 constant.ErrTooManyLoginAttempts
+	Check(ctx context.Context, email, ip string) error
+	// Fail 记录一次登录失败, 达到阈值时锁定邮箱
+	Fail(ctx context.Context, email, ip string) error
+	// Succeed 登录成功后清除邮箱的失败记录
+	Succeed(ctx context.Context, email string) error
+	// Unlock 解除邮箱的锁定
+	Unlock(ctx context.Context, email string) error
+}
+
+type guard struct {
+	cacheStore       store.CacheStorer
+	window           time.Duration
+	maxEmailFailures int64
+	maxIPFailures    int64
+	lockout          time.Duration
+	maxLockout       time.Duration
+}
+
+func NewGuard(cacheStore store.CacheStorer) (Guard, error) {
+	window, err := conf.GetLoginFailureWindow()
+	if err != nil {
+		return nil, err
+	}
+	lockout, err := conf.GetLoginLockoutDuration()
+	if err != nil {
+		return nil, err
+	}
+	maxLockout, err := conf.GetLoginMaxLockoutDuration()
+	if err != nil {
+		return nil, err
+	}
+	return &guard{
+		cacheStore:       cacheStore,
+		window:           window,
+		maxEmailFailures: int64(conf.GetLoginMaxEmailFailures()),
+		maxIPFailures:    int64(conf.GetLoginMaxIPFailures()),
+		lockout:          lockout,
+		maxLockout:       max(lockout, maxLockout),
+	}, nil
+}
+
+func (g *guard) Check(ctx context.Context, email, ip string) error {
+	email = normalizeEmail(email)
+	locked, err := g.cacheStore.GetString(ctx, store.LoginLockType, email)
+	if err != nil {
+		return err
+	}
+	if locked != "" {
+		log.WithRequestID(ctx).Warn("login rejected, email locked", zap.String("email", email), zap.String("ip", ip))
+		return constant.ErrTooManyLoginAttempts
+	}
+
+	if ip == "" {
+		return nil
+	}
+	n, err := g.cacheStore.SlidingWindowCount(ctx, store.LoginFailureType, ipKey(ip), g.window)
+	if err != nil {
+		return err
+	}
+	if n >= g.maxIPFailures {
+		log.WithRequestID(ctx).Warn("login rejected, too many failures from ip", zap.String("ip", ip), zap.Int64("failures", n))
+		return constant.ErrTooManyLoginAttempts
+	}
+	return nil
+}
+
+func (g *guard) Fail(ctx context.Context, email, ip string) error {
+	email = normalizeEmail(email)
+	if ip != "" {
+		if _, err := g.cacheStore.SlidingWindowAdd(ctx, store.LoginFailureType, ipKey(ip), g.window); err != nil {
+			return err
+		}
+	}
+
+	n, err := g.cacheStore.SlidingWindowAdd(ctx, store.LoginFailureType, emailKey(email), g.window)
+	if err != nil {
+		return err
+	}
+	if n < g.maxEmailFailures {
+		return nil
+	}
+
+	// 锁定级别在最长锁定时长的两倍内没有再次锁定时重置
+	level, err := g.cacheStore.Incr(ctx, store.LoginLockLevelType, email, 2*g.maxLockout)
+	if err != nil {
+		return err
+	}
+	duration := g.lockoutDuration(level)
+	if err := g.cacheStore.SetString(ctx, store.LoginLockType, email, strconv.FormatInt(level, 10), &duration); err != nil {
+		return err
+	}
+	log.WithRequestID(ctx).Warn("login locked", zap.String("email", email), zap.String("ip", ip), zap.Int64("level", level), zap.Duration("duration", duration))
+	// 解锁后重新计数
+	return g.cacheStore.DelKey(ctx, store.LoginFailureType, emailKey(email))
+}
+
+func (g *guard) Succeed(ctx context.Context, email string) error {
+	email = normalizeEmail(email)
+	if err := g.cacheStore.DelKey(ctx, store.LoginFailureType, emailKey(email)); err != nil {
+		return err
+	}
+	return g.cacheStore.DelKey(ctx, store.LoginLockLevelType, email)
+}
+
+func (g *guard) Unlock(ctx context.Context, email string) error {
+	email = normalizeEmail(email)
+	if err := g.cacheStore.DelKey(ctx, store.LoginLockType, email); err != nil {
+		return err
+	}
+	return g.Succeed(ctx, email)
+}
+
+// lockoutDuration 第 level 次锁定的时长, 每次翻倍, 不超过最长锁定时长
+func (g *guard) lockoutDuration(level int64) time.Duration {
+	duration := g.lockout
+	for i := int64(1); i < level && duration < g.maxLockout; i++ {
+		duration *= 2
+	}
+	return min(duration, g.maxLockout)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func emailKey(email string) string {
+	return "email:" + email
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// logMailer 只把邮件内容输出到日志, 用于本地开发
+type logMailer struct {
+	from string
+}
+
+func (m *logMailer) Send(_ context.Context, msg *Message) error {
+	zap.L().Info("send mail", zap.String("from", m.from), zap.Strings("to", msg.To), zap.String("subject", msg.Subject), zap.String("body", msg.Body))
+	return nil
+}
+
+// fileMailer 把邮件写入目录下的 .eml 文件, 用于本地开发和测试
+type fileMailer struct {
+	from string
+	dir  string
+}
+
+func (m *fileMailer) Send(_ context.Context, msg *Message) error {
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return fmt.Errorf("create mail dir error: %w", err)
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102150405"), uuid.New().String()[:8])
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, buildMessage(m.from, msg), 0o600); err != nil {
+		return fmt.Errorf("write mail file error: %w", err)
+	}
+	zap.L().Info("mail written to file", zap.Strings("to", msg.To), zap.String("subject", msg.Subject), zap.String("path", path))
+	return nil
+}
@@ -0,0 +1,78 @@
+// Package mailer 发送邮件, 支持 SMTP, 本地开发时可以只输出到日志或写入文件
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+const (
+	DriverSMTP = "smtp"
+	DriverLog  = "log"
+	DriverFile = "file"
+)
+
+// Message 纯文本邮件
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewMailer 根据 mail.driver 创建邮件发送器, 默认输出到日志
+func NewMailer() (Mailer, error) {
+	from := viper.GetString("mail.from")
+	if from == "" {
+		from = "noreply@localhost"
+	}
+
+	driver := viper.GetString("mail.driver")
+	switch driver {
+	case "", DriverLog:
+		return &logMailer{from: from}, nil
+	case DriverFile:
+		dir := viper.GetString("mail.file.dir")
+		if dir == "" {
+			dir = "./mail"
+		}
+		return &fileMailer{from: from, dir: dir}, nil
+	case DriverSMTP:
+		return newSMTPMailer(from)
+	default:
+		return nil, fmt.Errorf("invalid mail.driver %s, must be smtp, log or file", driver)
+	}
+}
+
+// buildMessage 生成 RFC 5322 格式的邮件内容
+func buildMessage(from string, msg *Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", uuid.New().String(), domainOf(from))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return buf.Bytes()
+}
+
+func domainOf(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return strings.Trim(address[i+1:], "> ")
+	}
+	return "localhost"
+}
@@ -0,0 +1,133 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// smtpTLSStartTLS 服务器支持时使用 STARTTLS 升级连接, 通常用于 587 端口
+	smtpTLSStartTLS = "starttls"
+	// smtpTLSImplicit 直接建立 TLS 连接, 通常用于 465 端口
+	smtpTLSImplicit = "tls"
+	// smtpTLSNone 不加密, 只用于本地调试
+	smtpTLSNone = "none"
+
+	smtpTimeout = 30 * time.Second
+)
+
+type smtpMailer struct {
+	from     string
+	host     string
+	port     int
+	username string
+	password string
+	tls      string
+}
+
+func newSMTPMailer(from string) (*smtpMailer, error) {
+	m := &smtpMailer{
+		from:     from,
+		host:     viper.GetString("mail.smtp.host"),
+		port:     viper.GetInt("mail.smtp.port"),
+		username: viper.GetString("mail.smtp.username"),
+		password: viper.GetString("mail.smtp.password"),
+		tls:      viper.GetString("mail.smtp.tls"),
+	}
+	if m.host == "" {
+		return nil, errors.New("mail.smtp.host is empty")
+	}
+	if m.tls == "" {
+		m.tls = smtpTLSStartTLS
+	}
+	if m.port == 0 {
+		m.port = 587
+		if m.tls == smtpTLSImplicit {
+			m.port = 465
+		}
+	}
+	switch m.tls {
+	case smtpTLSStartTLS, smtpTLSImplicit, smtpTLSNone:
+	default:
+		return nil, fmt.Errorf("invalid mail.smtp.tls %s, must be starttls, tls or none", m.tls)
+	}
+	return m, nil
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg *Message) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, smtpTimeout)
+		defer cancel()
+	}
+
+	from, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return fmt.Errorf("invalid mail.from: %w", err)
+	}
+
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	if m.tls == smtpTLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: m.host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("dial smtp server error: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("create smtp client error: %w", err)
+	}
+	defer client.Close()
+
+	if m.tls == smtpTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return fmt.Errorf("smtp starttls error: %w", err)
+		}
+	}
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("smtp auth error: %w", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp mail from error: %w", err)
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp rcpt to %s error: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data error: %w", err)
+	}
+	if _, err := w.Write(buildMessage(m.from, msg)); err != nil {
+		return fmt.Errorf("smtp write message error: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp send message error: %w", err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,70 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// appTokenLeeway 应用 access token 提前刷新的时间, 避免使用即将过期的 token
+const appTokenLeeway = time.Minute
+
+// httpClient 钉钉、企业微信和 GitHub 接口使用的 http client
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// appToken 钉钉和企业微信的应用 access token, 用于查询通讯录
+// 有效期内复用, 两个平台的获取接口都有频率限制
+type appToken struct {
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// get 返回未过期的 token, 过期时调用 fetch 重新获取
+func (t *appToken) get(ctx context.Context, fetch func(ctx context.Context) (string, time.Duration, error)) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.expiresAt) {
+		return t.token, nil
+	}
+	token, ttl, err := fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("fetch app access token failed: %w", err)
+	}
+	if token == "" {
+		return "", fmt.Errorf("fetch app access token failed: empty token")
+	}
+	t.token = token
+	t.expiresAt = time.Now().Add(ttl - appTokenLeeway)
+	return token, nil
+}
+
+// corpResponse 钉钉旧版接口和企业微信接口的错误码, HTTP 状态码为 200 时 errcode 不为 0 也表示失败
+type corpResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (r *corpResponse) err(api string) error {
+	if r.ErrCode == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s returned errcode %d: %s", api, r.ErrCode, r.ErrMsg)
+}
+
+// decodeProfile 将用户信息解析到结构体, 同时返回全部字段作为 claims, 去掉错误码
+func decodeProfile(data []byte, user any) (map[string]any, error) {
+	if err := json.Unmarshal(data, user); err != nil {
+		return nil, err
+	}
+	claims := make(map[string]any)
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, err
+	}
+	delete(claims, "errcode")
+	delete(claims, "errmsg")
+	return claims, nil
+}
@@ -0,0 +1,175 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yiran15/api-server/base/log"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+const (
+	dingtalkAuthURL = "https://login.dingtalk.com/oauth2/auth"
+	dingtalkAPIURL  = "https://api.dingtalk.com"
+	// dingtalkOAPIURL 旧版接口, 按 unionId 查询企业成员只有旧版接口
+	dingtalkOAPIURL = "https://oapi.dingtalk.com"
+	// dingtalkErrUserNotFound 企业中不存在该 unionId 对应的成员
+	dingtalkErrUserNotFound = 60121
+)
+
+var dingtalkScopes = []string{"openid"}
+
+// dingtalkUser contact/users/me 返回的个人信息
+type dingtalkUser struct {
+	UnionID   string `json:"unionId"`
+	Nick      string `json:"nick"`
+	AvatarURL string `json:"avatarUrl"`
+	Mobile    string `json:"mobile"`
+	Email     string `json:"email"`
+}
+
+type dingtalkDriver struct {
+	baseDriver
+	apiURL string
+	// appToken 应用 access token, 用于查询企业通讯录
+	appToken appToken
+}
+
+func newDingTalkDriver(_ string, config *OAuth2ProviderConfig) (ProviderDriver, error) {
+	d := &dingtalkDriver{baseDriver: newBaseDriver(config), apiURL: strings.TrimSuffix(orDefault(config.ApiUrl, dingtalkAPIURL), "/")}
+	d.withDefaults(dingtalkAuthURL, "", dingtalkScopes)
+	return d, nil
+}
+
+// AuthCodeURL 钉钉要求 prompt=consent
+func (d *dingtalkDriver) AuthCodeURL(_ context.Context, params *AuthParams) (string, error) {
+	return d.config.AuthCodeURL(params.State, oauth2.SetAuthURLParam("prompt", "consent")), nil
+}
+
+// Exchange 使用授权码换取用户 access token, 钉钉的 token 接口使用 JSON 请求体, 不兼容标准的 OAuth2 token 请求
+func (d *dingtalkDriver) Exchange(ctx context.Context, code string, _ *AuthParams) (*oauth2.Token, error) {
+	var res struct {
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+		ExpireIn     int64  `json:"expireIn"`
+	}
+	err := doJSON(ctx, httpClient, http.MethodPost, d.apiURL+"/v1.0/oauth2/userAccessToken", nil, map[string]string{
+		"clientId":     d.config.ClientID,
+		"clientSecret": d.config.ClientSecret,
+		"code":         code,
+		"grantType":    "authorization_code",
+	}, &res)
+	if err != nil {
+		return nil, fmt.Errorf("dingtalk exchange token failed: %w", err)
+	}
+	if res.AccessToken == "" {
+		return nil, errors.New("dingtalk exchange token failed: empty access token")
+	}
+	return &oauth2.Token{
+		AccessToken:  res.AccessToken,
+		RefreshToken: res.RefreshToken,
+		Expiry:       time.Now().Add(time.Duration(res.ExpireIn) * time.Second),
+	}, nil
+}
+
+// Identity 查询钉钉用户的个人信息, 再按 unionId 查询企业通讯录中的成员信息
+// unionId 在同一开发者的应用间不变, 作为用户标识; 企业邮箱由企业管理员分配, 视为已验证, 个人邮箱未经验证
+// 只有企业内部应用有通讯录权限, 查询失败或用户不是企业成员时只使用个人信息
+func (d *dingtalkDriver) Identity(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	var raw json.RawMessage
+	header := http.Header{"x-acs-dingtalk-access-token": {token.AccessToken}}
+	if err := doJSON(ctx, httpClient, http.MethodGet, d.apiURL+"/v1.0/contact/users/me", header, nil, &raw); err != nil {
+		return nil, fmt.Errorf("fetch dingtalk user failed: %w", err)
+	}
+	user := new(dingtalkUser)
+	claims, err := decodeProfile(raw, user)
+	if err != nil {
+		return nil, err
+	}
+	if user.UnionID == "" {
+		return nil, errors.New("dingtalk user unionId is empty")
+	}
+	identity := &Identity{
+		Subject:  user.UnionID,
+		Email:    user.Email,
+		Name:     user.Nick,
+		NickName: user.Nick,
+		Avatar:   user.AvatarURL,
+		Mobile:   user.Mobile,
+		Claims:   claims,
+	}
+
+	member, err := d.member(ctx, user.UnionID)
+	if err != nil {
+		log.WithRequestID(ctx).Warn("query dingtalk member by unionId failed", zap.String("unionId", user.UnionID), zap.Error(err))
+	}
+	if member != nil {
+		if name := valueString(member["name"]); name != "" {
+			identity.NickName = name
+		}
+		if email := valueString(member["org_email"]); email != "" {
+			identity.Email, identity.EmailVerified = email, true
+		}
+		// 个人信息中已有的字段以个人信息为准
+		for k, v := range member {
+			if _, ok := claims[k]; !ok {
+				claims[k] = v
+			}
+		}
+	}
+	return identity, nil
+}
+
+// member 按 unionId 查询企业成员的详细信息, 不是企业成员时返回 nil
+func (d *dingtalkDriver) member(ctx context.Context, unionID string) (map[string]any, error) {
+	accessToken, err := d.appToken.get(ctx, func(ctx context.Context) (string, time.Duration, error) {
+		var res struct {
+			AccessToken string `json:"accessToken"`
+			ExpireIn    int64  `json:"expireIn"`
+		}
+		err := doJSON(ctx, httpClient, http.MethodPost, d.apiURL+"/v1.0/oauth2/accessToken", nil, map[string]string{
+			"appKey":    d.config.ClientID,
+			"appSecret": d.config.ClientSecret,
+		}, &res)
+		return res.AccessToken, time.Duration(res.ExpireIn) * time.Second, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	query := "?access_token=" + url.QueryEscape(accessToken)
+
+	var byUnionID struct {
+		corpResponse
+		Result struct {
+			UserID string `json:"userid"`
+		} `json:"result"`
+	}
+	if err := doJSON(ctx, httpClient, http.MethodPost, dingtalkOAPIURL+"/topapi/user/getbyunionid"+query, nil, map[string]string{"unionid": unionID}, &byUnionID); err != nil {
+		return nil, err
+	}
+	if byUnionID.ErrCode == dingtalkErrUserNotFound {
+		return nil, nil
+	}
+	if err := byUnionID.err("user/getbyunionid"); err != nil {
+		return nil, err
+	}
+
+	var detail struct {
+		corpResponse
+		Result map[string]any `json:"result"`
+	}
+	if err := doJSON(ctx, httpClient, http.MethodPost, dingtalkOAPIURL+"/topapi/v2/user/get"+query, nil, map[string]string{"userid": byUnionID.Result.UserID}, &detail); err != nil {
+		return nil, err
+	}
+	if err := detail.err("v2/user/get"); err != nil {
+		return nil, err
+	}
+	return detail.Result, nil
+}
@@ -0,0 +1,119 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+// Identity provider 用户信息转换后的外部身份, 登录和关联身份只使用该结构, 不依赖具体的 provider
+type Identity struct {
+	// Provider provider 名称, 与配置中的 key 一致
+	Provider string `json:"provider"`
+	// Type provider 类型, 即使用的 driver
+	Type string `json:"type"`
+	// Subject 用户在 provider 中的唯一标识, 不随用户修改邮箱、用户名而变化
+	Subject string `json:"subject"`
+	Email   string `json:"email"`
+	// EmailVerified 邮箱是否经过 provider 验证, 只有验证过的邮箱才会自动关联同邮箱的本地用户
+	EmailVerified bool     `json:"emailVerified"`
+	Name          string   `json:"name"`
+	NickName      string   `json:"nickName"`
+	Avatar        string   `json:"avatar"`
+	Mobile        string   `json:"mobile"`
+	Groups        []string `json:"groups"`
+	Roles         []string `json:"roles"`
+	// Claims provider 返回的全部字段, 用于 mapping 和保存到 user_identities
+	Claims map[string]any `json:"claims"`
+}
+
+// ProviderDriver provider 类型的实现, 负责授权地址、授权码换取 token 和用户信息的转换
+// 每个 provider 配置创建一个实例, 同一类型可以配置多个 provider, 如两个 keycloak realm
+type ProviderDriver interface {
+	// AuthCodeURL 返回授权地址
+	AuthCodeURL(ctx context.Context, params *AuthParams) (string, error)
+	// Exchange 使用授权码换取 token
+	Exchange(ctx context.Context, code string, params *AuthParams) (*oauth2.Token, error)
+	// Identity 使用 token 查询用户信息, 转换为外部身份, Subject 不能为空
+	Identity(ctx context.Context, token *oauth2.Token) (*Identity, error)
+}
+
+// DriverFactory 按 provider 配置创建 driver, 配置不合法时返回错误
+type DriverFactory func(name string, config *OAuth2ProviderConfig) (ProviderDriver, error)
+
+// drivers 按类型名称注册的 driver
+var drivers = map[string]DriverFactory{
+	ProviderTypeFeishu:   newFeishuDriver,
+	ProviderTypeKeycloak: newKeycloakDriver,
+	ProviderTypeOIDC:     newOIDCDriver,
+	ProviderTypeGitHub:   newGitHubDriver,
+	ProviderTypeDingTalk: newDingTalkDriver,
+	ProviderTypeWeCom:    newWeComDriver,
+}
+
+// RegisterDriver 注册 provider 类型, 需要在 NewOAuth2 之前调用, 类型已存在时 panic
+func RegisterDriver(providerType string, factory DriverFactory) {
+	if _, ok := drivers[providerType]; ok {
+		panic(fmt.Sprintf("oauth2 driver %s already registered", providerType))
+	}
+	drivers[providerType] = factory
+}
+
+// baseDriver 标准 OAuth2 授权码流程, 启用 PKCE 时附带 code_challenge 和 code_verifier
+type baseDriver struct {
+	config *oauth2.Config
+	pkce   bool
+}
+
+func newBaseDriver(config *OAuth2ProviderConfig) baseDriver {
+	return baseDriver{
+		config: &oauth2.Config{
+			ClientID:     config.ClientId,
+			ClientSecret: config.ClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  config.AuthUrl,
+				TokenURL: config.TokenUrl,
+			},
+			RedirectURL: config.RedirectUrl,
+			Scopes:      config.Scopes,
+		},
+		pkce: config.PKCE,
+	}
+}
+
+// withDefaults 使用内置类型的默认端点和 scopes, 已配置的值不覆盖
+func (d *baseDriver) withDefaults(authURL, tokenURL string, scopes []string) {
+	d.config.Endpoint.AuthURL = orDefault(d.config.Endpoint.AuthURL, authURL)
+	d.config.Endpoint.TokenURL = orDefault(d.config.Endpoint.TokenURL, tokenURL)
+	if len(d.config.Scopes) == 0 {
+		d.config.Scopes = scopes
+	}
+}
+
+func (d *baseDriver) AuthCodeURL(_ context.Context, params *AuthParams) (string, error) {
+	var opts []oauth2.AuthCodeOption
+	if d.pkce {
+		opts = append(opts, oauth2.S256ChallengeOption(params.CodeVerifier))
+	}
+	return d.config.AuthCodeURL(params.State, opts...), nil
+}
+
+func (d *baseDriver) Exchange(ctx context.Context, code string, params *AuthParams) (*oauth2.Token, error) {
+	var opts []oauth2.AuthCodeOption
+	if d.pkce {
+		opts = append(opts, oauth2.VerifierOption(params.CodeVerifier))
+	}
+	return d.config.Exchange(ctx, code, opts...)
+}
+
+// userInfo 使用 access token 查询 userinfo 端点, 返回原始响应
+func (d *baseDriver) userInfo(ctx context.Context, token *oauth2.Token, url string) (json.RawMessage, error) {
+	client := d.config.Client(context.WithValue(ctx, oauth2.HTTPClient, httpClient), token)
+	var raw json.RawMessage
+	if err := getJSON(ctx, client, url, &raw); err != nil {
+		return nil, fmt.Errorf("fetch userinfo failed: %w", err)
+	}
+	return raw, nil
+}
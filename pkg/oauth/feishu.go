@@ -0,0 +1,55 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/model"
+	"golang.org/x/oauth2"
+)
+
+// feishuDriver 飞书登录, 用户信息接口的数据包装在 code、msg、data 中
+type feishuDriver struct {
+	baseDriver
+	userInfoURL string
+}
+
+func newFeishuDriver(_ string, config *OAuth2ProviderConfig) (ProviderDriver, error) {
+	return &feishuDriver{baseDriver: newBaseDriver(config), userInfoURL: config.UserInfoUrl}, nil
+}
+
+// Identity 飞书的 user_id 作为用户标识, 企业邮箱由企业管理员分配, 视为已验证
+func (d *feishuDriver) Identity(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	raw, err := d.userInfo(ctx, token, d.userInfoURL)
+	if err != nil {
+		return nil, err
+	}
+	var res helper.HttpResponse
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, err
+	}
+	if res.Code != 0 {
+		return nil, errors.New(res.Msg)
+	}
+	user := new(model.FeiShuUser)
+	claims, err := decodeProfile(res.Data, user)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Subject:  user.UserID,
+		Email:    user.Email,
+		Name:     user.EnName,
+		NickName: user.EnName,
+		Avatar:   user.AvatarUrl,
+		Mobile:   user.Mobile,
+		Claims:   claims,
+	}
+	if user.EnterpriseEmail != "" {
+		identity.Email, identity.EmailVerified = user.EnterpriseEmail, true
+	}
+	return identity, nil
+}
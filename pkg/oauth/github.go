@@ -0,0 +1,89 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// GitHub 默认端点, GitHub Enterprise 需要配置 authUrl、tokenUrl 和 apiUrl (如 https://github.example.com/api/v3)
+const (
+	githubAuthURL  = "https://github.com/login/oauth/authorize"
+	githubTokenURL = "https://github.com/login/oauth/access_token"
+	githubAPIURL   = "https://api.github.com"
+)
+
+// githubScopes user:email 用于查询 /user/emails
+var githubScopes = []string{"read:user", "user:email"}
+
+// githubUser GitHub /user 接口返回的用户信息
+type githubUser struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// githubEmail /user/emails 返回的邮箱
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+type githubDriver struct {
+	baseDriver
+	apiURL string
+}
+
+func newGitHubDriver(_ string, config *OAuth2ProviderConfig) (ProviderDriver, error) {
+	d := &githubDriver{baseDriver: newBaseDriver(config), apiURL: strings.TrimSuffix(orDefault(config.ApiUrl, githubAPIURL), "/")}
+	d.withDefaults(githubAuthURL, githubTokenURL, githubScopes)
+	return d, nil
+}
+
+// Identity 查询 GitHub 用户信息, id 不会变化而 login 可以修改, 因此使用 id 作为用户标识
+// /user 中的邮箱是用户选择公开的邮箱, 可能为空, 因此使用 /user/emails 中已验证的主邮箱
+func (d *githubDriver) Identity(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	raw, err := d.userInfo(ctx, token, d.apiURL+"/user")
+	if err != nil {
+		return nil, err
+	}
+	user := new(githubUser)
+	claims, err := decodeProfile(raw, user)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("github user id is empty")
+	}
+
+	emailsRaw, err := d.userInfo(ctx, token, d.apiURL+"/user/emails")
+	if err != nil {
+		return nil, fmt.Errorf("fetch github emails failed, user:email scope is required: %w", err)
+	}
+	var emails []githubEmail
+	if err := json.Unmarshal(emailsRaw, &emails); err != nil {
+		return nil, err
+	}
+	identity := &Identity{
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Login,
+		NickName: user.Name,
+		Avatar:   user.AvatarURL,
+		Claims:   claims,
+	}
+	for _, v := range emails {
+		if v.Primary && v.Verified {
+			identity.Email, identity.EmailVerified = v.Email, true
+			break
+		}
+	}
+	claims["email"] = identity.Email
+	return identity, nil
+}
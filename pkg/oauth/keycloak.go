@@ -0,0 +1,40 @@
+package oauth
+
+import (
+	"context"
+
+	"github.com/yiran15/api-server/model"
+	"golang.org/x/oauth2"
+)
+
+// keycloakDriver keycloak 登录, 不校验 ID token, 只使用 userinfo 端点, 新接入的 keycloak 建议使用 oidc 类型
+type keycloakDriver struct {
+	baseDriver
+	userInfoURL string
+}
+
+func newKeycloakDriver(_ string, config *OAuth2ProviderConfig) (ProviderDriver, error) {
+	return &keycloakDriver{baseDriver: newBaseDriver(config), userInfoURL: config.UserInfoUrl}, nil
+}
+
+func (d *keycloakDriver) Identity(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	raw, err := d.userInfo(ctx, token, d.userInfoURL)
+	if err != nil {
+		return nil, err
+	}
+	user := new(model.KeycloakUser)
+	claims, err := decodeProfile(raw, user)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{
+		Subject:       user.Sub,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Name:          user.PreferredUsername,
+		NickName:      user.FamilyName + user.GivenName,
+		Groups:        user.Group,
+		Roles:         user.Roles,
+		Claims:        claims,
+	}, nil
+}
@@ -0,0 +1,311 @@
+package oauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/yiran15/api-server/model"
+)
+
+// MappingConfig provider 用户信息到本地用户和角色的映射
+type MappingConfig struct {
+	User  UserMapping `mapstructure:"user"`
+	Roles RoleMapping `mapstructure:"roles"`
+}
+
+// UserMapping 本地用户字段的取值表达式, 未配置的字段使用 provider 的默认映射
+// 表达式为 claim 路径, 如 email、$.realm_access.roles[0]; 包含 {{ 时为 text/template 模板, 如 {{.family_name}}{{.given_name}}
+type UserMapping struct {
+	Name       string `mapstructure:"name"`
+	NickName   string `mapstructure:"nickName"`
+	Email      string `mapstructure:"email"`
+	Mobile     string `mapstructure:"mobile"`
+	Avatar     string `mapstructure:"avatar"`
+	Department string `mapstructure:"department"`
+}
+
+// RoleMapping 按用户组分配角色
+type RoleMapping struct {
+	// Claim 用户组所在的 claim 路径
+	Claim string `mapstructure:"claim"`
+	// Rules 用户组匹配规则, 所有匹配规则的角色合并后分配给用户
+	Rules []RoleRule `mapstructure:"rules"`
+	// Sync 每次登录时按规则重新计算用户角色, IdP 的用户组成为角色的唯一来源; 否则只在创建用户时分配
+	Sync bool `mapstructure:"sync"`
+}
+
+// RoleRule 用户组匹配规则, Group 支持 path.Match 通配符, 如 dev-*
+type RoleRule struct {
+	Group string   `mapstructure:"group"`
+	Roles []string `mapstructure:"roles"`
+}
+
+// Mapper 按配置将 provider 的 claims 转换为本地用户字段和角色
+type Mapper struct {
+	user      map[string]*expression
+	roleClaim *expression
+	rules     []RoleRule
+	sync      bool
+}
+
+// NewMapper 按配置创建 Mapper, 表达式不合法时返回错误
+func NewMapper(config MappingConfig) (*Mapper, error) {
+	m := &Mapper{user: make(map[string]*expression), rules: config.Roles.Rules, sync: config.Roles.Sync}
+	for field, src := range map[string]string{
+		"name":       config.User.Name,
+		"nickName":   config.User.NickName,
+		"email":      config.User.Email,
+		"mobile":     config.User.Mobile,
+		"avatar":     config.User.Avatar,
+		"department": config.User.Department,
+	} {
+		if src == "" {
+			continue
+		}
+		expr, err := newExpression(src)
+		if err != nil {
+			return nil, fmt.Errorf("mapping.user.%s: %w", field, err)
+		}
+		m.user[field] = expr
+	}
+
+	if len(config.Roles.Rules) > 0 {
+		if config.Roles.Claim == "" {
+			return nil, fmt.Errorf("mapping.roles.claim is required when rules are configured")
+		}
+		expr, err := newExpression(config.Roles.Claim)
+		if err != nil {
+			return nil, fmt.Errorf("mapping.roles.claim: %w", err)
+		}
+		m.roleClaim = expr
+		for i, rule := range config.Roles.Rules {
+			if _, err := path.Match(rule.Group, ""); err != nil {
+				return nil, fmt.Errorf("mapping.roles.rules[%d].group %s: %w", i, rule.Group, err)
+			}
+		}
+	} else if config.Roles.Sync {
+		return nil, fmt.Errorf("mapping.roles.sync requires rules")
+	}
+	return m, nil
+}
+
+// MapUser 使用配置的表达式覆盖用户字段, 表达式结果为空时保留原值
+func (m *Mapper) MapUser(claims map[string]any, user *model.User) error {
+	for field, target := range map[string]*string{
+		"name":       &user.Name,
+		"nickName":   &user.NickName,
+		"email":      &user.Email,
+		"mobile":     &user.Mobile,
+		"avatar":     &user.Avatar,
+		"department": &user.Department,
+	} {
+		expr, ok := m.user[field]
+		if !ok {
+			continue
+		}
+		v, err := expr.String(claims)
+		if err != nil {
+			return fmt.Errorf("map user %s: %w", field, err)
+		}
+		if v != "" {
+			*target = v
+		}
+	}
+	return nil
+}
+
+// MapRoles 返回用户组匹配到的角色名称, 未配置角色映射时 ok 为 false
+func (m *Mapper) MapRoles(claims map[string]any) (roles []string, ok bool) {
+	if m.roleClaim == nil {
+		return nil, false
+	}
+	roles = make([]string, 0)
+	for _, group := range m.roleClaim.Strings(claims) {
+		for _, rule := range m.rules {
+			if matched, _ := path.Match(rule.Group, group); !matched {
+				continue
+			}
+			for _, role := range rule.Roles {
+				if !slices.Contains(roles, role) {
+					roles = append(roles, role)
+				}
+			}
+		}
+	}
+	return roles, true
+}
+
+// SyncRoles 是否在每次登录时同步角色
+func (m *Mapper) SyncRoles() bool {
+	return m.sync
+}
+
+// expression claim 路径或模板
+type expression struct {
+	src  string
+	path []pathSegment
+	tmpl *template.Template
+}
+
+// pathSegment claim 路径的一段, index 为 -1 表示不取数组元素
+type pathSegment struct {
+	key   string
+	index int
+}
+
+var templateFuncs = template.FuncMap{
+	"join":  joinValues,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	// default 第二个参数为空时返回第一个参数, 用法: {{default "unknown" .department}}
+	"default": func(def string, v any) string {
+		if s := valueString(v); s != "" {
+			return s
+		}
+		return def
+	},
+}
+
+func newExpression(src string) (*expression, error) {
+	if strings.Contains(src, "{{") {
+		tmpl, err := template.New("mapping").Funcs(templateFuncs).Option("missingkey=zero").Parse(src)
+		if err != nil {
+			return nil, err
+		}
+		return &expression{tmpl: tmpl}, nil
+	}
+	segments, err := parsePath(src)
+	if err != nil {
+		return nil, err
+	}
+	return &expression{src: src, path: segments}, nil
+}
+
+// parsePath 解析 claim 路径, 支持 $. 前缀、. 分隔的嵌套字段和 [n] 数组下标
+func parsePath(src string) ([]pathSegment, error) {
+	src = strings.TrimPrefix(strings.TrimPrefix(src, "$"), ".")
+	if src == "" {
+		return nil, fmt.Errorf("empty claim path")
+	}
+	var segments []pathSegment
+	for _, part := range strings.Split(src, ".") {
+		seg := pathSegment{key: part, index: -1}
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			if !strings.HasSuffix(part, "]") {
+				return nil, fmt.Errorf("invalid claim path %s", src)
+			}
+			index, err := strconv.Atoi(part[i+1 : len(part)-1])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid claim path %s", src)
+			}
+			seg.key, seg.index = part[:i], index
+		}
+		if seg.key == "" {
+			return nil, fmt.Errorf("invalid claim path %s", src)
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+// value 按路径取值, 路径不存在时返回 nil; 完整名称存在时优先使用, 兼容名称中带 . 的 claim
+func (e *expression) value(claims map[string]any) any {
+	if v, ok := claims[e.src]; ok {
+		return v
+	}
+	var cur any = claims
+	for _, seg := range e.path {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[seg.key]
+		if seg.index >= 0 {
+			arr, ok := cur.([]any)
+			if !ok || seg.index >= len(arr) {
+				return nil
+			}
+			cur = arr[seg.index]
+		}
+	}
+	return cur
+}
+
+// String 返回表达式的字符串结果, 数组使用 , 连接
+func (e *expression) String(claims map[string]any) (string, error) {
+	if e.tmpl == nil {
+		return valueString(e.value(claims)), nil
+	}
+	var b strings.Builder
+	if err := e.tmpl.Execute(&b, claims); err != nil {
+		return "", err
+	}
+	// missingkey=zero 时 map[string]any 中不存在的字段输出 <no value>
+	return strings.TrimSpace(strings.ReplaceAll(b.String(), "<no value>", "")), nil
+}
+
+// Strings 返回表达式的字符串列表结果, 单个值视为只有一个元素
+func (e *expression) Strings(claims map[string]any) []string {
+	var v any
+	if e.tmpl == nil {
+		v = e.value(claims)
+	} else if s, err := e.String(claims); err == nil && s != "" {
+		v = strings.Split(s, ",")
+	}
+	switch v := v.(type) {
+	case []any:
+		res := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := valueString(item); s != "" {
+				res = append(res, s)
+			}
+		}
+		return res
+	case []string:
+		return v
+	case nil:
+		return nil
+	default:
+		if s := valueString(v); s != "" {
+			return []string{s}
+		}
+		return nil
+	}
+}
+
+func valueString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []any:
+		return joinValues(",", v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// joinValues 模板函数, 用法: {{join "," .groups}}
+func joinValues(sep string, v any) string {
+	arr, ok := v.([]any)
+	if !ok {
+		return valueString(v)
+	}
+	parts := make([]string, 0, len(arr))
+	for _, item := range arr {
+		parts = append(parts, valueString(item))
+	}
+	return strings.Join(parts, sep)
+}
@@ -2,19 +2,39 @@ package oauth
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
 
+	"github.com/google/uuid"
 	"github.com/spf13/viper"
-	"github.com/yiran15/api-server/base/helper"
-	"github.com/yiran15/api-server/model"
 	"golang.org/x/oauth2"
 )
 
+// 内置的 provider 类型, 未配置 type 时使用与 provider 名称相同的类型
+const (
+	ProviderTypeFeishu   = "feishu"
+	ProviderTypeKeycloak = "keycloak"
+	ProviderTypeOIDC     = "oidc"
+	ProviderTypeGitHub   = "github"
+	ProviderTypeDingTalk = "dingtalk"
+	ProviderTypeWeCom    = "wecom"
+)
+
 type OAuth2ProviderConfig struct {
+	// Type provider 类型, 即使用的 driver, 未配置时使用 provider 名称
+	Type string `mapstructure:"type"`
+	// Issuer OIDC issuer 地址, 从 {issuer}/.well-known/openid-configuration 获取端点和验签公钥
+	Issuer string `mapstructure:"issuer"`
+	// PKCE 授权请求是否使用 PKCE (S256), oidc 类型始终使用
+	PKCE bool `mapstructure:"pkce"`
+	// Claims 用户字段对应的 claim 名称, 只用于 oidc 类型
+	Claims ClaimMapping `mapstructure:"claims"`
+	// Mapping 用户信息到本地用户和角色的映射
+	Mapping MappingConfig `mapstructure:"mapping"`
+	// ApiUrl github、dingtalk 和 wecom 类型的接口地址, 默认为公有云地址
+	ApiUrl string `mapstructure:"apiUrl"`
+	// AgentId 企业微信应用的 agentid, 只用于 wecom 类型
+	AgentId      string   `mapstructure:"agentId"`
 	UserInfoUrl  string   `mapstructure:"userInfoUrl"`
 	ClientId     string   `mapstructure:"clientId"`
 	ClientSecret string   `mapstructure:"clientSecret"`
@@ -30,8 +50,43 @@ type OAuth2 struct {
 }
 
 type Provider struct {
-	UserInfoUrl string
-	OAuthConfig *oauth2.Config
+	Type   string
+	Mapper *Mapper
+
+	driver ProviderDriver
+}
+
+// AuthParams 一次授权请求的参数, 登录时生成并保存在 session 中, 回调时使用
+type AuthParams struct {
+	State string
+	// Nonce 写入 ID token, 回调时校验, 防止 ID token 重放
+	Nonce string
+	// CodeVerifier PKCE 的 code_verifier
+	CodeVerifier string
+}
+
+// NewAuthParams 生成随机的 state、nonce 和 code_verifier
+func NewAuthParams() *AuthParams {
+	return &AuthParams{
+		State:        uuid.New().String(),
+		Nonce:        oauth2.GenerateVerifier(),
+		CodeVerifier: oauth2.GenerateVerifier(),
+	}
+}
+
+type authParamsContextKey struct{}
+
+// WithAuthParams 将回调请求对应的授权参数写入 context
+func WithAuthParams(ctx context.Context, params *AuthParams) context.Context {
+	return context.WithValue(ctx, authParamsContextKey{}, params)
+}
+
+// AuthParamsFromContext 从 context 获取授权参数, 不存在时返回空参数
+func AuthParamsFromContext(ctx context.Context) *AuthParams {
+	if params, ok := ctx.Value(authParamsContextKey{}).(*AuthParams); ok {
+		return params
+	}
+	return &AuthParams{}
 }
 
 func NewOAuth2() (*OAuth2, error) {
@@ -47,82 +102,75 @@ func NewOAuth2() (*OAuth2, error) {
 
 	providers := make(map[string]*Provider)
 	for name, providerConfig := range providerConfigs {
-		providers[name] = &Provider{
-			UserInfoUrl: providerConfig.UserInfoUrl,
-			OAuthConfig: &oauth2.Config{
-				ClientID:     providerConfig.ClientId,
-				ClientSecret: providerConfig.ClientSecret,
-				Endpoint: oauth2.Endpoint{
-					AuthURL:  providerConfig.AuthUrl,
-					TokenURL: providerConfig.TokenUrl,
-				},
-				RedirectURL: providerConfig.RedirectUrl,
-				Scopes:      providerConfig.Scopes,
-			},
+		providerType := orDefault(providerConfig.Type, name)
+		factory, ok := drivers[providerType]
+		if !ok {
+			return nil, fmt.Errorf("oauth2 provider %s: unsupported type %s", name, providerType)
+		}
+		driver, err := factory(name, providerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2 provider %s: %w", name, err)
 		}
+		mapper, err := NewMapper(providerConfig.Mapping)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2 provider %s: %w", name, err)
+		}
+		providers[name] = &Provider{Type: providerType, Mapper: mapper, driver: driver}
 	}
 
 	return &OAuth2{Enable: enable, Providers: providers}, nil
 }
 
-func (f *OAuth2) Redirect(state string, provider string) string {
-	p, ok := f.Providers[provider]
+func (f *OAuth2) provider(name string) (*Provider, error) {
+	p, ok := f.Providers[name]
 	if !ok {
-		return ""
+		return nil, fmt.Errorf("provider %s not found", name)
 	}
-	return p.OAuthConfig.AuthCodeURL(state)
+	return p, nil
 }
 
-func (f *OAuth2) Auth(ctx context.Context, code, provider string) (*oauth2.Token, error) {
-	p, ok := f.Providers[provider]
-	if !ok {
-		return nil, fmt.Errorf("provider %s not found", provider)
+// Mapper 返回 provider 的用户映射, provider 不存在时返回不做任何映射的 Mapper
+func (f *OAuth2) Mapper(provider string) *Mapper {
+	if p, ok := f.Providers[provider]; ok && p.Mapper != nil {
+		return p.Mapper
 	}
-	return p.OAuthConfig.Exchange(ctx, code)
+	return &Mapper{}
 }
 
-func (f *OAuth2) UserInfo(ctx context.Context, token *oauth2.Token, provider string) (any, error) {
-	p, ok := f.Providers[provider]
-	if !ok {
-		return nil, fmt.Errorf("provider %s not found", provider)
+// Redirect 返回 provider 的授权地址
+func (f *OAuth2) Redirect(ctx context.Context, provider string, params *AuthParams) (string, error) {
+	p, err := f.provider(provider)
+	if err != nil {
+		return "", err
 	}
-	client := p.OAuthConfig.Client(ctx, token)
-	req, err := http.NewRequest("GET", p.UserInfoUrl, nil)
+	return p.driver.AuthCodeURL(ctx, params)
+}
+
+// Auth 使用授权码换取 token
+func (f *OAuth2) Auth(ctx context.Context, code, provider string, params *AuthParams) (*oauth2.Token, error) {
+	p, err := f.provider(provider)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
-	resp, err := client.Do(req)
+	return p.driver.Exchange(ctx, code, params)
+}
+
+// Identity 使用 token 查询用户信息, 转换为外部身份
+func (f *OAuth2) Identity(ctx context.Context, token *oauth2.Token, provider string) (*Identity, error) {
+	p, err := f.provider(provider)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	identity, err := p.driver.Identity(ctx, token)
 	if err != nil {
 		return nil, err
 	}
-
-	switch provider {
-	case "keycloak":
-		var kcUser model.KeycloakUser
-		if err := json.Unmarshal(body, &kcUser); err == nil && kcUser.Sub != "" {
-			return &kcUser, nil
-		}
-	case "feishu":
-		var res helper.HttpResponse
-		if err := json.Unmarshal(body, &res); err != nil {
-			return nil, err
-		}
-		if res.Code != 0 {
-			return nil, errors.New(res.Msg)
-		}
-		feishuUser, err := helper.UnmarshalData[model.FeiShuUser](res.Data)
-		if err != nil {
-			return nil, err
-		}
-		return feishuUser, nil
-	default:
-		return nil, fmt.Errorf("provider %s not supported", provider)
+	if identity.Subject == "" {
+		return nil, errors.New("oauth user subject is empty")
+	}
+	identity.Provider, identity.Type = provider, p.Type
+	if identity.Claims == nil {
+		identity.Claims = make(map[string]any)
 	}
-	return nil, nil
+	return identity, nil
 }
@@ -0,0 +1,396 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// jwksRefreshInterval ID token 的 kid 不在缓存中时重新拉取 JWKS 的最小间隔, 避免伪造 kid 频繁请求 IdP
+	jwksRefreshInterval = time.Minute
+	// idTokenLeeway 校验 ID token 时间时允许的时钟偏差
+	idTokenLeeway = time.Minute
+	// maxResponseSize IdP 响应的最大长度
+	maxResponseSize = 1 << 20
+)
+
+const scopeOpenID = "openid"
+
+// defaultSigningAlgs discovery 文档没有声明 id_token_signing_alg_values_supported 时允许的签名算法
+var defaultSigningAlgs = []string{"RS256"}
+
+// ClaimMapping 用户字段对应的 claim, 支持 realm_access.roles 形式的嵌套路径和模板, 语法与 UserMapping 相同
+type ClaimMapping struct {
+	Subject  string `mapstructure:"subject"`
+	Email    string `mapstructure:"email"`
+	Name     string `mapstructure:"name"`
+	NickName string `mapstructure:"nickName"`
+	Avatar   string `mapstructure:"avatar"`
+	Mobile   string `mapstructure:"mobile"`
+	Groups   string `mapstructure:"groups"`
+	Roles    string `mapstructure:"roles"`
+}
+
+// withDefaults 未配置的字段使用 OIDC 标准 claim
+func (m ClaimMapping) withDefaults() ClaimMapping {
+	return ClaimMapping{
+		Subject:  orDefault(m.Subject, "sub"),
+		Email:    orDefault(m.Email, "email"),
+		Name:     orDefault(m.Name, "preferred_username"),
+		NickName: orDefault(m.NickName, "name"),
+		Avatar:   orDefault(m.Avatar, "picture"),
+		Mobile:   orDefault(m.Mobile, "phone_number"),
+		Groups:   orDefault(m.Groups, "groups"),
+		Roles:    orDefault(m.Roles, "roles"),
+	}
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// oidcDriver 通用 OIDC provider, 端点通过 discovery 获取, 始终使用 PKCE 和 nonce
+type oidcDriver struct {
+	baseDriver
+	oidc   *oidcProvider
+	claims ClaimMapping
+}
+
+func newOIDCDriver(_ string, config *OAuth2ProviderConfig) (ProviderDriver, error) {
+	if config.Issuer == "" {
+		return nil, errors.New("issuer is required for type oidc")
+	}
+	d := &oidcDriver{
+		baseDriver: newBaseDriver(config),
+		oidc:       newOIDCProvider(config.Issuer, config.ClientId),
+		claims:     config.Claims.withDefaults(),
+	}
+	d.pkce = true
+	if !slices.Contains(d.config.Scopes, scopeOpenID) {
+		d.config.Scopes = append([]string{scopeOpenID}, d.config.Scopes...)
+	}
+	return d, nil
+}
+
+// oauth2Config 返回使用 discovery 端点的 oauth2 配置
+func (d *oidcDriver) oauth2Config(ctx context.Context) (*oauth2.Config, error) {
+	endpoint, err := d.oidc.endpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config := *d.config
+	config.Endpoint = endpoint
+	return &config, nil
+}
+
+func (d *oidcDriver) AuthCodeURL(ctx context.Context, params *AuthParams) (string, error) {
+	config, err := d.oauth2Config(ctx)
+	if err != nil {
+		return "", err
+	}
+	return config.AuthCodeURL(params.State, oauth2.S256ChallengeOption(params.CodeVerifier), oauth2.SetAuthURLParam("nonce", params.Nonce)), nil
+}
+
+func (d *oidcDriver) Exchange(ctx context.Context, code string, params *AuthParams) (*oauth2.Token, error) {
+	config, err := d.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return config.Exchange(ctx, code, oauth2.VerifierOption(params.CodeVerifier))
+}
+
+// Identity 校验 ID token, 再合并 userinfo 端点返回的 claims, nonce 从 context 的授权参数中获取
+func (d *oidcDriver) Identity(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("id_token not found in token response")
+	}
+	idClaims, err := d.oidc.verifyIDToken(ctx, rawIDToken, AuthParamsFromContext(ctx).Nonce)
+	if err != nil {
+		return nil, err
+	}
+	config, err := d.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userInfo, err := d.oidc.userInfo(ctx, config.Client(ctx, token))
+	if err != nil {
+		return nil, err
+	}
+	return oidcIdentity(idClaims, userInfo, d.claims)
+}
+
+// discoveryDocument .well-known/openid-configuration 中使用的字段
+type discoveryDocument struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserInfoEndpoint      string   `json:"userinfo_endpoint"`
+	JwksURI               string   `json:"jwks_uri"`
+	SigningAlgs           []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// oidcProvider 通过 discovery 获取 IdP 的端点和验签公钥, 首次使用时拉取并缓存
+type oidcProvider struct {
+	issuer   string
+	clientID string
+	client   *http.Client
+
+	mu            sync.Mutex
+	document      *discoveryDocument
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+func newOIDCProvider(issuer, clientID string) *oidcProvider {
+	return &oidcProvider{
+		issuer:   strings.TrimSuffix(issuer, "/"),
+		clientID: clientID,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// discover 返回 discovery 文档, 拉取失败时下次调用重试
+func (o *oidcProvider) discover(ctx context.Context) (*discoveryDocument, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.document != nil {
+		return o.document, nil
+	}
+
+	doc := new(discoveryDocument)
+	if err := o.getJSON(ctx, o.issuer+"/.well-known/openid-configuration", doc); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	// OpenID Connect Discovery 要求文档中的 issuer 与配置的 issuer 完全一致
+	if strings.TrimSuffix(doc.Issuer, "/") != o.issuer {
+		return nil, fmt.Errorf("oidc discovery issuer %s does not match %s", doc.Issuer, o.issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JwksURI == "" {
+		return nil, errors.New("oidc discovery document missing required endpoints")
+	}
+	o.document = doc
+	return doc, nil
+}
+
+// endpoint 返回 discovery 得到的授权和 token 端点
+func (o *oidcProvider) endpoint(ctx context.Context) (oauth2.Endpoint, error) {
+	doc, err := o.discover(ctx)
+	if err != nil {
+		return oauth2.Endpoint{}, err
+	}
+	return oauth2.Endpoint{AuthURL: doc.AuthorizationEndpoint, TokenURL: doc.TokenEndpoint}, nil
+}
+
+// verifyIDToken 校验 ID token 的签名、issuer、audience、有效期和 nonce, 返回其中的 claims
+func (o *oidcProvider) verifyIDToken(ctx context.Context, rawIDToken, nonce string) (jwtv5.MapClaims, error) {
+	doc, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	algs := doc.SigningAlgs
+	if len(algs) == 0 {
+		algs = defaultSigningAlgs
+	}
+
+	claims := jwtv5.MapClaims{}
+	_, err = jwtv5.ParseWithClaims(rawIDToken, claims, func(token *jwtv5.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return o.publicKey(ctx, doc.JwksURI, kid)
+	},
+		jwtv5.WithValidMethods(algs),
+		jwtv5.WithIssuer(doc.Issuer),
+		jwtv5.WithAudience(o.clientID),
+		jwtv5.WithExpirationRequired(),
+		jwtv5.WithIssuedAt(),
+		jwtv5.WithLeeway(idTokenLeeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("verify id token failed: %w", err)
+	}
+
+	// 多个 audience 时 azp 必须是当前客户端
+	if azp, ok := claims["azp"].(string); ok && azp != o.clientID {
+		return nil, fmt.Errorf("verify id token failed: azp %s does not match client id", azp)
+	}
+	if got, _ := claims["nonce"].(string); nonce == "" || got != nonce {
+		return nil, errors.New("verify id token failed: nonce mismatch")
+	}
+	return claims, nil
+}
+
+// publicKey 按 kid 查找验签公钥, 找不到时重新拉取 JWKS, IdP 轮换密钥后无需重启
+func (o *oidcProvider) publicKey(ctx context.Context, jwksURI, kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if key := o.findKey(kid); key != nil {
+		return key, nil
+	}
+	if time.Since(o.keysFetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("signing key %s not found", kid)
+	}
+
+	set := new(jwt.JSONWebKeySet)
+	if err := o.getJSON(ctx, jwksURI, set); err != nil {
+		return nil, fmt.Errorf("fetch jwks failed: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, v := range set.Keys {
+		if v.Use != "" && v.Use != "sig" {
+			continue
+		}
+		key, err := v.PublicKey()
+		if err != nil {
+			// 忽略不支持的密钥, 其余密钥仍然可用
+			continue
+		}
+		keys[v.Kid] = key
+	}
+	o.keys = keys
+	o.keysFetchedAt = time.Now()
+
+	if key := o.findKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("signing key %s not found", kid)
+}
+
+// findKey token 没有 kid 时, JWKS 中只有一个密钥才使用该密钥
+func (o *oidcProvider) findKey(kid string) crypto.PublicKey {
+	if kid == "" && len(o.keys) == 1 {
+		for _, key := range o.keys {
+			return key
+		}
+	}
+	return o.keys[kid]
+}
+
+// userInfo 查询 userinfo 端点, IdP 没有 userinfo 端点时返回 nil
+func (o *oidcProvider) userInfo(ctx context.Context, client *http.Client) (map[string]any, error) {
+	doc, err := o.discover(ctx)
+	if err != nil || doc.UserInfoEndpoint == "" {
+		return nil, err
+	}
+	claims := make(map[string]any)
+	if err := getJSON(ctx, client, doc.UserInfoEndpoint, &claims); err != nil {
+		return nil, fmt.Errorf("fetch userinfo failed: %w", err)
+	}
+	return claims, nil
+}
+
+func (o *oidcProvider) getJSON(ctx context.Context, url string, v any) error {
+	return getJSON(ctx, o.client, url, v)
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	return doJSON(ctx, client, http.MethodGet, url, nil, nil, v)
+}
+
+// doJSON 发送请求并解析 JSON 响应, body 不为 nil 时以 JSON 格式发送
+func doJSON(ctx context.Context, client *http.Client, method, url string, header http.Header, body, v any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	for k, values := range header {
+		for _, value := range values {
+			req.Header.Add(k, value)
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// 不输出 query, 钉钉和企业微信的 access token 在 query 中
+		return fmt.Errorf("%s returned %d: %s", req.URL.Host+req.URL.Path, resp.StatusCode, data)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// oidcIdentity 合并 ID token 和 userinfo 的 claims, 按映射转换为外部身份
+// userinfo 的 sub 必须与 ID token 一致, 否则可能是被替换的响应
+func oidcIdentity(idClaims, userInfo map[string]any, mapping ClaimMapping) (*Identity, error) {
+	claims := make(map[string]any, len(idClaims)+len(userInfo))
+	for k, v := range idClaims {
+		claims[k] = v
+	}
+	if userInfo != nil {
+		if sub, _ := userInfo["sub"].(string); sub != idClaims["sub"] {
+			return nil, errors.New("userinfo sub does not match id token")
+		}
+		for k, v := range userInfo {
+			claims[k] = v
+		}
+	}
+
+	identity := &Identity{
+		Subject:  claimString(claims, mapping.Subject),
+		Email:    claimString(claims, mapping.Email),
+		Name:     claimString(claims, mapping.Name),
+		NickName: claimString(claims, mapping.NickName),
+		Avatar:   claimString(claims, mapping.Avatar),
+		Mobile:   claimString(claims, mapping.Mobile),
+		Groups:   claimStrings(claims, mapping.Groups),
+		Roles:    claimStrings(claims, mapping.Roles),
+		Claims:   claims,
+	}
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("claim %s is empty", mapping.Subject)
+	}
+	return identity, nil
+}
+
+// claimString 按 claim 路径或模板取值, 表达式非法时返回空字符串
+func claimString(claims map[string]any, name string) string {
+	expr, err := newExpression(name)
+	if err != nil {
+		return ""
+	}
+	v, _ := expr.String(claims)
+	return v
+}
+
+// claimStrings 数组 claim 转换为字符串列表, 单个字符串视为只有一个元素
+func claimStrings(claims map[string]any, name string) []string {
+	expr, err := newExpression(name)
+	if err != nil {
+		return nil
+	}
+	return expr.Strings(claims)
+}
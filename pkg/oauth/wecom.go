@@ -0,0 +1,183 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// wecomAuthURL 企业微信网页登录 (扫码登录) 地址
+	wecomAuthURL = "https://login.work.weixin.qq.com/wwlogin/sso/login"
+	wecomAPIURL  = "https://qyapi.weixin.qq.com"
+)
+
+// wecomUser 企业微信成员信息, 来自 user/get 和 auth/getuserdetail
+type wecomUser struct {
+	UserID  string `json:"userid"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	BizMail string `json:"biz_mail"`
+	Mobile  string `json:"mobile"`
+	Avatar  string `json:"avatar"`
+}
+
+type wecomDriver struct {
+	baseDriver
+	apiURL  string
+	agentID string
+	// appToken 应用 access token, 企业微信不为成员签发 access token, 所有接口都使用应用 access token
+	appToken appToken
+}
+
+func newWeComDriver(_ string, config *OAuth2ProviderConfig) (ProviderDriver, error) {
+	if config.AgentId == "" {
+		return nil, errors.New("agentId is required for type wecom")
+	}
+	d := &wecomDriver{
+		baseDriver: newBaseDriver(config),
+		apiURL:     strings.TrimSuffix(orDefault(config.ApiUrl, wecomAPIURL), "/"),
+		agentID:    config.AgentId,
+	}
+	d.withDefaults(wecomAuthURL, "", nil)
+	return d, nil
+}
+
+// AuthCodeURL 企业微信网页登录的授权地址, 参数与标准 OAuth2 不同, 使用企业 id 和应用 agentid
+func (d *wecomDriver) AuthCodeURL(_ context.Context, params *AuthParams) (string, error) {
+	u, err := url.Parse(d.config.Endpoint.AuthURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("login_type", "CorpApp")
+	q.Set("appid", d.config.ClientID)
+	q.Set("agentid", d.agentID)
+	q.Set("redirect_uri", d.config.RedirectURL)
+	q.Set("state", params.State)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange 使用应用 access token 和授权码查询成员 userid
+// 返回的 token 为应用 access token, userid 和 user_ticket 保存在 Extra 中
+func (d *wecomDriver) Exchange(ctx context.Context, code string, _ *AuthParams) (*oauth2.Token, error) {
+	accessToken, err := d.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		corpResponse
+		UserID     string `json:"userid"`
+		UserTicket string `json:"user_ticket"`
+	}
+	query := url.Values{"access_token": {accessToken}, "code": {code}}
+	if err := getJSON(ctx, httpClient, d.apiURL+"/cgi-bin/auth/getuserinfo?"+query.Encode(), &res); err != nil {
+		return nil, fmt.Errorf("wecom exchange code failed: %w", err)
+	}
+	if err := res.err("auth/getuserinfo"); err != nil {
+		return nil, err
+	}
+	// 非企业成员只返回 openid
+	if res.UserID == "" {
+		return nil, errors.New("wecom user is not a member of the corp")
+	}
+	return (&oauth2.Token{AccessToken: accessToken}).WithExtra(map[string]any{
+		"userid":      res.UserID,
+		"user_ticket": res.UserTicket,
+	}), nil
+}
+
+func (d *wecomDriver) accessToken(ctx context.Context) (string, error) {
+	return d.appToken.get(ctx, func(ctx context.Context) (string, time.Duration, error) {
+		var res struct {
+			corpResponse
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int64  `json:"expires_in"`
+		}
+		query := url.Values{"corpid": {d.config.ClientID}, "corpsecret": {d.config.ClientSecret}}
+		if err := getJSON(ctx, httpClient, d.apiURL+"/cgi-bin/gettoken?"+query.Encode(), &res); err != nil {
+			return "", 0, err
+		}
+		if err := res.err("gettoken"); err != nil {
+			return "", 0, err
+		}
+		return res.AccessToken, time.Duration(res.ExpiresIn) * time.Second, nil
+	})
+}
+
+// Identity 查询成员信息, userid 在企业内唯一, 作为用户标识; 企业邮箱由企业管理员分配, 视为已验证, 个人邮箱未经验证
+// 2022 年 6 月后创建的应用, user/get 不返回手机号、邮箱和头像, 需要使用成员授权的 user_ticket 查询
+func (d *wecomDriver) Identity(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	userID, _ := token.Extra("userid").(string)
+	if userID == "" {
+		return nil, errors.New("wecom userid not found in token")
+	}
+	query := "?access_token=" + url.QueryEscape(token.AccessToken)
+
+	user := new(wecomUser)
+	claims, err := d.request(ctx, http.MethodGet, "/cgi-bin/user/get"+query+"&userid="+url.QueryEscape(userID), nil, user)
+	if err != nil {
+		return nil, err
+	}
+	if ticket, _ := token.Extra("user_ticket").(string); ticket != "" {
+		detail := new(wecomUser)
+		detailClaims, err := d.request(ctx, http.MethodPost, "/cgi-bin/auth/getuserdetail"+query, map[string]string{"user_ticket": ticket}, detail)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range detailClaims {
+			if valueString(v) != "" {
+				claims[k] = v
+			}
+		}
+		for _, field := range []struct{ dst, src *string }{
+			{&user.Email, &detail.Email},
+			{&user.BizMail, &detail.BizMail},
+			{&user.Mobile, &detail.Mobile},
+			{&user.Avatar, &detail.Avatar},
+		} {
+			if *field.src != "" {
+				*field.dst = *field.src
+			}
+		}
+	}
+
+	identity := &Identity{
+		Subject:  user.UserID,
+		Email:    user.Email,
+		Name:     user.UserID,
+		NickName: user.Name,
+		Avatar:   user.Avatar,
+		Mobile:   user.Mobile,
+		Claims:   claims,
+	}
+	if user.BizMail != "" {
+		identity.Email, identity.EmailVerified = user.BizMail, true
+	}
+	return identity, nil
+}
+
+// request 请求企业微信的成员接口, 检查错误码后解析到 user, 返回全部字段
+func (d *wecomDriver) request(ctx context.Context, method, path string, body any, user *wecomUser) (map[string]any, error) {
+	var raw json.RawMessage
+	if err := doJSON(ctx, httpClient, method, d.apiURL+path, nil, body, &raw); err != nil {
+		return nil, fmt.Errorf("fetch wecom user failed: %w", err)
+	}
+	var status corpResponse
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return nil, err
+	}
+	api, _, _ := strings.Cut(path, "?")
+	if err := status.err(api); err != nil {
+		return nil, err
+	}
+	return decodeProfile(raw, user)
+}
@@ -0,0 +1,88 @@
+package password
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/store"
+	"gorm.io/gorm"
+)
+
+// Checker 设置密码时检查密码策略和历史密码
+type Checker interface {
+	Policy() *Policy
+	// Check 检查新密码是否符合策略, 并且不是用户最近使用过的密码
+	// user 为新用户时只检查策略, 新用户的 ID 为 0
+	Check(ctx context.Context, user *model.User, password string) error
+	// Record 记录用户新设置的密码哈希, 只保留最近 HistorySize 条
+	Record(ctx context.Context, userID int64, hash string) error
+}
+
+type checker struct {
+	policy       *Policy
+	hasher       Hasher
+	historyStore store.PasswordHistoryStorer
+}
+
+func NewChecker(policy *Policy, hasher Hasher, historyStore store.PasswordHistoryStorer) Checker {
+	return &checker{
+		policy:       policy,
+		hasher:       hasher,
+		historyStore: historyStore,
+	}
+}
+
+func (c *checker) Policy() *Policy {
+	return c.policy
+}
+
+func (c *checker) Check(ctx context.Context, user *model.User, password string) error {
+	if err := c.policy.Validate(password, user.Email, user.Name); err != nil {
+		return err
+	}
+	if user.ID == 0 || c.policy.HistorySize == 0 {
+		return nil
+	}
+
+	historyErr := &PolicyError{Rule: RuleHistory, Param: strconv.Itoa(c.policy.HistorySize)}
+	// 启用历史记录前设置的密码不在历史表中, 也要和当前密码比较
+	if c.matchHash(password, user.Password) {
+		return historyErr
+	}
+	_, histories, err := c.historyStore.List(ctx, 1, c.policy.HistorySize, "id", "desc", store.Where("user_id", user.ID))
+	if err != nil {
+		return err
+	}
+	for _, v := range histories {
+		if v.Password != user.Password && c.matchHash(password, v.Password) {
+			return historyErr
+		}
+	}
+	return nil
+}
+
+func (c *checker) Record(ctx context.Context, userID int64, hash string) error {
+	if c.policy.HistorySize == 0 {
+		return nil
+	}
+	if err := c.historyStore.Create(ctx, &model.PasswordHistory{UserID: userID, Password: hash}); err != nil {
+		return err
+	}
+
+	total, histories, err := c.historyStore.List(ctx, 1, c.policy.HistorySize, "id", "desc", store.Where("user_id", userID))
+	if err != nil {
+		return err
+	}
+	if total <= int64(c.policy.HistorySize) {
+		return nil
+	}
+	oldest := histories[len(histories)-1].ID
+	return c.historyStore.Delete(ctx, &model.PasswordHistory{}, store.Scopes(func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ? AND id < ?", userID, oldest)
+	}))
+}
+
+func (c *checker) matchHash(password, hash string) bool {
+	return hash != "" && c.hasher.Verify(password, hash)
+}
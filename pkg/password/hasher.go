@@ -0,0 +1,165 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 密码哈希算法
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// ErrInvalidHash 哈希格式无法识别或参数非法
+var ErrInvalidHash = errors.New("invalid password hash")
+
+// Hasher 密码哈希接口, 哈希使用 PHC 字符串格式, bcrypt 使用其标准的 $2a$ 格式
+// Verify 可以校验所有支持的算法, Hash 只使用配置的算法
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Verify 校验明文密码是否与哈希匹配
+	Verify(password, hash string) bool
+	// NeedsRehash 哈希的算法或参数与当前配置不一致时返回 true, 登录成功后应使用新的哈希替换
+	NeedsRehash(hash string) bool
+}
+
+// Argon2idParams argon2id 参数, Memory 单位 KiB
+type Argon2idParams struct {
+	Memory      uint32 `mapstructure:"memory"`
+	Iterations  uint32 `mapstructure:"iterations"`
+	Parallelism uint8  `mapstructure:"parallelism"`
+	SaltLength  uint32 `mapstructure:"saltLength"`
+	KeyLength   uint32 `mapstructure:"keyLength"`
+}
+
+// HasherConfig 密码哈希配置, 从 password.hasher 读取
+type HasherConfig struct {
+	Algorithm  string         `mapstructure:"algorithm"`
+	BcryptCost int            `mapstructure:"bcryptCost"`
+	Argon2id   Argon2idParams `mapstructure:"argon2id"`
+}
+
+type hasher struct {
+	config HasherConfig
+}
+
+func NewHasher() (Hasher, error) {
+	config := HasherConfig{
+		Algorithm:  AlgorithmBcrypt,
+		BcryptCost: bcrypt.DefaultCost,
+		Argon2id: Argon2idParams{
+			Memory:      64 * 1024,
+			Iterations:  3,
+			Parallelism: 2,
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+	}
+	if err := viper.UnmarshalKey("password.hasher", &config); err != nil {
+		return nil, fmt.Errorf("unmarshal password.hasher failed: %w", err)
+	}
+	return NewHasherWithConfig(config)
+}
+
+// NewHasherWithConfig 使用指定配置创建 Hasher
+func NewHasherWithConfig(config HasherConfig) (Hasher, error) {
+	switch config.Algorithm {
+	case AlgorithmBcrypt:
+		if config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid password.hasher.bcryptCost %d, must be between %d and %d", config.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgorithmArgon2id:
+		p := config.Argon2id
+		if p.Memory < 8*uint32(p.Parallelism) || p.Iterations < 1 || p.Parallelism < 1 || p.SaltLength < 8 || p.KeyLength < 16 {
+			return nil, fmt.Errorf("invalid password.hasher.argon2id %+v", p)
+		}
+	default:
+		return nil, fmt.Errorf("invalid password.hasher.algorithm %s, must be bcrypt or argon2id", config.Algorithm)
+	}
+	return &hasher{config: config}, nil
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	if h.config.Algorithm == AlgorithmArgon2id {
+		return hashArgon2id(password, h.config.Argon2id)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.config.BcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *hasher) Verify(password, hash string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		return subtle.ConstantTimeCompare(key, other) == 1
+	case strings.HasPrefix(hash, "$2"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	default:
+		return false
+	}
+}
+
+func (h *hasher) NeedsRehash(hash string) bool {
+	switch h.config.Algorithm {
+	case AlgorithmArgon2id:
+		params, _, _, err := decodeArgon2id(hash)
+		return err != nil || params != h.config.Argon2id
+	default:
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.config.BcryptCost
+	}
+}
+
+// hashArgon2id 生成 PHC 格式的哈希: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func hashArgon2id(password string, p Argon2idParams) (string, error) {
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func decodeArgon2id(hash string) (Argon2idParams, []byte, []byte, error) {
+	var p Argon2idParams
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 || p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, ErrInvalidHash
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,185 @@
+// Package password 密码策略和历史密码检查
+package password
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+// 密码策略规则, 用于 PolicyError.Rule, 同时也是翻译的 key 后缀
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleUpper     = "upper"
+	RuleLower     = "lower"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleUserInfo  = "user_info"
+	RuleCommon    = "common"
+	RuleHistory   = "history"
+)
+
+// userInfoMinLength 邮箱前缀或用户名少于该长度时不检查密码是否包含它们, 避免误伤
+const userInfoMinLength = 3
+
+// PolicyError 密码不符合策略, Param 为规则的参数, 如最小长度
+type PolicyError struct {
+	Rule  string
+	Param string
+}
+
+func (e *PolicyError) Error() string {
+	if e.Param != "" {
+		return fmt.Sprintf("password violates policy %s(%s)", e.Rule, e.Param)
+	}
+	return fmt.Sprintf("password violates policy %s", e.Rule)
+}
+
+// Policy 密码策略, 从 password.policy 读取
+type Policy struct {
+	MinLength int `mapstructure:"minLength"`
+	// MaxLength 最大字节数, bcrypt 只使用前 72 字节
+	MaxLength     int  `mapstructure:"maxLength"`
+	RequireUpper  bool `mapstructure:"requireUpper"`
+	RequireLower  bool `mapstructure:"requireLower"`
+	RequireDigit  bool `mapstructure:"requireDigit"`
+	RequireSymbol bool `mapstructure:"requireSymbol"`
+	// DisallowUserInfo 密码不能包含邮箱前缀或用户名
+	DisallowUserInfo bool `mapstructure:"disallowUserInfo"`
+	// DenylistFile 常见密码列表文件, 每行一个, 不区分大小写
+	DenylistFile string `mapstructure:"denylistFile"`
+	// HistorySize 新密码不能与最近 HistorySize 次使用过的密码相同, 0 表示不检查
+	HistorySize int `mapstructure:"historySize"`
+
+	denylist map[string]struct{}
+}
+
+func NewPolicy() (*Policy, error) {
+	policy := &Policy{
+		MinLength:        8,
+		MaxLength:        72,
+		DisallowUserInfo: true,
+		HistorySize:      5,
+	}
+	if err := viper.UnmarshalKey("password.policy", policy); err != nil {
+		return nil, fmt.Errorf("unmarshal password.policy failed: %w", err)
+	}
+	if policy.MinLength < 1 {
+		return nil, fmt.Errorf("invalid password.policy.minLength %d", policy.MinLength)
+	}
+	if policy.MaxLength < policy.MinLength {
+		return nil, fmt.Errorf("invalid password.policy.maxLength %d, must not be less than minLength", policy.MaxLength)
+	}
+	if policy.HistorySize < 0 {
+		return nil, fmt.Errorf("invalid password.policy.historySize %d", policy.HistorySize)
+	}
+	if policy.DenylistFile != "" {
+		denylist, err := loadDenylist(policy.DenylistFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.denylist = denylist
+	}
+	return policy, nil
+}
+
+// SetDenylist 替换常见密码列表
+func (p *Policy) SetDenylist(passwords []string) {
+	p.denylist = make(map[string]struct{}, len(passwords))
+	for _, v := range passwords {
+		if v = strings.TrimSpace(v); v != "" {
+			p.denylist[strings.ToLower(v)] = struct{}{}
+		}
+	}
+}
+
+// Validate 检查密码是否符合策略, userInfo 为用户的邮箱、用户名等, 返回第一个不满足的规则
+func (p *Policy) Validate(password string, userInfo ...string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return &PolicyError{Rule: RuleMinLength, Param: strconv.Itoa(p.MinLength)}
+	}
+	if len(password) > p.MaxLength {
+		return &PolicyError{Rule: RuleMaxLength, Param: strconv.Itoa(p.MaxLength)}
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	switch {
+	case p.RequireUpper && !upper:
+		return &PolicyError{Rule: RuleUpper}
+	case p.RequireLower && !lower:
+		return &PolicyError{Rule: RuleLower}
+	case p.RequireDigit && !digit:
+		return &PolicyError{Rule: RuleDigit}
+	case p.RequireSymbol && !symbol:
+		return &PolicyError{Rule: RuleSymbol}
+	}
+
+	lowered := strings.ToLower(password)
+	if p.DisallowUserInfo {
+		for _, info := range userInfo {
+			for _, v := range userInfoParts(info) {
+				if strings.Contains(lowered, v) {
+					return &PolicyError{Rule: RuleUserInfo}
+				}
+			}
+		}
+	}
+	if _, ok := p.denylist[lowered]; ok {
+		return &PolicyError{Rule: RuleCommon}
+	}
+	return nil
+}
+
+// userInfoParts 返回需要检查的用户信息, 邮箱同时检查完整地址和前缀
+func userInfoParts(info string) []string {
+	info = strings.ToLower(strings.TrimSpace(info))
+	var parts []string
+	if utf8.RuneCountInString(info) >= userInfoMinLength {
+		parts = append(parts, info)
+	}
+	if local, _, ok := strings.Cut(info, "@"); ok && utf8.RuneCountInString(local) >= userInfoMinLength {
+		parts = append(parts, local)
+	}
+	return parts
+}
+
+func loadDenylist(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open password denylist file %s failed: %w", path, err)
+	}
+	defer f.Close()
+
+	denylist := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read password denylist file %s failed: %w", path, err)
+	}
+	return denylist, nil
+}
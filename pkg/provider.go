@@ -2,19 +2,39 @@ package pkg
 
 import (
 	"github.com/google/wire"
+	"github.com/yiran15/api-server/pkg/audit"
 	"github.com/yiran15/api-server/pkg/casbin"
 	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/pkg/ldap"
 	localcache "github.com/yiran15/api-server/pkg/local_cache"
+	"github.com/yiran15/api-server/pkg/loginevent"
+	"github.com/yiran15/api-server/pkg/loginguard"
+	"github.com/yiran15/api-server/pkg/mailer"
 	"github.com/yiran15/api-server/pkg/oauth"
+	"github.com/yiran15/api-server/pkg/password"
+	"github.com/yiran15/api-server/pkg/ratelimit"
+	"github.com/yiran15/api-server/pkg/session"
 )
 
 var PkgProviderSet = wire.NewSet(
 	wire.Bind(new(jwt.JwtInterface), new(*jwt.GenerateToken)),
 	jwt.NewGenerateToken,
+	jwt.NewRevoker,
+	loginguard.NewGuard,
+	ratelimit.NewRateLimiter,
+	mailer.NewMailer,
+	password.NewPolicy,
+	password.NewHasher,
+	password.NewChecker,
+	session.NewManager,
+	audit.NewRecorder,
+	loginevent.NewRecorder,
 
 	casbin.NewEnforcer,
+	casbin.NewWatcher,
 	casbin.NewCasbinManager,
 	casbin.NewAuthChecker,
 	oauth.NewOAuth2,
+	ldap.NewLDAP,
 	localcache.NewCacher,
 )
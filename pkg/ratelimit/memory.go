@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryCleanupInterval 清理过期窗口的周期
+const memoryCleanupInterval = time.Minute
+
+type memoryWindow struct {
+	hits   []time.Time
+	window time.Duration
+}
+
+// memoryLimiter 单副本内存滑动窗口限流
+type memoryLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*memoryWindow
+}
+
+// NewMemoryLimiter 创建内存限流器, 返回的函数用于停止后台清理
+func NewMemoryLimiter() (Limiter, func()) {
+	return newMemoryLimiter()
+}
+
+func newMemoryLimiter() (*memoryLimiter, func()) {
+	l := &memoryLimiter{windows: make(map[string]*memoryWindow)}
+	ctx, cancel := context.WithCancel(context.Background())
+	go l.run(ctx)
+	return l, cancel
+}
+
+func (l *memoryLimiter) Allow(_ context.Context, key string, limit int, window time.Duration) (*Result, error) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.windows[key]
+	if !ok {
+		w = &memoryWindow{window: window}
+		l.windows[key] = w
+	}
+	w.window = window
+	w.prune(now)
+
+	allowed := len(w.hits) < limit
+	if allowed {
+		w.hits = append(w.hits, now)
+	}
+	var resetAfter time.Duration
+	if len(w.hits) > 0 {
+		resetAfter = w.hits[0].Add(window).Sub(now)
+	}
+	return &Result{
+		Allowed:    allowed,
+		Limit:      limit,
+		Remaining:  max(limit-len(w.hits), 0),
+		ResetAfter: resetAfter,
+	}, nil
+}
+
+func (l *memoryLimiter) run(ctx context.Context) {
+	ticker := time.NewTicker(memoryCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			l.mu.Lock()
+			for key, w := range l.windows {
+				if w.prune(now); len(w.hits) == 0 {
+					delete(l.windows, key)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// prune 移除窗口外的请求记录
+func (w *memoryWindow) prune(now time.Time) {
+	cutoff := now.Add(-w.window)
+	i := 0
+	for i < len(w.hits) && !w.hits[i].After(cutoff) {
+		i++
+	}
+	w.hits = w.hits[i:]
+}
@@ -0,0 +1,140 @@
+// Package ratelimit 基于滑动窗口的接口限流, 多副本部署时使用 redis 共享计数, redis 不可用时退化为单副本内存限流
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/store"
+	"go.uber.org/zap"
+)
+
+const (
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+
+	// KeyUser 按用户限流, 个人访问令牌按令牌限流, 未认证的请求按客户端 IP 限流
+	KeyUser = "user"
+	// KeyIP 按客户端 IP 限流
+	KeyIP = "ip"
+)
+
+// Rule 路由分组的限流规则, 请求路径匹配多个分组时使用前缀最长的分组
+type Rule struct {
+	Prefix string        `mapstructure:"prefix"`
+	Limit  int           `mapstructure:"limit"`
+	Window time.Duration `mapstructure:"window"`
+	Key    string        `mapstructure:"key"`
+}
+
+// Result 限流结果
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter 窗口内最早的请求离开窗口的剩余时间, 被限流时即为需要等待的时间
+	ResetAfter time.Duration
+}
+
+// Limiter 滑动窗口限流器
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (*Result, error)
+}
+
+type RateLimiter struct {
+	rules   []*Rule
+	limiter Limiter
+}
+
+// NewRateLimiter 从配置文件的 rateLimit 加载限流规则, 未启用时返回的 RateLimiter 不匹配任何请求
+func NewRateLimiter(cacheStore store.CacheStorer) (*RateLimiter, func(), error) {
+	if !viper.GetBool("rateLimit.enable") {
+		return &RateLimiter{}, func() {}, nil
+	}
+
+	var rules []*Rule
+	if err := viper.UnmarshalKey("rateLimit.groups", &rules); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal rateLimit.groups faild. err: %w", err)
+	}
+	for _, rule := range rules {
+		if rule.Prefix == "" || rule.Limit <= 0 || rule.Window <= 0 {
+			return nil, nil, fmt.Errorf("invalid rateLimit group %+v, prefix, limit and window are required", *rule)
+		}
+		switch rule.Key {
+		case "":
+			rule.Key = KeyUser
+		case KeyUser, KeyIP:
+		default:
+			return nil, nil, fmt.Errorf("invalid rateLimit group %s key %s, must be user or ip", rule.Prefix, rule.Key)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return len(rules[i].Prefix) > len(rules[j].Prefix)
+	})
+
+	memory, cleanup := newMemoryLimiter()
+	var limiter Limiter = memory
+	backend := viper.GetString("rateLimit.backend")
+	switch backend {
+	case "", BackendRedis:
+		backend = BackendRedis
+		limiter = &fallbackLimiter{primary: &redisLimiter{cacheStore: cacheStore}, fallback: memory}
+	case BackendMemory:
+	default:
+		cleanup()
+		return nil, nil, fmt.Errorf("invalid rateLimit.backend %s, must be redis or memory", backend)
+	}
+	zap.S().Infof("rate limit enabled, backend %s, %d groups", backend, len(rules))
+	return &RateLimiter{rules: rules, limiter: limiter}, cleanup, nil
+}
+
+// Match 返回请求路径匹配的限流规则, 没有匹配的规则时返回 nil
+func (r *RateLimiter) Match(path string) *Rule {
+	for _, rule := range r.rules {
+		if strings.HasPrefix(path, rule.Prefix) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Allow 检查 identity 在规则对应的窗口内是否还可以请求
+func (r *RateLimiter) Allow(ctx context.Context, rule *Rule, identity string) (*Result, error) {
+	return r.limiter.Allow(ctx, rule.Prefix+"|"+identity, rule.Limit, rule.Window)
+}
+
+type redisLimiter struct {
+	cacheStore store.CacheStorer
+}
+
+func (l *redisLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (*Result, error) {
+	res, err := l.cacheStore.SlidingWindowAllow(ctx, store.RateLimitType, key, int64(limit), window)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Allowed:    res.Allowed,
+		Limit:      limit,
+		Remaining:  max(limit-int(res.Count), 0),
+		ResetAfter: res.ResetAfter,
+	}, nil
+}
+
+// fallbackLimiter redis 出错时使用内存限流, 保证 redis 故障时仍有单副本级别的保护
+type fallbackLimiter struct {
+	primary  Limiter
+	fallback Limiter
+}
+
+func (l *fallbackLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (*Result, error) {
+	res, err := l.primary.Allow(ctx, key, limit, window)
+	if err == nil {
+		return res, nil
+	}
+	zap.L().Warn("rate limit redis backend failed, fallback to memory", zap.Error(err))
+	return l.fallback.Allow(ctx, key, limit, window)
+}
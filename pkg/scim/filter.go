@@ -0,0 +1,525 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 比较操作
+const (
+	OpEq = "eq"
+	OpNe = "ne"
+	OpCo = "co"
+	OpSw = "sw"
+	OpEw = "ew"
+	OpPr = "pr"
+	OpGt = "gt"
+	OpGe = "ge"
+	OpLt = "lt"
+	OpLe = "le"
+)
+
+// Expr 过滤表达式
+type Expr interface {
+	expr()
+}
+
+// Compare 属性比较, Op 为 pr 时没有 Value, Value 为 string、float64、bool 或 nil
+type Compare struct {
+	Attr  string
+	Op    string
+	Value any
+}
+
+// Logical and / or
+type Logical struct {
+	Op          string
+	Left, Right Expr
+}
+
+// Not 取反
+type Not struct {
+	X Expr
+}
+
+// ValuePath 多值属性的过滤, 如 emails[type eq "work"], 子表达式中的属性相对于 Attr
+type ValuePath struct {
+	Attr   string
+	Filter Expr
+}
+
+func (*Compare) expr()   {}
+func (*Logical) expr()   {}
+func (*Not) expr()       {}
+func (*ValuePath) expr() {}
+
+// ParseFilter 解析过滤器, 如 userName eq "alice" and (active eq true or emails[type eq "work"] pr)
+func ParseFilter(filter string) (Expr, error) {
+	p, err := newParser(filter)
+	if err != nil {
+		return nil, err
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, invalidFilter("unexpected %q", tok.text)
+	}
+	return e, nil
+}
+
+func invalidFilter(format string, args ...any) error {
+	return NewError(http.StatusBadRequest, ErrInvalidFilter, format, args...)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func newParser(s string) (*parser, error) {
+	p := &parser{}
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			p.tokens = append(p.tokens, token{kind: map[byte]tokenKind{'(': tokenLParen, ')': tokenRParen, '[': tokenLBracket, ']': tokenRBracket}[c], text: string(c)})
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, invalidFilter("unterminated string at %d", i)
+			}
+			var v string
+			if err := json.Unmarshal([]byte(s[i:j+1]), &v); err != nil {
+				return nil, invalidFilter("invalid string %s", s[i:j+1])
+			}
+			p.tokens = append(p.tokens, token{kind: tokenString, text: v})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n\r()[]\"", rune(s[j])) {
+				j++
+			}
+			p.tokens = append(p.tokens, token{kind: tokenWord, text: s[i:j]})
+			i = j
+		}
+	}
+	return p, nil
+}
+
+func (p *parser) peek() token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return token{kind: tokenEOF}
+}
+
+func (p *parser) next() token {
+	tok := p.peek()
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) keyword(word string) bool {
+	tok := p.peek()
+	if tok.kind == tokenWord && strings.EqualFold(tok.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseOr 优先级 not > and > or
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.keyword("not") {
+		if p.peek().kind != tokenLParen {
+			return nil, invalidFilter("not must be followed by (")
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x}, nil
+	}
+	if p.peek().kind == tokenLParen {
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRParen {
+			return nil, invalidFilter("missing )")
+		}
+		return e, nil
+	}
+	return p.parseAttrExpr()
+}
+
+func (p *parser) parseAttrExpr() (Expr, error) {
+	tok := p.next()
+	if tok.kind != tokenWord {
+		return nil, invalidFilter("expected attribute, got %q", tok.text)
+	}
+	attr := tok.text
+	if p.peek().kind == tokenLBracket {
+		p.next()
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRBracket {
+			return nil, invalidFilter("missing ]")
+		}
+		return &ValuePath{Attr: attr, Filter: filter}, nil
+	}
+
+	op := p.next()
+	if op.kind != tokenWord {
+		return nil, invalidFilter("expected operator after %s", attr)
+	}
+	c := &Compare{Attr: attr, Op: strings.ToLower(op.text)}
+	switch c.Op {
+	case OpPr:
+		return c, nil
+	case OpEq, OpNe, OpCo, OpSw, OpEw, OpGt, OpGe, OpLt, OpLe:
+	default:
+		return nil, invalidFilter("unsupported operator %s", op.text)
+	}
+	value := p.next()
+	switch value.kind {
+	case tokenString:
+		c.Value = value.text
+	case tokenWord:
+		switch strings.ToLower(value.text) {
+		case "true":
+			c.Value = true
+		case "false":
+			c.Value = false
+		case "null":
+			c.Value = nil
+		default:
+			n, err := strconv.ParseFloat(value.text, 64)
+			if err != nil {
+				return nil, invalidFilter("invalid value %s", value.text)
+			}
+			c.Value = n
+		}
+	default:
+		return nil, invalidFilter("expected value after %s %s", attr, op.text)
+	}
+	return c, nil
+}
+
+// Match 在内存中判断 get 返回的属性是否满足表达式, 字符串比较不区分大小写
+func Match(e Expr, get func(attr string) (any, bool)) bool {
+	switch e := e.(type) {
+	case *Logical:
+		if e.Op == "and" {
+			return Match(e.Left, get) && Match(e.Right, get)
+		}
+		return Match(e.Left, get) || Match(e.Right, get)
+	case *Not:
+		return !Match(e.X, get)
+	case *ValuePath:
+		v, ok := get(e.Attr)
+		if !ok {
+			return false
+		}
+		items, _ := v.([]any)
+		for _, item := range items {
+			obj, _ := item.(map[string]any)
+			if obj != nil && Match(e.Filter, func(attr string) (any, bool) { return lookup(obj, attr) }) {
+				return true
+			}
+		}
+		return false
+	case *Compare:
+		v, ok := get(e.Attr)
+		return compare(v, ok, e.Op, e.Value)
+	}
+	return false
+}
+
+func compare(v any, ok bool, op string, want any) bool {
+	if op == OpPr {
+		return ok && v != nil && v != ""
+	}
+	if !ok || v == nil {
+		switch op {
+		case OpEq:
+			return want == nil
+		case OpNe:
+			return want != nil
+		}
+		return false
+	}
+	switch v := v.(type) {
+	case bool:
+		b, isBool := want.(bool)
+		switch op {
+		case OpEq:
+			return isBool && v == b
+		case OpNe:
+			return !isBool || v != b
+		}
+		return false
+	case float64:
+		n, isNumber := want.(float64)
+		if !isNumber {
+			return op == OpNe
+		}
+		switch op {
+		case OpEq:
+			return v == n
+		case OpNe:
+			return v != n
+		case OpGt:
+			return v > n
+		case OpGe:
+			return v >= n
+		case OpLt:
+			return v < n
+		case OpLe:
+			return v <= n
+		}
+		return false
+	}
+	s, w := strings.ToLower(fmt.Sprint(v)), strings.ToLower(fmt.Sprint(want))
+	switch op {
+	case OpEq:
+		return s == w
+	case OpNe:
+		return s != w
+	case OpCo:
+		return strings.Contains(s, w)
+	case OpSw:
+		return strings.HasPrefix(s, w)
+	case OpEw:
+		return strings.HasSuffix(s, w)
+	case OpGt:
+		return s > w
+	case OpGe:
+		return s >= w
+	case OpLt:
+		return s < w
+	case OpLe:
+		return s <= w
+	}
+	return false
+}
+
+// lookup 按属性路径读取对象中的值, 属性名称不区分大小写
+func lookup(obj map[string]any, attr string) (any, bool) {
+	var cur any = obj
+	for _, name := range splitAttr(attr) {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		key, ok := findKey(m, name)
+		if !ok {
+			return nil, false
+		}
+		cur = m[key]
+	}
+	return cur, true
+}
+
+func findKey(m map[string]any, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// splitAttr 拆分属性路径, 带 schema 前缀的属性如 urn:...:enterprise:2.0:User:department 拆分为 schema 和属性
+func splitAttr(attr string) []string {
+	var parts []string
+	if strings.HasPrefix(strings.ToLower(attr), "urn:") {
+		i := strings.LastIndex(attr, ":")
+		schema, rest := attr[:i], attr[i+1:]
+		// 核心 schema 的属性不需要前缀
+		if !strings.EqualFold(schema, SchemaUser) && !strings.EqualFold(schema, SchemaGroup) {
+			parts = append(parts, schema)
+		}
+		attr = rest
+	}
+	return append(parts, strings.Split(attr, ".")...)
+}
+
+// Column 过滤器中的属性对应的数据库列
+type Column struct {
+	// Name 列名或返回单个值的子查询
+	Name string
+	// Value 转换比较值, 为空时直接使用过滤器中的值
+	Value func(v any) (any, error)
+	// SQL 自定义条件, 为空时按 Name 和 Value 生成
+	SQL func(op string, v any) (string, []any, error)
+}
+
+// Columns 属性路径到数据库列的映射, key 为小写的属性路径, 如 username、name.formatted、emails.value
+type Columns map[string]*Column
+
+// SQL 将过滤表达式转换为 SQL 条件, 过滤器中的值都作为参数传递
+func (c Columns) SQL(e Expr) (string, []any, error) {
+	switch e := e.(type) {
+	case *Logical:
+		left, leftArgs, err := c.SQL(e.Left)
+		if err != nil {
+			return "", nil, err
+		}
+		right, rightArgs, err := c.SQL(e.Right)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(e.Op), right), append(leftArgs, rightArgs...), nil
+	case *Not:
+		sql, args, err := c.SQL(e.X)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("NOT (%s)", sql), args, nil
+	case *ValuePath:
+		// 本地只保存一个值, 子表达式中的属性按 attr.sub 查找
+		return c.prefixed(e.Attr).SQL(e.Filter)
+	case *Compare:
+		return c.compare(e)
+	}
+	return "", nil, invalidFilter("unsupported expression")
+}
+
+func (c Columns) prefixed(attr string) Columns {
+	prefix := strings.ToLower(strings.Join(splitAttr(attr), ".")) + "."
+	sub := make(Columns)
+	for k, v := range c {
+		if strings.HasPrefix(k, prefix) {
+			sub[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	return sub
+}
+
+func (c Columns) compare(e *Compare) (string, []any, error) {
+	col, ok := c[strings.ToLower(strings.Join(splitAttr(e.Attr), "."))]
+	if !ok {
+		return "", nil, invalidFilter("unsupported attribute %s", e.Attr)
+	}
+	value := e.Value
+	if col.Value != nil && e.Op != OpPr && value != nil {
+		v, err := col.Value(value)
+		if err != nil {
+			return "", nil, invalidFilter("invalid value for %s: %v", e.Attr, err)
+		}
+		value = v
+	}
+	if col.SQL != nil {
+		return col.SQL(e.Op, value)
+	}
+
+	switch e.Op {
+	case OpPr:
+		return fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", col.Name, col.Name), nil, nil
+	case OpEq, OpNe:
+		if value == nil {
+			if e.Op == OpEq {
+				return fmt.Sprintf("%s IS NULL", col.Name), nil, nil
+			}
+			return fmt.Sprintf("%s IS NOT NULL", col.Name), nil, nil
+		}
+		if e.Op == OpEq {
+			return fmt.Sprintf("%s = ?", col.Name), []any{value}, nil
+		}
+		return fmt.Sprintf("%s <> ?", col.Name), []any{value}, nil
+	case OpCo, OpSw, OpEw:
+		s, ok := value.(string)
+		if !ok {
+			return "", nil, invalidFilter("%s requires a string value", e.Op)
+		}
+		s = escapeLike(s)
+		switch e.Op {
+		case OpCo:
+			s = "%" + s + "%"
+		case OpSw:
+			s += "%"
+		case OpEw:
+			s = "%" + s
+		}
+		return fmt.Sprintf("%s LIKE ?", col.Name), []any{s}, nil
+	case OpGt, OpGe, OpLt, OpLe:
+		if value == nil {
+			return "", nil, invalidFilter("%s requires a value", e.Op)
+		}
+		op := map[string]string{OpGt: ">", OpGe: ">=", OpLt: "<", OpLe: "<="}[e.Op]
+		return fmt.Sprintf("%s %s ?", col.Name, op), []any{value}, nil
+	}
+	return "", nil, invalidFilter("unsupported operator %s", e.Op)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,265 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// PATCH 操作
+const (
+	PatchAdd     = "add"
+	PatchReplace = "replace"
+	PatchRemove  = "remove"
+)
+
+// Path PATCH 操作的路径, 如 members[value eq "2"]、name.givenName、emails[type eq "work"].value
+type Path struct {
+	// Attrs 属性路径, 扩展 schema 的属性以 schema 开头
+	Attrs []string
+	// Filter 多值属性的过滤条件, 作用于 Attrs 的最后一个属性
+	Filter Expr
+	// Sub 过滤后元素的子属性
+	Sub string
+}
+
+// ParsePath 解析 PATCH 路径
+func ParsePath(path string) (*Path, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, invalidPath("path is empty")
+	}
+	p := &Path{}
+	attr := path
+	if i := strings.IndexByte(path, '['); i >= 0 {
+		j := strings.LastIndexByte(path, ']')
+		if j < i {
+			return nil, invalidPath("missing ] in %s", path)
+		}
+		filter, err := ParseFilter(path[i+1 : j])
+		if err != nil {
+			return nil, invalidPath("invalid filter in %s: %v", path, err)
+		}
+		p.Filter = filter
+		attr = path[:i]
+		if rest := path[j+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ".") || strings.Contains(rest[1:], ".") {
+				return nil, invalidPath("invalid sub attribute in %s", path)
+			}
+			p.Sub = rest[1:]
+		}
+	}
+	p.Attrs = splitAttr(attr)
+	for _, a := range p.Attrs {
+		if a == "" {
+			return nil, invalidPath("invalid path %s", path)
+		}
+	}
+	return p, nil
+}
+
+func invalidPath(format string, args ...any) error {
+	return NewError(http.StatusBadRequest, ErrInvalidPath, format, args...)
+}
+
+func invalidValue(format string, args ...any) error {
+	return NewError(http.StatusBadRequest, ErrInvalidValue, format, args...)
+}
+
+// ApplyPatch 在资源的 JSON 对象上执行 PATCH 操作, 资源的属性名称不区分大小写
+func ApplyPatch(resource map[string]any, ops []PatchOperation) error {
+	for _, op := range ops {
+		var value any
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return invalidValue("invalid value: %v", err)
+			}
+		}
+		name := strings.ToLower(op.Op)
+		switch name {
+		case PatchAdd, PatchReplace, PatchRemove:
+		default:
+			return NewError(http.StatusBadRequest, ErrInvalidSyntax, "unsupported op %s", op.Op)
+		}
+
+		if op.Path == "" {
+			if name == PatchRemove {
+				return NewError(http.StatusBadRequest, ErrNoTarget, "remove requires a path")
+			}
+			// 没有路径时 value 为对象, 每个属性相当于一个操作
+			obj, ok := value.(map[string]any)
+			if !ok {
+				return invalidValue("value must be an object when path is empty")
+			}
+			for k, v := range obj {
+				path, err := ParsePath(k)
+				if err != nil {
+					return err
+				}
+				if err := apply(resource, name, path, v); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		path, err := ParsePath(op.Path)
+		if err != nil {
+			return err
+		}
+		if name != PatchRemove && value == nil {
+			return invalidValue("%s %s requires a value", op.Op, op.Path)
+		}
+		if err := apply(resource, name, path, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func apply(resource map[string]any, op string, path *Path, value any) error {
+	// 找到最后一个属性所在的对象, add 和 replace 时创建不存在的中间对象
+	parent := resource
+	for _, name := range path.Attrs[:len(path.Attrs)-1] {
+		key, ok := findKey(parent, name)
+		if !ok {
+			if op == PatchRemove {
+				return nil
+			}
+			key = name
+			parent[key] = map[string]any{}
+		}
+		child, ok := parent[key].(map[string]any)
+		if !ok {
+			return invalidPath("%s is not a complex attribute", name)
+		}
+		parent = child
+	}
+	last := path.Attrs[len(path.Attrs)-1]
+	key, exists := findKey(parent, last)
+	if !exists {
+		key = last
+	}
+
+	if path.Filter == nil {
+		switch op {
+		case PatchRemove:
+			if values, ok := value.([]any); ok && exists {
+				// Azure AD 通过 value 指定要移除的成员: {"op":"remove","path":"members","value":[{"value":"1"}]}
+				parent[key] = removeValues(parent[key], values)
+				return nil
+			}
+			delete(parent, key)
+		case PatchAdd:
+			parent[key] = merge(parent[key], value)
+		case PatchReplace:
+			parent[key] = value
+		}
+		return nil
+	}
+
+	items, _ := parent[key].([]any)
+	matched := false
+	kept := make([]any, 0, len(items))
+	for _, item := range items {
+		obj, _ := item.(map[string]any)
+		if obj == nil || !Match(path.Filter, func(attr string) (any, bool) { return lookup(obj, attr) }) {
+			kept = append(kept, item)
+			continue
+		}
+		matched = true
+		switch {
+		case op == PatchRemove && path.Sub == "":
+			continue
+		case op == PatchRemove:
+			if k, ok := findKey(obj, path.Sub); ok {
+				delete(obj, k)
+			}
+		case path.Sub != "":
+			k, ok := findKey(obj, path.Sub)
+			if !ok {
+				k = path.Sub
+			}
+			obj[k] = value
+		default:
+			replacement, ok := value.(map[string]any)
+			if !ok {
+				return invalidValue("value for %s must be an object", strings.Join(path.Attrs, "."))
+			}
+			for k, v := range replacement {
+				obj[k] = v
+			}
+		}
+		kept = append(kept, obj)
+	}
+	if !matched && op == PatchReplace {
+		return NewError(http.StatusBadRequest, ErrNoTarget, "no value matches %s", strings.Join(path.Attrs, "."))
+	}
+	parent[key] = kept
+	return nil
+}
+
+// merge add 操作: 多值属性追加不重复的元素, 对象合并属性, 其他类型直接替换
+func merge(current, value any) any {
+	switch cur := current.(type) {
+	case []any:
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+		for _, v := range values {
+			if !containsValue(cur, v) {
+				cur = append(cur, v)
+			}
+		}
+		return cur
+	case map[string]any:
+		if obj, ok := value.(map[string]any); ok {
+			for k, v := range obj {
+				cur[k] = v
+			}
+			return cur
+		}
+	}
+	return value
+}
+
+func containsValue(items []any, v any) bool {
+	id := valueOf(v)
+	if id == nil {
+		return false
+	}
+	// 成员的 value 可能是字符串或数字
+	for _, item := range items {
+		if other := valueOf(item); other != nil && fmt.Sprint(other) == fmt.Sprint(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func removeValues(current any, values []any) any {
+	items, ok := current.([]any)
+	if !ok {
+		return current
+	}
+	kept := make([]any, 0, len(items))
+	for _, item := range items {
+		if !containsValue(values, item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// valueOf 多值属性元素的 value, 用于判断元素是否相同
+func valueOf(item any) any {
+	if obj, ok := item.(map[string]any); ok {
+		if k, ok := findKey(obj, "value"); ok {
+			return obj[k]
+		}
+		return nil
+	}
+	return item
+}
@@ -0,0 +1,276 @@
+// Package scim 实现 SCIM 2.0 (RFC 7643 / RFC 7644) 的资源定义、过滤器和 PATCH 操作
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ContentType SCIM 请求和响应的内容类型
+	ContentType = "application/scim+json"
+	// BasePath SCIM 接口的路径前缀
+	BasePath = "/scim/v2"
+	// Provider 保存 externalId 的外部身份的 provider 名称
+	Provider = "scim"
+)
+
+// 资源和消息的 schema
+const (
+	SchemaUser            = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup           = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaEnterpriseUser  = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	SchemaListResponse    = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp         = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError           = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProvider = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// 资源类型
+const (
+	ResourceUser  = "User"
+	ResourceGroup = "Group"
+)
+
+// 错误类型, 见 RFC 7644 3.12
+const (
+	ErrInvalidFilter = "invalidFilter"
+	ErrInvalidPath   = "invalidPath"
+	ErrInvalidValue  = "invalidValue"
+	ErrInvalidSyntax = "invalidSyntax"
+	ErrUniqueness    = "uniqueness"
+	ErrMutability    = "mutability"
+	ErrNoTarget      = "noTarget"
+)
+
+// 分页参数
+const (
+	DefaultCount = 100
+	MaxCount     = 200
+)
+
+// Error SCIM 错误响应
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+
+	code int
+}
+
+func NewError(code int, scimType, format string, args ...any) *Error {
+	return &Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(code),
+		ScimType: scimType,
+		Detail:   fmt.Sprintf(format, args...),
+		code:     code,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.ScimType != "" {
+		return fmt.Sprintf("scim %s: %s", e.ScimType, e.Detail)
+	}
+	return "scim: " + e.Detail
+}
+
+// Code 返回 HTTP 状态码
+func (e *Error) Code() int {
+	if e.code == 0 {
+		return http.StatusBadRequest
+	}
+	return e.code
+}
+
+// Bool 兼容 Azure AD 等客户端发送的字符串布尔值, 如 "False"
+type Bool bool
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case bool:
+		*b = Bool(v)
+	case string:
+		parsed, err := strconv.ParseBool(strings.ToLower(v))
+		if err != nil {
+			return NewError(http.StatusBadRequest, ErrInvalidValue, "invalid boolean %q", v)
+		}
+		*b = Bool(parsed)
+	case nil:
+		*b = false
+	default:
+		return NewError(http.StatusBadRequest, ErrInvalidValue, "invalid boolean %s", data)
+	}
+	return nil
+}
+
+// Meta 资源的元数据
+type Meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location,omitempty"`
+}
+
+// Name 用户的姓名
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+// MultiValue emails、phoneNumbers 等多值属性
+type MultiValue struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary Bool   `json:"primary,omitempty"`
+}
+
+// Reference 用户所属的组或组的成员
+type Reference struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+// EnterpriseUser 企业用户扩展
+type EnterpriseUser struct {
+	EmployeeNumber string `json:"employeeNumber,omitempty"`
+	Organization   string `json:"organization,omitempty"`
+	Department     string `json:"department,omitempty"`
+}
+
+// User 用户资源
+type User struct {
+	Schemas      []string        `json:"schemas"`
+	ID           string          `json:"id,omitempty"`
+	ExternalID   string          `json:"externalId,omitempty"`
+	UserName     string          `json:"userName"`
+	Name         *Name           `json:"name,omitempty"`
+	DisplayName  string          `json:"displayName,omitempty"`
+	Active       *Bool           `json:"active,omitempty"`
+	Emails       []MultiValue    `json:"emails,omitempty"`
+	PhoneNumbers []MultiValue    `json:"phoneNumbers,omitempty"`
+	Photos       []MultiValue    `json:"photos,omitempty"`
+	Groups       []Reference     `json:"groups,omitempty"`
+	Enterprise   *EnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta         *Meta           `json:"meta,omitempty"`
+}
+
+// Group 组资源
+type Group struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []Reference `json:"members,omitempty"`
+	Meta        *Meta       `json:"meta,omitempty"`
+}
+
+// ListResponse 查询结果
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    any      `json:"Resources"`
+}
+
+// ListRequest 查询参数, startIndex 从 1 开始
+type ListRequest struct {
+	Filter             string `form:"filter"`
+	StartIndex         int    `form:"startIndex"`
+	Count              *int   `form:"count"`
+	ExcludedAttributes string `form:"excludedAttributes"`
+}
+
+// Page 返回修正后的起始位置和数量
+func (r *ListRequest) Page() (startIndex, count int) {
+	startIndex, count = r.StartIndex, DefaultCount
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if r.Count != nil {
+		count = min(max(*r.Count, 0), MaxCount)
+	}
+	return startIndex, count
+}
+
+// Excluded 判断属性是否在 excludedAttributes 中
+func (r *ListRequest) Excluded(attr string) bool {
+	for _, v := range strings.Split(r.ExcludedAttributes, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), attr) {
+			return true
+		}
+	}
+	return false
+}
+
+// PatchRequest PATCH 请求
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation PATCH 操作, op 不区分大小写
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ServiceProviderConfig 服务端支持的功能
+type ServiceProviderConfig struct {
+	Schemas               []string  `json:"schemas"`
+	Patch                 Supported `json:"patch"`
+	Bulk                  Bulk      `json:"bulk"`
+	Filter                Filter    `json:"filter"`
+	ChangePassword        Supported `json:"changePassword"`
+	Sort                  Supported `json:"sort"`
+	ETag                  Supported `json:"etag"`
+	AuthenticationSchemes []Scheme  `json:"authenticationSchemes"`
+}
+
+type Supported struct {
+	Supported bool `json:"supported"`
+}
+
+type Bulk struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type Filter struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type Scheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// NewServiceProviderConfig 返回服务端的功能说明, 支持 PATCH 和过滤, 不支持批量、排序和修改密码
+func NewServiceProviderConfig() *ServiceProviderConfig {
+	return &ServiceProviderConfig{
+		Schemas: []string{SchemaServiceProvider},
+		Patch:   Supported{Supported: true},
+		Filter:  Filter{Supported: true, MaxResults: MaxCount},
+		AuthenticationSchemes: []Scheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "Authentication with the bearer token configured in scim.token",
+		}},
+	}
+}
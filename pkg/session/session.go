@@ -0,0 +1,189 @@
+// Package session 记录用户的登录会话, 同一次登录签发的 token 共享同一个 sid, 会话信息保存在 redis 中
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/yiran15/api-server/base/conf"
+	"github.com/yiran15/api-server/store"
+)
+
+// touchInterval 会话最后活跃时间的更新间隔, 避免每个请求都写 redis
+const touchInterval = time.Minute
+
+// Session 一次登录产生的会话
+type Session struct {
+	// ID 会话 id, 即 token 中的 sid
+	ID     string `json:"id"`
+	UserID int64  `json:"userId"`
+	// JTI 会话当前 access token 的 jti, 刷新 token 后更新
+	JTI         string    `json:"jti"`
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"userAgent"`
+	AuthMethods []string  `json:"authMethods,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LastSeenAt  time.Time `json:"lastSeenAt"`
+	// ExpiresAt 会话 refresh token 的过期时间, 过期后会话自动删除
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Current 是否为发起请求的会话, 只在列表中返回
+	Current bool `json:"current"`
+}
+
+// Manager 会话管理接口
+// 会话删除后, 认证中间件拒绝该会话的 access token, 会话的 refresh token 也不能再使用
+type Manager interface {
+	// Save 保存会话, 有效期到 ExpiresAt
+	Save(ctx context.Context, s *Session) error
+	// Get 查询会话, 不存在时返回 nil
+	Get(ctx context.Context, sessionID string) (*Session, error)
+	// Touch 更新会话的最后活跃时间和客户端信息, 会话不存在或不属于该用户时返回 false
+	Touch(ctx context.Context, userID int64, sessionID, ip, userAgent string) (bool, error)
+	// List 查询用户的全部会话, 按最后活跃时间倒序
+	List(ctx context.Context, userID int64) ([]*Session, error)
+	// Delete 删除用户的会话
+	Delete(ctx context.Context, userID int64, sessionID string) error
+	// DeleteAll 删除用户的全部会话
+	DeleteAll(ctx context.Context, userID int64) error
+}
+
+type manager struct {
+	cacheStore store.CacheStorer
+	// maxTTL 用户会话集合的过期时间, 与 refresh token 的有效期一致
+	maxTTL time.Duration
+}
+
+func NewManager(cacheStore store.CacheStorer) (Manager, error) {
+	refreshExpire, err := conf.GetJwtRefreshExpirationTime()
+	if err != nil {
+		return nil, err
+	}
+	return &manager{
+		cacheStore: cacheStore,
+		maxTTL:     refreshExpire,
+	}, nil
+}
+
+func (m *manager) Save(ctx context.Context, s *Session) error {
+	ttl := time.Until(s.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := m.cacheStore.SetString(ctx, store.SessionType, s.ID, string(data), &ttl); err != nil {
+		return err
+	}
+	return m.cacheStore.SetSet(ctx, store.UserSessionsType, s.UserID, []any{s.ID}, &m.maxTTL)
+}
+
+func (m *manager) Get(ctx context.Context, sessionID string) (*Session, error) {
+	s, _, err := m.get(ctx, sessionID)
+	return s, err
+}
+
+func (m *manager) get(ctx context.Context, sessionID string) (*Session, string, error) {
+	data, err := m.cacheStore.GetString(ctx, store.SessionType, sessionID)
+	if err != nil || data == "" {
+		return nil, "", err
+	}
+	s := new(Session)
+	if err := json.Unmarshal([]byte(data), s); err != nil {
+		return nil, "", fmt.Errorf("unmarshal session %s error: %w", sessionID, err)
+	}
+	return s, data, nil
+}
+
+func (m *manager) Touch(ctx context.Context, userID int64, sessionID, ip, userAgent string) (bool, error) {
+	s, old, err := m.get(ctx, sessionID)
+	if err != nil || s == nil {
+		return false, err
+	}
+	if s.UserID != userID {
+		return false, nil
+	}
+
+	now := time.Now()
+	if now.Sub(s.LastSeenAt) < touchInterval && s.IP == ip {
+		return true, nil
+	}
+	s.LastSeenAt = now
+	s.IP = ip
+	s.UserAgent = userAgent
+	data, err := json.Marshal(s)
+	if err != nil {
+		return false, err
+	}
+	// 只在会话未被修改或删除时更新, 避免并发请求把已删除的会话写回
+	swapped, err := m.cacheStore.CompareAndSwap(ctx, store.SessionType, sessionID, old, string(data), nil)
+	if err != nil || swapped {
+		return swapped, err
+	}
+	// 会话被并发修改或删除, 重新读取确认会话仍然有效
+	s, err = m.Get(ctx, sessionID)
+	if err != nil || s == nil {
+		return false, err
+	}
+	return s.UserID == userID, nil
+}
+
+func (m *manager) List(ctx context.Context, userID int64) ([]*Session, error) {
+	ids, err := m.cacheStore.GetSet(ctx, store.UserSessionsType, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(ids))
+	var expired []any
+	for _, id := range ids {
+		s, err := m.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			expired = append(expired, id)
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	// 过期的会话只会从集合中惰性删除
+	if err := m.cacheStore.RemSet(ctx, store.UserSessionsType, userID, expired...); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+func (m *manager) Delete(ctx context.Context, userID int64, sessionID string) error {
+	if err := m.cacheStore.DelKey(ctx, store.SessionType, sessionID); err != nil {
+		return err
+	}
+	if err := m.cacheStore.DelKey(ctx, store.RefreshTokenType, sessionID); err != nil {
+		return err
+	}
+	return m.cacheStore.RemSet(ctx, store.UserSessionsType, userID, sessionID)
+}
+
+func (m *manager) DeleteAll(ctx context.Context, userID int64) error {
+	ids, err := m.cacheStore.GetSet(ctx, store.UserSessionsType, userID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := m.cacheStore.DelKey(ctx, store.SessionType, id); err != nil {
+			return err
+		}
+		if err := m.cacheStore.DelKey(ctx, store.RefreshTokenType, id); err != nil {
+			return err
+		}
+	}
+	return m.cacheStore.DelKey(ctx, store.UserSessionsType, userID)
+}
@@ -9,4 +9,14 @@ var ServiceProviderSet = wire.NewSet(
 	v1.NewUserService,
 	v1.NewRoleService,
 	v1.NewApiServicer,
+	v1.NewAccessTokenService,
+	v1.NewMfaService,
+	v1.NewPasswordService,
+	v1.NewSessionService,
+	v1.NewAuditService,
+	v1.NewLoginHistoryService,
+	v1.NewIdentityService,
+	v1.NewScimService,
+	v1.NewTenantService,
+	v1.NewAuthzService,
 )
@@ -0,0 +1,217 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/base/log"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/store"
+	"go.uber.org/zap"
+)
+
+// tokenPrefixLen 保存并展示的令牌前缀长度, 便于用户识别令牌
+const tokenPrefixLen = 12
+
+type AccessTokenServicer interface {
+	CreateAccessToken(ctx context.Context, req *apitypes.AccessTokenCreateRequest) (*apitypes.AccessTokenCreateResponse, error)
+	ListAccessToken(ctx context.Context, req *apitypes.AccessTokenListRequest) (*apitypes.AccessTokenListResponse, error)
+	UpdateAccessToken(ctx context.Context, req *apitypes.AccessTokenUpdateRequest) error
+	DeleteAccessToken(ctx context.Context, req *apitypes.IDRequest) error
+}
+
+type accessTokenService struct {
+	tokenStore store.PersonalAccessTokenStorer
+	userStore  store.UserStorer
+	tx         store.TxManagerInterface
+	jwt        jwt.JwtInterface
+}
+
+func NewAccessTokenService(tokenStore store.PersonalAccessTokenStorer, userStore store.UserStorer, tx store.TxManagerInterface, jwt jwt.JwtInterface) AccessTokenServicer {
+	return &accessTokenService{
+		tokenStore: tokenStore,
+		userStore:  userStore,
+		tx:         tx,
+		jwt:        jwt,
+	}
+}
+
+// CreateAccessToken 为当前用户创建个人访问令牌, 令牌明文只在创建时返回
+func (receiver *accessTokenService) CreateAccessToken(ctx context.Context, req *apitypes.AccessTokenCreateRequest) (*apitypes.AccessTokenCreateResponse, error) {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 不允许使用个人访问令牌创建新的令牌, 避免令牌泄露后被无限续期
+	if mc.TokenType == jwt.TokenTypePersonalAccess {
+		return nil, fmt.Errorf("%w: personal access token can not create new token", constant.ErrNoPermission)
+	}
+
+	user, err := receiver.userStore.Query(ctx, store.Where("id", mc.UserID), store.Preload(model.PreloadRoles))
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := receiver.grantedRoles(user, mc.Tenant(), helper.RemoveDuplicates(req.RolesID))
+	if err != nil {
+		return nil, err
+	}
+
+	token, hash, err := helper.GenerateAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &model.PersonalAccessToken{
+		UserID:      user.ID,
+		Name:        req.Name,
+		TokenPrefix: token[:tokenPrefixLen],
+		TokenHash:   hash,
+		ExpiresAt:   time.Now().AddDate(0, 0, req.ExpireDays),
+		Restricted:  len(roles) > 0,
+	}
+	err = receiver.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := receiver.tokenStore.Create(ctx, obj); err != nil {
+			return err
+		}
+		if len(roles) == 0 {
+			return nil
+		}
+		return receiver.tokenStore.AppendAssociation(ctx, obj, model.PreloadRoles, roles)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithRequestID(ctx).Info("create personal access token", zap.Int64("userID", user.ID), zap.Int64("tokenID", obj.ID), zap.String("name", obj.Name))
+	return &apitypes.AccessTokenCreateResponse{
+		Token:       token,
+		AccessToken: obj,
+	}, nil
+}
+
+func (receiver *accessTokenService) ListAccessToken(ctx context.Context, req *apitypes.AccessTokenListRequest) (*apitypes.AccessTokenListResponse, error) {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	total, objs, err := receiver.tokenStore.List(ctx, req.Page, req.PageSize, "id", "desc", store.Where("user_id", mc.UserID), store.Preload(model.PreloadRoles))
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.AccessTokenListResponse{
+		ListResponse: &apitypes.ListResponse{
+			Pagination: &apitypes.Pagination{
+				Page:     req.Page,
+				PageSize: req.PageSize,
+			},
+			Total: total,
+		},
+		List: objs,
+	}, nil
+}
+
+// UpdateAccessToken 修改当前用户的个人访问令牌的名称、有效期或限定的角色, 角色的校验与创建时相同
+func (receiver *accessTokenService) UpdateAccessToken(ctx context.Context, req *apitypes.AccessTokenUpdateRequest) error {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return err
+	}
+	// 与创建相同, 令牌泄露后不能用来延长自身或其他令牌的有效期
+	if mc.TokenType == jwt.TokenTypePersonalAccess {
+		return fmt.Errorf("%w: personal access token can not update token", constant.ErrNoPermission)
+	}
+
+	obj, err := receiver.tokenStore.Query(ctx, store.Where("id", req.ID), store.Where("user_id", mc.UserID))
+	if err != nil {
+		return err
+	}
+	if req.Name != "" {
+		obj.Name = req.Name
+	}
+	if req.ExpireDays > 0 {
+		obj.ExpiresAt = time.Now().AddDate(0, 0, req.ExpireDays)
+	}
+
+	var roles []*model.Role
+	if req.RolesID != nil {
+		user, err := receiver.userStore.Query(ctx, store.Where("id", mc.UserID), store.Preload(model.PreloadRoles))
+		if err != nil {
+			return err
+		}
+		if roles, err = receiver.grantedRoles(user, mc.Tenant(), helper.RemoveDuplicates(*req.RolesID)); err != nil {
+			return err
+		}
+		obj.Restricted = len(roles) > 0
+	}
+
+	err = receiver.tx.Transaction(ctx, func(ctx context.Context) error {
+		// restricted 可能改为 false, 需要指定更新的字段
+		if err := receiver.tokenStore.Update(ctx, obj, store.Select("name", "expires_at", "restricted")); err != nil {
+			return err
+		}
+		if req.RolesID == nil {
+			return nil
+		}
+		if len(roles) == 0 {
+			return receiver.tokenStore.ClearAssociation(ctx, obj, model.PreloadRoles)
+		}
+		return receiver.tokenStore.ReplaceAssociation(ctx, obj, model.PreloadRoles, roles)
+	})
+	if err != nil {
+		return err
+	}
+	log.WithRequestID(ctx).Info("update personal access token", zap.Int64("userID", mc.UserID), zap.Int64("tokenID", obj.ID), zap.String("name", obj.Name))
+	return nil
+}
+
+// DeleteAccessToken 吊销当前用户的个人访问令牌
+func (receiver *accessTokenService) DeleteAccessToken(ctx context.Context, req *apitypes.IDRequest) error {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	obj, err := receiver.tokenStore.Query(ctx, store.Where("id", req.ID), store.Where("user_id", mc.UserID))
+	if err != nil {
+		return err
+	}
+
+	return receiver.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := receiver.tokenStore.ClearAssociation(ctx, obj, model.PreloadRoles); err != nil {
+			return err
+		}
+		return receiver.tokenStore.Delete(ctx, obj)
+	})
+}
+
+// grantedRoles 校验请求的角色都是用户在当前租户的角色, 返回对应的角色
+func (receiver *accessTokenService) grantedRoles(user *model.User, tenantID int64, rolesID []int64) ([]*model.Role, error) {
+	roles := make([]*model.Role, 0, len(rolesID))
+	notGranted := make([]int64, 0)
+	for _, id := range rolesID {
+		var found *model.Role
+		for _, role := range user.Roles {
+			// 令牌只能限定为当前租户的角色
+			if role.ID == id && role.TenantID == tenantID {
+				found = role
+				break
+			}
+		}
+		if found == nil {
+			notGranted = append(notGranted, id)
+			continue
+		}
+		roles = append(roles, found)
+	}
+	if len(notGranted) > 0 {
+		return nil, fmt.Errorf("%w: roles %v not granted to user", constant.ErrNoPermission, notGranted)
+	}
+	return roles, nil
+}
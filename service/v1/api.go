@@ -9,6 +9,7 @@ import (
 	"github.com/yiran15/api-server/base/apitypes"
 	"github.com/yiran15/api-server/base/log"
 	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/audit"
 	"github.com/yiran15/api-server/store"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -24,15 +25,19 @@ type ApiServicer interface {
 
 type ApiService struct {
 	apiStore store.ApiStorer
+	audit    audit.Recorder
 }
 
-func NewApiServicer(apiStore store.ApiStorer) ApiServicer {
+func NewApiServicer(apiStore store.ApiStorer, audit audit.Recorder) ApiServicer {
 	return &ApiService{
 		apiStore: apiStore,
+		audit:    audit,
 	}
 }
 
-func (receiver *ApiService) CreateApi(ctx context.Context, req *apitypes.ApiCreateRequest) error {
+func (receiver *ApiService) CreateApi(ctx context.Context, req *apitypes.ApiCreateRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionApiCreate, TargetType: audit.TargetApi}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
 	if api, err := receiver.apiStore.Query(ctx, store.Where("name", req.Name)); err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			return err
@@ -42,32 +47,50 @@ func (receiver *ApiService) CreateApi(ctx context.Context, req *apitypes.ApiCrea
 		}
 	}
 
-	return receiver.apiStore.Create(ctx, &model.Api{
+	api := &model.Api{
 		Name:        req.Name,
 		Path:        req.Path,
 		Method:      req.Method,
 		Description: req.Description,
-	})
+		Effect:      req.Effect,
+	}
+	api.Effect = api.PolicyEffect()
+	if err := receiver.apiStore.Create(ctx, api); err != nil {
+		return err
+	}
+	entry.TargetID = api.ID
+	entry.After = apiSnapshot(api)
+	return nil
 }
 
-func (receiver *ApiService) UpdateApi(ctx context.Context, req *apitypes.ApiUpdateRequest) error {
+func (receiver *ApiService) UpdateApi(ctx context.Context, req *apitypes.ApiUpdateRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionApiUpdate, TargetType: audit.TargetApi, TargetID: req.ID}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
 	api, err := receiver.apiStore.Query(ctx, store.Where("id", req.ID))
 	if err != nil {
 		return err
 	}
+	entry.Before = apiSnapshot(api)
 	api.Description = req.Description
-	return receiver.apiStore.Update(ctx, api)
+	if err := receiver.apiStore.Update(ctx, api); err != nil {
+		return err
+	}
+	entry.After = apiSnapshot(api)
+	return nil
 }
 
-func (receiver *ApiService) DeleteApi(ctx context.Context, req *apitypes.IDRequest) error {
-	api, err := receiver.apiStore.Query(ctx, store.Where("id", req.ID), store.Preload(model.PreloadRoles))
+func (receiver *ApiService) DeleteApi(ctx context.Context, req *apitypes.IDRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionApiDelete, TargetType: audit.TargetApi, TargetID: req.ID}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+	api, err := receiver.apiStore.Query(ctx, store.Where("id", req.ID), store.Preload(model.PreloadRoles), store.Preload(model.PreloadDenyRoles))
 	if err != nil {
 		return err
 	}
+	entry.Before = apiSnapshot(api)
 
-	if len(api.Roles) > 0 {
-		roles := make([]string, 0, len(api.Roles))
-		for _, role := range api.Roles {
+	if len(api.Roles) > 0 || len(api.DenyRoles) > 0 {
+		roles := make([]string, 0, len(api.Roles)+len(api.DenyRoles))
+		for _, role := range append(api.Roles, api.DenyRoles...) {
 			roles = append(roles, role.Name)
 		}
 		rolesName := strings.Join(roles, ",")
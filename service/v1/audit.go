@@ -0,0 +1,164 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/store"
+	"gorm.io/gorm"
+)
+
+type AuditServicer interface {
+	ListAudit(ctx context.Context, req *apitypes.AuditListRequest) (*apitypes.AuditListResponse, error)
+}
+
+type auditService struct {
+	auditStore store.AuditLogStorer
+}
+
+func NewAuditService(auditStore store.AuditLogStorer) AuditServicer {
+	return &auditService{
+		auditStore: auditStore,
+	}
+}
+
+// ListAudit 分页查询审计日志, 默认按时间倒序
+func (receiver *auditService) ListAudit(ctx context.Context, req *apitypes.AuditListRequest) (*apitypes.AuditListResponse, error) {
+	var opts []store.Option
+	if req.ActorID != 0 {
+		opts = append(opts, store.Where("actor_id", req.ActorID))
+	}
+	if req.Action != "" {
+		opts = append(opts, store.Where("action", req.Action))
+	}
+	if req.TargetType != "" {
+		opts = append(opts, store.Where("target_type", req.TargetType))
+	}
+	if req.TargetID != "" {
+		opts = append(opts, store.Where("target_id", req.TargetID))
+	}
+	if req.Result != "" {
+		opts = append(opts, store.Where("result", req.Result))
+	}
+	if req.StartTime != nil {
+		opts = append(opts, timeRange("created_at >= ?", *req.StartTime))
+	}
+	if req.EndTime != nil {
+		opts = append(opts, timeRange("created_at < ?", *req.EndTime))
+	}
+
+	direction := "desc"
+	if req.Direction != "" {
+		direction = req.Direction
+	}
+	// 审计日志数据量大, 不允许一次查询全部
+	if req.Pagination == nil {
+		req.Pagination = &apitypes.Pagination{}
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+	total, objs, err := receiver.auditStore.List(ctx, req.Page, req.PageSize, "id", direction, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.AuditListResponse{
+		ListResponse: &apitypes.ListResponse{
+			Pagination: &apitypes.Pagination{
+				Page:     req.Page,
+				PageSize: req.PageSize,
+			},
+			Total: total,
+		},
+		List: objs,
+	}, nil
+}
+
+func timeRange(query string, value any) store.Option {
+	return store.Scopes(func(db *gorm.DB) *gorm.DB {
+		return db.Where(query, value)
+	})
+}
+
+// userSnapshot 审计日志中用户的快照, 不包含密码等敏感信息
+func userSnapshot(user *model.User) map[string]any {
+	if user == nil {
+		return nil
+	}
+	snapshot := map[string]any{
+		"name":       user.Name,
+		"nickName":   user.NickName,
+		"email":      user.Email,
+		"mobile":     user.Mobile,
+		"avatar":     user.Avatar,
+		"department": user.Department,
+	}
+	if user.Status != nil {
+		snapshot["status"] = *user.Status
+	}
+	return snapshot
+}
+
+// rolesSnapshot 审计日志中用户角色的快照
+func rolesSnapshot(roles []*model.Role) map[string]any {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return map[string]any{"roles": names}
+}
+
+// roleSnapshot 审计日志中角色的快照, 接口使用 "METHOD path" 表示
+func roleSnapshot(role *model.Role, apis []*model.Api) map[string]any {
+	if role == nil {
+		return nil
+	}
+	apiNames := make([]string, 0, len(apis))
+	for _, api := range apis {
+		apiNames = append(apiNames, fmt.Sprintf("%s %s", api.Method, api.Path))
+	}
+	denyApis := make([]string, 0, len(role.DenyApis))
+	for _, api := range role.DenyApis {
+		denyApis = append(denyApis, fmt.Sprintf("%s %s", api.Method, api.Path))
+	}
+	parents := make([]string, 0, len(role.Parents))
+	for _, parent := range role.Parents {
+		parents = append(parents, parent.Name)
+	}
+	return map[string]any{
+		"name":        role.Name,
+		"description": role.Description,
+		"apis":        apiNames,
+		"denyApis":    denyApis,
+		"parents":     parents,
+	}
+}
+
+// apiSnapshot 审计日志中接口的快照
+func apiSnapshot(api *model.Api) map[string]any {
+	if api == nil {
+		return nil
+	}
+	return map[string]any{
+		"name":        api.Name,
+		"path":        api.Path,
+		"method":      api.Method,
+		"effect":      api.PolicyEffect(),
+		"description": api.Description,
+	}
+}
+
+func tenantSnapshot(tenant *model.Tenant) map[string]any {
+	if tenant == nil {
+		return nil
+	}
+	return map[string]any{
+		"name":        tenant.Name,
+		"description": tenant.Description,
+	}
+}
@@ -0,0 +1,114 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/conf"
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/casbin"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/store"
+	"gorm.io/gorm"
+)
+
+type AuthzServicer interface {
+	Check(ctx context.Context, req *apitypes.AuthzCheckRequest) (*apitypes.AuthzCheckResponse, error)
+	Permissions(ctx context.Context) (*apitypes.AuthzPermissionsResponse, error)
+}
+
+type authzService struct {
+	userStore store.UserStorer
+	roleStore store.RoleStorer
+	checker   casbin.AuthChecker
+	jwt       jwt.JwtInterface
+}
+
+func NewAuthzService(userStore store.UserStorer, roleStore store.RoleStorer, checker casbin.AuthChecker, jwt jwt.JwtInterface) AuthzServicer {
+	return &authzService{
+		userStore: userStore,
+		roleStore: roleStore,
+		checker:   checker,
+		jwt:       jwt,
+	}
+}
+
+// Check 使用用户在当前租户的角色或指定角色检查能否调用接口, 与 AuthZ 中间件的判断相同
+// 检查用户时不考虑个人访问令牌限定的角色和两步验证的要求
+func (receiver *authzService) Check(ctx context.Context, req *apitypes.AuthzCheckRequest) (*apitypes.AuthzCheckResponse, error) {
+	tenantID := jwt.TenantFromContext(ctx)
+	var roles []string
+	if req.UserID != 0 {
+		user, err := receiver.userStore.Query(ctx, store.Where("id", req.UserID), tenantUsers(ctx), store.Preload(model.PreloadRoles))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("user %d not found", req.UserID)
+			}
+			return nil, err
+		}
+		roles = tenantRoleNames(user.Roles, tenantID)
+	} else {
+		role, err := receiver.roleStore.Query(ctx, store.Where("tenant_id", tenantID), store.Where("name", req.Role))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("role %s not found", req.Role)
+			}
+			return nil, err
+		}
+		roles = []string{role.Name}
+	}
+
+	allowed, policy, err := receiver.checker.EnforceRoles(roles, model.TenantDomain(tenantID), req.Path, req.Method)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		policy = []string{}
+	}
+	return &apitypes.AuthzCheckResponse{Allowed: allowed, Roles: roles, Policy: policy}, nil
+}
+
+// Permissions 当前用户可以调用的全部接口, 使用当前 token 在当前租户可以使用的角色检查服务注册的每个路由
+func (receiver *authzService) Permissions(ctx context.Context) (*apitypes.AuthzPermissionsResponse, error) {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := receiver.userStore.Query(ctx, store.Where("id", mc.UserID), store.Preload(model.PreloadRoles))
+	if err != nil {
+		return nil, err
+	}
+	roles := mc.UsableRoles(tenantRoleNames(user.Roles, mc.Tenant()), conf.GetMfaRequiredRoles())
+
+	res := &apitypes.AuthzPermissionsResponse{Roles: roles, Apis: []*apitypes.AuthzPermission{}}
+	if len(roles) == 0 {
+		return res, nil
+	}
+	dom := model.TenantDomain(mc.Tenant())
+	for _, apiType := range constant.ApiData.ApiType {
+		for _, api := range constant.ApiData.ApiInfo[apiType] {
+			allowed, _, err := receiver.checker.EnforceRoles(roles, dom, api.Path, api.Method)
+			if err != nil {
+				return nil, err
+			}
+			if allowed {
+				res.Apis = append(res.Apis, &apitypes.AuthzPermission{Method: api.Method, Path: api.Path})
+			}
+		}
+	}
+	return res, nil
+}
+
+// tenantRoleNames 用户在租户中的角色名称
+func tenantRoleNames(roles []*model.Role, tenantID int64) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if role.TenantID == tenantID {
+			names = append(names, role.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/base/log"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/pkg/oauth"
+	"github.com/yiran15/api-server/store"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type IdentityServicer interface {
+	ListIdentities(ctx context.Context) ([]*model.UserIdentity, error)
+	LinkIdentity(ctx context.Context, provider string, params *oauth.AuthParams) (*apitypes.IdentityLinkResponse, error)
+	LinkIdentityCallback(ctx context.Context, req *apitypes.OAuthLoginRequest) (*model.UserIdentity, error)
+	UnlinkIdentity(ctx context.Context, req *apitypes.IDRequest) error
+}
+
+type identityService struct {
+	identityStore store.UserIdentityStorer
+	userStore     store.UserStorer
+	oauth         *oauth.OAuth2
+	jwt           jwt.JwtInterface
+}
+
+func NewIdentityService(identityStore store.UserIdentityStorer, userStore store.UserStorer, oauth *oauth.OAuth2, jwt jwt.JwtInterface) IdentityServicer {
+	return &identityService{
+		identityStore: identityStore,
+		userStore:     userStore,
+		oauth:         oauth,
+		jwt:           jwt,
+	}
+}
+
+// ListIdentities 当前用户关联的外部身份
+func (receiver *identityService) ListIdentities(ctx context.Context) ([]*model.UserIdentity, error) {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, identities, err := receiver.identityStore.List(ctx, 0, 0, "id", "asc", store.Where("user_id", mc.UserID))
+	return identities, err
+}
+
+// LinkIdentity 返回关联外部身份的授权地址, 授权流程与 OAuth2 登录相同
+func (receiver *identityService) LinkIdentity(ctx context.Context, provider string, params *oauth.AuthParams) (*apitypes.IdentityLinkResponse, error) {
+	url, err := receiver.oauth.Redirect(ctx, provider, params)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.IdentityLinkResponse{URL: url}, nil
+}
+
+// LinkIdentityCallback 使用授权码获取 provider 用户信息, 关联到当前用户
+// 每个 provider 只能关联一个身份, 已关联其他用户的身份不能再关联
+func (receiver *identityService) LinkIdentityCallback(ctx context.Context, req *apitypes.OAuthLoginRequest) (*model.UserIdentity, error) {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := ctx.Value(constant.ProviderContextKey).(string)
+	if !ok {
+		return nil, errors.New("invalid provider")
+	}
+
+	oauthToken, err := receiver.oauth.Auth(ctx, req.Code, provider, oauth.AuthParamsFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	external, err := receiver.oauth.Identity(ctx, oauthToken, provider)
+	if err != nil {
+		return nil, err
+	}
+	subject := external.Subject
+
+	current, err := receiver.identityStore.Query(ctx, store.Where("provider", provider), store.Where("subject", subject))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil && current.UserID != mc.UserID {
+		return nil, constant.ErrIdentityLinked
+	}
+	if err != nil {
+		current = nil
+		_, linked, err := receiver.identityStore.List(ctx, 0, 0, "", "", store.Where("user_id", mc.UserID), store.Where("provider", provider))
+		if err != nil {
+			return nil, err
+		}
+		if len(linked) > 0 {
+			return nil, errors.New("provider is already linked, unlink it first")
+		}
+	}
+
+	identity, err := saveIdentity(ctx, receiver.identityStore, current, &model.UserIdentity{UserID: mc.UserID, Provider: provider, Subject: subject, Profile: external.Claims})
+	if err != nil {
+		return nil, err
+	}
+	log.WithRequestID(ctx).Info("link identity", zap.Int64("userID", mc.UserID), zap.String("provider", provider), zap.String("subject", subject))
+	return identity, nil
+}
+
+// UnlinkIdentity 解除当前用户的外部身份关联
+// 没有设置密码的用户不能解除最后一个身份, 否则无法再登录
+func (receiver *identityService) UnlinkIdentity(ctx context.Context, req *apitypes.IDRequest) error {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return err
+	}
+	identity, err := receiver.identityStore.Query(ctx, store.Where("id", req.ID), store.Where("user_id", mc.UserID))
+	if err != nil {
+		return err
+	}
+
+	user, err := receiver.userStore.Query(ctx, store.Where("id", mc.UserID))
+	if err != nil {
+		return err
+	}
+	if user.Password == "" {
+		total, _, err := receiver.identityStore.List(ctx, 0, 0, "", "", store.Where("user_id", mc.UserID))
+		if err != nil {
+			return err
+		}
+		if total <= 1 {
+			return errors.New("cannot unlink the last identity of a user without password")
+		}
+	}
+
+	if err := receiver.identityStore.Delete(ctx, identity); err != nil {
+		return err
+	}
+	log.WithRequestID(ctx).Info("unlink identity", zap.Int64("userID", mc.UserID), zap.String("provider", identity.Provider), zap.String("subject", identity.Subject))
+	return nil
+}
+
+// saveIdentity 保存外部身份, current 为已存在的记录时只更新用户信息, 否则创建新的关联
+func saveIdentity(ctx context.Context, identityStore store.UserIdentityStorer, current, identity *model.UserIdentity) (*model.UserIdentity, error) {
+	if current != nil {
+		current.Profile = identity.Profile
+		return current, identityStore.Update(ctx, current)
+	}
+	identity.LinkedAt = time.Now()
+	return identity, identityStore.Create(ctx, identity)
+}
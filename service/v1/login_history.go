@@ -0,0 +1,87 @@
+package v1
+
+import (
+	"context"
+
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/store"
+)
+
+type LoginHistoryServicer interface {
+	ListLoginHistory(ctx context.Context, req *apitypes.LoginHistoryRequest) (*apitypes.LoginHistoryResponse, error)
+	ListSelfLoginHistory(ctx context.Context, req *apitypes.LoginHistoryFilter) (*apitypes.LoginHistoryResponse, error)
+}
+
+type loginHistoryService struct {
+	eventStore store.LoginEventStorer
+	userStore  store.UserStorer
+	jwt        jwt.JwtInterface
+}
+
+func NewLoginHistoryService(eventStore store.LoginEventStorer, userStore store.UserStorer, jwt jwt.JwtInterface) LoginHistoryServicer {
+	return &loginHistoryService{
+		eventStore: eventStore,
+		userStore:  userStore,
+		jwt:        jwt,
+	}
+}
+
+// ListLoginHistory 管理员查询用户的登录历史
+func (receiver *loginHistoryService) ListLoginHistory(ctx context.Context, req *apitypes.LoginHistoryRequest) (*apitypes.LoginHistoryResponse, error) {
+	user, err := receiver.userStore.Query(ctx, store.Where("id", req.ID), tenantUsers(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return receiver.list(ctx, user.ID, &req.LoginHistoryFilter)
+}
+
+// ListSelfLoginHistory 当前用户的登录历史, 用于发现不是本人的登录
+func (receiver *loginHistoryService) ListSelfLoginHistory(ctx context.Context, req *apitypes.LoginHistoryFilter) (*apitypes.LoginHistoryResponse, error) {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return receiver.list(ctx, mc.UserID, req)
+}
+
+// list 分页查询用户的登录事件, 按时间倒序
+func (receiver *loginHistoryService) list(ctx context.Context, userID int64, req *apitypes.LoginHistoryFilter) (*apitypes.LoginHistoryResponse, error) {
+	opts := []store.Option{store.Where("user_id", userID)}
+	if req.Event != "" {
+		opts = append(opts, store.Where("event", req.Event))
+	}
+	if req.Result != "" {
+		opts = append(opts, store.Where("result", req.Result))
+	}
+	if req.StartTime != nil {
+		opts = append(opts, timeRange("created_at >= ?", *req.StartTime))
+	}
+	if req.EndTime != nil {
+		opts = append(opts, timeRange("created_at < ?", *req.EndTime))
+	}
+
+	if req.Pagination == nil {
+		req.Pagination = &apitypes.Pagination{}
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+	total, objs, err := receiver.eventStore.List(ctx, req.Page, req.PageSize, "id", "desc", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.LoginHistoryResponse{
+		ListResponse: &apitypes.ListResponse{
+			Pagination: &apitypes.Pagination{
+				Page:     req.Page,
+				PageSize: req.PageSize,
+			},
+			Total: total,
+		},
+		List: objs,
+	}, nil
+}
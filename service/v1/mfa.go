@@ -0,0 +1,261 @@
+package v1
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/conf"
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/base/log"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/audit"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/pkg/totp"
+	"github.com/yiran15/api-server/store"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+type MfaServicer interface {
+	EnrollMfa(ctx context.Context) (*apitypes.MfaEnrollResponse, error)
+	ConfirmMfa(ctx context.Context, req *apitypes.MfaConfirmRequest) (*apitypes.MfaConfirmResponse, error)
+	ResetMfa(ctx context.Context, req *apitypes.IDRequest) error
+	// MfaEnabled 用户是否已启用两步验证
+	MfaEnabled(ctx context.Context, userID int64) (bool, error)
+	// VerifyMfa 校验验证码或恢复码, 恢复码校验通过后失效
+	VerifyMfa(ctx context.Context, userID int64, code string) (bool, error)
+}
+
+type mfaService struct {
+	mfaStore  store.UserMfaStorer
+	userStore store.UserStorer
+	jwt       jwt.JwtInterface
+	secretBox *helper.SecretBox
+	issuer    string
+	audit     audit.Recorder
+}
+
+func NewMfaService(mfaStore store.UserMfaStorer, userStore store.UserStorer, jwt jwt.JwtInterface, audit audit.Recorder) (MfaServicer, error) {
+	secret, err := conf.GetJwtSecret()
+	if err != nil {
+		return nil, err
+	}
+	secretBox, err := helper.NewSecretBox(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &mfaService{
+		mfaStore:  mfaStore,
+		userStore: userStore,
+		jwt:       jwt,
+		secretBox: secretBox,
+		issuer:    conf.GetMfaIssuer(),
+		audit:     audit,
+	}, nil
+}
+
+// EnrollMfa 为当前用户生成新的 TOTP 密钥, 需要调用 ConfirmMfa 确认后才会生效
+func (receiver *mfaService) EnrollMfa(ctx context.Context) (*apitypes.MfaEnrollResponse, error) {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if mc.TokenType == jwt.TokenTypePersonalAccess {
+		return nil, fmt.Errorf("%w: personal access token can not enroll mfa", constant.ErrNoPermission)
+	}
+
+	user, err := receiver.userStore.Query(ctx, store.Where("id", mc.UserID))
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := receiver.mfaStore.Query(ctx, store.Where("user_id", user.ID))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if mfa != nil && mfa.Enabled {
+		return nil, errors.New("mfa already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := receiver.secretBox.Seal([]byte(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	if mfa == nil {
+		err = receiver.mfaStore.Create(ctx, &model.UserMfa{UserID: user.ID, Secret: encrypted})
+	} else {
+		mfa.Secret = encrypted
+		err = receiver.mfaStore.Update(ctx, mfa)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &apitypes.MfaEnrollResponse{
+		Secret: secret,
+		URI:    totp.URI(receiver.issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmMfa 使用验证器生成的第一个验证码确认启用两步验证, 返回一次性恢复码
+func (receiver *mfaService) ConfirmMfa(ctx context.Context, req *apitypes.MfaConfirmRequest) (*apitypes.MfaConfirmResponse, error) {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if mc.TokenType == jwt.TokenTypePersonalAccess {
+		return nil, fmt.Errorf("%w: personal access token can not enroll mfa", constant.ErrNoPermission)
+	}
+
+	mfa, err := receiver.mfaStore.Query(ctx, store.Where("user_id", mc.UserID))
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled {
+		return nil, errors.New("mfa already enabled")
+	}
+
+	step, ok, err := receiver.validateTotp(mfa, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, constant.ErrMfaFailed
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	mfa.Enabled = true
+	mfa.RecoveryCodes = hashes
+	mfa.LastUsedStep = step
+	if err := receiver.mfaStore.Update(ctx, mfa); err != nil {
+		return nil, err
+	}
+
+	log.WithRequestID(ctx).Info("user enabled mfa", zap.Int64("userID", mc.UserID))
+	return &apitypes.MfaConfirmResponse{RecoveryCodes: codes}, nil
+}
+
+// ResetMfa 管理员重置用户的两步验证, 用户需要重新绑定验证器
+func (receiver *mfaService) ResetMfa(ctx context.Context, req *apitypes.IDRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionUserResetMfa, TargetType: audit.TargetUser, TargetID: req.ID}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+	user, err := receiver.userStore.Query(ctx, store.Where("id", req.ID), tenantUsers(ctx))
+	if err != nil {
+		return err
+	}
+	if err := receiver.mfaStore.Delete(ctx, &model.UserMfa{}, store.Where("user_id", user.ID)); err != nil {
+		return err
+	}
+	log.WithRequestID(ctx).Info("reset user mfa", zap.Int64("userID", user.ID), zap.String("userName", user.Name))
+	return nil
+}
+
+func (receiver *mfaService) MfaEnabled(ctx context.Context, userID int64) (bool, error) {
+	mfa, err := receiver.mfaStore.Query(ctx, store.Where("user_id", userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return mfa.Enabled, nil
+}
+
+func (receiver *mfaService) VerifyMfa(ctx context.Context, userID int64, code string) (bool, error) {
+	mfa, err := receiver.mfaStore.Query(ctx, store.Where("user_id", userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !mfa.Enabled {
+		return false, nil
+	}
+
+	step, ok, err := receiver.validateTotp(mfa, code)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		// 同一个验证码只能使用一次, 条件更新保证并发请求中只有一个能使用该周期的验证码
+		rows, err := receiver.mfaStore.UpdateRows(ctx, &model.UserMfa{ID: mfa.ID, LastUsedStep: step}, store.Lt("last_used_step", step))
+		if err != nil {
+			return false, err
+		}
+		if rows == 0 {
+			log.WithRequestID(ctx).Error("mfa code replayed", zap.Int64("userID", userID))
+			return false, nil
+		}
+		return true, nil
+	}
+
+	hash := hashRecoveryCode(code)
+	idx := slices.Index(mfa.RecoveryCodes, hash)
+	if idx < 0 {
+		return false, nil
+	}
+	mfa.RecoveryCodes = slices.Delete(mfa.RecoveryCodes, idx, idx+1)
+	// 以 updated_at 作为乐观锁, 恢复码在并发请求中只能使用一次
+	rows, err := receiver.mfaStore.UpdateRows(ctx, mfa, store.Select("recovery_codes", "updated_at"), store.Where("updated_at", mfa.UpdatedAt))
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		log.WithRequestID(ctx).Error("mfa recovery code replayed", zap.Int64("userID", userID))
+		return false, nil
+	}
+	log.WithRequestID(ctx).Info("user used mfa recovery code", zap.Int64("userID", userID), zap.Int("remaining", len(mfa.RecoveryCodes)))
+	return true, nil
+}
+
+func (receiver *mfaService) validateTotp(mfa *model.UserMfa, code string) (int64, bool, error) {
+	secret, err := receiver.secretBox.Open(mfa.Secret)
+	if err != nil {
+		return 0, false, fmt.Errorf("decrypt mfa secret error, jwt.secret may have changed: %w", err)
+	}
+	step, ok := totp.Validate(string(secret), code, time.Now())
+	return step, ok, nil
+}
+
+// generateRecoveryCodes 生成恢复码, 返回明文和用于存储的哈希
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for range recoveryCodeCount {
+		buf := make([]byte, 6)
+		if _, err = rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(buf))[:10]
+		code = code[:5] + "-" + code[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
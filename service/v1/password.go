@@ -0,0 +1,192 @@
+package v1
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/conf"
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/base/log"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/pkg/loginguard"
+	"github.com/yiran15/api-server/pkg/mailer"
+	"github.com/yiran15/api-server/pkg/password"
+	"github.com/yiran15/api-server/pkg/session"
+	"github.com/yiran15/api-server/store"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// mailTimeout 发送密码重置邮件的超时时间
+const mailTimeout = time.Minute
+
+type PasswordServicer interface {
+	ForgotPassword(ctx context.Context, req *apitypes.PasswordForgotRequest) error
+	ResetPassword(ctx context.Context, req *apitypes.PasswordResetRequest) error
+}
+
+type passwordService struct {
+	userStore  store.UserStorer
+	cacheStore store.CacheStorer
+	revoker    jwt.Revoker
+	loginGuard loginguard.Guard
+	mailer     mailer.Mailer
+	checker    password.Checker
+	hasher     password.Hasher
+	sessions   session.Manager
+	tx         store.TxManagerInterface
+}
+
+func NewPasswordService(userStore store.UserStorer, cacheStore store.CacheStorer, revoker jwt.Revoker, loginGuard loginguard.Guard, mailer mailer.Mailer, checker password.Checker, hasher password.Hasher, sessions session.Manager, tx store.TxManagerInterface) PasswordServicer {
+	return &passwordService{
+		userStore:  userStore,
+		cacheStore: cacheStore,
+		revoker:    revoker,
+		loginGuard: loginGuard,
+		mailer:     mailer,
+		checker:    checker,
+		hasher:     hasher,
+		sessions:   sessions,
+		tx:         tx,
+	}
+}
+
+// ForgotPassword 向用户邮箱发送一次性的密码重置链接
+// 无论邮箱是否存在都返回成功, 邮件异步发送, 避免被用于探测用户是否存在
+func (receiver *passwordService) ForgotPassword(ctx context.Context, req *apitypes.PasswordForgotRequest) error {
+	resetURL, err := conf.GetPasswordResetURL()
+	if err != nil {
+		return err
+	}
+	expire, err := conf.GetPasswordResetExpireTime()
+	if err != nil {
+		return err
+	}
+
+	user, err := receiver.userStore.Query(ctx, store.Where("email", req.Email), store.Where("status", model.UserStatusActive))
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		log.WithRequestID(ctx).Warn("forgot password, user not found", zap.String("email", req.Email), zap.String("ip", helper.GetClientIPFromContext(ctx)))
+		return nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	hash := helper.HashToken(token)
+
+	// 同一用户只保留最新的重置 token
+	previous, err := receiver.cacheStore.GetString(ctx, store.PasswordResetUserType, user.ID)
+	if err != nil {
+		return err
+	}
+	if previous != "" {
+		if err := receiver.cacheStore.DelKey(ctx, store.PasswordResetType, previous); err != nil {
+			return err
+		}
+	}
+	if err := receiver.cacheStore.SetString(ctx, store.PasswordResetType, hash, strconv.FormatInt(user.ID, 10), &expire); err != nil {
+		return err
+	}
+	if err := receiver.cacheStore.SetString(ctx, store.PasswordResetUserType, user.ID, hash, &expire); err != nil {
+		return err
+	}
+
+	msg := &mailer.Message{
+		To:      []string{user.Email},
+		Subject: "重置密码",
+		Body: fmt.Sprintf("%s, 你好:\n\n我们收到了重置你的账号密码的请求, 请在 %s 内打开以下链接设置新密码:\n\n%s\n\n如果不是你本人操作, 请忽略这封邮件, 你的密码不会被修改。\n",
+			user.Name, expire, strings.ReplaceAll(resetURL, "{token}", token)),
+	}
+	requestID := helper.GetRequestIDFromContext(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), constant.RequestIDContextKey, requestID), mailTimeout)
+		defer cancel()
+		if err := receiver.mailer.Send(ctx, msg); err != nil {
+			log.WithRequestID(ctx).Error("send password reset mail failed", zap.Int64("userID", user.ID), zap.Error(err))
+		}
+	}()
+
+	log.WithRequestID(ctx).Info("password reset requested", zap.Int64("userID", user.ID), zap.String("ip", helper.GetClientIPFromContext(ctx)))
+	return nil
+}
+
+// ResetPassword 使用重置 token 设置新密码, token 只能使用一次, 重置后吊销用户已签发的 token
+// 新密码不符合策略时不消耗 token, 用户可以换一个密码重试
+func (receiver *passwordService) ResetPassword(ctx context.Context, req *apitypes.PasswordResetRequest) error {
+	hash := helper.HashToken(req.Token)
+	value, err := receiver.cacheStore.GetString(ctx, store.PasswordResetType, hash)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		log.WithRequestID(ctx).Error("reset password failed, token invalid or expired", zap.String("ip", helper.GetClientIPFromContext(ctx)))
+		return constant.ErrPasswordResetTokenInvalid
+	}
+	userID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid password reset token value %s: %w", value, err)
+	}
+
+	user, err := receiver.userStore.Query(ctx, store.Where("id", userID), store.Where("status", model.UserStatusActive))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return constant.ErrPasswordResetTokenInvalid
+		}
+		return err
+	}
+	if err := receiver.checker.Check(ctx, user, req.Password); err != nil {
+		return err
+	}
+
+	// 并发请求只有一个能消耗 token
+	consumed, err := receiver.cacheStore.GetDelString(ctx, store.PasswordResetType, hash)
+	if err != nil {
+		return err
+	}
+	if consumed != value {
+		log.WithRequestID(ctx).Error("reset password failed, token already used", zap.Int64("userID", userID), zap.String("ip", helper.GetClientIPFromContext(ctx)))
+		return constant.ErrPasswordResetTokenInvalid
+	}
+	if err := receiver.cacheStore.DelKey(ctx, store.PasswordResetUserType, userID); err != nil {
+		return err
+	}
+
+	hashedPassword, err := receiver.hasher.Hash(req.Password)
+	if err != nil {
+		return err
+	}
+	if err := receiver.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := receiver.userStore.Update(ctx, &model.User{ID: user.ID, Password: hashedPassword}); err != nil {
+			return err
+		}
+		return receiver.checker.Record(ctx, user.ID, hashedPassword)
+	}); err != nil {
+		return err
+	}
+
+	if err := receiver.revoker.RevokeUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := receiver.sessions.DeleteAll(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := receiver.loginGuard.Unlock(ctx, user.Email); err != nil {
+		log.WithRequestID(ctx).Error("reset password unlock login failed", zap.Int64("userID", user.ID), zap.Error(err))
+	}
+	log.WithRequestID(ctx).Info("password reset", zap.Int64("userID", user.ID), zap.String("ip", helper.GetClientIPFromContext(ctx)))
+	return nil
+}
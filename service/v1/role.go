@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/yiran15/api-server/base/apitypes"
 	"github.com/yiran15/api-server/base/helper"
 	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/audit"
 	"github.com/yiran15/api-server/pkg/casbin"
+	"github.com/yiran15/api-server/pkg/jwt"
 	"github.com/yiran15/api-server/store"
 	"gorm.io/gorm"
 )
@@ -20,37 +23,49 @@ type RoleServicer interface {
 	DeleteRole(ctx context.Context, req *apitypes.IDRequest) error
 	QueryRole(ctx context.Context, req *apitypes.IDRequest) (*model.Role, error)
 	ListRole(ctx context.Context, pagination *apitypes.RoleListRequest) (*apitypes.RoleListResponse, error)
+	EffectiveApis(ctx context.Context, req *apitypes.IDRequest) (*apitypes.RoleEffectiveApisResponse, error)
 }
 
+// maxRoleDepth casbin 的角色管理器最多解析 10 层继承, 更深的父角色不会生效
+const maxRoleDepth = 10
+
 type roleService struct {
 	roleRepository store.RoleStorer
 	apiRepository  store.ApiStorer
 	casbinStore    store.CasbinStorer
 	casbinManager  casbin.CasbinManager
 	txManager      store.TxManagerInterface
+	audit          audit.Recorder
 }
 
-func NewRoleService(roleRepository store.RoleStorer, apiRepository store.ApiStorer, casbinStore store.CasbinStorer, casbinManager casbin.CasbinManager, txManager store.TxManagerInterface) RoleServicer {
+func NewRoleService(roleRepository store.RoleStorer, apiRepository store.ApiStorer, casbinStore store.CasbinStorer, casbinManager casbin.CasbinManager, txManager store.TxManagerInterface, audit audit.Recorder) RoleServicer {
 	return &roleService{
 		roleRepository: roleRepository,
 		apiRepository:  apiRepository,
 		casbinStore:    casbinStore,
 		casbinManager:  casbinManager,
 		txManager:      txManager,
+		audit:          audit,
 	}
 }
 
-func (receiver *roleService) CreateRole(ctx context.Context, req *apitypes.RoleCreateRequest) error {
+func (receiver *roleService) CreateRole(ctx context.Context, req *apitypes.RoleCreateRequest) (err error) {
 	req.Apis = helper.RemoveDuplicates(req.Apis)
+	req.DenyApis = helper.RemoveDuplicates(req.DenyApis)
+	req.ParentRoles = helper.RemoveDuplicates(req.ParentRoles)
 	var (
-		role  *model.Role
-		total int64
-		apis  []*model.Api
-		err   error
-		rules []*model.CasbinRule
+		role     *model.Role
+		total    int64
+		apis     []*model.Api
+		denyApis []*model.Api
+		parents  []*model.Role
+		rules    []*model.CasbinRule
 	)
+	entry := &audit.Entry{Action: audit.ActionRoleCreate, TargetType: audit.TargetRole}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+	tenantID := jwt.TenantFromContext(ctx)
 
-	if role, err = receiver.roleRepository.Query(ctx, store.Where("name", req.Name)); err != nil {
+	if role, err = receiver.roleRepository.Query(ctx, store.Where("tenant_id", tenantID), store.Where("name", req.Name)); err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			return err
 		}
@@ -71,21 +86,26 @@ func (receiver *roleService) CreateRole(ctx context.Context, req *apitypes.RoleC
 		}
 	}
 
-	for _, api := range apis {
-		rules = append(rules, &model.CasbinRule{
-			PType: helper.String("p"),
-			V0:    helper.String(req.Name),
-			V1:    helper.String(api.Path),
-			V2:    helper.String(api.Method),
-		})
+	if denyApis, err = receiver.listDenyApis(ctx, req.Apis, req.DenyApis); err != nil {
+		return err
+	}
+
+	if parents, err = receiver.listParentRoles(ctx, tenantID, nil, req.ParentRoles); err != nil {
+		return err
 	}
 
+	rules = append(apiRules(req.Name, tenantID, apis, denyApis), parentRules(req.Name, tenantID, parents)...)
+
+	role = &model.Role{
+		TenantID:    tenantID,
+		Name:        req.Name,
+		Description: req.Description,
+		Apis:        apis,
+		DenyApis:    denyApis,
+		Parents:     parents,
+	}
 	if err := receiver.txManager.Transaction(ctx, func(ctx context.Context) error {
-		if err := receiver.roleRepository.Create(ctx, &model.Role{
-			Name:        req.Name,
-			Description: req.Description,
-			Apis:        apis,
-		}); err != nil {
+		if err := receiver.roleRepository.Create(ctx, role); err != nil {
 			return err
 		}
 		if err := receiver.casbinStore.CreateBatch(ctx, rules); err != nil {
@@ -95,22 +115,38 @@ func (receiver *roleService) CreateRole(ctx context.Context, req *apitypes.RoleC
 	}); err != nil {
 		return err
 	}
+	entry.TargetID = role.ID
+	entry.After = roleSnapshot(role, apis)
 
-	return receiver.casbinManager.LoadPolicy()
+	return receiver.casbinManager.UpdatePolicies(nil, rules)
 }
 
-func (receiver *roleService) UpdateRole(ctx context.Context, req *apitypes.RoleUpdateRequest) error {
+func (receiver *roleService) UpdateRole(ctx context.Context, req *apitypes.RoleUpdateRequest) (err error) {
 	var (
 		total int64
 		apis  []*model.Api
-		err   error
 		rules []*model.CasbinRule
 	)
+	entry := &audit.Entry{Action: audit.ActionRoleUpdate, TargetType: audit.TargetRole, TargetID: req.ID}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
 	req.Apis = helper.RemoveDuplicates(req.Apis)
-	role, err := receiver.roleRepository.Query(ctx, store.Where("id", req.ID))
+	role, err := receiver.roleRepository.Query(ctx, store.Where("id", req.ID), store.Where("tenant_id", jwt.TenantFromContext(ctx)), store.Preload(model.PreloadApis), store.Preload(model.PreloadDenyApis), store.Preload(model.PreloadParents))
 	if err != nil {
 		return err
 	}
+	entry.Before = roleSnapshot(role, role.Apis)
+
+	parents := role.Parents
+	if req.ParentRoles != nil {
+		if parents, err = receiver.listParentRoles(ctx, role.TenantID, role, helper.RemoveDuplicates(req.ParentRoles)); err != nil {
+			return err
+		}
+	}
+	// 接口和父角色的关联通过 ReplaceAssociation 更新, 避免 Update 时保存旧的关联
+	denyApis := role.DenyApis
+	role.Apis = nil
+	role.DenyApis = nil
+	role.Parents = nil
 
 	role.Description = req.Description
 	if len(req.Apis) > 0 {
@@ -124,20 +160,24 @@ func (receiver *roleService) UpdateRole(ctx context.Context, req *apitypes.RoleU
 		}
 	}
 
-	total, casbinRules, err := receiver.casbinStore.List(ctx, 0, 0, "", "", store.Where("v0", role.Name))
-	if err != nil {
+	denyIDs := make([]int64, 0, len(denyApis))
+	for _, api := range denyApis {
+		denyIDs = append(denyIDs, api.ID)
+	}
+	if req.DenyApis != nil {
+		denyIDs = helper.RemoveDuplicates(req.DenyApis)
+	}
+	if denyApis, err = receiver.listDenyApis(ctx, req.Apis, denyIDs); err != nil {
 		return err
 	}
 
-	for _, api := range apis {
-		rules = append(rules, &model.CasbinRule{
-			PType: helper.String("p"),
-			V0:    helper.String(role.Name),
-			V1:    helper.String(api.Path),
-			V2:    helper.String(api.Method),
-		})
+	total, casbinRules, err := receiver.casbinStore.List(ctx, 0, 0, "", "", roleRules(role.Name, role.TenantID))
+	if err != nil {
+		return err
 	}
 
+	rules = append(apiRules(role.Name, role.TenantID, apis, denyApis), parentRules(role.Name, role.TenantID, parents)...)
+
 	if err := receiver.txManager.Transaction(ctx, func(ctx context.Context) error {
 		if err := receiver.roleRepository.Update(ctx, role); err != nil {
 			return err
@@ -153,19 +193,36 @@ func (receiver *roleService) UpdateRole(ctx context.Context, req *apitypes.RoleU
 		if err := receiver.roleRepository.ReplaceAssociation(ctx, role, model.PreloadApis, apis); err != nil {
 			return err
 		}
-		return nil
+		if req.DenyApis != nil {
+			if err := receiver.roleRepository.ReplaceAssociation(ctx, role, model.PreloadDenyApis, denyApis); err != nil {
+				return err
+			}
+		}
+		if req.ParentRoles == nil {
+			return nil
+		}
+		if len(parents) == 0 {
+			return receiver.roleRepository.ClearAssociation(ctx, role, model.PreloadParents)
+		}
+		return receiver.roleRepository.ReplaceAssociation(ctx, role, model.PreloadParents, parents)
 	}); err != nil {
 		return err
 	}
+	role.DenyApis = denyApis
+	role.Parents = parents
+	entry.After = roleSnapshot(role, apis)
 
-	return receiver.casbinManager.LoadPolicy()
+	return receiver.casbinManager.UpdatePolicies(casbinRules, rules)
 }
 
-func (receiver *roleService) DeleteRole(ctx context.Context, req *apitypes.IDRequest) error {
-	role, err := receiver.roleRepository.Query(ctx, store.Where("id", req.ID), store.Preload(model.PreloadUsers))
+func (receiver *roleService) DeleteRole(ctx context.Context, req *apitypes.IDRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionRoleDelete, TargetType: audit.TargetRole, TargetID: req.ID}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+	role, err := receiver.roleRepository.Query(ctx, store.Where("id", req.ID), store.Where("tenant_id", jwt.TenantFromContext(ctx)), store.Preload(model.PreloadUsers), store.Preload(model.PreloadApis), store.Preload(model.PreloadDenyApis), store.Preload(model.PreloadParents))
 	if err != nil {
 		return err
 	}
+	entry.Before = roleSnapshot(role, role.Apis)
 
 	if len(role.Users) > 0 {
 		unameArry := make([]string, 0, len(role.Users))
@@ -176,7 +233,19 @@ func (receiver *roleService) DeleteRole(ctx context.Context, req *apitypes.IDReq
 		return fmt.Errorf("the role is being used by the users %s", unames)
 	}
 
-	_, casbinRules, err := receiver.casbinStore.List(ctx, 0, 0, "", "", store.Where("v0", role.Name))
+	_, children, err := receiver.casbinStore.List(ctx, 0, 0, "", "", childRules(role.TenantID, role.Name))
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		names := make([]string, 0, len(children))
+		for _, rule := range children {
+			names = append(names, *rule.V0)
+		}
+		return fmt.Errorf("the role is inherited by the roles %s", strings.Join(names, ","))
+	}
+
+	total, casbinRules, err := receiver.casbinStore.List(ctx, 0, 0, "", "", roleRules(role.Name, role.TenantID))
 	if err != nil {
 		return err
 	}
@@ -188,19 +257,27 @@ func (receiver *roleService) DeleteRole(ctx context.Context, req *apitypes.IDReq
 		if err := receiver.roleRepository.ClearAssociation(ctx, role, model.PreloadApis); err != nil {
 			return err
 		}
-		if err := receiver.casbinStore.DeleteBatch(ctx, casbinRules); err != nil {
+		if err := receiver.roleRepository.ClearAssociation(ctx, role, model.PreloadDenyApis); err != nil {
+			return err
+		}
+		if err := receiver.roleRepository.ClearAssociation(ctx, role, model.PreloadParents); err != nil {
 			return err
 		}
+		if total > 0 {
+			if err := receiver.casbinStore.DeleteBatch(ctx, casbinRules); err != nil {
+				return err
+			}
+		}
 		return nil
 	}); err != nil {
 		return err
 	}
 
-	return receiver.casbinManager.LoadPolicy()
+	return receiver.casbinManager.UpdatePolicies(casbinRules, nil)
 }
 
 func (receiver *roleService) QueryRole(ctx context.Context, req *apitypes.IDRequest) (*model.Role, error) {
-	return receiver.roleRepository.Query(ctx, store.Where("id", req.ID), store.Preload(model.PreloadApis))
+	return receiver.roleRepository.Query(ctx, store.Where("id", req.ID), store.Where("tenant_id", jwt.TenantFromContext(ctx)), store.Preload(model.PreloadApis), store.Preload(model.PreloadDenyApis), store.Preload(model.PreloadParents))
 }
 
 func (receiver *roleService) ListRole(ctx context.Context, req *apitypes.RoleListRequest) (*apitypes.RoleListResponse, error) {
@@ -218,7 +295,7 @@ func (receiver *roleService) ListRole(ctx context.Context, req *apitypes.RoleLis
 		oder = req.Direction
 	}
 
-	total, objs, err := receiver.roleRepository.List(ctx, req.Page, req.PageSize, colum, oder, where)
+	total, objs, err := receiver.roleRepository.List(ctx, req.Page, req.PageSize, colum, oder, store.Where("tenant_id", jwt.TenantFromContext(ctx)), where)
 	if err != nil {
 		return nil, err
 	}
@@ -234,3 +311,206 @@ func (receiver *roleService) ListRole(ctx context.Context, req *apitypes.RoleLis
 	}
 	return res, nil
 }
+
+// EffectiveApis 角色直接拥有和继承的全部接口, 以及每个接口最终的效果
+func (receiver *roleService) EffectiveApis(ctx context.Context, req *apitypes.IDRequest) (*apitypes.RoleEffectiveApisResponse, error) {
+	roles, _, err := receiver.inheritedRoles(ctx, jwt.TenantFromContext(ctx), []int64{req.ID}, store.Preload(model.PreloadApis), store.Preload(model.PreloadDenyApis))
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	res := &apitypes.RoleEffectiveApisResponse{
+		Roles: make([]string, 0, len(roles)),
+		Apis:  []*apitypes.RoleEffectiveApi{},
+	}
+	apis := make(map[int64]*apitypes.RoleEffectiveApi)
+	add := func(role *model.Role, api *model.Api, effect string) {
+		effective, ok := apis[api.ID]
+		if !ok {
+			effective = &apitypes.RoleEffectiveApi{Api: api, Effect: model.EffectAllow, GrantedBy: []string{}, DeniedBy: []string{}}
+			apis[api.ID] = effective
+			res.Apis = append(res.Apis, effective)
+		}
+		if effect == model.EffectDeny {
+			effective.Effect = model.EffectDeny
+			effective.DeniedBy = append(effective.DeniedBy, role.Name)
+			return
+		}
+		effective.GrantedBy = append(effective.GrantedBy, role.Name)
+	}
+	for _, role := range roles {
+		res.Roles = append(res.Roles, role.Name)
+		for _, api := range role.Apis {
+			add(role, api, api.PolicyEffect())
+		}
+		for _, api := range role.DenyApis {
+			add(role, api, model.EffectDeny)
+		}
+	}
+	return res, nil
+}
+
+// listDenyApis 查询角色禁止访问的接口, 同一个接口不能同时通过 apis 和 denyApis 分配
+func (receiver *roleService) listDenyApis(ctx context.Context, apis, ids []int64) ([]*model.Api, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	for _, id := range ids {
+		if slices.Contains(apis, id) {
+			return nil, fmt.Errorf("api %d cannot be both allowed and denied", id)
+		}
+	}
+	total, denyApis, err := receiver.apiRepository.List(ctx, 0, 0, "", "", store.In("id", ids))
+	if err != nil {
+		return nil, err
+	}
+	if err := helper.ValidateRoleApis(ids, total, denyApis); err != nil {
+		return nil, err
+	}
+	return denyApis, nil
+}
+
+// listParentRoles 查询要继承的同一租户的角色, 检查角色是否存在、是否形成环以及继承层数
+// role 为修改的角色, 创建角色时为 nil; 继承层数包括角色已有的子角色的层数
+func (receiver *roleService) listParentRoles(ctx context.Context, tenantID int64, role *model.Role, ids []int64) ([]*model.Role, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	total, parents, err := receiver.roleRepository.List(ctx, 0, 0, "", "", store.Where("tenant_id", tenantID), store.In("id", ids))
+	if err != nil {
+		return nil, err
+	}
+	if int(total) != len(ids) {
+		notFound := make([]int64, 0, len(ids))
+		for _, id := range ids {
+			if !slices.ContainsFunc(parents, func(role *model.Role) bool { return role.ID == id }) {
+				notFound = append(notFound, id)
+			}
+		}
+		return nil, fmt.Errorf("parent roles not found: %v", notFound)
+	}
+
+	ancestors, depth, err := receiver.inheritedRoles(ctx, tenantID, ids)
+	if err != nil {
+		return nil, err
+	}
+	var descendants int
+	if role != nil {
+		for _, ancestor := range ancestors {
+			if ancestor.ID == role.ID {
+				return nil, fmt.Errorf("role cannot inherit from itself or its descendants, cycle through role %s", ancestor.Name)
+			}
+		}
+		if descendants, err = receiver.descendantDepth(ctx, tenantID, role.Name); err != nil {
+			return nil, err
+		}
+	}
+	if descendants+depth+1 > maxRoleDepth {
+		return nil, fmt.Errorf("role inheritance cannot exceed %d levels", maxRoleDepth)
+	}
+	return parents, nil
+}
+
+// descendantDepth 直接或间接继承该角色的子角色的层数, 没有子角色时为 0
+func (receiver *roleService) descendantDepth(ctx context.Context, tenantID int64, name string) (int, error) {
+	var depth int
+	seen := map[string]bool{name: true}
+	names := []string{name}
+	for len(names) > 0 {
+		_, children, err := receiver.casbinStore.List(ctx, 0, 0, "", "", childRules(tenantID, names...))
+		if err != nil {
+			return 0, err
+		}
+		names = nil
+		for _, rule := range children {
+			if child := *rule.V0; !seen[child] {
+				seen[child] = true
+				names = append(names, child)
+			}
+		}
+		if len(names) > 0 {
+			depth++
+		}
+	}
+	return depth, nil
+}
+
+// inheritedRoles 从 ids 开始逐层查询父角色, 返回这些角色及其直接或间接继承的角色和层数
+func (receiver *roleService) inheritedRoles(ctx context.Context, tenantID int64, ids []int64, opts ...store.Option) (roles []*model.Role, depth int, err error) {
+	seen := make(map[int64]bool)
+	for len(ids) > 0 {
+		_, level, err := receiver.roleRepository.List(ctx, 0, 0, "", "", append(opts, store.Where("tenant_id", tenantID), store.In("id", ids), store.Preload(model.PreloadParents))...)
+		if err != nil {
+			return nil, 0, err
+		}
+		ids = nil
+		for _, role := range level {
+			if seen[role.ID] {
+				continue
+			}
+			seen[role.ID] = true
+			roles = append(roles, role)
+			for _, parent := range role.Parents {
+				if !seen[parent.ID] {
+					ids = append(ids, parent.ID)
+				}
+			}
+		}
+		depth++
+	}
+	return roles, depth, nil
+}
+
+// apiRules 角色接口权限对应的 p 规则 (角色, 租户, 路径, 方法, 效果), denyApis 的效果为 deny
+func apiRules(name string, tenantID int64, apis, denyApis []*model.Api) []*model.CasbinRule {
+	rules := make([]*model.CasbinRule, 0, len(apis)+len(denyApis))
+	rule := func(api *model.Api, effect string) *model.CasbinRule {
+		return &model.CasbinRule{
+			PType: helper.String("p"),
+			V0:    helper.String(name),
+			V1:    helper.String(model.TenantDomain(tenantID)),
+			V2:    helper.String(api.Path),
+			V3:    helper.String(api.Method),
+			V4:    helper.String(effect),
+		}
+	}
+	for _, api := range apis {
+		rules = append(rules, rule(api, api.PolicyEffect()))
+	}
+	for _, api := range denyApis {
+		rules = append(rules, rule(api, model.EffectDeny))
+	}
+	return rules
+}
+
+// parentRules 角色继承对应的 g 规则 (子角色, 父角色, 租户)
+func parentRules(name string, tenantID int64, parents []*model.Role) []*model.CasbinRule {
+	rules := make([]*model.CasbinRule, 0, len(parents))
+	for _, parent := range parents {
+		rules = append(rules, &model.CasbinRule{
+			PType: helper.String("g"),
+			V0:    helper.String(name),
+			V1:    helper.String(parent.Name),
+			V2:    helper.String(model.TenantDomain(tenantID)),
+		})
+	}
+	return rules
+}
+
+// roleRules 查询角色在租户中的 p 规则和继承父角色的 g 规则
+func roleRules(name string, tenantID int64) store.Option {
+	dom := model.TenantDomain(tenantID)
+	return store.Scopes(func(db *gorm.DB) *gorm.DB {
+		return db.Where("v0 = ? AND ((ptype = 'p' AND v1 = ?) OR (ptype = 'g' AND v2 = ?))", name, dom, dom)
+	})
+}
+
+// childRules 查询租户中继承这些角色的子角色的 g 规则
+func childRules(tenantID int64, names ...string) store.Option {
+	return store.Scopes(func(db *gorm.DB) *gorm.DB {
+		return db.Where("ptype = 'g' AND v1 IN ? AND v2 = ?", names, model.TenantDomain(tenantID))
+	})
+}
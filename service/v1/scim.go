@@ -0,0 +1,817 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/base/log"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/audit"
+	"github.com/yiran15/api-server/pkg/casbin"
+	"github.com/yiran15/api-server/pkg/scim"
+	"github.com/yiran15/api-server/store"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ScimServicer SCIM 2.0 用户和组的同步, 组对应本地角色
+type ScimServicer interface {
+	ListUsers(ctx context.Context, req *scim.ListRequest) (*scim.ListResponse, error)
+	GetUser(ctx context.Context, id string) (*scim.User, error)
+	CreateUser(ctx context.Context, req *scim.User) (*scim.User, error)
+	ReplaceUser(ctx context.Context, id string, req *scim.User) (*scim.User, error)
+	PatchUser(ctx context.Context, id string, req *scim.PatchRequest) (*scim.User, error)
+	DeleteUser(ctx context.Context, id string) error
+	ListGroups(ctx context.Context, req *scim.ListRequest) (*scim.ListResponse, error)
+	GetGroup(ctx context.Context, id string) (*scim.Group, error)
+	CreateGroup(ctx context.Context, req *scim.Group) (*scim.Group, error)
+	ReplaceGroup(ctx context.Context, id string, req *scim.Group) (*scim.Group, error)
+	PatchGroup(ctx context.Context, id string, req *scim.PatchRequest) (*scim.Group, error)
+	DeleteGroup(ctx context.Context, id string) error
+}
+
+type scimService struct {
+	userStore     store.UserStorer
+	roleStore     store.RoleStorer
+	identityStore store.UserIdentityStorer
+	casbinStore   store.CasbinStorer
+	casbinManager casbin.CasbinManager
+	cacheStore    store.CacheStorer
+	tx            store.TxManagerInterface
+	users         UserServicer
+	roles         RoleServicer
+	audit         audit.Recorder
+}
+
+func NewScimService(userStore store.UserStorer, roleStore store.RoleStorer, identityStore store.UserIdentityStorer, casbinStore store.CasbinStorer, casbinManager casbin.CasbinManager, cacheStore store.CacheStorer, tx store.TxManagerInterface, users UserServicer, roles RoleServicer, audit audit.Recorder) ScimServicer {
+	return &scimService{
+		userStore:     userStore,
+		roleStore:     roleStore,
+		identityStore: identityStore,
+		casbinStore:   casbinStore,
+		casbinManager: casbinManager,
+		cacheStore:    cacheStore,
+		tx:            tx,
+		users:         users,
+		roles:         roles,
+		audit:         audit,
+	}
+}
+
+// scimExternalID 用户 externalId 保存在 provider 为 scim 的外部身份中
+const scimExternalID = "(SELECT subject FROM user_identities WHERE user_identities.user_id = users.id AND user_identities.provider = '" + scim.Provider + "' LIMIT 1)"
+
+// scimUserColumns 用户过滤器支持的属性
+var scimUserColumns = scim.Columns{
+	"id":                 {Name: "users.id", Value: scimIDValue},
+	"externalid":         {Name: scimExternalID},
+	"username":           {Name: "users.name"},
+	"displayname":        {Name: "users.nick_name"},
+	"name.formatted":     {Name: "users.nick_name"},
+	"emails":             {Name: "users.email"},
+	"emails.value":       {Name: "users.email"},
+	"phonenumbers":       {Name: "users.mobile"},
+	"phonenumbers.value": {Name: "users.mobile"},
+	"active":             {SQL: scimActiveSQL},
+	strings.ToLower(scim.SchemaEnterpriseUser) + ".department": {Name: "users.department"},
+	"meta.created":      {Name: "users.created_at", Value: scimTimeValue},
+	"meta.lastmodified": {Name: "users.updated_at", Value: scimTimeValue},
+}
+
+// scimGroupColumns 组过滤器支持的属性
+var scimGroupColumns = scim.Columns{
+	"id":                {Name: "roles.id", Value: scimIDValue},
+	"displayname":       {Name: "roles.name"},
+	"members":           {SQL: scimMemberSQL},
+	"members.value":     {SQL: scimMemberSQL},
+	"meta.created":      {Name: "roles.created_at", Value: scimTimeValue},
+	"meta.lastmodified": {Name: "roles.updated_at", Value: scimTimeValue},
+}
+
+func scimIDValue(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case float64:
+		return int64(v), nil
+	}
+	return nil, fmt.Errorf("invalid id %v", v)
+}
+
+func scimTimeValue(v any) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid time %v", v)
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// scimActiveSQL 只有正常状态的用户是 active, 禁用和未激活的用户都不是
+func scimActiveSQL(op string, v any) (string, []any, error) {
+	if op == scim.OpPr {
+		return "users.status IS NOT NULL", nil, nil
+	}
+	active, ok := v.(bool)
+	if !ok || (op != scim.OpEq && op != scim.OpNe) {
+		return "", nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidFilter, "active only supports eq and ne with a boolean")
+	}
+	if active == (op == scim.OpEq) {
+		return "users.status = ?", []any{model.UserStatusActive}, nil
+	}
+	return "users.status <> ?", []any{model.UserStatusActive}, nil
+}
+
+func scimMemberSQL(op string, v any) (string, []any, error) {
+	if op == scim.OpPr {
+		return "roles.id IN (SELECT role_id FROM user_roles)", nil, nil
+	}
+	if op != scim.OpEq {
+		return "", nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidFilter, "members only supports eq and pr")
+	}
+	id, err := scimIDValue(v)
+	if err != nil {
+		return "", nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidFilter, "%v", err)
+	}
+	return "roles.id IN (SELECT role_id FROM user_roles WHERE user_id = ?)", []any{id}, nil
+}
+
+func (receiver *scimService) ListUsers(ctx context.Context, req *scim.ListRequest) (*scim.ListResponse, error) {
+	opts, err := scimFilter(req.Filter, scimUserColumns)
+	if err != nil {
+		return nil, err
+	}
+	startIndex, count := req.Page()
+	page, pageSize, skip := scimPage(startIndex, count)
+	opts = append(opts, store.Preload(model.PreloadRoles))
+	total, users, err := receiver.userStore.List(ctx, page, pageSize, "id", "asc", opts...)
+	if err != nil {
+		return nil, err
+	}
+	users = users[min(skip, len(users)):]
+
+	externalIDs, err := receiver.externalIDs(ctx, users...)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]*scim.User, 0, len(users))
+	for _, user := range users {
+		resources = append(resources, scimUser(user, externalIDs[user.ID]))
+	}
+	return scimList(total, startIndex, resources, len(resources)), nil
+}
+
+func (receiver *scimService) GetUser(ctx context.Context, id string) (*scim.User, error) {
+	user, err := receiver.queryUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return receiver.toScimUser(ctx, user)
+}
+
+func (receiver *scimService) CreateUser(ctx context.Context, req *scim.User) (res *scim.User, err error) {
+	entry := &audit.Entry{Action: audit.ActionUserCreate, TargetType: audit.TargetUser}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+
+	// 同步的用户没有本地密码, 只能通过 SSO 或 LDAP 登录
+	status := model.UserStatusActive
+	user := &model.User{Status: &status}
+	if err := applyScimUser(req, user); err != nil {
+		return nil, err
+	}
+	if err := receiver.checkUser(ctx, user, req.ExternalID); err != nil {
+		return nil, err
+	}
+	if err := receiver.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := receiver.userStore.Create(ctx, user); err != nil {
+			return err
+		}
+		return receiver.saveExternalID(ctx, user.ID, req.ExternalID)
+	}); err != nil {
+		return nil, err
+	}
+	entry.TargetID = user.ID
+	entry.After = userSnapshot(user)
+	log.WithRequestID(ctx).Info("scim create user", zap.Int64("userID", user.ID), zap.String("userName", user.Name))
+	return scimUser(user, req.ExternalID), nil
+}
+
+func (receiver *scimService) ReplaceUser(ctx context.Context, id string, req *scim.User) (*scim.User, error) {
+	user, err := receiver.queryUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := receiver.updateUser(ctx, user, req); err != nil {
+		return nil, err
+	}
+	return receiver.toScimUser(ctx, user)
+}
+
+func (receiver *scimService) PatchUser(ctx context.Context, id string, req *scim.PatchRequest) (*scim.User, error) {
+	user, err := receiver.queryUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	current, err := receiver.toScimUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	patched := &scim.User{}
+	if err := scimPatch(current, req, patched); err != nil {
+		return nil, err
+	}
+	if err := receiver.updateUser(ctx, user, patched); err != nil {
+		return nil, err
+	}
+	return receiver.toScimUser(ctx, user)
+}
+
+// DeleteUser 删除用户, 同时吊销用户的 token 和会话
+func (receiver *scimService) DeleteUser(ctx context.Context, id string) error {
+	user, err := receiver.queryUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	return receiver.users.DeleteUser(ctx, &apitypes.IDRequest{ID: user.ID})
+}
+
+func (receiver *scimService) ListGroups(ctx context.Context, req *scim.ListRequest) (*scim.ListResponse, error) {
+	opts, err := scimFilter(req.Filter, scimGroupColumns)
+	if err != nil {
+		return nil, err
+	}
+	startIndex, count := req.Page()
+	page, pageSize, skip := scimPage(startIndex, count)
+	if !req.Excluded("members") {
+		opts = append(opts, store.Preload(model.PreloadUsers))
+	}
+	total, roles, err := receiver.roleStore.List(ctx, page, pageSize, "id", "asc", append(opts, store.Where("tenant_id", model.DefaultTenantID))...)
+	if err != nil {
+		return nil, err
+	}
+	roles = roles[min(skip, len(roles)):]
+
+	resources := make([]*scim.Group, 0, len(roles))
+	for _, role := range roles {
+		resources = append(resources, scimGroup(role))
+	}
+	return scimList(total, startIndex, resources, len(resources)), nil
+}
+
+func (receiver *scimService) GetGroup(ctx context.Context, id string) (*scim.Group, error) {
+	role, err := receiver.queryRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return scimGroup(role), nil
+}
+
+// CreateGroup 创建没有接口权限的角色, 权限由管理员在本地分配
+func (receiver *scimService) CreateGroup(ctx context.Context, req *scim.Group) (*scim.Group, error) {
+	name, err := scimGroupName(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := receiver.checkRoleName(ctx, name, 0); err != nil {
+		return nil, err
+	}
+	if err := receiver.roles.CreateRole(ctx, &apitypes.RoleCreateRequest{Name: name}); err != nil {
+		return nil, err
+	}
+	role, err := receiver.roleStore.Query(ctx, store.Where("tenant_id", model.DefaultTenantID), store.Where("name", name))
+	if err != nil {
+		return nil, err
+	}
+	if err := receiver.setMembers(ctx, role, nil, req.Members); err != nil {
+		return nil, err
+	}
+	return receiver.GetGroup(ctx, strconv.FormatInt(role.ID, 10))
+}
+
+func (receiver *scimService) ReplaceGroup(ctx context.Context, id string, req *scim.Group) (*scim.Group, error) {
+	role, err := receiver.queryRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := receiver.updateGroup(ctx, role, req); err != nil {
+		return nil, err
+	}
+	return receiver.GetGroup(ctx, id)
+}
+
+func (receiver *scimService) PatchGroup(ctx context.Context, id string, req *scim.PatchRequest) (*scim.Group, error) {
+	role, err := receiver.queryRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	patched := &scim.Group{}
+	if err := scimPatch(scimGroup(role), req, patched); err != nil {
+		return nil, err
+	}
+	if err := receiver.updateGroup(ctx, role, patched); err != nil {
+		return nil, err
+	}
+	return receiver.GetGroup(ctx, id)
+}
+
+// DeleteGroup 先移除成员的角色, 再删除角色和角色的权限
+func (receiver *scimService) DeleteGroup(ctx context.Context, id string) error {
+	role, err := receiver.queryRole(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := receiver.setMembers(ctx, role, role.Users, nil); err != nil {
+		return err
+	}
+	return receiver.roles.DeleteRole(ctx, &apitypes.IDRequest{ID: role.ID})
+}
+
+func (receiver *scimService) queryUser(ctx context.Context, id string) (*model.User, error) {
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, scim.NewError(http.StatusNotFound, "", "user %s not found", id)
+	}
+	user, err := receiver.userStore.Query(ctx, store.Where("id", userID), store.Preload(model.PreloadRoles))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, scim.NewError(http.StatusNotFound, "", "user %s not found", id)
+	}
+	return user, err
+}
+
+func (receiver *scimService) queryRole(ctx context.Context, id string) (*model.Role, error) {
+	roleID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, scim.NewError(http.StatusNotFound, "", "group %s not found", id)
+	}
+	role, err := receiver.roleStore.Query(ctx, store.Where("id", roleID), store.Where("tenant_id", model.DefaultTenantID), store.Preload(model.PreloadUsers), store.Preload(model.PreloadApis))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, scim.NewError(http.StatusNotFound, "", "group %s not found", id)
+	}
+	return role, err
+}
+
+func (receiver *scimService) toScimUser(ctx context.Context, user *model.User) (*scim.User, error) {
+	externalIDs, err := receiver.externalIDs(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return scimUser(user, externalIDs[user.ID]), nil
+}
+
+// externalIDs 批量查询用户的 externalId
+func (receiver *scimService) externalIDs(ctx context.Context, users ...*model.User) (map[int64]string, error) {
+	ids := make([]int64, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	externalIDs := make(map[int64]string, len(users))
+	if len(ids) == 0 {
+		return externalIDs, nil
+	}
+	_, identities, err := receiver.identityStore.List(ctx, 0, 0, "", "", store.Where("provider", scim.Provider), store.In("user_id", ids))
+	if err != nil {
+		return nil, err
+	}
+	for _, identity := range identities {
+		externalIDs[identity.UserID] = identity.Subject
+	}
+	return externalIDs, nil
+}
+
+// saveExternalID 保存用户的 externalId, 为空时保持不变
+func (receiver *scimService) saveExternalID(ctx context.Context, userID int64, externalID string) error {
+	if externalID == "" {
+		return nil
+	}
+	identity, err := receiver.identityStore.Query(ctx, store.Where("provider", scim.Provider), store.Where("user_id", userID))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if identity != nil {
+		if identity.Subject == externalID {
+			return nil
+		}
+		identity.Subject = externalID
+		return receiver.identityStore.Update(ctx, identity)
+	}
+	return receiver.identityStore.Create(ctx, &model.UserIdentity{
+		UserID:   userID,
+		Provider: scim.Provider,
+		Subject:  externalID,
+		LinkedAt: time.Now(),
+	})
+}
+
+// checkUser userName、邮箱和 externalId 不能与其他用户重复
+func (receiver *scimService) checkUser(ctx context.Context, user *model.User, externalID string) error {
+	others := store.Scopes(func(db *gorm.DB) *gorm.DB {
+		return db.Where("id <> ?", user.ID)
+	})
+	conflicts := []struct {
+		column, attr, value string
+	}{
+		{"name", "userName", user.Name},
+		{"email", "emails", user.Email},
+	}
+	for _, c := range conflicts {
+		if c.value == "" {
+			continue
+		}
+		total, _, err := receiver.userStore.List(ctx, 1, 1, "", "", store.Where(c.column, c.value), others)
+		if err != nil {
+			return err
+		}
+		if total > 0 {
+			return scim.NewError(http.StatusConflict, scim.ErrUniqueness, "%s %s already exists", c.attr, c.value)
+		}
+	}
+	if externalID == "" {
+		return nil
+	}
+	identity, err := receiver.identityStore.Query(ctx, store.Where("provider", scim.Provider), store.Where("subject", externalID))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if identity != nil && identity.UserID != user.ID {
+		return scim.NewError(http.StatusConflict, scim.ErrUniqueness, "externalId %s already exists", externalID)
+	}
+	return nil
+}
+
+// updateUser 使用 SCIM 用户替换本地用户的属性, 用户被停用时吊销其 token 和会话
+func (receiver *scimService) updateUser(ctx context.Context, user *model.User, req *scim.User) (err error) {
+	entry := &audit.Entry{Action: audit.ActionUserUpdate, TargetType: audit.TargetUser, TargetID: user.ID, Before: userSnapshot(user)}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+
+	wasActive := user.Status != nil && *user.Status == model.UserStatusActive
+	if err := applyScimUser(req, user); err != nil {
+		return err
+	}
+	if err := receiver.checkUser(ctx, user, req.ExternalID); err != nil {
+		return err
+	}
+	// 角色不通过用户资源修改, 更新时不保存关联, 清空的属性也需要更新
+	updated := *user
+	updated.Roles = nil
+	if err := receiver.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := receiver.userStore.Update(ctx, &updated, store.Select("name", "nick_name", "email", "mobile", "avatar", "department", "status")); err != nil {
+			return err
+		}
+		return receiver.saveExternalID(ctx, user.ID, req.ExternalID)
+	}); err != nil {
+		return err
+	}
+	entry.After = userSnapshot(user)
+
+	if wasActive && (user.Status == nil || *user.Status != model.UserStatusActive) {
+		log.WithRequestID(ctx).Info("scim deactivate user", zap.Int64("userID", user.ID), zap.String("userName", user.Name))
+		return receiver.users.RevokeUserTokens(ctx, &apitypes.IDRequest{ID: user.ID})
+	}
+	return nil
+}
+
+// updateGroup 使用 SCIM 组替换角色的名称和成员
+func (receiver *scimService) updateGroup(ctx context.Context, role *model.Role, req *scim.Group) error {
+	name, err := scimGroupName(req)
+	if err != nil {
+		return err
+	}
+	if name != role.Name {
+		if err := receiver.renameRole(ctx, role, name); err != nil {
+			return err
+		}
+	}
+	return receiver.setMembers(ctx, role, role.Users, req.Members)
+}
+
+// renameRole 修改角色名称, 同时修改 casbin 策略中的角色名称并清除成员的角色缓存
+func (receiver *scimService) renameRole(ctx context.Context, role *model.Role, name string) (err error) {
+	entry := &audit.Entry{Action: audit.ActionRoleUpdate, TargetType: audit.TargetRole, TargetID: role.ID, Before: roleSnapshot(role, role.Apis)}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+
+	if err := receiver.checkRoleName(ctx, name, role.ID); err != nil {
+		return err
+	}
+	_, rules, err := receiver.casbinStore.List(ctx, 0, 0, "", "", roleRules(role.Name, role.TenantID))
+	if err != nil {
+		return err
+	}
+	// 继承该角色的子角色的 g 规则
+	_, children, err := receiver.casbinStore.List(ctx, 0, 0, "", "", childRules(role.TenantID, role.Name))
+	if err != nil {
+		return err
+	}
+	removed := make([]*model.CasbinRule, 0, len(rules)+len(children))
+	for _, rule := range append(rules, children...) {
+		old := *rule
+		removed = append(removed, &old)
+	}
+	if err := receiver.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := receiver.roleStore.Update(ctx, &model.Role{ID: role.ID, Name: name}); err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			rule.V0 = helper.String(name)
+			if err := receiver.casbinStore.Update(ctx, rule); err != nil {
+				return err
+			}
+		}
+		for _, rule := range children {
+			rule.V1 = helper.String(name)
+			if err := receiver.casbinStore.Update(ctx, rule); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	role.Name = name
+	entry.After = roleSnapshot(role, role.Apis)
+
+	if err := receiver.casbinManager.UpdatePolicies(removed, append(rules, children...)); err != nil {
+		return err
+	}
+	// 鉴权时从数据库重新加载角色
+	for _, user := range role.Users {
+		if err := receiver.cacheStore.DelKey(ctx, store.RoleType, user.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (receiver *scimService) checkRoleName(ctx context.Context, name string, id int64) error {
+	role, err := receiver.roleStore.Query(ctx, store.Where("tenant_id", model.DefaultTenantID), store.Where("name", name))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if role != nil && role.ID != id {
+		return scim.NewError(http.StatusConflict, scim.ErrUniqueness, "group %s already exists", name)
+	}
+	return nil
+}
+
+// setMembers 将角色的成员修改为 members, 通过 UserServicer 修改用户的角色, 记录审计日志并刷新角色缓存
+func (receiver *scimService) setMembers(ctx context.Context, role *model.Role, current []*model.User, members []scim.Reference) error {
+	want := make([]int64, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.ParseInt(member.Value, 10, 64)
+		if err != nil {
+			return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "invalid member %s", member.Value)
+		}
+		want = append(want, id)
+	}
+	want = helper.RemoveDuplicates(want)
+	have := make([]int64, 0, len(current))
+	for _, user := range current {
+		have = append(have, user.ID)
+	}
+
+	for _, id := range want {
+		if slices.Contains(have, id) {
+			continue
+		}
+		if err := receiver.updateMemberRoles(ctx, id, func(roles []int64) []int64 { return append(roles, role.ID) }); err != nil {
+			return err
+		}
+	}
+	for _, id := range have {
+		if slices.Contains(want, id) {
+			continue
+		}
+		if err := receiver.updateMemberRoles(ctx, id, func(roles []int64) []int64 {
+			return slices.DeleteFunc(roles, func(r int64) bool { return r == role.ID })
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (receiver *scimService) updateMemberRoles(ctx context.Context, userID int64, change func(roles []int64) []int64) error {
+	user, err := receiver.userStore.Query(ctx, store.Where("id", userID), store.Preload(model.PreloadRoles))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "member %d not found", userID)
+		}
+		return err
+	}
+	// 只修改默认租户的角色, 其他租户的角色由 UpdateUserByAdmin 保留
+	roles := make([]int64, 0, len(user.Roles)+1)
+	for _, role := range user.Roles {
+		if role.TenantID == model.DefaultTenantID {
+			roles = append(roles, role.ID)
+		}
+	}
+	roles = change(roles)
+	return receiver.users.UpdateUserByAdmin(ctx, &apitypes.UserUpdateAdminRequest{ID: user.ID, RolesID: &roles})
+}
+
+func scimFilter(filter string, columns scim.Columns) ([]store.Option, error) {
+	if strings.TrimSpace(filter) == "" {
+		return nil, nil
+	}
+	expr, err := scim.ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	sql, args, err := columns.SQL(expr)
+	if err != nil {
+		return nil, err
+	}
+	return []store.Option{store.Scopes(func(db *gorm.DB) *gorm.DB {
+		return db.Where(sql, args...)
+	})}, nil
+}
+
+// scimPage 将 startIndex 和 count 转换为分页参数, startIndex 不在页首时多查询前面的记录再跳过
+func scimPage(startIndex, count int) (page, pageSize, skip int) {
+	if count == 0 {
+		return 1, 1, 1
+	}
+	if (startIndex-1)%count == 0 {
+		return (startIndex-1)/count + 1, count, 0
+	}
+	return 1, startIndex - 1 + count, startIndex - 1
+}
+
+func scimList(total int64, startIndex int, resources any, n int) *scim.ListResponse {
+	return &scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: n,
+		Resources:    resources,
+	}
+}
+
+// scimPatch 在资源的 JSON 上执行 PATCH 操作, 结果写入 out
+func scimPatch(current any, req *scim.PatchRequest, out any) error {
+	data, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	resource := make(map[string]any)
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return err
+	}
+	if err := scim.ApplyPatch(resource, req.Operations); err != nil {
+		return err
+	}
+	if data, err = json.Marshal(resource); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		var scimErr *scim.Error
+		if errors.As(err, &scimErr) {
+			return scimErr
+		}
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "%v", err)
+	}
+	return nil
+}
+
+func scimUser(user *model.User, externalID string) *scim.User {
+	id := strconv.FormatInt(user.ID, 10)
+	active := scim.Bool(user.Status != nil && *user.Status == model.UserStatusActive)
+	res := &scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		ID:          id,
+		ExternalID:  externalID,
+		UserName:    user.Name,
+		DisplayName: user.NickName,
+		Active:      &active,
+		Meta: &scim.Meta{
+			ResourceType: scim.ResourceUser,
+			Created:      &user.CreatedAt,
+			LastModified: &user.UpdatedAt,
+			Location:     scim.BasePath + "/Users/" + id,
+		},
+	}
+	if user.NickName != "" {
+		res.Name = &scim.Name{Formatted: user.NickName}
+	}
+	if user.Email != "" {
+		res.Emails = []scim.MultiValue{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	if user.Mobile != "" {
+		res.PhoneNumbers = []scim.MultiValue{{Value: user.Mobile, Type: "mobile", Primary: true}}
+	}
+	if user.Avatar != "" {
+		res.Photos = []scim.MultiValue{{Value: user.Avatar, Type: "photo", Primary: true}}
+	}
+	if user.Department != "" {
+		res.Schemas = append(res.Schemas, scim.SchemaEnterpriseUser)
+		res.Enterprise = &scim.EnterpriseUser{Department: user.Department}
+	}
+	for _, role := range user.Roles {
+		if role.TenantID != model.DefaultTenantID {
+			continue
+		}
+		roleID := strconv.FormatInt(role.ID, 10)
+		res.Groups = append(res.Groups, scim.Reference{Value: roleID, Ref: scim.BasePath + "/Groups/" + roleID, Display: role.Name})
+	}
+	return res
+}
+
+func scimGroup(role *model.Role) *scim.Group {
+	id := strconv.FormatInt(role.ID, 10)
+	res := &scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          id,
+		DisplayName: role.Name,
+		Meta: &scim.Meta{
+			ResourceType: scim.ResourceGroup,
+			Created:      &role.CreatedAt,
+			LastModified: &role.UpdatedAt,
+			Location:     scim.BasePath + "/Groups/" + id,
+		},
+	}
+	for _, user := range role.Users {
+		userID := strconv.FormatInt(user.ID, 10)
+		res.Members = append(res.Members, scim.Reference{Value: userID, Ref: scim.BasePath + "/Users/" + userID, Display: user.Name})
+	}
+	return res
+}
+
+func scimGroupName(req *scim.Group) (string, error) {
+	name := strings.TrimSpace(req.DisplayName)
+	if name == "" {
+		return "", scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "displayName is required")
+	}
+	return name, nil
+}
+
+// applyScimUser 将 SCIM 用户的属性写入本地用户, 没有 active 时保持原状态
+func applyScimUser(req *scim.User, user *model.User) error {
+	user.Name = strings.TrimSpace(req.UserName)
+	if user.Name == "" {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "userName is required")
+	}
+	user.NickName = req.DisplayName
+	if user.NickName == "" && req.Name != nil {
+		user.NickName = req.Name.Formatted
+		if user.NickName == "" {
+			user.NickName = req.Name.FamilyName + req.Name.GivenName
+		}
+	}
+	user.Email = primaryValue(req.Emails)
+	// 多数 IdP 使用邮箱作为 userName
+	if user.Email == "" && strings.Contains(user.Name, "@") {
+		user.Email = user.Name
+	}
+	user.Mobile = primaryValue(req.PhoneNumbers)
+	user.Avatar = primaryValue(req.Photos)
+	user.Department = ""
+	if req.Enterprise != nil {
+		user.Department = req.Enterprise.Department
+	}
+	if req.Active != nil {
+		status := model.UserStatusDisabled
+		if *req.Active {
+			status = model.UserStatusActive
+		}
+		user.Status = &status
+	}
+
+	// 与表字段长度一致
+	for _, field := range []struct {
+		attr, value string
+		max         int
+	}{
+		{"userName", user.Name, 50},
+		{"displayName", user.NickName, 50},
+		{"department", user.Department, 50},
+		{"emails", user.Email, 100},
+		{"phoneNumbers", user.Mobile, 20},
+		{"photos", user.Avatar, 1024},
+	} {
+		if utf8.RuneCountInString(field.value) > field.max {
+			return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "%s exceeds %d characters", field.attr, field.max)
+		}
+	}
+	return nil
+}
+
+// primaryValue 返回 primary 的值, 没有时返回第一个值
+func primaryValue(values []scim.MultiValue) string {
+	for _, v := range values {
+		if v.Primary {
+			return v.Value
+		}
+	}
+	if len(values) > 0 {
+		return values[0].Value
+	}
+	return ""
+}
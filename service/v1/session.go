@@ -0,0 +1,98 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/log"
+	"github.com/yiran15/api-server/pkg/audit"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/pkg/session"
+	"github.com/yiran15/api-server/store"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type SessionServicer interface {
+	ListSessions(ctx context.Context) ([]*session.Session, error)
+	RevokeSession(ctx context.Context, req *apitypes.SessionIDRequest) error
+	ListUserSessions(ctx context.Context, req *apitypes.IDRequest) ([]*session.Session, error)
+	RevokeUserSession(ctx context.Context, req *apitypes.UserSessionRequest) error
+}
+
+type sessionService struct {
+	sessions  session.Manager
+	userStore store.UserStorer
+	jwt       jwt.JwtInterface
+	audit     audit.Recorder
+}
+
+func NewSessionService(sessions session.Manager, userStore store.UserStorer, jwt jwt.JwtInterface, audit audit.Recorder) SessionServicer {
+	return &sessionService{
+		sessions:  sessions,
+		userStore: userStore,
+		jwt:       jwt,
+		audit:     audit,
+	}
+}
+
+// ListSessions 当前用户的登录会话, 标记发起请求的会话
+func (receiver *sessionService) ListSessions(ctx context.Context) ([]*session.Session, error) {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := receiver.sessions.List(ctx, mc.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		s.Current = s.ID == mc.SessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession 注销当前用户的指定会话, 会话的 token 立即失效
+func (receiver *sessionService) RevokeSession(ctx context.Context, req *apitypes.SessionIDRequest) error {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return err
+	}
+	return receiver.revoke(ctx, mc.UserID, req.ID)
+}
+
+// ListUserSessions 管理员查询用户的登录会话
+func (receiver *sessionService) ListUserSessions(ctx context.Context, req *apitypes.IDRequest) ([]*session.Session, error) {
+	user, err := receiver.userStore.Query(ctx, store.Where("id", req.ID), tenantUsers(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return receiver.sessions.List(ctx, user.ID)
+}
+
+// RevokeUserSession 管理员注销用户的指定会话
+func (receiver *sessionService) RevokeUserSession(ctx context.Context, req *apitypes.UserSessionRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionUserRevokeSession, TargetType: audit.TargetUser, TargetID: req.ID, Before: map[string]any{"session": req.SessionID}}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+	user, err := receiver.userStore.Query(ctx, store.Where("id", req.ID), tenantUsers(ctx))
+	if err != nil {
+		return err
+	}
+	return receiver.revoke(ctx, user.ID, req.SessionID)
+}
+
+func (receiver *sessionService) revoke(ctx context.Context, userID int64, sessionID string) error {
+	s, err := receiver.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if s == nil || s.UserID != userID {
+		return fmt.Errorf("session %s: %w", sessionID, gorm.ErrRecordNotFound)
+	}
+	if err := receiver.sessions.Delete(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	log.WithRequestID(ctx).Info("revoke session", zap.Int64("userID", userID), zap.String("sid", sessionID))
+	return nil
+}
@@ -0,0 +1,270 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/base/log"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/audit"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/store"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type TenantServicer interface {
+	CreateTenant(ctx context.Context, req *apitypes.TenantCreateRequest) error
+	UpdateTenant(ctx context.Context, req *apitypes.TenantUpdateRequest) error
+	ListTenant(ctx context.Context, req *apitypes.TenantListRequest) (*apitypes.TenantListResponse, error)
+	AddUsers(ctx context.Context, req *apitypes.TenantAddUsersRequest) error
+	RemoveUser(ctx context.Context, req *apitypes.TenantRemoveUserRequest) error
+}
+
+// tenantAdminRole 创建租户时复制默认租户中的同名角色, 并分配给创建者
+const tenantAdminRole = "admin"
+
+var errDefaultTenantMembers = errors.New("all users belong to the default tenant")
+
+type tenantService struct {
+	tenantStore store.TenantStorer
+	userStore   store.UserStorer
+	roleStore   store.RoleStorer
+	cacheStore  store.CacheStorer
+	tx          store.TxManagerInterface
+	jwt         jwt.JwtInterface
+	roles       RoleServicer
+	users       UserServicer
+	audit       audit.Recorder
+}
+
+func NewTenantService(tenantStore store.TenantStorer, userStore store.UserStorer, roleStore store.RoleStorer, cacheStore store.CacheStorer, tx store.TxManagerInterface, jwt jwt.JwtInterface, roles RoleServicer, users UserServicer, audit audit.Recorder) TenantServicer {
+	return &tenantService{
+		tenantStore: tenantStore,
+		userStore:   userStore,
+		roleStore:   roleStore,
+		cacheStore:  cacheStore,
+		tx:          tx,
+		jwt:         jwt,
+		roles:       roles,
+		users:       users,
+		audit:       audit,
+	}
+}
+
+// CreateTenant 创建租户, 创建者加入租户并获得租户的管理员角色
+func (receiver *tenantService) CreateTenant(ctx context.Context, req *apitypes.TenantCreateRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionTenantCreate, TargetType: audit.TargetTenant}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+
+	if err = receiver.checkName(ctx, req.Name, 0); err != nil {
+		return err
+	}
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	tenant := &model.Tenant{Name: req.Name, Description: req.Description}
+	if err = receiver.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := receiver.tenantStore.Create(ctx, tenant); err != nil {
+			return err
+		}
+		if mc.UserID == 0 {
+			return nil
+		}
+		return receiver.tenantStore.AppendAssociation(ctx, tenant, model.PreloadUsers, &model.User{ID: mc.UserID})
+	}); err != nil {
+		return err
+	}
+	entry.TargetID = tenant.ID
+	entry.After = tenantSnapshot(tenant)
+
+	if mc.UserID == 0 {
+		return nil
+	}
+	return receiver.grantAdmin(ctx, tenant, mc.UserID)
+}
+
+// grantAdmin 在租户中创建与默认租户 admin 角色权限相同的角色并分配给用户, 默认租户没有 admin 角色时跳过
+func (receiver *tenantService) grantAdmin(ctx context.Context, tenant *model.Tenant, userID int64) error {
+	admin, err := receiver.roleStore.Query(ctx, store.Where("tenant_id", model.DefaultTenantID), store.Where("name", tenantAdminRole), store.Preload(model.PreloadApis))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.WithRequestID(ctx).Warn("default tenant has no admin role, skip granting tenant admin", zap.Int64("tenantID", tenant.ID))
+			return nil
+		}
+		return err
+	}
+	apis := make([]int64, 0, len(admin.Apis))
+	for _, api := range admin.Apis {
+		apis = append(apis, api.ID)
+	}
+
+	tenantCtx := jwt.ContextWithTenant(ctx, tenant.ID)
+	if err := receiver.roles.CreateRole(tenantCtx, &apitypes.RoleCreateRequest{Name: tenantAdminRole, Description: admin.Description, Apis: apis}); err != nil {
+		return err
+	}
+	role, err := receiver.roleStore.Query(ctx, store.Where("tenant_id", tenant.ID), store.Where("name", tenantAdminRole))
+	if err != nil {
+		return err
+	}
+	return receiver.users.UpdateUserByAdmin(tenantCtx, &apitypes.UserUpdateAdminRequest{ID: userID, RolesID: &[]int64{role.ID}})
+}
+
+func (receiver *tenantService) UpdateTenant(ctx context.Context, req *apitypes.TenantUpdateRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionTenantUpdate, TargetType: audit.TargetTenant, TargetID: req.ID}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+	if err = checkManageTenant(ctx, req.ID); err != nil {
+		return err
+	}
+
+	tenant, err := receiver.tenantStore.Query(ctx, store.Where("id", req.ID))
+	if err != nil {
+		return err
+	}
+	entry.Before = tenantSnapshot(tenant)
+
+	if req.Name != "" && req.Name != tenant.Name {
+		if err = receiver.checkName(ctx, req.Name, tenant.ID); err != nil {
+			return err
+		}
+		tenant.Name = req.Name
+	}
+	tenant.Description = req.Description
+	if err = receiver.tenantStore.Update(ctx, tenant); err != nil {
+		return err
+	}
+	entry.After = tenantSnapshot(tenant)
+	return nil
+}
+
+func (receiver *tenantService) ListTenant(ctx context.Context, req *apitypes.TenantListRequest) (*apitypes.TenantListResponse, error) {
+	var (
+		likeOpt store.Option
+		filed   = "id"
+		oder    = "asc"
+	)
+	if req.Name != "" {
+		likeOpt = store.Like("name", req.Name+"%")
+	}
+	if req.Sort != "" && req.Direction != "" {
+		filed = req.Sort
+		oder = req.Direction
+	}
+	// 在其他租户中只能看到当前租户
+	var tenantOpt store.Option
+	if tenantID := jwt.TenantFromContext(ctx); tenantID != model.DefaultTenantID {
+		tenantOpt = store.Where("id", tenantID)
+	}
+
+	total, objs, err := receiver.tenantStore.List(ctx, req.Page, req.PageSize, filed, oder, likeOpt, tenantOpt)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.TenantListResponse{
+		ListResponse: &apitypes.ListResponse{
+			Pagination: req.Pagination,
+			Total:      total,
+		},
+		List: objs,
+	}, nil
+}
+
+// AddUsers 将用户加入租户, 加入后才能在该租户中分配角色或切换到该租户
+func (receiver *tenantService) AddUsers(ctx context.Context, req *apitypes.TenantAddUsersRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionTenantAddUsers, TargetType: audit.TargetTenant, TargetID: req.ID}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+	if req.ID == model.DefaultTenantID {
+		return errDefaultTenantMembers
+	}
+	if err = checkManageTenant(ctx, req.ID); err != nil {
+		return err
+	}
+	req.UsersID = helper.RemoveDuplicates(req.UsersID)
+
+	tenant, err := receiver.tenantStore.Query(ctx, store.Where("id", req.ID))
+	if err != nil {
+		return err
+	}
+	_, users, err := receiver.userStore.List(ctx, 0, 0, "", "", store.In("id", req.UsersID))
+	if err != nil {
+		return err
+	}
+	if len(users) != len(req.UsersID) {
+		return fmt.Errorf("some users in %v not found", req.UsersID)
+	}
+	if err = receiver.tenantStore.AppendAssociation(ctx, tenant, model.PreloadUsers, users); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+	entry.After = map[string]any{"users": names}
+	return nil
+}
+
+// RemoveUser 将用户移出租户, 同时删除用户在该租户中的角色
+func (receiver *tenantService) RemoveUser(ctx context.Context, req *apitypes.TenantRemoveUserRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionTenantRemoveUser, TargetType: audit.TargetTenant, TargetID: req.ID}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+	if req.ID == model.DefaultTenantID {
+		return errDefaultTenantMembers
+	}
+	if err = checkManageTenant(ctx, req.ID); err != nil {
+		return err
+	}
+
+	tenant, err := receiver.tenantStore.Query(ctx, store.Where("id", req.ID))
+	if err != nil {
+		return err
+	}
+	user, err := receiver.userStore.Query(ctx, store.Where("id", req.UserID), store.Preload(model.PreloadRoles))
+	if err != nil {
+		return err
+	}
+	roles, _ := splitTenantRoles(user.Roles, tenant.ID)
+	entry.Before = map[string]any{"user": user.Name, "roles": rolesSnapshot(roles)["roles"]}
+
+	if err = receiver.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := receiver.tenantStore.DeleteAssociation(ctx, tenant, model.PreloadUsers, user); err != nil {
+			return err
+		}
+		if len(roles) == 0 {
+			return nil
+		}
+		return receiver.userStore.DeleteAssociation(ctx, user, model.PreloadRoles, roles)
+	}); err != nil {
+		return err
+	}
+	// 下次鉴权时从数据库重新加载角色
+	return receiver.cacheStore.DelKey(ctx, store.RoleType, user.ID)
+}
+
+// checkManageTenant 在其他租户中只能管理当前租户, 默认租户可以管理所有租户
+func checkManageTenant(ctx context.Context, tenantID int64) error {
+	if current := jwt.TenantFromContext(ctx); current != model.DefaultTenantID && current != tenantID {
+		return fmt.Errorf("%w: can not manage tenant %d in tenant %d", constant.ErrNoPermission, tenantID, current)
+	}
+	return nil
+}
+
+func (receiver *tenantService) checkName(ctx context.Context, name string, id int64) error {
+	tenant, err := receiver.tenantStore.Query(ctx, store.Where("name", name))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if tenant.ID != id {
+		return fmt.Errorf("tenant %s already exists", name)
+	}
+	return nil
+}
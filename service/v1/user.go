@@ -4,21 +4,29 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/conf"
 	"github.com/yiran15/api-server/base/constant"
 	"github.com/yiran15/api-server/base/helper"
 	"github.com/yiran15/api-server/base/log"
 	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/audit"
 	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/pkg/ldap"
 	localcache "github.com/yiran15/api-server/pkg/local_cache"
+	"github.com/yiran15/api-server/pkg/loginevent"
+	"github.com/yiran15/api-server/pkg/loginguard"
 	"github.com/yiran15/api-server/pkg/oauth"
+	"github.com/yiran15/api-server/pkg/password"
+	"github.com/yiran15/api-server/pkg/session"
 	"github.com/yiran15/api-server/store"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -29,14 +37,18 @@ type UserServicer interface {
 
 type OAuthServicer interface {
 	OAuth2Provider(ctx context.Context) ([]string, error)
-	OAuth2Login(provider, state string) (string, error)
+	OAuth2Login(ctx context.Context, provider string, params *oauth.AuthParams) (string, error)
 	OAuth2Callback(ctx context.Context, req *apitypes.OAuthLoginRequest) (*apitypes.UserLoginResponse, error)
 	OAuth2Activate(ctx context.Context, req *apitypes.OAuthActivateRequest) (*apitypes.UserLoginResponse, error)
 }
 
 type GeneralUserServicer interface {
 	Login(ctx context.Context, req *apitypes.UserLoginRequest) (*apitypes.UserLoginResponse, error)
+	LoginMfa(ctx context.Context, req *apitypes.UserLoginMfaRequest) (*apitypes.UserLoginResponse, error)
+	RefreshToken(ctx context.Context, req *apitypes.UserRefreshTokenRequest) (*apitypes.UserLoginResponse, error)
 	Logout(ctx context.Context) error
+	RevokeUserTokens(ctx context.Context, req *apitypes.IDRequest) error
+	UnlockUser(ctx context.Context, req *apitypes.IDRequest) error
 	Info(ctx context.Context) (*model.User, error)
 	CreateUser(ctx context.Context, req *apitypes.UserCreateRequest) error
 	UpdateUserByAdmin(ctx context.Context, req *apitypes.UserUpdateAdminRequest) error
@@ -44,86 +56,407 @@ type GeneralUserServicer interface {
 	DeleteUser(ctx context.Context, req *apitypes.IDRequest) error
 	QueryUser(ctx context.Context, req *apitypes.IDRequest) (*model.User, error)
 	ListUser(ctx context.Context, pagination *apitypes.UserListRequest) (*apitypes.UserListResponse, error)
+	ListTenants(ctx context.Context) ([]*model.Tenant, error)
 }
 
 type UserService struct {
 	userStore       store.UserStorer
 	roleStore       store.RoleStorer
+	tenantStore     store.TenantStorer
 	cacheStore      store.CacheStorer
 	tx              store.TxManagerInterface
 	jwt             jwt.JwtInterface
+	revoker         jwt.Revoker
+	tokenStore      store.PersonalAccessTokenStorer
+	mfa             MfaServicer
+	loginGuard      loginguard.Guard
+	passwordChecker password.Checker
+	hasher          password.Hasher
+	sessions        session.Manager
+	audit           audit.Recorder
+	loginEvents     loginevent.Recorder
 	oauth           *oauth.OAuth2
 	feishuUserStore store.FeiShuUserStorer
+	identityStore   store.UserIdentityStorer
+	ldap            *ldap.LDAP
 	localCache      localcache.Cacher
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-func NewUserService(userStore store.UserStorer, roleStore store.RoleStorer, cacheStore store.CacheStorer, tx store.TxManagerInterface, jwt jwt.JwtInterface, feishuOauth *oauth.OAuth2, feishuUserStore store.FeiShuUserStorer, localCache localcache.Cacher) UserServicer {
+func NewUserService(userStore store.UserStorer, roleStore store.RoleStorer, tenantStore store.TenantStorer, cacheStore store.CacheStorer, tx store.TxManagerInterface, jwt jwt.JwtInterface, revoker jwt.Revoker, tokenStore store.PersonalAccessTokenStorer, mfa MfaServicer, loginGuard loginguard.Guard, passwordChecker password.Checker, hasher password.Hasher, sessions session.Manager, audit audit.Recorder, loginEvents loginevent.Recorder, feishuOauth *oauth.OAuth2, feishuUserStore store.FeiShuUserStorer, identityStore store.UserIdentityStorer, ldapAuth *ldap.LDAP, localCache localcache.Cacher) UserServicer {
 	return &UserService{
 		userStore:       userStore,
 		roleStore:       roleStore,
+		tenantStore:     tenantStore,
 		cacheStore:      cacheStore,
 		tx:              tx,
 		jwt:             jwt,
+		revoker:         revoker,
+		tokenStore:      tokenStore,
+		mfa:             mfa,
+		loginGuard:      loginGuard,
+		passwordChecker: passwordChecker,
+		hasher:          hasher,
+		sessions:        sessions,
+		audit:           audit,
+		loginEvents:     loginEvents,
 		oauth:           feishuOauth,
 		feishuUserStore: feishuUserStore,
+		identityStore:   identityStore,
+		ldap:            ldapAuth,
 		localCache:      localCache,
 	}
 }
 
-func (receiver *UserService) Login(ctx context.Context, req *apitypes.UserLoginRequest) (*apitypes.UserLoginResponse, error) {
+func (receiver *UserService) Login(ctx context.Context, req *apitypes.UserLoginRequest) (res *apitypes.UserLoginResponse, err error) {
+	event := &model.LoginEvent{Event: loginevent.EventLogin, Provider: jwt.AuthMethodPassword, Account: req.Email}
+	defer func() { receiver.recordLoginEvent(ctx, event, res, err) }()
+
+	ip := helper.GetClientIPFromContext(ctx)
+	if err := receiver.loginGuard.Check(ctx, req.Email, ip); err != nil {
+		return nil, err
+	}
+
+	user, err := receiver.authenticate(ctx, req, event)
+	if err != nil {
+		// 只有账号或密码错误时计入失败, 登录后端或数据库不可用时不能锁定账号
+		if errors.Is(err, constant.ErrLoginFailed) {
+			receiver.loginFailed(ctx, req.Email, ip)
+		}
+		return nil, err
+	}
+
+	res, err = receiver.completeLogin(ctx, user, jwt.AuthMethodPassword, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	// 需要两步验证时, 验证通过后才清除失败记录
+	if !res.MfaRequired {
+		receiver.loginSucceed(ctx, req.Email)
+	}
+
+	receiver.cacheRoles(ctx, user)
+	return res, nil
+}
+
+// authenticate 按顺序使用登录后端校验账号和密码, 第一个校验通过的后端生效
+// 所有后端都未通过时返回 constant.ErrLoginFailed; 后端不可用时继续尝试下一个后端, 都未通过时返回不可用的错误
+func (receiver *UserService) authenticate(ctx context.Context, req *apitypes.UserLoginRequest, event *model.LoginEvent) (*model.User, error) {
+	backends := []string{ldap.BackendLocal}
+	if receiver.ldap != nil {
+		backends = receiver.ldap.Backends()
+	}
+
+	var unavailable error
+	for _, backend := range backends {
+		var (
+			user *model.User
+			err  error
+		)
+		if backend == ldap.BackendLDAP {
+			user, err = receiver.ldapLogin(ctx, req, event)
+		} else {
+			user, err = receiver.localLogin(ctx, req, event)
+		}
+		if err == nil {
+			event.Reason = ""
+			return user, nil
+		}
+		if !errors.Is(err, constant.ErrLoginFailed) {
+			log.WithRequestID(ctx).Error("login backend unavailable", zap.String("backend", backend), zap.String("email", req.Email), zap.Error(err))
+			unavailable = err
+		}
+	}
+	if unavailable != nil {
+		return nil, unavailable
+	}
+	return nil, constant.ErrLoginFailed
+}
+
+// localLogin 使用本地密码登录, 只有设置了密码的已激活用户可以登录
+func (receiver *UserService) localLogin(ctx context.Context, req *apitypes.UserLoginRequest, event *model.LoginEvent) (*model.User, error) {
+	ip := helper.GetClientIPFromContext(ctx)
 	user, err := receiver.userStore.Query(ctx, store.Where("email", req.Email), store.Where("status", 1), store.Preload(model.PreloadRoles))
 	if err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, err
 		}
-		log.WithRequestID(ctx).Error("login failed, user not found", zap.String("email", req.Email))
+		// 用户不存在时同样校验一次密码, 避免通过响应时间探测用户是否存在
+		receiver.checkPasswordHash(req.Password, receiver.dummyPasswordHash())
+		log.WithRequestID(ctx).Error("login failed, user not found", zap.String("email", req.Email), zap.String("ip", ip))
+		event.Reason = loginevent.ReasonUserNotFound
 		return nil, constant.ErrLoginFailed
 	}
 
+	event.UserID = user.ID
 	if !receiver.checkPasswordHash(req.Password, user.Password) {
-		log.WithRequestID(ctx).Error("login failed, invalid password", zap.String("email", req.Email))
+		log.WithRequestID(ctx).Error("login failed, invalid password", zap.String("email", req.Email), zap.String("ip", ip))
+		event.Reason = loginevent.ReasonInvalidPassword
 		return nil, constant.ErrLoginFailed
 	}
-	token, err := receiver.jwt.GenerateToken(user.ID, user.Name)
+	receiver.rehashPassword(ctx, user, req.Password)
+	return user, nil
+}
+
+// ldapLogin 使用 LDAP 登录, 目录中的用户首次登录时关联同邮箱的本地用户或创建已激活的用户
+func (receiver *UserService) ldapLogin(ctx context.Context, req *apitypes.UserLoginRequest, event *model.LoginEvent) (*model.User, error) {
+	external, err := receiver.ldap.Authenticate(ctx, req.Email, req.Password)
+	switch {
+	case errors.Is(err, ldap.ErrUserNotFound):
+		log.WithRequestID(ctx).Error("ldap login failed, user not found", zap.String("account", req.Email))
+		event.Reason = loginevent.ReasonUserNotFound
+		return nil, constant.ErrLoginFailed
+	case errors.Is(err, ldap.ErrInvalidCredentials):
+		log.WithRequestID(ctx).Error("ldap login failed, invalid password", zap.String("account", req.Email))
+		event.Reason = loginevent.ReasonInvalidPassword
+		return nil, constant.ErrLoginFailed
+	case err != nil:
+		return nil, err
+	}
+
+	user, err := receiver.externalUser(ctx, external, receiver.ldap.Mapper(), model.UserStatusActive)
 	if err != nil {
 		return nil, err
 	}
+	event.UserID = user.ID
+	if user.Status == nil || *user.Status != model.UserStatusActive {
+		event.Reason = loginevent.ReasonInactive
+		return nil, constant.ErrLoginFailed
+	}
+	event.Provider = ldap.Provider
+	return user, nil
+}
+
+// loginFailed 记录登录失败, 达到阈值时锁定账号
+func (receiver *UserService) loginFailed(ctx context.Context, email, ip string) {
+	if err := receiver.loginGuard.Fail(ctx, email, ip); err != nil {
+		log.WithRequestID(ctx).Error("login record failure error", zap.String("email", email), zap.Error(err))
+	}
+}
+
+// loginSucceed 登录完成后清除账号的失败记录
+func (receiver *UserService) loginSucceed(ctx context.Context, email string) {
+	if err := receiver.loginGuard.Succeed(ctx, email); err != nil {
+		log.WithRequestID(ctx).Error("login clear failure records error", zap.String("email", email), zap.Error(err))
+	}
+}
 
-	if len(user.Roles) == 0 {
-		if err := receiver.cacheStore.SetSet(ctx, store.RoleType, user.ID, []any{constant.EmptyRoleSentinel}, nil); err != nil {
-			log.WithRequestID(ctx).Error("login set empty role cache error", zap.Int64("userID", user.ID), zap.Error(err))
+// LoginMfa 使用登录返回的 mfa token 和两步验证码完成登录
+// 每个 mfa token 只能成功使用一次, 并限制尝试次数; 验证码错误同样计入账号的登录失败, 防止重新获取 mfa token 暴力破解验证码
+func (receiver *UserService) LoginMfa(ctx context.Context, req *apitypes.UserLoginMfaRequest) (res *apitypes.UserLoginResponse, err error) {
+	claims, err := receiver.jwt.ParseMfaToken(req.MfaToken)
+	if err != nil {
+		log.WithRequestID(ctx).Error("mfa token parse failed", zap.Error(err))
+		return nil, constant.ErrMfaFailed
+	}
+	// 无法解析的 mfa token 不对应任何用户, 不记录事件
+	event := &model.LoginEvent{Event: loginevent.EventLoginMfa, Provider: jwt.AuthMethodOTP, UserID: claims.UserID, Account: claims.UserName}
+	defer func() { receiver.recordLoginEvent(ctx, event, res, err) }()
+
+	ip := helper.GetClientIPFromContext(ctx)
+	if claims.Account != "" {
+		if err := receiver.loginGuard.Check(ctx, claims.Account, ip); err != nil {
+			return nil, err
 		}
-	} else {
-		roleNames := make([]any, 0, len(user.Roles))
-		for _, role := range user.Roles {
-			roleNames = append(roleNames, role.Name)
+	}
+
+	revoked, err := receiver.revoker.IsRevoked(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		log.WithRequestID(ctx).Error("mfa token has been used", zap.Int64("userID", claims.UserID), zap.String("jti", claims.ID))
+		return nil, constant.ErrMfaFailed
+	}
+
+	attempts, err := receiver.cacheStore.Incr(ctx, store.MfaAttemptType, claims.ID, time.Until(claims.ExpiresAt.Time)+time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if attempts > int64(conf.GetMfaMaxAttempts()) {
+		log.WithRequestID(ctx).Error("mfa too many attempts", zap.Int64("userID", claims.UserID), zap.Int64("attempts", attempts))
+		return nil, constant.ErrMfaFailed
+	}
+
+	// 校验前先原子地使用 mfa token, 并发请求中只有一个能继续校验; 校验失败后释放, 在尝试次数内可以重试
+	consumed, err := receiver.revoker.ConsumeToken(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		log.WithRequestID(ctx).Error("mfa token has been used", zap.Int64("userID", claims.UserID), zap.String("jti", claims.ID))
+		return nil, constant.ErrMfaFailed
+	}
+	defer func() {
+		if err != nil {
+			if releaseErr := receiver.revoker.ReleaseToken(ctx, claims); releaseErr != nil {
+				log.WithRequestID(ctx).Error("release mfa token failed", zap.Int64("userID", claims.UserID), zap.Error(releaseErr))
+			}
 		}
-		if err := receiver.cacheStore.SetSet(ctx, store.RoleType, user.ID, roleNames, nil); err != nil {
-			log.WithRequestID(ctx).Error("login set role cache error", zap.Int64("userID", user.ID), zap.Any("roles", roleNames), zap.Error(err))
+	}()
+
+	user, err := receiver.userStore.Query(ctx, store.Where("id", claims.UserID), store.Where("status", model.UserStatusActive), store.Preload(model.PreloadRoles))
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
 		}
+		log.WithRequestID(ctx).Error("mfa login failed, user not found or disabled", zap.Int64("userID", claims.UserID))
+		return nil, constant.ErrMfaFailed
 	}
 
-	return &apitypes.UserLoginResponse{
-		User:  user,
-		Token: token,
-	}, nil
+	ok, err := receiver.mfa.VerifyMfa(ctx, user.ID, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		log.WithRequestID(ctx).Error("mfa login failed, invalid code", zap.Int64("userID", user.ID))
+		event.Reason = loginevent.ReasonInvalidCode
+		if claims.Account != "" {
+			receiver.loginFailed(ctx, claims.Account, ip)
+		}
+		return nil, constant.ErrMfaFailed
+	}
+
+	if claims.Account != "" {
+		receiver.loginSucceed(ctx, claims.Account)
+	}
+	return receiver.issueToken(ctx, user, jwt.WithAuthMethods(append(claims.AuthMethods, jwt.AuthMethodOTP)...), jwt.WithTenant(claims.TenantID))
+}
+
+// RefreshToken 使用 refresh token 换取新的 access token 和 refresh token, 可以同时切换到用户所属的其他租户
+// refresh token 只能使用一次, 同一会话中旧的 refresh token 被再次使用时视为泄露, 整个会话失效
+func (receiver *UserService) RefreshToken(ctx context.Context, req *apitypes.UserRefreshTokenRequest) (*apitypes.UserLoginResponse, error) {
+	claims, err := receiver.jwt.ParseRefreshToken(req.RefreshToken)
+	if err != nil {
+		log.WithRequestID(ctx).Error("refresh token parse failed", zap.Error(err))
+		return nil, constant.ErrRefreshTokenInvalid
+	}
+
+	revoked, err := receiver.revoker.IsRevoked(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		log.WithRequestID(ctx).Error("refresh token has been revoked", zap.Int64("userID", claims.UserID), zap.String("jti", claims.ID))
+		return nil, constant.ErrRefreshTokenInvalid
+	}
+
+	user, err := receiver.userStore.Query(ctx, store.Where("id", claims.UserID), store.Where("status", model.UserStatusActive))
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		log.WithRequestID(ctx).Error("refresh token failed, user not found or disabled", zap.Int64("userID", claims.UserID))
+		return nil, constant.ErrRefreshTokenInvalid
+	}
+
+	tenantID := claims.TenantID
+	if req.TenantID != nil {
+		if err := receiver.checkTenantMember(ctx, *req.TenantID, user.ID); err != nil {
+			return nil, err
+		}
+		tenantID = *req.TenantID
+	}
+
+	pair, err := receiver.jwt.GenerateTokenPair(user.ID, user.Name, jwt.WithSessionID(claims.SessionID), jwt.WithAuthMethods(claims.AuthMethods...), jwt.WithTenant(tenantID))
+	if err != nil {
+		return nil, err
+	}
+
+	swapped, err := receiver.cacheStore.CompareAndSwap(ctx, store.RefreshTokenType, claims.SessionID, claims.ID, pair.RefreshClaims.ID, store.GetExpireTime(time.Until(pair.RefreshClaims.ExpiresAt.Time)))
+	if err != nil {
+		return nil, err
+	}
+	if !swapped {
+		// refresh token 已被使用过或会话已失效, 删除会话使同一会话签发的 token 全部失效
+		log.WithRequestID(ctx).Warn("refresh token reuse detected, revoke session", zap.Int64("userID", claims.UserID), zap.String("sid", claims.SessionID), zap.String("jti", claims.ID))
+		if err := receiver.sessions.Delete(ctx, claims.UserID, claims.SessionID); err != nil {
+			log.WithRequestID(ctx).Error("revoke refresh session error", zap.String("sid", claims.SessionID), zap.Error(err))
+		}
+		return nil, constant.ErrRefreshTokenInvalid
+	}
+
+	// 功能上线前登录的会话没有记录, 刷新时补上
+	s, err := receiver.sessions.Get(ctx, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		s = receiver.newSession(ctx, user, pair)
+	}
+	s.JTI = pair.AccessClaims.ID
+	s.ExpiresAt = pair.RefreshClaims.ExpiresAt.Time
+	s.LastSeenAt = time.Now()
+	if err := receiver.sessions.Save(ctx, s); err != nil {
+		return nil, err
+	}
+	return receiver.newLoginResponse(user, pair), nil
 }
 
-func (receiver *UserService) Logout(ctx context.Context) error {
+// Logout 吊销当前 access token, 并使同一会话的 refresh token 失效
+func (receiver *UserService) Logout(ctx context.Context) (err error) {
 	mc, err := receiver.jwt.GetUser(ctx)
 	if err != nil {
 		return err
 	}
+	event := &model.LoginEvent{Event: loginevent.EventLogout, UserID: mc.UserID, Account: mc.UserName}
+	defer func() { receiver.loginEvents.Record(ctx, event, err) }()
+	if mc.ID != "" {
+		if err := receiver.revoker.RevokeToken(ctx, mc); err != nil {
+			return err
+		}
+	}
+	if mc.SessionID != "" {
+		if err := receiver.sessions.Delete(ctx, mc.UserID, mc.SessionID); err != nil {
+			return err
+		}
+	}
 	return receiver.cacheStore.DelKey(ctx, store.RoleType, mc.UserID)
 }
 
-func (receiver *UserService) CreateUser(ctx context.Context, req *apitypes.UserCreateRequest) error {
+// RevokeUserTokens 吊销用户所有已签发的 token 和个人访问令牌, 用户需要重新登录
+func (receiver *UserService) RevokeUserTokens(ctx context.Context, req *apitypes.IDRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionUserRevokeTokens, TargetType: audit.TargetUser, TargetID: req.ID}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+	user, err := receiver.userStore.Query(ctx, store.Where("id", req.ID), tenantUsers(ctx))
+	if err != nil {
+		return err
+	}
+	if err := receiver.revokeUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := receiver.tokenStore.Delete(ctx, &model.PersonalAccessToken{}, store.Where("user_id", user.ID)); err != nil {
+		return err
+	}
+	log.WithRequestID(ctx).Info("revoke user tokens", zap.Int64("userID", user.ID), zap.String("userName", user.Name))
+	return nil
+}
+
+// UnlockUser 解除用户因登录失败次数过多导致的锁定
+func (receiver *UserService) UnlockUser(ctx context.Context, req *apitypes.IDRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionUserUnlock, TargetType: audit.TargetUser, TargetID: req.ID}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+	user, err := receiver.userStore.Query(ctx, store.Where("id", req.ID), tenantUsers(ctx))
+	if err != nil {
+		return err
+	}
+	if err := receiver.loginGuard.Unlock(ctx, user.Email); err != nil {
+		return err
+	}
+	log.WithRequestID(ctx).Info("unlock user login", zap.Int64("userID", user.ID), zap.String("email", user.Email))
+	return nil
+}
+
+func (receiver *UserService) CreateUser(ctx context.Context, req *apitypes.UserCreateRequest) (err error) {
 	var (
 		user  *model.User
-		err   error
 		total int64
 		roles []*model.Role
 	)
+	entry := &audit.Entry{Action: audit.ActionUserCreate, TargetType: audit.TargetUser}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
 
 	if req.RolesID != nil {
 		*req.RolesID = helper.RemoveDuplicates(*req.RolesID)
@@ -139,13 +472,17 @@ func (receiver *UserService) CreateUser(ctx context.Context, req *apitypes.UserC
 		return fmt.Errorf("user %s already exists", req.Name)
 	}
 
+	if err = receiver.passwordChecker.Check(ctx, &model.User{Name: req.Name, Email: req.Email}, req.Password); err != nil {
+		return err
+	}
 	hashedPassword, err := receiver.hashPassword(req.Password)
 	if err != nil {
 		return err
 	}
 
+	tenantID := jwt.TenantFromContext(ctx)
 	if req.RolesID != nil {
-		total, roles, err = receiver.roleStore.List(ctx, 0, 0, "", "", store.In("id", *req.RolesID))
+		total, roles, err = receiver.roleStore.List(ctx, 0, 0, "", "", store.In("id", *req.RolesID), store.Where("tenant_id", tenantID))
 		if err != nil {
 			return err
 		}
@@ -163,22 +500,48 @@ func (receiver *UserService) CreateUser(ctx context.Context, req *apitypes.UserC
 		Mobile:   req.Mobile,
 	}
 
-	return receiver.tx.Transaction(ctx, func(ctx context.Context) error {
-		if err = receiver.userStore.Create(ctx, user); err != nil {
+	if err := receiver.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := receiver.userStore.Create(ctx, user); err != nil {
 			return err
 		}
+		if err := receiver.passwordChecker.Record(ctx, user.ID, user.Password); err != nil {
+			return err
+		}
+		// 在其他租户中创建的用户同时加入该租户
+		if tenantID != model.DefaultTenantID {
+			if err := receiver.tenantStore.AppendAssociation(ctx, &model.Tenant{ID: tenantID}, model.PreloadUsers, user); err != nil {
+				return err
+			}
+		}
 
 		if req.RolesID == nil {
 			return nil
 		}
 
 		return receiver.userStore.AppendAssociation(ctx, user, model.PreloadRoles, roles)
-	})
+	}); err != nil {
+		return err
+	}
+	snapshot := userSnapshot(user)
+	maps.Copy(snapshot, rolesSnapshot(roles))
+	entry.TargetID = user.ID
+	entry.After = snapshot
+	return nil
 }
 
 func (receiver *UserService) UpdateUserByAdmin(ctx context.Context, req *apitypes.UserUpdateAdminRequest) error {
-	if err := receiver.updateUser(ctx, nil, req); err != nil {
-		return err
+	// 只修改角色时不更新用户信息, 避免产生没有变更的审计日志
+	if req.UserUpdateSelfRequest != nil || req.Status != 0 {
+		if err := receiver.updateUser(ctx, nil, req); err != nil {
+			return err
+		}
+	}
+
+	// 禁用用户后立即吊销其已签发的 token
+	if req.Status == model.UserStatusDisabled {
+		if err := receiver.revokeUser(ctx, req.ID); err != nil {
+			return err
+		}
 	}
 
 	if req.RolesID == nil {
@@ -212,15 +575,32 @@ func (receiver *UserService) UpdateUserBySelf(ctx context.Context, req *apitypes
 	return receiver.updateUser(ctx, user, newReq)
 }
 
-func (receiver *UserService) DeleteUser(ctx context.Context, req *apitypes.IDRequest) error {
-	user, err := receiver.userStore.Query(ctx, store.Where("id", req.ID))
+func (receiver *UserService) DeleteUser(ctx context.Context, req *apitypes.IDRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionUserDelete, TargetType: audit.TargetUser, TargetID: req.ID}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+	user, err := receiver.userStore.Query(ctx, store.Where("id", req.ID), tenantUsers(ctx), store.Preload(model.PreloadRoles))
 	if err != nil {
 		return err
 	}
+	snapshot := userSnapshot(user)
+	maps.Copy(snapshot, rolesSnapshot(user.Roles))
+	entry.Before = snapshot
 	if err := receiver.userStore.Delete(ctx, user); err != nil {
 		return err
 	}
 
+	if err := receiver.revokeUser(ctx, user.ID); err != nil {
+		return err
+	}
+
+	if err := receiver.tokenStore.Delete(ctx, &model.PersonalAccessToken{}, store.Where("user_id", user.ID)); err != nil {
+		return err
+	}
+
+	if err := receiver.identityStore.Delete(ctx, &model.UserIdentity{}, store.Where("user_id", user.ID)); err != nil {
+		return err
+	}
+
 	feishuUser, err := receiver.feishuUserStore.Query(ctx, store.Where("user_id", req.ID))
 	if err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
@@ -237,7 +617,7 @@ func (receiver *UserService) DeleteUser(ctx context.Context, req *apitypes.IDReq
 }
 
 func (receiver *UserService) QueryUser(ctx context.Context, req *apitypes.IDRequest) (*model.User, error) {
-	return receiver.userStore.Query(ctx, store.Where("id", req.ID), store.Preload(model.PreloadRoles))
+	return receiver.userStore.Query(ctx, store.Where("id", req.ID), tenantUsers(ctx), store.Preload(model.PreloadRoles))
 }
 
 func (receiver *UserService) Info(ctx context.Context) (*model.User, error) {
@@ -279,7 +659,7 @@ func (receiver *UserService) ListUser(ctx context.Context, req *apitypes.UserLis
 		oder = req.Direction
 	}
 
-	total, objs, err := receiver.userStore.List(ctx, req.Page, req.PageSize, filed, oder, likeOpt, statusOpt)
+	total, objs, err := receiver.userStore.List(ctx, req.Page, req.PageSize, filed, oder, likeOpt, statusOpt, tenantUsers(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -296,10 +676,11 @@ func (receiver *UserService) ListUser(ctx context.Context, req *apitypes.UserLis
 	return res, nil
 }
 
-func (receiver *UserService) updateUser(ctx context.Context, user *model.User, req *apitypes.UserUpdateAdminRequest) error {
-	var err error
+func (receiver *UserService) updateUser(ctx context.Context, user *model.User, req *apitypes.UserUpdateAdminRequest) (err error) {
+	entry := &audit.Entry{Action: audit.ActionUserUpdate, TargetType: audit.TargetUser, TargetID: req.ID}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
 	if user == nil {
-		user, err = receiver.userStore.Query(ctx, store.Where("id", req.ID))
+		user, err = receiver.userStore.Query(ctx, store.Where("id", req.ID), tenantUsers(ctx))
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return fmt.Errorf("user %d not found", req.ID)
@@ -307,6 +688,17 @@ func (receiver *UserService) updateUser(ctx context.Context, user *model.User, r
 			return err
 		}
 	}
+	entry.Before = userSnapshot(user)
+	defer func() {
+		if err == nil {
+			after := userSnapshot(user)
+			// 审计日志不记录密码, 只记录密码被修改
+			if req.UserUpdateSelfRequest != nil && req.Password != "" {
+				after["passwordChanged"] = true
+			}
+			entry.After = after
+		}
+	}()
 
 	if req.UserUpdateSelfRequest != nil {
 		user.Name = req.UserUpdateSelfRequest.Name
@@ -315,6 +707,9 @@ func (receiver *UserService) updateUser(ctx context.Context, user *model.User, r
 		user.Avatar = req.UserUpdateSelfRequest.Avatar
 		user.Mobile = req.UserUpdateSelfRequest.Mobile
 		if req.Password != "" {
+			if err := receiver.passwordChecker.Check(ctx, user, req.Password); err != nil {
+				return err
+			}
 			hashedPassword, err := receiver.hashPassword(req.Password)
 			if err != nil {
 				return err
@@ -326,22 +721,37 @@ func (receiver *UserService) updateUser(ctx context.Context, user *model.User, r
 	if req.Status != 0 {
 		user.Status = &req.Status
 	}
-	return receiver.userStore.Update(ctx, user)
+	if req.UserUpdateSelfRequest == nil || req.Password == "" {
+		return receiver.userStore.Update(ctx, user)
+	}
+	return receiver.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := receiver.userStore.Update(ctx, user); err != nil {
+			return err
+		}
+		return receiver.passwordChecker.Record(ctx, user.ID, user.Password)
+	})
 }
 
-func (receiver *UserService) updateRole(ctx context.Context, req *apitypes.UserUpdateRoleRequest) error {
+func (receiver *UserService) updateRole(ctx context.Context, req *apitypes.UserUpdateRoleRequest) (err error) {
 	var (
 		total int64
-		err   error
 		roles []*model.Role
 	)
+	entry := &audit.Entry{Action: audit.ActionUserAssignRoles, TargetType: audit.TargetUser, TargetID: req.ID}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
 	req.RolesID = helper.RemoveDuplicates(req.RolesID)
 	user, err := receiver.userStore.Query(ctx, store.Where("id", req.ID), store.Preload(model.PreloadRoles))
 	if err != nil {
 		return err
 	}
+	entry.Before = rolesSnapshot(user.Roles)
 
-	total, roles, err = receiver.roleStore.List(ctx, 0, 0, "", "", store.In("id", req.RolesID))
+	// 只替换当前租户的角色, 保留用户在其他租户的角色
+	tenantID := jwt.TenantFromContext(ctx)
+	if err := receiver.checkTenantMember(ctx, tenantID, user.ID); err != nil {
+		return err
+	}
+	total, roles, err = receiver.roleStore.List(ctx, 0, 0, "", "", store.In("id", req.RolesID), store.Where("tenant_id", tenantID))
 	if err != nil {
 		return err
 	}
@@ -350,9 +760,12 @@ func (receiver *UserService) updateRole(ctx context.Context, req *apitypes.UserU
 		return err
 	}
 
+	_, others := splitTenantRoles(user.Roles, tenantID)
+	roles = append(others, roles...)
 	if err := receiver.userStore.ReplaceAssociation(ctx, user, model.PreloadRoles, roles); err != nil {
 		return err
 	}
+	entry.After = rolesSnapshot(roles)
 
 	// 如果redis缓存中存在该用户的角色，需要删除
 	cacheRoles, err := receiver.cacheStore.GetSet(ctx, store.RoleType, user.ID)
@@ -371,7 +784,7 @@ func (receiver *UserService) updateRole(ctx context.Context, req *apitypes.UserU
 
 	roleNames := make([]any, 0, len(roles))
 	for _, role := range roles {
-		roleNames = append(roleNames, role.Name)
+		roleNames = append(roleNames, role.ScopedName())
 	}
 
 	go func() {
@@ -386,192 +799,303 @@ func (receiver *UserService) updateRole(ctx context.Context, req *apitypes.UserU
 	return receiver.cacheStore.SetSet(ctx, store.RoleType, user.ID, roleNames, nil)
 }
 
-// hashPassword 对密码进行 Bcrypt 哈希
+// hashPassword 使用配置的算法对密码进行哈希
 func (receiver *UserService) hashPassword(password string) (string, error) {
-	// bcrypt.DefaultCost 是一个合理的默认值，如果需要更高的安全性可以增加
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hashedPassword), nil
+	return receiver.hasher.Hash(password)
 }
 
 // checkPasswordHash 验证明文密码是否与哈希密码匹配
 func (receiver *UserService) checkPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil // 如果没有错误，则匹配成功
+	return receiver.hasher.Verify(password, hash)
 }
 
-func (receiver *UserService) OAuth2Login(provider, state string) (string, error) {
-	return receiver.oauth.Redirect(state, provider), nil
+// rehashPassword 登录成功后, 哈希的算法或参数与当前配置不一致时使用新配置重新哈希, 失败不影响登录
+func (receiver *UserService) rehashPassword(ctx context.Context, user *model.User, password string) {
+	if !receiver.hasher.NeedsRehash(user.Password) {
+		return
+	}
+	hashedPassword, err := receiver.hashPassword(password)
+	if err != nil {
+		log.WithRequestID(ctx).Error("rehash password error", zap.Int64("userID", user.ID), zap.Error(err))
+		return
+	}
+	if err := receiver.userStore.Update(ctx, &model.User{ID: user.ID, Password: hashedPassword}); err != nil {
+		log.WithRequestID(ctx).Error("save rehashed password error", zap.Int64("userID", user.ID), zap.Error(err))
+		return
+	}
+	user.Password = hashedPassword
+	log.WithRequestID(ctx).Info("password rehashed", zap.Int64("userID", user.ID))
 }
 
-func (receiver *UserService) OAuth2Callback(ctx context.Context, req *apitypes.OAuthLoginRequest) (*apitypes.UserLoginResponse, error) {
-	var (
-		userID   int64
-		userName string
-		roles    []*model.Role
-		user     *model.User
-	)
+// dummyPasswordHash 用户不存在时用于比较的密码哈希, 使响应时间与用户存在时一致
+func (receiver *UserService) dummyPasswordHash() string {
+	receiver.dummyHashOnce.Do(func() {
+		receiver.dummyHash, _ = receiver.hasher.Hash("dummy-password")
+	})
+	return receiver.dummyHash
+}
+
+func (receiver *UserService) OAuth2Login(ctx context.Context, provider string, params *oauth.AuthParams) (string, error) {
+	return receiver.oauth.Redirect(ctx, provider, params)
+}
+
+func (receiver *UserService) OAuth2Callback(ctx context.Context, req *apitypes.OAuthLoginRequest) (res *apitypes.UserLoginResponse, err error) {
 	provider, ok := ctx.Value(constant.ProviderContextKey).(string)
 	if !ok {
 		return nil, errors.New("invalid provider")
 	}
+	event := &model.LoginEvent{Event: loginevent.EventOAuth2Callback, Provider: provider}
+	defer func() { receiver.recordLoginEvent(ctx, event, res, err) }()
 
-	oauthToken, err := receiver.oauth.Auth(ctx, req.Code, provider)
+	oauthToken, err := receiver.oauth.Auth(ctx, req.Code, provider, oauth.AuthParamsFromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	userInfo, err := receiver.oauth.UserInfo(ctx, oauthToken, provider)
+	external, err := receiver.oauth.Identity(ctx, oauthToken, provider)
+	if err != nil {
+		return nil, err
+	}
+	user, err := receiver.externalUser(ctx, external, receiver.oauth.Mapper(provider), model.UserStatusInactive)
 	if err != nil {
 		return nil, err
 	}
+	if user.Status != nil && *user.Status != model.UserStatusActive {
+		return &apitypes.UserLoginResponse{User: user, Token: ""}, nil
+	}
 
-	switch v := userInfo.(type) {
-	case *model.FeiShuUser:
-		feishuUser, err := receiver.feishuLogin(ctx, v)
-		if err != nil {
-			return nil, err
-		}
-		if feishuUser == nil || feishuUser.User == nil {
-			return nil, errors.New("feishu user not found after login")
+	event.UserID, event.Account = user.ID, user.Name
+	res, err = receiver.completeLogin(ctx, user, jwt.AuthMethodOAuth, user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	receiver.cacheRoles(ctx, user)
+	return res, nil
+}
+
+// cacheRoles 登录后缓存用户所有租户的角色, 没有角色时写入占位符
+func (receiver *UserService) cacheRoles(ctx context.Context, user *model.User) {
+	roleNames := make([]any, 0, len(user.Roles))
+	for _, r := range user.Roles {
+		if r == nil {
+			continue
 		}
-		user = feishuUser.User
-		userID = user.ID
-		userName = user.Name
-		roles = user.Roles
-		if user.Status != nil && *user.Status != model.UserStatusActive {
-			return &apitypes.UserLoginResponse{User: user, Token: ""}, nil
+		roleNames = append(roleNames, r.ScopedName())
+	}
+
+	if len(roleNames) > 0 {
+		if err := receiver.cacheStore.SetSet(ctx, store.RoleType, user.ID, roleNames, nil); err != nil {
+			log.WithRequestID(ctx).Error("login set role cache error", zap.Int64("userID", user.ID), zap.Any("roles", roleNames), zap.Error(err))
 		}
+		return
+	}
+	// set a sentinel so other parts know user has no roles
+	if err := receiver.cacheStore.SetSet(ctx, store.RoleType, user.ID, []any{constant.EmptyRoleSentinel}, nil); err != nil {
+		log.WithRequestID(ctx).Error("login set empty role cache error", zap.Int64("userID", user.ID), zap.Error(err))
+	}
+}
 
-	case *model.KeycloakUser:
-		u, err := receiver.genericLogin(ctx, v)
-		if err != nil {
+// externalUser 查找或创建外部身份对应的本地用户, 并按映射同步角色
+// 优先按 (provider, subject) 查找已关联的用户, 用户在 provider 中修改邮箱后仍能登录到同一个账号
+func (receiver *UserService) externalUser(ctx context.Context, external *oauth.Identity, mapper *oauth.Mapper, status int) (*model.User, error) {
+	var user *model.User
+	identity, err := receiver.identityStore.Query(ctx, store.Where("provider", external.Provider), store.Where("subject", external.Subject))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		if user, err = receiver.userStore.Query(ctx, store.Where("id", identity.UserID), store.Preload(model.PreloadRoles)); err != nil {
 			return nil, err
 		}
-		if u == nil {
-			return nil, errors.New("generic user not found after login")
-		}
-		user = u
-		userID = user.ID
-		userName = user.Name
-		roles = user.Roles
-		if user.Status != nil && *user.Status != model.UserStatusActive {
-			return &apitypes.UserLoginResponse{User: user, Token: ""}, nil
+	} else {
+		identity = nil
+		if user, err = receiver.externalLogin(ctx, external, mapper, status); err != nil {
+			return nil, err
 		}
-	default:
-		return nil, errors.New("unsupported oauth user type")
 	}
-
-	token, err := receiver.jwt.GenerateToken(userID, userName)
-	if err != nil {
+	if _, err := saveIdentity(ctx, receiver.identityStore, identity, &model.UserIdentity{UserID: user.ID, Provider: external.Provider, Subject: external.Subject, Profile: external.Claims}); err != nil {
 		return nil, err
 	}
 
-	roleNames := make([]any, 0, len(roles))
-	if len(roles) > 0 {
-		for _, r := range roles {
-			if r == nil {
-				continue
-			}
-			roleNames = append(roleNames, r.Name)
+	if mapper.SyncRoles() {
+		if err := receiver.syncRoles(ctx, user, mapper, external.Claims); err != nil {
+			return nil, err
 		}
 	}
+	return user, nil
+}
 
-	if len(roleNames) > 0 {
-		if err := receiver.cacheStore.SetSet(ctx, store.RoleType, userID, roleNames, nil); err != nil {
-			log.WithRequestID(ctx).Error("login set role cache error", zap.Int64("userID", userID), zap.Any("roles", roleNames), zap.Error(err))
+// externalLogin 外部身份首次登录时按邮箱关联本地用户, 不存在时按 status 创建用户
+// 只有 provider 验证过的邮箱才自动关联, 否则任何能在 IdP 中设置邮箱的人都可以登录到该邮箱的本地账号
+func (receiver *UserService) externalLogin(ctx context.Context, identity *oauth.Identity, mapper *oauth.Mapper, status int) (*model.User, error) {
+	if identity.Type == oauth.ProviderTypeFeishu {
+		user, err := receiver.legacyFeishuUser(ctx, identity.Subject)
+		if err != nil || user != nil {
+			return user, err
 		}
-	} else {
-		// set a sentinel so other parts know user has no roles
-		if err := receiver.cacheStore.SetSet(ctx, store.RoleType, userID, []any{constant.EmptyRoleSentinel}, nil); err != nil {
-			log.WithRequestID(ctx).Error("login set empty role cache error", zap.Int64("userID", userID), zap.Error(err))
+	}
+
+	data := &model.User{
+		Name:       identity.Name,
+		NickName:   identity.NickName,
+		Email:      identity.Email,
+		Avatar:     identity.Avatar,
+		Mobile:     identity.Mobile,
+		Status:     helper.Int(status),
+		Department: strings.Join(identity.Groups, ","),
+	}
+	if err := mapper.MapUser(identity.Claims, data); err != nil {
+		return nil, err
+	}
+	// 企业微信等 provider 可能不返回邮箱, 此时直接创建新用户
+	if data.Email != "" {
+		user, err := receiver.userStore.Query(ctx, store.Where("email", data.Email), store.Preload(model.PreloadRoles))
+		if err == nil {
+			if !identity.EmailVerified {
+				return nil, constant.ErrIdentityEmailConflict
+			}
+			return user, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
 		}
 	}
 
-	return &apitypes.UserLoginResponse{User: user, Token: token}, nil
+	if err := receiver.mapNewUser(ctx, data, mapper, identity.Claims, identity.Roles); err != nil {
+		return nil, err
+	}
+	if err := receiver.userStore.Create(ctx, data); err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
-func (receiver *UserService) feishuLogin(ctx context.Context, userInfo *model.FeiShuUser) (*model.FeiShuUser, error) {
-	if userInfo.UserID == "" {
-		return nil, errors.New("feishu user is empty")
+// legacyFeishuUser 查询关联外部身份之前通过飞书登录的用户, 这些用户只记录在 feishu_users 中, 不存在时返回 nil
+func (receiver *UserService) legacyFeishuUser(ctx context.Context, userID string) (*model.User, error) {
+	feishuUser, err := receiver.feishuUserStore.Query(ctx, store.Where("user_id", userID), store.Preload("User.Roles"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
 	}
+	if err != nil {
+		return nil, err
+	}
+	return feishuUser.User, nil
+}
 
-	var email string
-	if userInfo.EnterpriseEmail != "" {
-		email = userInfo.EnterpriseEmail
-	} else if userInfo.Email != "" {
-		email = userInfo.Email
+// mapNewUser 按 provider 的映射设置新用户的字段和角色
+// 配置了角色映射时按用户组分配角色, 否则使用 provider 返回的同名角色
+func (receiver *UserService) mapNewUser(ctx context.Context, user *model.User, mapper *oauth.Mapper, claims map[string]any, providerRoles []string) error {
+	if err := mapper.MapUser(claims, user); err != nil {
+		return err
+	}
+	roleNames, ok := mapper.MapRoles(claims)
+	if !ok {
+		roleNames = providerRoles
 	}
+	roles, err := receiver.rolesByName(ctx, roleNames)
+	if err != nil {
+		return err
+	}
+	user.Roles = roles
+	return nil
+}
 
-	u := &model.User{
-		Name:     userInfo.EnName,
-		NickName: userInfo.EnName,
-		Avatar:   userInfo.AvatarUrl,
-		Mobile:   userInfo.Mobile,
-		Status:   helper.Int(model.UserStatusInactive),
-		Email:    email,
+// syncRoles 按 provider 或 LDAP 的角色映射重新计算用户在默认租户的角色, 与当前角色不一致时替换
+func (receiver *UserService) syncRoles(ctx context.Context, user *model.User, mapper *oauth.Mapper, claims map[string]any) (err error) {
+	roleNames, _ := mapper.MapRoles(claims)
+	roles, err := receiver.rolesByName(ctx, roleNames)
+	if err != nil {
+		return err
+	}
+	current, others := splitTenantRoles(user.Roles, model.DefaultTenantID)
+	if sameRoles(current, roles) {
+		return nil
 	}
+	roles = append(others, roles...)
 
-	feishuUser, err := receiver.feishuUserStore.Query(ctx, store.Where("user_id", userInfo.UserID), store.Preload("User.Roles"))
+	entry := &audit.Entry{Action: audit.ActionUserSyncRoles, TargetType: audit.TargetUser, TargetID: user.ID, Before: rolesSnapshot(user.Roles), After: rolesSnapshot(roles)}
+	defer func() { receiver.audit.Record(ctx, entry, err) }()
+	if len(roles) == 0 {
+		err = receiver.userStore.ClearAssociation(ctx, user, model.PreloadRoles)
+	} else {
+		err = receiver.userStore.ReplaceAssociation(ctx, user, model.PreloadRoles, roles)
+	}
 	if err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, err
-		}
-		if feishuUser == nil {
-			feishuUser = userInfo
-		}
-		if feishuUser.User == nil {
-			feishuUser.User = u
-		}
-		if err := receiver.feishuUserStore.Create(ctx, feishuUser); err != nil {
-			return nil, err
-		}
-		return feishuUser, nil
+		return err
 	}
+	user.Roles = roles
+	log.WithRequestID(ctx).Info("external login sync roles", zap.Int64("userID", user.ID), zap.Strings("roles", roleNames))
+	return nil
+}
 
-	if feishuUser.User == nil {
-		if err := receiver.userStore.Create(ctx, u); err != nil {
-			return nil, err
+// rolesByName 查询默认租户中名称对应的角色, 不存在的角色忽略
+func (receiver *UserService) rolesByName(ctx context.Context, names []string) ([]*model.Role, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	_, roles, err := receiver.roleStore.List(ctx, 0, 0, "", "", store.In("name", names), store.Where("tenant_id", model.DefaultTenantID))
+	return roles, err
+}
+
+// splitTenantRoles 将角色分为属于租户的角色和其他租户的角色
+func splitTenantRoles(roles []*model.Role, tenantID int64) (current, others []*model.Role) {
+	for _, role := range roles {
+		if role.TenantID == tenantID {
+			current = append(current, role)
+		} else {
+			others = append(others, role)
 		}
-		feishuUser.User = u
 	}
+	return current, others
+}
 
-	return feishuUser, nil
+// ListTenants 当前用户所属的租户
+func (receiver *UserService) ListTenants(ctx context.Context) ([]*model.Tenant, error) {
+	mc, err := receiver.jwt.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, tenants, err := receiver.tenantStore.List(ctx, 0, 0, "id", "asc", store.UserTenants(mc.UserID))
+	return tenants, err
 }
 
-func (receiver *UserService) genericLogin(ctx context.Context, userInfo *model.KeycloakUser) (data *model.User, err error) {
-	if userInfo.Sub == "" {
-		return nil, errors.New("generic user is empty")
+// tenantUsers 在其他租户中只能管理属于该租户的用户, 默认租户可以管理所有用户
+func tenantUsers(ctx context.Context) store.Option {
+	if tenantID := jwt.TenantFromContext(ctx); tenantID != model.DefaultTenantID {
+		return store.TenantUsers(tenantID)
 	}
+	return nil
+}
 
-	data, err = receiver.userStore.Query(ctx, store.Where("email", userInfo.Email), store.Preload("Roles"))
-	if err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, err
+// checkTenantMember 检查用户是否属于租户, 所有用户都属于默认租户
+func (receiver *UserService) checkTenantMember(ctx context.Context, tenantID, userID int64) error {
+	if tenantID == model.DefaultTenantID {
+		return nil
+	}
+	if _, err := receiver.tenantStore.Query(ctx, store.Where("id", tenantID), store.UserTenants(userID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: user %d is not a member of tenant %d", constant.ErrNoPermission, userID, tenantID)
 		}
+		return err
+	}
+	return nil
+}
 
-		data = &model.User{
-			Name:       userInfo.PreferredUsername,
-			NickName:   userInfo.FamilyName + userInfo.GivenName,
-			Email:      userInfo.Email,
-			Status:     helper.Int(model.UserStatusInactive),
-			Department: strings.Join(userInfo.Group, ","),
-		}
-		if len(userInfo.Roles) > 0 {
-			_, roles, err := receiver.roleStore.List(ctx, 0, 0, "", "", store.In("name", userInfo.Roles))
-			if err != nil {
-				return nil, err
-			}
-			data.Roles = roles
-		}
-		if err := receiver.userStore.Create(ctx, data); err != nil {
-			return nil, err
+func sameRoles(a, b []*model.Role) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ids := make(map[int64]struct{}, len(a))
+	for _, v := range a {
+		ids[v.ID] = struct{}{}
+	}
+	for _, v := range b {
+		if _, ok := ids[v.ID]; !ok {
+			return false
 		}
 	}
-
-	return data, nil
+	return true
 }
 
 func (receiver *UserService) OAuth2Provider(_ context.Context) ([]string, error) {
@@ -587,7 +1111,11 @@ func (receiver *UserService) OAuth2Provider(_ context.Context) ([]string, error)
 	return list, nil
 }
 
-func (receiver *UserService) OAuth2Activate(ctx context.Context, req *apitypes.OAuthActivateRequest) (*apitypes.UserLoginResponse, error) {
+func (receiver *UserService) OAuth2Activate(ctx context.Context, req *apitypes.OAuthActivateRequest) (res *apitypes.UserLoginResponse, err error) {
+	provider, _ := ctx.Value(constant.ProviderContextKey).(string)
+	event := &model.LoginEvent{Event: loginevent.EventOAuth2Activate, Provider: provider}
+	defer func() { receiver.recordLoginEvent(ctx, event, res, err) }()
+
 	if req.Password != req.ConfirmPassword {
 		return nil, errors.New("password not match")
 	}
@@ -596,19 +1124,113 @@ func (receiver *UserService) OAuth2Activate(ctx context.Context, req *apitypes.O
 	if err != nil {
 		return nil, err
 	}
+	event.UserID = user.ID
+	event.Account = user.Name
 
-	password, err := receiver.hashPassword(req.Password)
+	if err := receiver.passwordChecker.Check(ctx, user, req.Password); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := receiver.hashPassword(req.Password)
 	if err != nil {
 		return nil, fmt.Errorf("hash password error: %v", err)
 	}
-	user.Password = password
+	user.Password = hashedPassword
 	user.Status = helper.Int(model.UserStatusActive)
-	if err := receiver.userStore.Update(ctx, user); err != nil {
-		return nil, fmt.Errorf("update user error: %v", err)
+	if err := receiver.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := receiver.userStore.Update(ctx, user); err != nil {
+			return err
+		}
+		return receiver.passwordChecker.Record(ctx, user.ID, user.Password)
+	}); err != nil {
+		return nil, fmt.Errorf("update user error: %w", err)
 	}
-	token, err := receiver.jwt.GenerateToken(user.ID, user.Name)
+	return receiver.completeLogin(ctx, user, jwt.AuthMethodOAuth, user.Email)
+}
+
+// completeLogin 第一步认证通过后, 启用了两步验证的用户返回 mfa token, 否则直接签发 token
+func (receiver *UserService) completeLogin(ctx context.Context, user *model.User, method, account string) (*apitypes.UserLoginResponse, error) {
+	enabled, err := receiver.mfa.MfaEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return receiver.issueToken(ctx, user, jwt.WithAuthMethods(method))
+	}
+
+	token, _, err := receiver.jwt.GenerateMfaToken(user.ID, user.Name, jwt.WithAuthMethods(method), jwt.WithAccount(account))
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.UserLoginResponse{
+		User:        user,
+		MfaRequired: true,
+		MfaToken:    token,
+	}, nil
+}
+
+// issueToken 为登录成功的用户签发 token, 并记录会话当前有效的 refresh token
+func (receiver *UserService) issueToken(ctx context.Context, user *model.User, opts ...jwt.ClaimsOption) (*apitypes.UserLoginResponse, error) {
+	pair, err := receiver.jwt.GenerateTokenPair(user.ID, user.Name, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return &apitypes.UserLoginResponse{User: user, Token: token}, nil
+
+	refreshExpire := time.Until(pair.RefreshClaims.ExpiresAt.Time)
+	if err := receiver.cacheStore.SetString(ctx, store.RefreshTokenType, pair.RefreshClaims.SessionID, pair.RefreshClaims.ID, &refreshExpire); err != nil {
+		return nil, err
+	}
+	if err := receiver.sessions.Save(ctx, receiver.newSession(ctx, user, pair)); err != nil {
+		return nil, err
+	}
+	return receiver.newLoginResponse(user, pair), nil
+}
+
+// newSession 根据新签发的 token 构造会话, 客户端信息取自请求上下文
+func (receiver *UserService) newSession(ctx context.Context, user *model.User, pair *jwt.TokenPair) *session.Session {
+	now := time.Now()
+	return &session.Session{
+		ID:          pair.AccessClaims.SessionID,
+		UserID:      user.ID,
+		JTI:         pair.AccessClaims.ID,
+		IP:          helper.GetClientIPFromContext(ctx),
+		UserAgent:   helper.GetUserAgentFromContext(ctx),
+		AuthMethods: pair.AccessClaims.AuthMethods,
+		CreatedAt:   now,
+		LastSeenAt:  now,
+		ExpiresAt:   pair.RefreshClaims.ExpiresAt.Time,
+	}
+}
+
+// recordLoginEvent 记录登录事件, 登录成功时补充用户信息, 需要两步验证或账号未激活时记录在 Reason 中
+func (receiver *UserService) recordLoginEvent(ctx context.Context, event *model.LoginEvent, res *apitypes.UserLoginResponse, err error) {
+	if err == nil && res != nil && res.User != nil {
+		event.UserID = res.User.ID
+		if event.Account == "" {
+			event.Account = res.User.Name
+		}
+		switch {
+		case res.MfaRequired:
+			event.Reason = loginevent.ReasonMfaRequired
+		case res.Token == "":
+			event.Reason = loginevent.ReasonInactive
+		}
+	}
+	receiver.loginEvents.Record(ctx, event, err)
+}
+
+// revokeUser 吊销用户已签发的全部 token 并删除用户的会话
+func (receiver *UserService) revokeUser(ctx context.Context, userID int64) error {
+	if err := receiver.revoker.RevokeUser(ctx, userID); err != nil {
+		return err
+	}
+	return receiver.sessions.DeleteAll(ctx, userID)
+}
+
+func (receiver *UserService) newLoginResponse(user *model.User, pair *jwt.TokenPair) *apitypes.UserLoginResponse {
+	return &apitypes.UserLoginResponse{
+		User:         user,
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    int64(time.Until(pair.AccessClaims.ExpiresAt.Time).Seconds()),
+	}
 }
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/yiran15/api-server/base/conf"
 )
@@ -16,6 +17,16 @@ type CacheStorer interface {
 	DelKey(ctx context.Context, cacheType CacheType, cacheKey any) error
 	GetSet(ctx context.Context, cacheType CacheType, cacheKey any) ([]string, error)
 	SetSet(ctx context.Context, cacheType CacheType, cacheKey any, cacheValue []any, expireTime *time.Duration) error
+	RemSet(ctx context.Context, cacheType CacheType, cacheKey any, cacheValue ...any) error
+	GetString(ctx context.Context, cacheType CacheType, cacheKey any) (string, error)
+	SetString(ctx context.Context, cacheType CacheType, cacheKey any, cacheValue string, expireTime *time.Duration) error
+	SetStringNX(ctx context.Context, cacheType CacheType, cacheKey any, cacheValue string, expireTime *time.Duration) (bool, error)
+	GetDelString(ctx context.Context, cacheType CacheType, cacheKey any) (string, error)
+	CompareAndSwap(ctx context.Context, cacheType CacheType, cacheKey any, oldValue, newValue string, expireTime *time.Duration) (bool, error)
+	Incr(ctx context.Context, cacheType CacheType, cacheKey any, expireTime time.Duration) (int64, error)
+	SlidingWindowAdd(ctx context.Context, cacheType CacheType, cacheKey any, window time.Duration) (int64, error)
+	SlidingWindowCount(ctx context.Context, cacheType CacheType, cacheKey any, window time.Duration) (int64, error)
+	SlidingWindowAllow(ctx context.Context, cacheType CacheType, cacheKey any, limit int64, window time.Duration) (*WindowResult, error)
 }
 
 var (
@@ -27,8 +38,98 @@ type CacheType string
 const (
 	RoleType CacheType = "role"
 	TestType CacheType = "test"
+	// RefreshTokenType 保存每个会话当前有效的 refresh token jti
+	RefreshTokenType CacheType = "refresh"
+	// RevokedTokenType 已吊销的 token, key 为 jti
+	RevokedTokenType CacheType = "revoked_token"
+	// RevokedUserType 用户级别的吊销时间 (毫秒), 此前签发的 token 全部失效
+	RevokedUserType CacheType = "revoked_user"
+	// MfaAttemptType 两步验证的尝试次数, key 为 mfa token 的 jti
+	MfaAttemptType CacheType = "mfa_attempt"
+	// LoginFailureType 登录失败记录的滑动窗口, key 为 email:<邮箱> 或 ip:<IP>
+	LoginFailureType CacheType = "login_failure"
+	// LoginLockType 被临时锁定的登录邮箱, 值为锁定级别
+	LoginLockType CacheType = "login_lock"
+	// LoginLockLevelType 邮箱连续被锁定的次数, 用于计算指数退避的锁定时长
+	LoginLockLevelType CacheType = "login_lock_level"
+	// RateLimitType 接口限流的滑动窗口
+	RateLimitType CacheType = "ratelimit"
+	// PasswordResetType 密码重置 token, key 为 token 的 sha256, 值为用户 id
+	PasswordResetType CacheType = "password_reset"
+	// PasswordResetUserType 用户当前有效的密码重置 token 哈希, 重新申请时使之前的 token 失效
+	PasswordResetUserType CacheType = "password_reset_user"
+	// SessionType 登录会话信息, key 为 sid
+	SessionType CacheType = "session"
+	// UserSessionsType 用户的会话 sid 集合
+	UserSessionsType CacheType = "user_sessions"
 )
 
+// compareAndSwapScript 仅当 key 的值等于 ARGV[1] 时才替换为 ARGV[2], 保证 refresh token 只能使用一次
+var compareAndSwapScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[2], "KEEPTTL")
+end
+return 1
+`)
+
+// incrScript 计数器加一, 首次创建时设置过期时间, 保证计数窗口从第一次计数开始
+var incrScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// slidingWindowScript 清理窗口外的记录, ARGV[3] 不为空时添加一条记录, 返回窗口内的记录数
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+if ARGV[3] ~= "" then
+	redis.call("ZADD", KEYS[1], now, ARGV[3])
+	redis.call("PEXPIRE", KEYS[1], window)
+end
+return redis.call("ZCARD", KEYS[1])
+`)
+
+// slidingWindowAllowScript 窗口内记录数小于 ARGV[3] 时添加一条记录
+// 返回是否允许、窗口内记录数以及最早一条记录离开窗口的剩余毫秒数
+var slidingWindowAllowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[4])
+	redis.call("PEXPIRE", KEYS[1], window)
+	count = count + 1
+	allowed = 1
+end
+local reset = 0
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, count, reset}
+`)
+
+// WindowResult 滑动窗口限流结果
+type WindowResult struct {
+	Allowed bool
+	// Count 窗口内的记录数
+	Count int64
+	// ResetAfter 最早一条记录离开窗口的剩余时间
+	ResetAfter time.Duration
+}
+
 type CacheStore struct {
 	client     *redis.Client
 	expireTime time.Duration
@@ -113,6 +214,165 @@ func (c *CacheStore) SetSet(ctx context.Context, cacheType CacheType, cacheKey a
 	return nil
 }
 
+// RemSet 从集合中删除成员
+func (c *CacheStore) RemSet(ctx context.Context, cacheType CacheType, cacheKey any, cacheValue ...any) error {
+	if len(cacheValue) == 0 {
+		return nil
+	}
+	key, err := c.NormalizeCacheKey(cacheKey)
+	if err != nil {
+		return err
+	}
+	if err := c.client.SRem(ctx, c.buildCacheKey(cacheType, key), cacheValue...).Err(); err != nil {
+		return fmt.Errorf("redis remSet error: %w", err)
+	}
+	return nil
+}
+
+func (c *CacheStore) GetString(ctx context.Context, cacheType CacheType, cacheKey any) (string, error) {
+	key, err := c.NormalizeCacheKey(cacheKey)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := c.client.Get(ctx, c.buildCacheKey(cacheType, key)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return "", fmt.Errorf("redis getString error: %w", err)
+	}
+	return result, nil
+}
+
+func (c *CacheStore) SetString(ctx context.Context, cacheType CacheType, cacheKey any, cacheValue string, expireTime *time.Duration) error {
+	key, err := c.NormalizeCacheKey(cacheKey)
+	if err != nil {
+		return err
+	}
+
+	expire := c.expireTime
+	if expireTime != nil {
+		expire = *expireTime
+	}
+	if err := c.client.Set(ctx, c.buildCacheKey(cacheType, key), cacheValue, expire).Err(); err != nil {
+		return fmt.Errorf("redis setString error: %w", err)
+	}
+	return nil
+}
+
+// SetStringNX key 不存在时写入缓存, 返回是否写入成功
+func (c *CacheStore) SetStringNX(ctx context.Context, cacheType CacheType, cacheKey any, cacheValue string, expireTime *time.Duration) (bool, error) {
+	key, err := c.NormalizeCacheKey(cacheKey)
+	if err != nil {
+		return false, err
+	}
+
+	expire := c.expireTime
+	if expireTime != nil {
+		expire = *expireTime
+	}
+	ok, err := c.client.SetNX(ctx, c.buildCacheKey(cacheType, key), cacheValue, expire).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis setStringNX error: %w", err)
+	}
+	return ok, nil
+}
+
+// GetDelString 获取并删除缓存, 用于只能使用一次的 token, key 不存在时返回空字符串
+func (c *CacheStore) GetDelString(ctx context.Context, cacheType CacheType, cacheKey any) (string, error) {
+	key, err := c.NormalizeCacheKey(cacheKey)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := c.client.GetDel(ctx, c.buildCacheKey(cacheType, key)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return "", fmt.Errorf("redis getDelString error: %w", err)
+	}
+	return result, nil
+}
+
+// CompareAndSwap 当缓存值等于 oldValue 时原子地替换为 newValue, 返回是否替换成功
+// expireTime 为 nil 时保留原有的过期时间
+func (c *CacheStore) CompareAndSwap(ctx context.Context, cacheType CacheType, cacheKey any, oldValue, newValue string, expireTime *time.Duration) (bool, error) {
+	key, err := c.NormalizeCacheKey(cacheKey)
+	if err != nil {
+		return false, err
+	}
+
+	var expire int64
+	if expireTime != nil {
+		expire = expireTime.Milliseconds()
+	}
+	swapped, err := compareAndSwapScript.Run(ctx, c.client, []string{c.buildCacheKey(cacheType, key)}, oldValue, newValue, expire).Int()
+	if err != nil {
+		return false, fmt.Errorf("redis compareAndSwap error: %w", err)
+	}
+	return swapped == 1, nil
+}
+
+// Incr 计数器加一并返回当前值, 计数器在首次计数 expireTime 后过期
+func (c *CacheStore) Incr(ctx context.Context, cacheType CacheType, cacheKey any, expireTime time.Duration) (int64, error) {
+	key, err := c.NormalizeCacheKey(cacheKey)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := incrScript.Run(ctx, c.client, []string{c.buildCacheKey(cacheType, key)}, expireTime.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("redis incr error: %w", err)
+	}
+	return n, nil
+}
+
+// SlidingWindowAdd 在滑动窗口中添加一条记录, 返回窗口内的记录数
+func (c *CacheStore) SlidingWindowAdd(ctx context.Context, cacheType CacheType, cacheKey any, window time.Duration) (int64, error) {
+	return c.slidingWindow(ctx, cacheType, cacheKey, window, uuid.New().String())
+}
+
+// SlidingWindowCount 返回滑动窗口内的记录数
+func (c *CacheStore) SlidingWindowCount(ctx context.Context, cacheType CacheType, cacheKey any, window time.Duration) (int64, error) {
+	return c.slidingWindow(ctx, cacheType, cacheKey, window, "")
+}
+
+// SlidingWindowAllow 窗口内记录数小于 limit 时添加一条记录, 用于接口限流
+func (c *CacheStore) SlidingWindowAllow(ctx context.Context, cacheType CacheType, cacheKey any, limit int64, window time.Duration) (*WindowResult, error) {
+	key, err := c.NormalizeCacheKey(cacheKey)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := slidingWindowAllowScript.Run(ctx, c.client, []string{c.buildCacheKey(cacheType, key)}, time.Now().UnixMilli(), window.Milliseconds(), limit, uuid.New().String()).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("redis sliding window error: %w", err)
+	}
+	if len(res) != 3 {
+		return nil, fmt.Errorf("redis sliding window error: unexpected result %v", res)
+	}
+	return &WindowResult{
+		Allowed:    res[0] == 1,
+		Count:      res[1],
+		ResetAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+func (c *CacheStore) slidingWindow(ctx context.Context, cacheType CacheType, cacheKey any, window time.Duration, member string) (int64, error) {
+	key, err := c.NormalizeCacheKey(cacheKey)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := slidingWindowScript.Run(ctx, c.client, []string{c.buildCacheKey(cacheType, key)}, time.Now().UnixMilli(), window.Milliseconds(), member).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("redis sliding window error: %w", err)
+	}
+	return n, nil
+}
+
 func GetExpireTime(expireTime time.Duration) *time.Duration {
 	return &expireTime
 }
@@ -3,6 +3,7 @@ package store
 import (
 	"fmt"
 
+	"github.com/yiran15/api-server/model"
 	"gorm.io/gorm"
 )
 
@@ -28,6 +29,13 @@ func Where(colum string, value any) Option {
 	}
 }
 
+// Lt 用于添加小于条件。
+func Lt(colum string, value any) Option {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where(fmt.Sprintf("%s < ?", colum), value)
+	}
+}
+
 func Like(colum string, value any) Option {
 	return func(query *gorm.DB) *gorm.DB {
 		where := fmt.Sprintf("%s like ?", colum)
@@ -82,3 +90,19 @@ func Scopes(funcs ...func(*gorm.DB) *gorm.DB) Option {
 		return db.Scopes(funcs...)
 	}
 }
+
+// TenantUsers 只查询属于租户的用户
+func TenantUsers(tenantID int64) Option {
+	return func(db *gorm.DB) *gorm.DB {
+		members := db.Session(&gorm.Session{NewDB: true}).Table("user_tenants").Select("user_id").Where("tenant_id = ?", tenantID)
+		return db.Where("id in (?)", members)
+	}
+}
+
+// UserTenants 只查询用户所属的租户, 所有用户都属于默认租户
+func UserTenants(userID int64) Option {
+	return func(db *gorm.DB) *gorm.DB {
+		tenants := db.Session(&gorm.Session{NewDB: true}).Table("user_tenants").Select("tenant_id").Where("user_id = ?", userID)
+		return db.Where("id = ? or id in (?)", model.DefaultTenantID, tenants)
+	}
+}
@@ -14,6 +14,14 @@ var StoreProviderSet = wire.NewSet(
 	NewApiStore,
 	NewCasbinStore,
 	NewFeiShuUserStore,
+	NewJwtKeyStore,
+	NewPersonalAccessTokenStore,
+	NewUserMfaStore,
+	NewPasswordHistoryStore,
+	NewAuditLogStore,
+	NewLoginEventStore,
+	NewUserIdentityStore,
+	NewTenantStore,
 
 	wire.Bind(new(CacheStorer), new(*CacheStore)),
 	NewCacheStore,
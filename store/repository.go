@@ -53,6 +53,17 @@ func (r *repository[T]) Update(ctx context.Context, obj *T, opts ...Option) erro
 	return nil
 }
 
+// UpdateRows 更新对象并返回受影响的行数。
+// 用于带条件的更新, 受影响行数为 0 表示条件不满足, 调用方可据此实现乐观锁。
+func (r *repository[T]) UpdateRows(ctx context.Context, obj *T, opts ...Option) (int64, error) {
+	db := r.getDB(ctx, obj, opts...).Updates(obj)
+	if err := db.Error; err != nil {
+		log.WithRequestID(ctx).Error("failed to update object", zap.Error(err), zap.Any("obj", obj))
+		return 0, err
+	}
+	return db.RowsAffected, nil
+}
+
 // Delete 删除对象。
 // 可以通过 opts 指定删除条件（如 Where），或者直接使用 obj 的主键进行软删除/硬删除。
 func (r *repository[T]) Delete(ctx context.Context, obj *T, opts ...Option) error {
@@ -141,6 +152,14 @@ func (r *repository[T]) ReplaceAssociation(ctx context.Context, model *T, objNam
 	return nil
 }
 
+func (r *repository[T]) DeleteAssociation(ctx context.Context, model *T, objName string, obj any) error {
+	if err := r.getDB(ctx, model).Association(objName).Delete(obj); err != nil {
+		log.WithRequestID(ctx).Error("failed to delete association", zap.Error(err), zap.Any("obj", obj))
+		return err
+	}
+	return nil
+}
+
 func (r *repository[T]) ClearAssociation(ctx context.Context, model *T, objName string) error {
 	if err := r.getDB(ctx, model).Association(objName).Clear(); err != nil {
 		log.WithRequestID(ctx).Error("failed to clear association", zap.Error(err))
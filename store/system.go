@@ -15,6 +15,7 @@ type UserStorer interface {
 	List(ctx context.Context, page, pageSize int, colum, oder string, opts ...Option) (total int64, objs []*model.User, err error)
 	AppendAssociation(ctx context.Context, model *model.User, objName string, obj any) error
 	ReplaceAssociation(ctx context.Context, model *model.User, objName string, obj any) error
+	DeleteAssociation(ctx context.Context, model *model.User, objName string, obj any) error
 	ClearAssociation(ctx context.Context, model *model.User, objName string) error
 }
 
@@ -80,3 +81,94 @@ type FeiShuUserStorer interface {
 func NewFeiShuUserStore(dbProvider DBProviderInterface) FeiShuUserStorer {
 	return NewRepository[model.FeiShuUser](dbProvider)
 }
+
+type JwtKeyStorer interface {
+	Create(ctx context.Context, obj *model.JwtKey) error
+	Delete(ctx context.Context, obj *model.JwtKey, opts ...Option) error
+	Query(ctx context.Context, opts ...Option) (*model.JwtKey, error)
+	List(ctx context.Context, page, pageSize int, colum, oder string, opts ...Option) (total int64, objs []*model.JwtKey, err error)
+}
+
+func NewJwtKeyStore(dbProvider DBProviderInterface) JwtKeyStorer {
+	return NewRepository[model.JwtKey](dbProvider)
+}
+
+type PersonalAccessTokenStorer interface {
+	Create(ctx context.Context, obj *model.PersonalAccessToken) error
+	Update(ctx context.Context, obj *model.PersonalAccessToken, opts ...Option) error
+	Delete(ctx context.Context, obj *model.PersonalAccessToken, opts ...Option) error
+	Query(ctx context.Context, opts ...Option) (*model.PersonalAccessToken, error)
+	List(ctx context.Context, page, pageSize int, colum, oder string, opts ...Option) (total int64, objs []*model.PersonalAccessToken, err error)
+	AppendAssociation(ctx context.Context, model *model.PersonalAccessToken, objName string, obj any) error
+	ReplaceAssociation(ctx context.Context, model *model.PersonalAccessToken, objName string, obj any) error
+	ClearAssociation(ctx context.Context, model *model.PersonalAccessToken, objName string) error
+}
+
+func NewPersonalAccessTokenStore(dbProvider DBProviderInterface) PersonalAccessTokenStorer {
+	return NewRepository[model.PersonalAccessToken](dbProvider)
+}
+
+type UserMfaStorer interface {
+	Create(ctx context.Context, obj *model.UserMfa) error
+	Update(ctx context.Context, obj *model.UserMfa, opts ...Option) error
+	UpdateRows(ctx context.Context, obj *model.UserMfa, opts ...Option) (int64, error)
+	Delete(ctx context.Context, obj *model.UserMfa, opts ...Option) error
+	Query(ctx context.Context, opts ...Option) (*model.UserMfa, error)
+}
+
+func NewUserMfaStore(dbProvider DBProviderInterface) UserMfaStorer {
+	return NewRepository[model.UserMfa](dbProvider)
+}
+
+type PasswordHistoryStorer interface {
+	Create(ctx context.Context, obj *model.PasswordHistory) error
+	Delete(ctx context.Context, obj *model.PasswordHistory, opts ...Option) error
+	List(ctx context.Context, page, pageSize int, colum, oder string, opts ...Option) (total int64, objs []*model.PasswordHistory, err error)
+}
+
+func NewPasswordHistoryStore(dbProvider DBProviderInterface) PasswordHistoryStorer {
+	return NewRepository[model.PasswordHistory](dbProvider)
+}
+
+type AuditLogStorer interface {
+	Create(ctx context.Context, obj *model.AuditLog) error
+	List(ctx context.Context, page, pageSize int, colum, oder string, opts ...Option) (total int64, objs []*model.AuditLog, err error)
+}
+
+func NewAuditLogStore(dbProvider DBProviderInterface) AuditLogStorer {
+	return NewRepository[model.AuditLog](dbProvider)
+}
+
+type LoginEventStorer interface {
+	Create(ctx context.Context, obj *model.LoginEvent) error
+	List(ctx context.Context, page, pageSize int, colum, oder string, opts ...Option) (total int64, objs []*model.LoginEvent, err error)
+}
+
+func NewLoginEventStore(dbProvider DBProviderInterface) LoginEventStorer {
+	return NewRepository[model.LoginEvent](dbProvider)
+}
+
+type UserIdentityStorer interface {
+	Create(ctx context.Context, obj *model.UserIdentity) error
+	Update(ctx context.Context, obj *model.UserIdentity, opts ...Option) error
+	Delete(ctx context.Context, obj *model.UserIdentity, opts ...Option) error
+	Query(ctx context.Context, opts ...Option) (*model.UserIdentity, error)
+	List(ctx context.Context, page, pageSize int, colum, oder string, opts ...Option) (total int64, objs []*model.UserIdentity, err error)
+}
+
+func NewUserIdentityStore(dbProvider DBProviderInterface) UserIdentityStorer {
+	return NewRepository[model.UserIdentity](dbProvider)
+}
+
+type TenantStorer interface {
+	Create(ctx context.Context, obj *model.Tenant) error
+	Update(ctx context.Context, obj *model.Tenant, opts ...Option) error
+	Query(ctx context.Context, opts ...Option) (*model.Tenant, error)
+	List(ctx context.Context, page, pageSize int, colum, oder string, opts ...Option) (total int64, objs []*model.Tenant, err error)
+	AppendAssociation(ctx context.Context, model *model.Tenant, objName string, obj any) error
+	DeleteAssociation(ctx context.Context, model *model.Tenant, objName string, obj any) error
+}
+
+func NewTenantStore(dbProvider DBProviderInterface) TenantStorer {
+	return NewRepository[model.Tenant](dbProvider)
+}
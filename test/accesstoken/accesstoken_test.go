@@ -0,0 +1,94 @@
+package accesstoken_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/jwt"
+	v1 "github.com/yiran15/api-server/service/v1"
+	"github.com/yiran15/api-server/store"
+	"gorm.io/gorm"
+)
+
+func newService(t *testing.T) (v1.AccessTokenServicer, store.PersonalAccessTokenStorer, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.User{}, &model.Role{}, &model.Api{}, &model.Tenant{}, &model.PersonalAccessToken{}); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("jwt.secret", "test-secret")
+	viper.Set("jwt.algorithm", "HS256")
+	generator, cleanup, err := jwt.NewGenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+
+	provider := store.NewDBProvider(db)
+	tokenStore := store.NewPersonalAccessTokenStore(provider)
+	return v1.NewAccessTokenService(tokenStore, store.NewUserStore(provider), store.NewTxManager(db), generator), tokenStore, db
+}
+
+func userContext(userID int64, tokenType string) context.Context {
+	return context.WithValue(context.Background(), constant.UserContextKey, &jwt.JwtClaims{UserID: userID, TokenType: tokenType})
+}
+
+func TestUpdateAccessToken(t *testing.T) {
+	svc, tokenStore, db := newService(t)
+	owned := []*model.Role{{Name: "dev", TenantID: model.DefaultTenantID}, {Name: "ops", TenantID: model.DefaultTenantID}}
+	other := &model.Role{Name: "admin", TenantID: model.DefaultTenantID}
+	if err := db.Create(append(owned, other)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.User{ID: 1, Name: "dev", Email: "dev@example.com", Roles: owned}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := userContext(1, jwt.TokenTypeAccess)
+	res, err := svc.CreateAccessToken(ctx, &apitypes.AccessTokenCreateRequest{Name: "ci", ExpireDays: 1, RolesID: []int64{owned[0].ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := &apitypes.IDRequest{ID: res.AccessToken.ID}
+
+	// 只能限定为用户拥有的角色
+	err = svc.UpdateAccessToken(ctx, &apitypes.AccessTokenUpdateRequest{IDRequest: id, RolesID: &[]int64{other.ID}})
+	if !errors.Is(err, constant.ErrNoPermission) {
+		t.Fatalf("roles not granted to user should be rejected, got %v", err)
+	}
+	if err := svc.UpdateAccessToken(ctx, &apitypes.AccessTokenUpdateRequest{IDRequest: id, Name: "deploy", ExpireDays: 30, RolesID: &[]int64{owned[1].ID}}); err != nil {
+		t.Fatal(err)
+	}
+	pat, err := tokenStore.Query(ctx, store.Where("id", id.ID), store.Preload(model.PreloadRoles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pat.Name != "deploy" || !pat.ExpiresAt.After(res.AccessToken.ExpiresAt) || !pat.Restricted || len(pat.Roles) != 1 || pat.Roles[0].ID != owned[1].ID {
+		t.Fatalf("unexpected token after update: %+v", pat)
+	}
+
+	// 空数组取消角色限定
+	if err := svc.UpdateAccessToken(ctx, &apitypes.AccessTokenUpdateRequest{IDRequest: id, RolesID: &[]int64{}}); err != nil {
+		t.Fatal(err)
+	}
+	if pat, _ = tokenStore.Query(ctx, store.Where("id", id.ID), store.Preload(model.PreloadRoles)); pat.Restricted || len(pat.Roles) != 0 || pat.Name != "deploy" {
+		t.Fatalf("token should no longer be restricted: %+v", pat)
+	}
+
+	if err := svc.UpdateAccessToken(userContext(2, jwt.TokenTypeAccess), &apitypes.AccessTokenUpdateRequest{IDRequest: id, Name: "stolen"}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("token of other user should not be found, got %v", err)
+	}
+	if err := svc.UpdateAccessToken(userContext(1, jwt.TokenTypePersonalAccess), &apitypes.AccessTokenUpdateRequest{IDRequest: id, ExpireDays: 365}); !errors.Is(err, constant.ErrNoPermission) {
+		t.Fatalf("personal access token should not update tokens, got %v", err)
+	}
+}
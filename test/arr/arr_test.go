@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+
+	"github.com/yiran15/api-server/base/helper"
 )
 
 func TestArr(t *testing.T) {
@@ -23,3 +25,13 @@ func TestSplit(t *testing.T) {
 	ty := strings.Split(a, "/")[2]
 	fmt.Println(ty)
 }
+
+func TestIntersect(t *testing.T) {
+	got := helper.Intersect([]string{"admin", "dev", "ops"}, []string{"ops", "admin", "guest"})
+	if len(got) != 2 || got[0] != "admin" || got[1] != "ops" {
+		t.Fatalf("unexpected intersect result: %v", got)
+	}
+	if got := helper.Intersect([]string{"admin"}, nil); len(got) != 0 {
+		t.Fatalf("expected empty result, got %v", got)
+	}
+}
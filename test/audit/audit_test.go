@@ -0,0 +1,37 @@
+package audit_test
+
+import (
+	"testing"
+
+	"github.com/yiran15/api-server/pkg/audit"
+)
+
+func TestDiff(t *testing.T) {
+	before := map[string]any{"name": "alice", "status": 1, "roles": []string{"admin"}}
+	after := map[string]any{"name": "alice", "status": 2, "roles": []string{"admin", "dev"}, "passwordChanged": true}
+
+	diff := audit.Diff(before, after)
+	if len(diff) != 3 {
+		t.Fatalf("expected 3 changed fields, got %d: %v", len(diff), diff)
+	}
+	if _, ok := diff["name"]; ok {
+		t.Fatal("unchanged field name should not be in diff")
+	}
+	if diff["status"].Before != float64(1) || diff["status"].After != float64(2) {
+		t.Fatalf("unexpected status change: %+v", diff["status"])
+	}
+	if diff["passwordChanged"].Before != nil || diff["passwordChanged"].After != true {
+		t.Fatalf("unexpected passwordChanged change: %+v", diff["passwordChanged"])
+	}
+
+	// 创建时只有 After, 删除时只有 Before
+	if diff := audit.Diff(nil, map[string]any{"name": "alice"}); diff["name"].After != "alice" {
+		t.Fatalf("unexpected create diff: %v", diff)
+	}
+	if diff := audit.Diff(map[string]any{"name": "alice"}, nil); diff["name"].Before != "alice" || diff["name"].After != nil {
+		t.Fatalf("unexpected delete diff: %v", diff)
+	}
+	if diff := audit.Diff(before, before); diff != nil {
+		t.Fatalf("expected nil diff, got %v", diff)
+	}
+}
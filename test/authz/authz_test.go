@@ -0,0 +1,94 @@
+package authz_test
+
+import (
+	"slices"
+	"testing"
+
+	casbinv2 "github.com/casbin/casbin/v2"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/casbin"
+)
+
+const dom = "1"
+
+func newChecker(t *testing.T, policies [][]string, groups [][]string) casbin.AuthChecker {
+	t.Helper()
+	m, err := casbin.NewModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer, err := casbinv2.NewSyncedEnforcer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enforcer.AddPolicies(policies); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enforcer.AddGroupingPolicies(groups); err != nil {
+		t.Fatal(err)
+	}
+	return casbin.NewAuthChecker(enforcer)
+}
+
+func TestDenyOverride(t *testing.T) {
+	checker := newChecker(t, [][]string{
+		{"readOnly", dom, "*", "GET", model.EffectAllow},
+		{"readOnly", dom, "/api/v1/audit", "GET", model.EffectDeny},
+		{"auditor", dom, "/api/v1/audit", "GET", model.EffectAllow},
+	}, [][]string{
+		// 继承 readOnly 后 deny 规则同样生效
+		{"ops", "readOnly", dom},
+		{"ops", "auditor", dom},
+	})
+
+	tests := []struct {
+		sub, obj string
+		want     bool
+		rule     []string
+	}{
+		{"readOnly", "/api/v1/user", true, []string{"readOnly", dom, "*", "GET", model.EffectAllow}},
+		{"readOnly", "/api/v1/audit", false, []string{"readOnly", dom, "/api/v1/audit", "GET", model.EffectDeny}},
+		{"auditor", "/api/v1/audit", true, []string{"auditor", dom, "/api/v1/audit", "GET", model.EffectAllow}},
+		{"ops", "/api/v1/audit", false, []string{"readOnly", dom, "/api/v1/audit", "GET", model.EffectDeny}},
+		{"auditor", "/api/v1/user", false, nil},
+	}
+	for _, tt := range tests {
+		ok, rule, err := checker.EnforceEx(tt.sub, dom, tt.obj, "GET")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want || !slices.Equal(rule, tt.rule) {
+			t.Errorf("EnforceEx(%s, %s) = %v, %v, want %v, %v", tt.sub, tt.obj, ok, rule, tt.want, tt.rule)
+		}
+	}
+}
+
+func TestEnforceRoles(t *testing.T) {
+	checker := newChecker(t, [][]string{
+		{"readOnly", dom, "*", "GET", model.EffectAllow},
+		{"readOnly", dom, "/api/v1/audit", "GET", model.EffectDeny},
+		{"auditor", dom, "/api/v1/audit", "GET", model.EffectAllow},
+	}, nil)
+
+	tests := []struct {
+		roles []string
+		obj   string
+		want  bool
+		rule  []string
+	}{
+		// 任一角色的 deny 规则覆盖其他角色的 allow 规则
+		{[]string{"auditor", "readOnly"}, "/api/v1/audit", false, []string{"readOnly", dom, "/api/v1/audit", "GET", model.EffectDeny}},
+		{[]string{"auditor", "readOnly"}, "/api/v1/user", true, []string{"readOnly", dom, "*", "GET", model.EffectAllow}},
+		{[]string{"auditor"}, "/api/v1/user", false, nil},
+		{nil, "/api/v1/user", false, nil},
+	}
+	for _, tt := range tests {
+		ok, rule, err := checker.EnforceRoles(tt.roles, dom, tt.obj, "GET")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want || !slices.Equal(rule, tt.rule) {
+			t.Errorf("EnforceRoles(%v, %s) = %v, %v, want %v, %v", tt.roles, tt.obj, ok, rule, tt.want, tt.rule)
+		}
+	}
+}
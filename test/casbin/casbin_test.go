@@ -12,11 +12,13 @@ import (
 
 var (
 	casbinManager casbin.CasbinManager
-	enforcer      *casbinv2.Enforcer
+	enforcer      *casbinv2.SyncedEnforcer
 	// 定义测试数据
 	testRole = "test_role"
 	testUser = "test_user"
-	testApis = []*model.Api{
+	// 默认租户
+	testDomain = model.TenantDomain(model.DefaultTenantID)
+	testApis   = []*model.Api{
 		{
 			ID:          1,
 			Name:        "resource1",
@@ -51,11 +53,11 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
-	casbinManager = casbin.NewCasbinManager(enforcer)
+	casbinManager = casbin.NewCasbinManager(enforcer, nil)
 }
 
 func TestGetRole(t *testing.T) {
-	apis, err := casbinManager.GetRolePolicies(testRole)
+	apis, err := casbinManager.GetRolePolicies(testRole, testDomain)
 	if err != nil {
 		t.Fatalf("GetRolePolicies failed unexpectedly: %v", err)
 	}
@@ -66,7 +68,7 @@ func TestGetRole(t *testing.T) {
 
 func TestDeleteRolePolicy(t *testing.T) {
 	for _, api := range testApis {
-		_, err := casbinManager.DeleteRolePolicy(testRole, api)
+		_, err := casbinManager.DeleteRolePolicy(testRole, testDomain, api)
 		if err != nil {
 			t.Fatalf("DeleteRolePolicy failed unexpectedly: %v", err)
 		}
@@ -74,21 +76,21 @@ func TestDeleteRolePolicy(t *testing.T) {
 }
 
 func TestAddUserToRole(t *testing.T) {
-	_, err := casbinManager.AddUserToRole(testUser, testRole)
+	_, err := casbinManager.AddUserToRole(testUser, testRole, testDomain)
 	if err != nil {
 		t.Fatalf("AddUserToRole failed unexpectedly: %v", err)
 	}
 }
 
 func TestDeleteUserFromRole(t *testing.T) {
-	_, err := casbinManager.DeleteUserFromRole(testUser, testRole)
+	_, err := casbinManager.DeleteUserFromRole(testUser, testRole, testDomain)
 	if err != nil {
 		t.Fatalf("DeleteUserFromRole failed unexpectedly: %v", err)
 	}
 }
 
 func TestGetRolesForUser(t *testing.T) {
-	roles, err := casbinManager.GetRolesForUser(testUser)
+	roles, err := casbinManager.GetRolesForUser(testUser, testDomain)
 	if err != nil {
 		t.Fatalf("GetRolesForUser failed unexpectedly: %v", err)
 	}
@@ -98,7 +100,7 @@ func TestGetRolesForUser(t *testing.T) {
 }
 
 func TestCreateP(t *testing.T) {
-	_, err := casbinManager.AddRolePolicy("admin_p", &model.Api{
+	_, err := casbinManager.AddRolePolicy("admin_p", testDomain, &model.Api{
 		Path:   "*",
 		Method: "*",
 	})
@@ -108,7 +110,7 @@ func TestCreateP(t *testing.T) {
 }
 
 func TestAddRole(t *testing.T) {
-	_, err := casbinManager.AddUserToRole("admin_p", "admin")
+	_, err := casbinManager.AddUserToRole("admin_p", "admin", testDomain)
 	if err != nil {
 		t.Fatalf("AddUserToRole failed unexpectedly: %v", err)
 	}
@@ -0,0 +1,227 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/pkg/loginevent"
+	"github.com/yiran15/api-server/pkg/oauth"
+	"github.com/yiran15/api-server/pkg/session"
+	v1 "github.com/yiran15/api-server/service/v1"
+	"github.com/yiran15/api-server/store"
+	"github.com/yiran15/api-server/test/memcache"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+// identities 授权码对应的外部身份, staticDriver 使用授权码作为 token 查询
+var identities = map[string]*oauth.Identity{}
+
+type staticDriver struct{}
+
+func (staticDriver) AuthCodeURL(context.Context, *oauth.AuthParams) (string, error) {
+	return "https://idp.example.com/authorize", nil
+}
+
+func (staticDriver) Exchange(_ context.Context, code string, _ *oauth.AuthParams) (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: code}, nil
+}
+
+func (staticDriver) Identity(_ context.Context, token *oauth2.Token) (*oauth.Identity, error) {
+	identity, ok := identities[token.AccessToken]
+	if !ok {
+		return nil, errors.New("invalid code")
+	}
+	copied := *identity
+	return &copied, nil
+}
+
+func init() {
+	oauth.RegisterDriver("static", func(string, *oauth.OAuth2ProviderConfig) (oauth.ProviderDriver, error) {
+		return staticDriver{}, nil
+	})
+}
+
+type services struct {
+	db       *gorm.DB
+	user     v1.UserServicer
+	identity v1.IdentityServicer
+}
+
+func newServices(t *testing.T) *services {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.User{}, &model.Role{}, &model.Api{}, &model.Tenant{}, &model.UserIdentity{}, &model.UserMfa{}, &model.LoginEvent{}); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Set("jwt.secret", "test-secret")
+	viper.Set("jwt.expireTime", "1h")
+	viper.Set("jwt.refreshExpireTime", "24h")
+	viper.Set("jwt.algorithm", "HS256")
+	viper.Set("oauth2.enable", true)
+	viper.Set("oauth2.providers", map[string]any{
+		"idp":   map[string]any{"type": "static"},
+		"other": map[string]any{"type": "static"},
+	})
+	t.Cleanup(func() {
+		viper.Set("oauth2.enable", nil)
+		viper.Set("oauth2.providers", nil)
+	})
+	o, err := oauth.NewOAuth2()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := memcache.New()
+	generator, cleanup, err := jwt.NewGenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	sessions, err := session.NewManager(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := store.NewDBProvider(db)
+	userStore := store.NewUserStore(provider)
+	identityStore := store.NewUserIdentityStore(provider)
+	mfa, err := v1.NewMfaService(store.NewUserMfaStore(provider), userStore, generator, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &services{
+		db:       db,
+		user:     v1.NewUserService(userStore, store.NewRoleStore(provider), nil, cache, nil, generator, nil, nil, mfa, nil, nil, nil, sessions, nil, loginevent.NewRecorder(store.NewLoginEventStore(provider)), o, nil, identityStore, nil, nil),
+		identity: v1.NewIdentityService(identityStore, userStore, o, generator),
+	}
+}
+
+func (s *services) createUser(t *testing.T, user *model.User, identities ...*model.UserIdentity) {
+	t.Helper()
+	user.Status = helper.Int(model.UserStatusActive)
+	if err := s.db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, identity := range identities {
+		identity.UserID = user.ID
+		if err := s.db.Create(identity).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func providerContext(ctx context.Context, provider string) context.Context {
+	return context.WithValue(ctx, constant.ProviderContextKey, provider)
+}
+
+func userContext(userID int64) context.Context {
+	return context.WithValue(context.Background(), constant.UserContextKey, &jwt.JwtClaims{UserID: userID, TokenType: jwt.TokenTypeAccess})
+}
+
+func TestLinkIdentity(t *testing.T) {
+	s := newServices(t)
+	identities["alice"] = &oauth.Identity{Subject: "sub-alice"}
+	identities["bob"] = &oauth.Identity{Subject: "sub-bob"}
+	identities["bob2"] = &oauth.Identity{Subject: "sub-bob-2"}
+	s.createUser(t, &model.User{ID: 1, Name: "alice", Email: "alice@example.com"}, &model.UserIdentity{Provider: "idp", Subject: "sub-alice"})
+	s.createUser(t, &model.User{ID: 2, Name: "bob", Email: "bob@example.com"})
+
+	ctx := providerContext(userContext(2), "idp")
+	// 已关联其他用户的身份不能再关联
+	if _, err := s.identity.LinkIdentityCallback(ctx, &apitypes.OAuthLoginRequest{Code: "alice"}); !errors.Is(err, constant.ErrIdentityLinked) {
+		t.Fatalf("identity linked to another user should be rejected, got %v", err)
+	}
+
+	identity, err := s.identity.LinkIdentityCallback(ctx, &apitypes.OAuthLoginRequest{Code: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.UserID != 2 || identity.Provider != "idp" || identity.Subject != "sub-bob" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+	// 重复关联同一个身份只更新用户信息
+	if _, err := s.identity.LinkIdentityCallback(ctx, &apitypes.OAuthLoginRequest{Code: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	// 同一个 provider 只能关联一个身份
+	if _, err := s.identity.LinkIdentityCallback(ctx, &apitypes.OAuthLoginRequest{Code: "bob2"}); err == nil {
+		t.Fatal("second identity of the same provider should be rejected")
+	}
+	if _, err := s.identity.LinkIdentityCallback(providerContext(userContext(2), "other"), &apitypes.OAuthLoginRequest{Code: "bob2"}); err != nil {
+		t.Fatalf("identity of another provider should be linked, got %v", err)
+	}
+
+	linked, err := s.identity.ListIdentities(userContext(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(linked) != 2 {
+		t.Fatalf("expected 2 identities, got %d", len(linked))
+	}
+}
+
+func TestUnlinkIdentity(t *testing.T) {
+	s := newServices(t)
+	first, second := &model.UserIdentity{Provider: "idp", Subject: "sub-1"}, &model.UserIdentity{Provider: "other", Subject: "sub-2"}
+	s.createUser(t, &model.User{ID: 1, Name: "alice", Email: "alice@example.com"}, first, second)
+	ctx := userContext(1)
+
+	if err := s.identity.UnlinkIdentity(ctx, &apitypes.IDRequest{ID: first.ID}); err != nil {
+		t.Fatal(err)
+	}
+	// 没有密码的用户不能解除最后一个身份
+	if err := s.identity.UnlinkIdentity(ctx, &apitypes.IDRequest{ID: second.ID}); err == nil {
+		t.Fatal("last identity of user without password should not be unlinked")
+	}
+	if err := s.db.Model(&model.User{}).Where("id", 1).Update("password", "hashed").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.identity.UnlinkIdentity(ctx, &apitypes.IDRequest{ID: second.ID}); err != nil {
+		t.Fatalf("user with password should unlink last identity, got %v", err)
+	}
+	// 不能解除其他用户的身份
+	s.createUser(t, &model.User{ID: 2, Name: "bob", Email: "bob@example.com", Password: "hashed"}, &model.UserIdentity{Provider: "idp", Subject: "sub-3"})
+	var other model.UserIdentity
+	if err := s.db.Where("user_id", 2).First(&other).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.identity.UnlinkIdentity(ctx, &apitypes.IDRequest{ID: other.ID}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("identity of another user should not be found, got %v", err)
+	}
+}
+
+func TestLoginBySubject(t *testing.T) {
+	s := newServices(t)
+	s.createUser(t, &model.User{ID: 1, Name: "alice", Email: "alice@example.com"}, &model.UserIdentity{Provider: "idp", Subject: "sub-alice"})
+	// 用户在 provider 中修改了邮箱, 仍按 (provider, subject) 登录到原来的账号
+	identities["alice"] = &oauth.Identity{Subject: "sub-alice", Name: "alice", Email: "alice@new.example.com", EmailVerified: true}
+
+	res, err := s.user.OAuth2Callback(providerContext(context.Background(), "idp"), &apitypes.OAuthLoginRequest{Code: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.User.ID != 1 || res.Token == "" {
+		t.Fatalf("expected login as user 1, got %+v", res)
+	}
+	var users int64
+	if err := s.db.Model(&model.User{}).Count(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if users != 1 {
+		t.Fatalf("login with changed email should not create user, got %d users", users)
+	}
+}
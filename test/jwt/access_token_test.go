@@ -0,0 +1,49 @@
+package jwt_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/jwt"
+)
+
+func TestPersonalAccessClaims(t *testing.T) {
+	now := time.Now()
+	pat := &model.PersonalAccessToken{
+		ID:        1,
+		UserID:    1,
+		User:      &model.User{ID: 1, Name: "bot"},
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	userRoles := []string{"admin", "readOnly"}
+
+	claims, err := jwt.NewPersonalAccessClaims(pat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := claims.UsableRoles(slices.Clone(userRoles), nil); !slices.Equal(got, userRoles) {
+		t.Fatalf("unrestricted token should use all user roles, got %v", got)
+	}
+
+	pat.Restricted = true
+	pat.Roles = []*model.Role{{ID: 2, Name: "readOnly", TenantID: 2}}
+	claims, err = jwt.NewPersonalAccessClaims(pat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := claims.UsableRoles(slices.Clone(userRoles), nil); !slices.Equal(got, []string{"readOnly"}) {
+		t.Fatalf("restricted token should only use its roles, got %v", got)
+	}
+	if claims.Tenant() != 2 {
+		t.Fatalf("restricted token should use the tenant of its roles, got %d", claims.Tenant())
+	}
+
+	// 角色软删除后预加载不到, 令牌不能退化为使用用户的全部角色
+	pat.Roles = nil
+	if _, err := jwt.NewPersonalAccessClaims(pat); err == nil {
+		t.Fatal("restricted token without roles should be rejected")
+	}
+}
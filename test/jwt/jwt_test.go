@@ -0,0 +1,114 @@
+package jwt_test
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/pkg/jwt"
+)
+
+func newGenerateToken(t *testing.T) *jwt.GenerateToken {
+	t.Helper()
+	viper.Set("jwt.secret", "test-secret")
+	viper.Set("jwt.expireTime", "1h")
+	viper.Set("jwt.refreshExpireTime", "24h")
+	viper.Set("jwt.algorithm", "HS256")
+	g, cleanup, err := jwt.NewGenerateToken(nil)
+	if err != nil {
+		t.Fatalf("NewGenerateToken failed: %v", err)
+	}
+	t.Cleanup(cleanup)
+	return g
+}
+
+func TestGenerateTokenPair(t *testing.T) {
+	g := newGenerateToken(t)
+	pair, err := g.GenerateTokenPair(1, "admin")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair failed: %v", err)
+	}
+	if pair.AccessClaims.SessionID == "" || pair.AccessClaims.SessionID != pair.RefreshClaims.SessionID {
+		t.Fatalf("access and refresh token should share sid, got %q and %q", pair.AccessClaims.SessionID, pair.RefreshClaims.SessionID)
+	}
+
+	access, err := g.ParseToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken failed: %v", err)
+	}
+	if access.UserID != 1 || access.TokenType != jwt.TokenTypeAccess {
+		t.Fatalf("unexpected access claims: %+v", access)
+	}
+
+	refresh, err := g.ParseRefreshToken(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("ParseRefreshToken failed: %v", err)
+	}
+	if refresh.ID != pair.RefreshClaims.ID {
+		t.Fatalf("unexpected refresh jti: %s", refresh.ID)
+	}
+}
+
+func TestTokenTypeMismatch(t *testing.T) {
+	g := newGenerateToken(t)
+	pair, err := g.GenerateTokenPair(1, "admin")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair failed: %v", err)
+	}
+	if _, err := g.ParseToken(pair.RefreshToken); err == nil {
+		t.Fatal("refresh token should not be accepted as access token")
+	}
+	if _, err := g.ParseRefreshToken(pair.AccessToken); err == nil {
+		t.Fatal("access token should not be accepted as refresh token")
+	}
+}
+
+func TestRotateKeepSession(t *testing.T) {
+	g := newGenerateToken(t)
+	pair, err := g.GenerateTokenPair(1, "admin")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair failed: %v", err)
+	}
+	rotated, err := g.GenerateTokenPair(1, "admin", jwt.WithSessionID(pair.RefreshClaims.SessionID))
+	if err != nil {
+		t.Fatalf("GenerateTokenPair failed: %v", err)
+	}
+	if rotated.RefreshClaims.SessionID != pair.RefreshClaims.SessionID {
+		t.Fatalf("rotated token should keep sid")
+	}
+	if rotated.RefreshClaims.ID == pair.RefreshClaims.ID {
+		t.Fatalf("rotated token should have a new jti")
+	}
+}
+
+func TestMfaToken(t *testing.T) {
+	g := newGenerateToken(t)
+	token, _, err := g.GenerateMfaToken(1, "admin", jwt.WithAuthMethods(jwt.AuthMethodPassword))
+	if err != nil {
+		t.Fatalf("GenerateMfaToken failed: %v", err)
+	}
+	if _, err := g.ParseToken(token); err == nil {
+		t.Fatal("mfa token should not be accepted as access token")
+	}
+	claims, err := g.ParseMfaToken(token)
+	if err != nil {
+		t.Fatalf("ParseMfaToken failed: %v", err)
+	}
+	if !claims.HasAuthMethod(jwt.AuthMethodPassword) || claims.HasAuthMethod(jwt.AuthMethodOTP) {
+		t.Fatalf("unexpected amr: %v", claims.AuthMethods)
+	}
+
+	pair, err := g.GenerateTokenPair(1, "admin", jwt.WithAuthMethods(jwt.AuthMethodPassword, jwt.AuthMethodOTP))
+	if err != nil {
+		t.Fatalf("GenerateTokenPair failed: %v", err)
+	}
+	if _, err := g.ParseMfaToken(pair.AccessToken); err == nil {
+		t.Fatal("access token should not be accepted as mfa token")
+	}
+	access, err := g.ParseToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken failed: %v", err)
+	}
+	if !access.HasAuthMethod(jwt.AuthMethodOTP) {
+		t.Fatalf("expected otp in amr, got %v", access.AuthMethods)
+	}
+}
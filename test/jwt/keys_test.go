@@ -0,0 +1,118 @@
+package jwt_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/store"
+)
+
+// memoryKeyStore 内存版 JwtKeyStorer, 模拟多个副本共享的数据库
+type memoryKeyStore struct {
+	mu   sync.Mutex
+	keys []*model.JwtKey
+}
+
+func (s *memoryKeyStore) Create(_ context.Context, obj *model.JwtKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj.ID = int64(len(s.keys) + 1)
+	obj.CreatedAt = time.Now()
+	s.keys = append(s.keys, obj)
+	return nil
+}
+
+func (s *memoryKeyStore) Delete(_ context.Context, _ *model.JwtKey, _ ...store.Option) error {
+	return nil
+}
+
+func (s *memoryKeyStore) Query(_ context.Context, _ ...store.Option) (*model.JwtKey, error) {
+	return nil, nil
+}
+
+func (s *memoryKeyStore) List(_ context.Context, _, _ int, _, _ string, _ ...store.Option) (int64, []*model.JwtKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]*model.JwtKey, len(s.keys))
+	copy(keys, s.keys)
+	return int64(len(keys)), keys, nil
+}
+
+func newAsymmetricToken(t *testing.T, algorithm string, keyStore store.JwtKeyStorer) *jwt.GenerateToken {
+	t.Helper()
+	viper.Set("jwt.secret", "test-secret")
+	viper.Set("jwt.expireTime", "1h")
+	viper.Set("jwt.refreshExpireTime", "24h")
+	viper.Set("jwt.algorithm", algorithm)
+	g, cleanup, err := jwt.NewGenerateToken(keyStore)
+	if err != nil {
+		t.Fatalf("NewGenerateToken %s failed: %v", algorithm, err)
+	}
+	t.Cleanup(cleanup)
+	return g
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	for _, algorithm := range []string{"RS256", "ES256", "EdDSA"} {
+		t.Run(algorithm, func(t *testing.T) {
+			viper.Set("jwt.rotationInterval", "720h")
+			g := newAsymmetricToken(t, algorithm, &memoryKeyStore{})
+			token, err := g.GenerateToken(1, "admin")
+			if err != nil {
+				t.Fatalf("GenerateToken failed: %v", err)
+			}
+			claims, err := g.ParseToken(token)
+			if err != nil {
+				t.Fatalf("ParseToken failed: %v", err)
+			}
+			if claims.UserID != 1 {
+				t.Fatalf("unexpected user id %d", claims.UserID)
+			}
+
+			jwks := g.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Alg != algorithm || jwks.Keys[0].Kid == "" {
+				t.Fatalf("unexpected jwks: %+v", jwks)
+			}
+		})
+	}
+}
+
+func TestKeyRotationKeepsOldKeys(t *testing.T) {
+	keyStore := &memoryKeyStore{}
+	viper.Set("jwt.rotationInterval", "1ns")
+	first := newAsymmetricToken(t, "ES256", keyStore)
+	token, err := first.GenerateToken(1, "admin")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	// 第二个副本启动时发现密钥已超过轮换周期, 生成新密钥
+	second := newAsymmetricToken(t, "ES256", keyStore)
+	if _, err := second.ParseToken(token); err != nil {
+		t.Fatalf("token signed by rotated key should still be valid: %v", err)
+	}
+	if n := len(second.JWKS().Keys); n != 2 {
+		t.Fatalf("expected 2 published keys after rotation, got %d", n)
+	}
+
+	// 第一个副本遇到未知 kid 时从数据库重新加载密钥
+	rotated, err := second.GenerateToken(1, "admin")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	if _, err := first.ParseToken(rotated); err != nil {
+		t.Fatalf("token signed by new key should be accepted after reload: %v", err)
+	}
+}
+
+func TestHS256PublishesNoKeys(t *testing.T) {
+	g := newGenerateToken(t)
+	if n := len(g.JWKS().Keys); n != 0 {
+		t.Fatalf("HS256 should not publish keys, got %d", n)
+	}
+}
@@ -0,0 +1,99 @@
+package jwt_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/test/memcache"
+)
+
+func newRevoker(t *testing.T) jwt.Revoker {
+	t.Helper()
+	viper.Set("jwt.expireTime", "1h")
+	viper.Set("jwt.refreshExpireTime", "24h")
+	revoker, err := jwt.NewRevoker(memcache.New())
+	if err != nil {
+		t.Fatalf("NewRevoker failed: %v", err)
+	}
+	return revoker
+}
+
+func newClaims(userID int64, jti string, issuedAt time.Time) *jwt.JwtClaims {
+	return &jwt.JwtClaims{
+		UserID: userID,
+		RegisteredClaims: &jwtv5.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwtv5.NewNumericDate(issuedAt),
+			ExpiresAt: jwtv5.NewNumericDate(issuedAt.Add(time.Hour)),
+		},
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	ctx := context.Background()
+	revoker := newRevoker(t)
+	now := time.Now()
+	revoked, other := newClaims(1, "jti-1", now), newClaims(1, "jti-2", now)
+
+	if err := revoker.RevokeToken(ctx, revoked); err != nil {
+		t.Fatalf("RevokeToken failed: %v", err)
+	}
+	if ok, err := revoker.IsRevoked(ctx, revoked); err != nil || !ok {
+		t.Fatalf("revoked jti should be rejected, got %v, %v", ok, err)
+	}
+	if ok, err := revoker.IsRevoked(ctx, other); err != nil || ok {
+		t.Fatalf("other jti should not be rejected, got %v, %v", ok, err)
+	}
+}
+
+func TestRevokeUser(t *testing.T) {
+	ctx := context.Background()
+	revoker := newRevoker(t)
+	// 避开秒的边界, 保证吊销前后签发的 token 在同一秒内
+	if ms := time.Now().Nanosecond() / int(time.Millisecond); ms > 900 {
+		time.Sleep(time.Duration(1000-ms) * time.Millisecond)
+	}
+	before := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	if err := revoker.RevokeUser(ctx, 1); err != nil {
+		t.Fatalf("RevokeUser failed: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	after := time.Now()
+
+	// 吊销之前签发的 token 失效, 吊销之后同一秒内重新登录签发的 token 不受影响
+	tests := []struct {
+		claims *jwt.JwtClaims
+		want   bool
+	}{
+		{newClaims(1, "before", before.Add(-time.Minute)), true},
+		{newClaims(1, "just-before", before), true},
+		{newClaims(1, "after", after), false},
+		{newClaims(2, "other-user", before.Add(-time.Minute)), false},
+	}
+	for _, tt := range tests {
+		ok, err := revoker.IsRevoked(ctx, tt.claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want {
+			t.Errorf("IsRevoked(user %d, %s) = %v, want %v", tt.claims.UserID, tt.claims.ID, ok, tt.want)
+		}
+	}
+	// 签发时间写入 token 后仍然精确到毫秒
+	pair, err := newGenerateToken(t).GenerateTokenPair(1, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := newGenerateToken(t).ParseToken(pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := revoker.IsRevoked(ctx, claims); err != nil || ok {
+		t.Fatalf("token issued after revocation should be accepted, got %v, %v", ok, err)
+	}
+}
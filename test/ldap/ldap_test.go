@@ -0,0 +1,279 @@
+package ldap_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jimlambrt/gldap"
+	"github.com/jimlambrt/gldap/testdirectory"
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/ldap"
+	"github.com/yiran15/api-server/pkg/oauth"
+)
+
+const (
+	baseDN          = "dc=example,dc=com"
+	serviceDN       = "cn=svc,dc=example,dc=com"
+	servicePassword = "svc-password"
+)
+
+type entry struct {
+	dn       string
+	upn      string
+	password string
+	attrs    map[string][]string
+}
+
+// directory 进程内的 LDAP 服务, 只实现简单绑定、查询和 StartTLS
+type directory struct {
+	url     string
+	entries []*entry
+
+	mu    sync.Mutex
+	bound map[int]bool
+}
+
+func startDirectory(t *testing.T) *directory {
+	d := &directory{
+		bound: make(map[int]bool),
+		entries: []*entry{
+			{
+				dn: "uid=alice,ou=people,dc=example,dc=com", upn: "alice@example.com", password: "alice-password",
+				attrs: map[string][]string{
+					"uid":         {"alice"},
+					"mail":        {"alice@example.com"},
+					"displayName": {"Alice Liu"},
+					"mobile":      {"13800000000"},
+					"department":  {"platform"},
+					"entryUUID":   {"5d0c7a1e-0001"},
+					"memberOf":    {"cn=sre,ou=groups,dc=example,dc=com", "cn=ldap-admins,ou=groups,dc=example,dc=com"},
+				},
+			},
+			{
+				dn: "uid=bob,ou=people,dc=example,dc=com", upn: "bob@example.com", password: "bob-password",
+				attrs: map[string][]string{
+					"uid":  {"bob"},
+					"mail": {"bob@example.com"},
+				},
+			},
+		},
+	}
+	serverTLS, _ := testdirectory.GetTLSConfig(t)
+
+	mux, err := gldap.NewMux()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.Bind(d.handleBind); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.Search(d.handleSearch); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.ExtendedOperation(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		res := r.NewExtendedResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+		res.SetResponseName(gldap.ExtendedOperationStartTLS)
+		if err := w.Write(res); err == nil {
+			_ = r.StartTLS(serverTLS)
+		}
+	}, gldap.ExtendedOperationStartTLS); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := gldap.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Router(mux); err != nil {
+		t.Fatal(err)
+	}
+	port := testdirectory.FreePort(t)
+	go func() { _ = s.Run(fmt.Sprintf("127.0.0.1:%d", port)) }()
+	t.Cleanup(func() { _ = s.Stop() })
+	for !s.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	d.url = fmt.Sprintf("ldap://127.0.0.1:%d", port)
+	return d
+}
+
+func (d *directory) handleBind(w *gldap.ResponseWriter, r *gldap.Request) {
+	res := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+	defer func() { _ = w.Write(res) }()
+	m, err := r.GetSimpleBindMessage()
+	if err != nil || m.Password == "" {
+		return
+	}
+	ok := m.UserName == serviceDN && string(m.Password) == servicePassword
+	for _, e := range d.entries {
+		if (m.UserName == e.dn || m.UserName == e.upn) && string(m.Password) == e.password {
+			ok = true
+		}
+	}
+	if ok {
+		d.mu.Lock()
+		d.bound[r.ConnectionID()] = true
+		d.mu.Unlock()
+		res.SetResultCode(gldap.ResultSuccess)
+	}
+}
+
+// handleSearch 只返回过滤器中 uid 或 mail 完全匹配的用户, 未绑定的连接不能查询
+func (d *directory) handleSearch(w *gldap.ResponseWriter, r *gldap.Request) {
+	res := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultInsufficientAccessRights))
+	defer func() { _ = w.Write(res) }()
+	m, err := r.GetSearchMessage()
+	if err != nil {
+		return
+	}
+	d.mu.Lock()
+	bound := d.bound[r.ConnectionID()]
+	d.mu.Unlock()
+	if !bound {
+		return
+	}
+	for _, e := range d.entries {
+		if !strings.HasSuffix(e.dn, m.BaseDN) {
+			continue
+		}
+		if !strings.Contains(m.Filter, "(uid="+e.attrs["uid"][0]+")") && !strings.Contains(m.Filter, "(mail="+e.attrs["mail"][0]+")") {
+			continue
+		}
+		result := r.NewSearchResponseEntry(e.dn)
+		for name, values := range e.attrs {
+			result.AddAttribute(name, values)
+		}
+		_ = w.Write(result)
+	}
+	res.SetResultCode(gldap.ResultSuccess)
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	d := startDirectory(t)
+	l, err := ldap.New(&ldap.Config{
+		URL:          d.url,
+		BindDN:       serviceDN,
+		BindPassword: servicePassword,
+		BaseDN:       baseDN,
+		Mapping: oauth.MappingConfig{Roles: oauth.RoleMapping{
+			Sync:  true,
+			Rules: []oauth.RoleRule{{Group: "cn=ldap-admins,*", Roles: []string{"admin"}}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for _, account := range []string{"alice", "alice@example.com"} {
+		identity, err := l.Authenticate(ctx, account, "alice-password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if identity.Provider != ldap.Provider || identity.Subject != "5d0c7a1e-0001" || identity.Name != "alice" || identity.NickName != "Alice Liu" ||
+			identity.Email != "alice@example.com" || !identity.EmailVerified || identity.Mobile != "13800000000" {
+			t.Fatalf("unexpected identity %+v", identity)
+		}
+	}
+
+	identity, err := l.Authenticate(ctx, "alice", "alice-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &model.User{}
+	if err := l.Mapper().MapUser(identity.Claims, user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Department != "platform" {
+		t.Fatalf("unexpected department %q", user.Department)
+	}
+	roles, ok := l.Mapper().MapRoles(identity.Claims)
+	if !ok || !slices.Equal(roles, []string{"admin"}) {
+		t.Fatalf("unexpected roles %v", roles)
+	}
+
+	// 没有 entryUUID 时使用 DN 作为标识
+	identity, err = l.Authenticate(ctx, "bob", "bob-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Subject != "uid=bob,ou=people,dc=example,dc=com" {
+		t.Fatalf("unexpected subject %s", identity.Subject)
+	}
+
+	for _, c := range []struct {
+		username, password string
+		want               error
+	}{
+		{"alice", "wrong-password", ldap.ErrInvalidCredentials},
+		{"alice", "", ldap.ErrInvalidCredentials},
+		{"carol", "carol-password", ldap.ErrUserNotFound},
+		// 用户名中的过滤器字符需要转义
+		{"*", "alice-password", ldap.ErrUserNotFound},
+		{"alice)(uid=bob", "bob-password", ldap.ErrUserNotFound},
+	} {
+		if _, err := l.Authenticate(ctx, c.username, c.password); !errors.Is(err, c.want) {
+			t.Errorf("Authenticate(%q) error = %v, want %v", c.username, err, c.want)
+		}
+	}
+}
+
+func TestLDAPDirectBind(t *testing.T) {
+	d := startDirectory(t)
+	l, err := ldap.New(&ldap.Config{URL: d.url, BindDN: "{username}@example.com", BaseDN: baseDN, StartTLS: true, InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	identity, err := l.Authenticate(ctx, "bob", "bob-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Email != "bob@example.com" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+	if _, err := l.Authenticate(ctx, "bob", "alice-password"); !errors.Is(err, ldap.ErrInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+}
+
+func TestLDAPConfig(t *testing.T) {
+	defer viper.Set("ldap", nil)
+	viper.Set("ldap", map[string]any{"enable": false, "url": "ldap://127.0.0.1:389"})
+	if l, err := ldap.NewLDAP(); err != nil || l != nil {
+		t.Fatalf("expected disabled ldap, got %v %v", l, err)
+	}
+
+	viper.Set("ldap", map[string]any{"enable": true, "url": "ldap://127.0.0.1:389", "baseDN": baseDN})
+	l, err := ldap.NewLDAP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(l.Backends(), []string{ldap.BackendLocal, ldap.BackendLDAP}) {
+		t.Fatalf("unexpected backends %v", l.Backends())
+	}
+	viper.Set("ldap.precedence", ldap.BackendLDAP)
+	if l, err = ldap.NewLDAP(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(l.Backends(), []string{ldap.BackendLDAP, ldap.BackendLocal}) {
+		t.Fatalf("unexpected backends %v", l.Backends())
+	}
+
+	for name, config := range map[string]*ldap.Config{
+		"missing baseDN":      {URL: "ldap://127.0.0.1:389"},
+		"filter without user": {URL: "ldap://127.0.0.1:389", BaseDN: baseDN, Filter: "(objectClass=person)"},
+		"invalid precedence":  {URL: "ldap://127.0.0.1:389", BaseDN: baseDN, Precedence: "remote"},
+	} {
+		if _, err := ldap.New(config); err == nil {
+			t.Errorf("%s: expected config error", name)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package loginevent_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/jwt"
+	"github.com/yiran15/api-server/pkg/loginevent"
+	"github.com/yiran15/api-server/pkg/loginguard"
+	"github.com/yiran15/api-server/pkg/password"
+	"github.com/yiran15/api-server/pkg/session"
+	v1 "github.com/yiran15/api-server/service/v1"
+	"github.com/yiran15/api-server/store"
+	"github.com/yiran15/api-server/test/memcache"
+)
+
+const (
+	email      = "user@example.com"
+	userPasswd = "Passw0rd!"
+	mfaCode    = "123456"
+)
+
+// userStore 只返回同一个用户
+type userStore struct {
+	store.UserStorer
+	user *model.User
+}
+
+func (s *userStore) Query(context.Context, ...store.Option) (*model.User, error) {
+	user := *s.user
+	return &user, nil
+}
+
+// eventStore 保存写入的登录事件
+type eventStore struct {
+	store.LoginEventStorer
+	mu     sync.Mutex
+	events []*model.LoginEvent
+}
+
+func (s *eventStore) Create(_ context.Context, obj *model.LoginEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, obj)
+	return nil
+}
+
+type mfaService struct {
+	v1.MfaServicer
+	enabled bool
+}
+
+func (s *mfaService) MfaEnabled(context.Context, int64) (bool, error) {
+	return s.enabled, nil
+}
+
+func (s *mfaService) VerifyMfa(_ context.Context, _ int64, code string) (bool, error) {
+	return code == mfaCode, nil
+}
+
+func newUserService(t *testing.T, mfaEnabled bool) (v1.UserServicer, *eventStore) {
+	t.Helper()
+	viper.Set("jwt.secret", "test-secret")
+	viper.Set("jwt.expireTime", "1h")
+	viper.Set("jwt.refreshExpireTime", "24h")
+	viper.Set("jwt.algorithm", "HS256")
+
+	cache := memcache.New()
+	generator, cleanup, err := jwt.NewGenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	revoker, err := jwt.NewRevoker(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard, err := loginguard.NewGuard(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := session.NewManager(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher, err := password.NewHasher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed, err := hasher.Hash(userPasswd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := &userStore{user: &model.User{ID: 1, Name: "user", Email: email, Password: hashed, Status: helper.Int(model.UserStatusActive)}}
+	events := &eventStore{}
+	svc := v1.NewUserService(users, nil, nil, cache, nil, generator, revoker, nil, &mfaService{enabled: mfaEnabled}, guard, nil, hasher, sessions, nil, loginevent.NewRecorder(events), nil, nil, nil, nil, nil)
+	return svc, events
+}
+
+func assertEvent(t *testing.T, event *model.LoginEvent, name, result, reason string) {
+	t.Helper()
+	if event.Event != name || event.Result != result || event.Reason != reason || event.UserID != 1 {
+		t.Fatalf("unexpected event %+v, want %s %s %s", event, name, result, reason)
+	}
+}
+
+func TestLoginEvents(t *testing.T) {
+	ctx := context.Background()
+	svc, events := newUserService(t, false)
+
+	if _, err := svc.Login(ctx, &apitypes.UserLoginRequest{Email: email, Password: "wrong"}); err == nil {
+		t.Fatal("login with wrong password should fail")
+	}
+	if _, err := svc.Login(ctx, &apitypes.UserLoginRequest{Email: email, Password: userPasswd}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events.events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events.events))
+	}
+	assertEvent(t, events.events[0], loginevent.EventLogin, model.LoginResultFailure, loginevent.ReasonInvalidPassword)
+	assertEvent(t, events.events[1], loginevent.EventLogin, model.LoginResultSuccess, "")
+}
+
+func TestLoginMfaEvents(t *testing.T) {
+	ctx := context.Background()
+	svc, events := newUserService(t, true)
+
+	res, err := svc.Login(ctx, &apitypes.UserLoginRequest{Email: email, Password: userPasswd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.MfaRequired || res.MfaToken == "" {
+		t.Fatalf("login should require mfa, got %+v", res)
+	}
+	if _, err := svc.LoginMfa(ctx, &apitypes.UserLoginMfaRequest{MfaToken: res.MfaToken, Code: "000000"}); err == nil {
+		t.Fatal("login with wrong mfa code should fail")
+	}
+	if _, err := svc.LoginMfa(ctx, &apitypes.UserLoginMfaRequest{MfaToken: res.MfaToken, Code: mfaCode}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events.events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events.events))
+	}
+	assertEvent(t, events.events[0], loginevent.EventLogin, model.LoginResultSuccess, loginevent.ReasonMfaRequired)
+	assertEvent(t, events.events[1], loginevent.EventLoginMfa, model.LoginResultFailure, loginevent.ReasonInvalidCode)
+	assertEvent(t, events.events[2], loginevent.EventLoginMfa, model.LoginResultSuccess, "")
+}
+
+func TestLoginMfaTokenUsedOnce(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newUserService(t, true)
+
+	res, err := svc.Login(ctx, &apitypes.UserLoginRequest{Email: email, Password: userPasswd})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 并发使用同一个 mfa token, 只有一个请求能登录成功
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.LoginMfa(ctx, &apitypes.UserLoginMfaRequest{MfaToken: res.MfaToken, Code: mfaCode}); err == nil {
+				succeeded.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := succeeded.Load(); n != 1 {
+		t.Fatalf("mfa token should be used once, got %d successful logins", n)
+	}
+}
@@ -0,0 +1,93 @@
+package loginguard_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/pkg/loginguard"
+	"github.com/yiran15/api-server/test/memcache"
+)
+
+const (
+	email = "user@example.com"
+	ip    = "127.0.0.1"
+)
+
+func newGuard(t *testing.T) loginguard.Guard {
+	t.Helper()
+	viper.Set("login.failureWindow", "100ms")
+	viper.Set("login.maxEmailFailures", 3)
+	viper.Set("login.maxIPFailures", 5)
+	viper.Set("login.lockoutDuration", "50ms")
+	viper.Set("login.maxLockoutDuration", "1s")
+	guard, err := loginguard.NewGuard(memcache.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return guard
+}
+
+func fail(t *testing.T, guard loginguard.Guard, email, ip string, n int) {
+	t.Helper()
+	for range n {
+		if err := guard.Fail(context.Background(), email, ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func assertLocked(t *testing.T, guard loginguard.Guard, email, ip string, want bool) {
+	t.Helper()
+	err := guard.Check(context.Background(), email, ip)
+	if locked := errors.Is(err, constant.ErrTooManyLoginAttempts); locked != want || (!locked && err != nil) {
+		t.Fatalf("Check(%s, %s) = %v, want locked %v", email, ip, err, want)
+	}
+}
+
+func TestLockoutThreshold(t *testing.T) {
+	guard := newGuard(t)
+	fail(t, guard, email, "", 2)
+	assertLocked(t, guard, email, "", false)
+
+	fail(t, guard, email, "", 1)
+	assertLocked(t, guard, email, "", true)
+	// 邮箱大小写和空格不影响锁定
+	assertLocked(t, guard, " USER@example.com ", "", true)
+	assertLocked(t, guard, "other@example.com", "", false)
+
+	time.Sleep(60 * time.Millisecond)
+	assertLocked(t, guard, email, "", false)
+}
+
+func TestFailureWindowExpiry(t *testing.T) {
+	guard := newGuard(t)
+	fail(t, guard, email, "", 2)
+	time.Sleep(110 * time.Millisecond)
+	// 窗口外的失败不再计数
+	fail(t, guard, email, "", 2)
+	assertLocked(t, guard, email, "", false)
+}
+
+func TestSucceedResetsFailures(t *testing.T) {
+	guard := newGuard(t)
+	fail(t, guard, email, "", 2)
+	if err := guard.Succeed(context.Background(), email); err != nil {
+		t.Fatal(err)
+	}
+	fail(t, guard, email, "", 2)
+	assertLocked(t, guard, email, "", false)
+}
+
+func TestIPFailures(t *testing.T) {
+	guard := newGuard(t)
+	// 不同邮箱的失败同样计入 IP
+	for _, e := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+		fail(t, guard, e, ip, 1)
+	}
+	assertLocked(t, guard, "f@example.com", ip, true)
+	assertLocked(t, guard, "f@example.com", "10.0.0.1", false)
+}
@@ -0,0 +1,56 @@
+package mailer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/pkg/mailer"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	viper.Set("mail.driver", "file")
+	viper.Set("mail.from", "api-server <noreply@example.com>")
+	viper.Set("mail.file.dir", dir)
+	defer viper.Set("mail.driver", "")
+
+	m, err := mailer.NewMailer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Send(context.Background(), &mailer.Message{
+		To:      []string{"admin@qqlx.net"},
+		Subject: "重置密码",
+		Body:    "line1\nline2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one mail file, got %v %v", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	for _, want := range []string{"To: admin@qqlx.net\r\n", "Subject: =?utf-8?q?", "\r\n\r\nline1\r\nline2"} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("mail content missing %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestInvalidDriver(t *testing.T) {
+	viper.Set("mail.driver", "pigeon")
+	defer viper.Set("mail.driver", "")
+	if _, err := mailer.NewMailer(); err == nil {
+		t.Fatal("expected error for invalid driver")
+	}
+}
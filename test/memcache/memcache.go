@@ -0,0 +1,295 @@
+// Package memcache 基于内存的 store.CacheStorer, 供不依赖 redis 的测试使用, 语义与 store.CacheStore 一致
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yiran15/api-server/store"
+)
+
+type item struct {
+	value    string
+	set      map[string]struct{}
+	window   []time.Time
+	expireAt time.Time
+}
+
+func (i *item) expired(now time.Time) bool {
+	return !i.expireAt.IsZero() && !now.Before(i.expireAt)
+}
+
+type Cache struct {
+	mu    sync.Mutex
+	items map[string]*item
+	// expireTime SetString 未指定过期时间时使用的默认过期时间
+	expireTime time.Duration
+}
+
+var _ store.CacheStorer = (*Cache)(nil)
+
+func New() *Cache {
+	return &Cache{items: make(map[string]*item), expireTime: time.Hour}
+}
+
+func buildKey(cacheType store.CacheType, cacheKey any) (string, error) {
+	switch v := cacheKey.(type) {
+	case string:
+		return string(cacheType) + ":" + v, nil
+	case int:
+		return string(cacheType) + ":" + strconv.Itoa(v), nil
+	case int64:
+		return string(cacheType) + ":" + strconv.FormatInt(v, 10), nil
+	default:
+		return "", fmt.Errorf("unsupported cacheKey type: %v", cacheKey)
+	}
+}
+
+// get 返回未过期的 key, 调用方需要持有锁
+func (c *Cache) get(key string) *item {
+	i, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	if i.expired(time.Now()) {
+		delete(c.items, key)
+		return nil
+	}
+	return i
+}
+
+func expireAt(expire time.Duration) time.Time {
+	if expire <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(expire)
+}
+
+func (c *Cache) DelKey(_ context.Context, cacheType store.CacheType, cacheKey any) error {
+	key, err := buildKey(cacheType, cacheKey)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+	return nil
+}
+
+func (c *Cache) GetSet(_ context.Context, cacheType store.CacheType, cacheKey any) ([]string, error) {
+	key, err := buildKey(cacheType, cacheKey)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.get(key)
+	if i == nil {
+		return nil, nil
+	}
+	members := make([]string, 0, len(i.set))
+	for m := range i.set {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+func (c *Cache) SetSet(_ context.Context, cacheType store.CacheType, cacheKey any, cacheValue []any, expireTime *time.Duration) error {
+	if cacheValue == nil {
+		return fmt.Errorf("cacheValue cannot be nil")
+	}
+	key, err := buildKey(cacheType, cacheKey)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.get(key)
+	if i == nil {
+		i = &item{set: make(map[string]struct{})}
+		c.items[key] = i
+	}
+	for _, v := range cacheValue {
+		i.set[fmt.Sprint(v)] = struct{}{}
+	}
+	if expireTime != nil {
+		i.expireAt = expireAt(*expireTime)
+	}
+	return nil
+}
+
+func (c *Cache) RemSet(_ context.Context, cacheType store.CacheType, cacheKey any, cacheValue ...any) error {
+	key, err := buildKey(cacheType, cacheKey)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i := c.get(key); i != nil {
+		for _, v := range cacheValue {
+			delete(i.set, fmt.Sprint(v))
+		}
+	}
+	return nil
+}
+
+func (c *Cache) GetString(_ context.Context, cacheType store.CacheType, cacheKey any) (string, error) {
+	key, err := buildKey(cacheType, cacheKey)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i := c.get(key); i != nil {
+		return i.value, nil
+	}
+	return "", nil
+}
+
+func (c *Cache) SetString(_ context.Context, cacheType store.CacheType, cacheKey any, cacheValue string, expireTime *time.Duration) error {
+	key, err := buildKey(cacheType, cacheKey)
+	if err != nil {
+		return err
+	}
+	expire := c.expireTime
+	if expireTime != nil {
+		expire = *expireTime
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = &item{value: cacheValue, expireAt: expireAt(expire)}
+	return nil
+}
+
+func (c *Cache) SetStringNX(_ context.Context, cacheType store.CacheType, cacheKey any, cacheValue string, expireTime *time.Duration) (bool, error) {
+	key, err := buildKey(cacheType, cacheKey)
+	if err != nil {
+		return false, err
+	}
+	expire := c.expireTime
+	if expireTime != nil {
+		expire = *expireTime
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.get(key) != nil {
+		return false, nil
+	}
+	c.items[key] = &item{value: cacheValue, expireAt: expireAt(expire)}
+	return true, nil
+}
+
+func (c *Cache) GetDelString(_ context.Context, cacheType store.CacheType, cacheKey any) (string, error) {
+	key, err := buildKey(cacheType, cacheKey)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.get(key)
+	if i == nil {
+		return "", nil
+	}
+	delete(c.items, key)
+	return i.value, nil
+}
+
+func (c *Cache) CompareAndSwap(_ context.Context, cacheType store.CacheType, cacheKey any, oldValue, newValue string, expireTime *time.Duration) (bool, error) {
+	key, err := buildKey(cacheType, cacheKey)
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.get(key)
+	if i == nil || i.value != oldValue {
+		return false, nil
+	}
+	i.value = newValue
+	if expireTime != nil && *expireTime > 0 {
+		i.expireAt = expireAt(*expireTime)
+	}
+	return true, nil
+}
+
+func (c *Cache) Incr(_ context.Context, cacheType store.CacheType, cacheKey any, expireTime time.Duration) (int64, error) {
+	key, err := buildKey(cacheType, cacheKey)
+	if err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.get(key)
+	if i == nil {
+		i = &item{value: "0", expireAt: expireAt(expireTime)}
+		c.items[key] = i
+	}
+	n, err := strconv.ParseInt(i.value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("incr error: %w", err)
+	}
+	n++
+	i.value = strconv.FormatInt(n, 10)
+	return n, nil
+}
+
+func (c *Cache) SlidingWindowAdd(_ context.Context, cacheType store.CacheType, cacheKey any, window time.Duration) (int64, error) {
+	res, err := c.slidingWindow(cacheType, cacheKey, window, -1)
+	if err != nil {
+		return 0, err
+	}
+	return res.Count, nil
+}
+
+func (c *Cache) SlidingWindowCount(_ context.Context, cacheType store.CacheType, cacheKey any, window time.Duration) (int64, error) {
+	res, err := c.slidingWindow(cacheType, cacheKey, window, 0)
+	if err != nil {
+		return 0, err
+	}
+	return res.Count, nil
+}
+
+func (c *Cache) SlidingWindowAllow(_ context.Context, cacheType store.CacheType, cacheKey any, limit int64, window time.Duration) (*store.WindowResult, error) {
+	return c.slidingWindow(cacheType, cacheKey, window, limit)
+}
+
+// slidingWindow limit 小于 0 时总是添加记录, 等于 0 时只统计, 大于 0 时记录数小于 limit 才添加
+func (c *Cache) slidingWindow(cacheType store.CacheType, cacheKey any, window time.Duration, limit int64) (*store.WindowResult, error) {
+	key, err := buildKey(cacheType, cacheKey)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	i := c.get(key)
+	if i == nil {
+		i = &item{}
+		c.items[key] = i
+	}
+	kept := i.window[:0]
+	for _, t := range i.window {
+		if now.Sub(t) < window {
+			kept = append(kept, t)
+		}
+	}
+	i.window = kept
+
+	res := &store.WindowResult{}
+	if limit < 0 || (limit > 0 && int64(len(i.window)) < limit) {
+		i.window = append(i.window, now)
+		i.expireAt = now.Add(window)
+		res.Allowed = true
+	}
+	res.Count = int64(len(i.window))
+	if len(i.window) > 0 {
+		res.ResetAfter = i.window[0].Add(window).Sub(now)
+	}
+	return res, nil
+}
@@ -0,0 +1,83 @@
+package mfa_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/totp"
+	v1 "github.com/yiran15/api-server/service/v1"
+	"github.com/yiran15/api-server/store"
+	"gorm.io/gorm"
+)
+
+const (
+	jwtSecret    = "test-secret"
+	recoveryCode = "abcde-fghij"
+)
+
+func newMfaService(t *testing.T, secret string) v1.MfaServicer {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.UserMfa{}); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("jwt.secret", jwtSecret)
+
+	box, err := helper.NewSecretBox(jwtSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := box.Seal([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("abcdefghij"))
+	mfa := &model.UserMfa{UserID: 1, Secret: sealed, Enabled: true, RecoveryCodes: []string{hex.EncodeToString(sum[:])}}
+	if err := db.Create(mfa).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	svc, err := v1.NewMfaService(store.NewUserMfaStore(store.NewDBProvider(db)), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func TestVerifyMfaReplay(t *testing.T) {
+	ctx := context.Background()
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := newMfaService(t, secret)
+
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := svc.VerifyMfa(ctx, 1, code); err != nil || !ok {
+		t.Fatalf("first use of code should pass, got %v %v", ok, err)
+	}
+	if ok, err := svc.VerifyMfa(ctx, 1, code); err != nil || ok {
+		t.Fatalf("replayed code should be rejected, got %v %v", ok, err)
+	}
+
+	if ok, err := svc.VerifyMfa(ctx, 1, recoveryCode); err != nil || !ok {
+		t.Fatalf("first use of recovery code should pass, got %v %v", ok, err)
+	}
+	if ok, err := svc.VerifyMfa(ctx, 1, recoveryCode); err != nil || ok {
+		t.Fatalf("used recovery code should be rejected, got %v %v", ok, err)
+	}
+}
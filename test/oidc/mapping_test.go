@@ -0,0 +1,106 @@
+package oidc_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/oauth"
+)
+
+func TestMapping(t *testing.T) {
+	viper.Set("oauth2.enable", true)
+	viper.Set("oauth2.providers", map[string]any{
+		"keycloak": map[string]any{
+			"clientId": "api-server",
+			"mapping": map[string]any{
+				"user": map[string]any{
+					"name":       "$.preferred_username",
+					"nickName":   "{{.family_name}}{{.given_name}}",
+					"department": `{{join "/" .groups}}`,
+					"mobile":     "phones[0]",
+					"avatar":     "{{.picture}}",
+				},
+				"roles": map[string]any{
+					"claim": "realm_access.roles",
+					"sync":  true,
+					"rules": []map[string]any{
+						{"group": "platform-admin", "roles": []string{"admin"}},
+						{"group": "dev-*", "roles": []string{"dev", "viewer"}},
+						{"group": "*", "roles": []string{"viewer"}},
+					},
+				},
+			},
+		},
+	})
+	defer func() {
+		viper.Set("oauth2.enable", nil)
+		viper.Set("oauth2.providers", nil)
+	}()
+
+	o, err := oauth.NewOAuth2()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapper := o.Mapper("keycloak")
+	if !mapper.SyncRoles() {
+		t.Fatal("expected sync roles to be enabled")
+	}
+
+	claims := map[string]any{
+		"preferred_username": "alice",
+		"family_name":        "Liu",
+		"given_name":         "Alice",
+		"groups":             []any{"platform", "sre"},
+		"phones":             []any{"13800000000", "13900000000"},
+		"realm_access":       map[string]any{"roles": []any{"dev-backend", "platform-admin"}},
+	}
+
+	user := &model.User{Name: "default", Email: "alice@example.com", Avatar: "default.png"}
+	if err := mapper.MapUser(claims, user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "alice" || user.NickName != "LiuAlice" || user.Department != "platform/sre" || user.Mobile != "13800000000" {
+		t.Fatalf("unexpected mapped user %+v", user)
+	}
+	// 表达式结果为空时保留原值
+	if user.Email != "alice@example.com" || user.Avatar != "default.png" {
+		t.Fatalf("unmapped fields should be kept, got %+v", user)
+	}
+
+	roles, ok := mapper.MapRoles(claims)
+	if !ok {
+		t.Fatal("expected role mapping to be configured")
+	}
+	slices.Sort(roles)
+	if !slices.Equal(roles, []string{"admin", "dev", "viewer"}) {
+		t.Fatalf("unexpected roles %v", roles)
+	}
+
+	// 没有配置映射的 provider 不修改用户, 也不返回角色
+	if _, ok := o.Mapper("feishu").MapRoles(claims); ok {
+		t.Fatal("expected no role mapping for unknown provider")
+	}
+}
+
+func TestMappingInvalidConfig(t *testing.T) {
+	defer func() {
+		viper.Set("oauth2.enable", nil)
+		viper.Set("oauth2.providers", nil)
+	}()
+	for name, mapping := range map[string]map[string]any{
+		"sync without rules": {"roles": map[string]any{"sync": true}},
+		"rules without claim": {"roles": map[string]any{
+			"rules": []map[string]any{{"group": "dev", "roles": []string{"dev"}}},
+		}},
+		"invalid template": {"user": map[string]any{"name": "{{.name"}},
+		"invalid path":     {"user": map[string]any{"name": "groups[x]"}},
+	} {
+		viper.Set("oauth2.enable", true)
+		viper.Set("oauth2.providers", map[string]any{"keycloak": map[string]any{"mapping": mapping}})
+		if _, err := oauth.NewOAuth2(); err == nil {
+			t.Errorf("%s: expected config error", name)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/pkg/oauth"
+)
+
+const (
+	clientID = "api-server"
+	kid      = "stub-key"
+	code     = "stub-code"
+)
+
+// stubIdP 本地的 OIDC IdP, 只实现 discovery、JWKS、token 和 userinfo 端点
+type stubIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	// challenge 授权请求中的 code_challenge 和 nonce, 测试中直接设置, 省去浏览器跳转
+	challenge string
+	nonce     string
+	// signKey 签发 ID token 使用的私钥, 用于模拟伪造的签名
+	signKey *rsa.PrivateKey
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &stubIdP{key: key, signKey: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"userinfo_endpoint":                     idp.server.URL + "/userinfo",
+			"jwks_uri":                              idp.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("code") != code {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != idp.challenge {
+			http.Error(w, `{"error":"invalid_grant","error_description":"pkce verification failed"}`, http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"access_token": "stub-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   300,
+			"id_token":     idp.idToken(t),
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer stub-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"sub":         "user-1",
+			"email":       "alice@example.com",
+			"picture":     "https://example.com/alice.png",
+			"realm_roles": map[string]any{"roles": []string{"admin", "dev"}},
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *stubIdP) idToken(t *testing.T) string {
+	now := time.Now()
+	token := jwtv5.NewWithClaims(jwtv5.SigningMethodRS256, jwtv5.MapClaims{
+		"iss":                idp.server.URL,
+		"sub":                "user-1",
+		"aud":                clientID,
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              idp.nonce,
+		"preferred_username": "alice",
+		"name":               "Alice",
+		"groups":             []string{"platform"},
+	})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(idp.signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func newOAuth2(t *testing.T, issuer string) *oauth.OAuth2 {
+	viper.Set("oauth2.enable", true)
+	viper.Set("oauth2.providers", map[string]any{
+		"gitlab": map[string]any{
+			"type":         "oidc",
+			"issuer":       issuer,
+			"clientId":     clientID,
+			"clientSecret": "secret",
+			"scopes":       []string{"profile", "email"},
+			"redirectUrl":  "http://localhost/oauth/login",
+			"claims":       map[string]any{"roles": "realm_roles.roles"},
+		},
+	})
+	t.Cleanup(func() {
+		viper.Set("oauth2.enable", nil)
+		viper.Set("oauth2.providers", nil)
+	})
+	o, err := oauth.NewOAuth2()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// authorize 模拟浏览器跳转到 IdP 授权页面, 记录授权请求中的 code_challenge 和 nonce
+func authorize(t *testing.T, o *oauth.OAuth2, idp *stubIdP, params *oauth.AuthParams) {
+	redirect, err := o.Redirect(context.Background(), "gitlab", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(redirect, idp.server.URL+"/authorize?") {
+		t.Fatalf("unexpected authorize url %s", redirect)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("state") != params.State {
+		t.Fatalf("unexpected authorize params %v", q)
+	}
+	if !strings.Contains(q.Get("scope"), "openid") {
+		t.Fatalf("openid scope missing: %s", q.Get("scope"))
+	}
+	idp.challenge = q.Get("code_challenge")
+	idp.nonce = q.Get("nonce")
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newStubIdP(t)
+	o := newOAuth2(t, idp.server.URL)
+	params := oauth.NewAuthParams()
+	authorize(t, o, idp, params)
+
+	ctx := oauth.WithAuthParams(context.Background(), params)
+	token, err := o.Auth(ctx, code, "gitlab", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := o.Identity(ctx, token, "gitlab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Provider != "gitlab" || user.Type != oauth.ProviderTypeOIDC {
+		t.Fatalf("unexpected provider %s/%s", user.Provider, user.Type)
+	}
+	if user.Subject != "user-1" || user.Name != "alice" || user.NickName != "Alice" || user.Email != "alice@example.com" || user.Avatar != "https://example.com/alice.png" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if len(user.Groups) != 1 || user.Groups[0] != "platform" {
+		t.Fatalf("unexpected groups %v", user.Groups)
+	}
+	if len(user.Roles) != 2 || user.Roles[0] != "admin" {
+		t.Fatalf("unexpected roles %v", user.Roles)
+	}
+}
+
+func TestOIDCRejectsInvalidLogin(t *testing.T) {
+	idp := newStubIdP(t)
+	o := newOAuth2(t, idp.server.URL)
+
+	t.Run("pkce verifier mismatch", func(t *testing.T) {
+		params := oauth.NewAuthParams()
+		authorize(t, o, idp, params)
+		if _, err := o.Auth(context.Background(), code, "gitlab", oauth.NewAuthParams()); err == nil {
+			t.Fatal("expected token exchange to fail with another code_verifier")
+		}
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		params := oauth.NewAuthParams()
+		authorize(t, o, idp, params)
+		token, err := o.Auth(context.Background(), code, "gitlab", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := oauth.WithAuthParams(context.Background(), &oauth.AuthParams{Nonce: "other"})
+		if _, err := o.Identity(ctx, token, "gitlab"); err == nil || !strings.Contains(err.Error(), "nonce") {
+			t.Fatalf("expected nonce error, got %v", err)
+		}
+	})
+
+	t.Run("forged signature", func(t *testing.T) {
+		forged, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idp.signKey = forged
+		defer func() { idp.signKey = idp.key }()
+
+		params := oauth.NewAuthParams()
+		authorize(t, o, idp, params)
+		ctx := oauth.WithAuthParams(context.Background(), params)
+		token, err := o.Auth(ctx, code, "gitlab", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := o.Identity(ctx, token, "gitlab"); err == nil {
+			t.Fatal("expected id token signed by another key to be rejected")
+		}
+	})
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newStubIdP(t)
+	o := newOAuth2(t, idp.server.URL+"/realms/other")
+	if _, err := o.Redirect(context.Background(), "gitlab", oauth.NewAuthParams()); err == nil {
+		t.Fatal("expected discovery to fail for unknown issuer")
+	}
+}
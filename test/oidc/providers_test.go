@@ -0,0 +1,217 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/pkg/oauth"
+	"golang.org/x/oauth2"
+)
+
+func newProviders(t *testing.T, providers map[string]any) *oauth.OAuth2 {
+	viper.Set("oauth2.enable", true)
+	viper.Set("oauth2.providers", providers)
+	t.Cleanup(func() {
+		viper.Set("oauth2.enable", nil)
+		viper.Set("oauth2.providers", nil)
+	})
+	o, err := oauth.NewOAuth2()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestGitHubLogin(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("code") != code {
+			http.Error(w, `{"error":"bad_verification_code"}`, http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]any{"access_token": "gho_stub", "token_type": "bearer", "scope": "read:user,user:email"})
+	})
+	mux.HandleFunc("/api/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gho_stub" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]any{"id": 583231, "login": "octocat", "name": "The Octocat", "email": "public@example.com", "avatar_url": "https://example.com/octocat.png"})
+	})
+	mux.HandleFunc("/api/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []map[string]any{
+			{"email": "unverified@example.com", "primary": false, "verified": false},
+			{"email": "octocat@example.com", "primary": true, "verified": true},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	o := newProviders(t, map[string]any{
+		"github": map[string]any{
+			"clientId":     clientID,
+			"clientSecret": "secret",
+			"authUrl":      server.URL + "/login/oauth/authorize",
+			"tokenUrl":     server.URL + "/login/oauth/access_token",
+			"apiUrl":       server.URL + "/api",
+			"redirectUrl":  "http://localhost/oauth/login",
+		},
+	})
+	ctx := context.Background()
+	token, err := o.Auth(ctx, code, "github", oauth.NewAuthParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := o.Identity(ctx, token, "github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Subject != "583231" || user.Name != "octocat" || user.Email != "octocat@example.com" || !user.EmailVerified {
+		t.Fatalf("unexpected user %+v", user)
+	}
+}
+
+func TestWeComLogin(t *testing.T) {
+	var tokenRequests int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/gettoken", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		if r.URL.Query().Get("corpid") != "ww-corp" || r.URL.Query().Get("corpsecret") != "secret" {
+			writeJSON(w, map[string]any{"errcode": 40001, "errmsg": "invalid credential"})
+			return
+		}
+		writeJSON(w, map[string]any{"errcode": 0, "access_token": "corp-token", "expires_in": 7200})
+	})
+	mux.HandleFunc("/cgi-bin/auth/getuserinfo", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("code") {
+		case code:
+			writeJSON(w, map[string]any{"errcode": 0, "userid": "zhangsan", "user_ticket": "ticket"})
+		case "outsider":
+			writeJSON(w, map[string]any{"errcode": 0, "openid": "o-1"})
+		default:
+			writeJSON(w, map[string]any{"errcode": 40029, "errmsg": "invalid code"})
+		}
+	})
+	mux.HandleFunc("/cgi-bin/user/get", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"errcode": 0, "userid": r.URL.Query().Get("userid"), "name": "张三", "department": []int{1, 2}, "position": "engineer"})
+	})
+	mux.HandleFunc("/cgi-bin/auth/getuserdetail", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"errcode": 0, "userid": "zhangsan", "mobile": "13800000000", "biz_mail": "zhangsan@corp.example.com", "avatar": "https://example.com/zs.png"})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	o := newProviders(t, map[string]any{
+		"corp": map[string]any{
+			"type":         "wecom",
+			"clientId":     "ww-corp",
+			"clientSecret": "secret",
+			"agentId":      "1000002",
+			"apiUrl":       server.URL,
+			"redirectUrl":  "http://localhost/oauth/login",
+		},
+	})
+	ctx := context.Background()
+
+	redirect, err := o.Redirect(ctx, "corp", &oauth.AuthParams{State: "state-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := u.Query(); q.Get("appid") != "ww-corp" || q.Get("agentid") != "1000002" || q.Get("state") != "state-1" || q.Get("login_type") != "CorpApp" {
+		t.Fatalf("unexpected redirect %s", redirect)
+	}
+
+	token, err := o.Auth(ctx, code, "corp", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := o.Identity(ctx, token, "corp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Type != oauth.ProviderTypeWeCom || user.Subject != "zhangsan" || user.NickName != "张三" || user.Email != "zhangsan@corp.example.com" || !user.EmailVerified || user.Mobile != "13800000000" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if _, ok := user.Claims["errcode"]; ok {
+		t.Fatalf("errcode should not be in claims: %v", user.Claims)
+	}
+
+	if _, err := o.Auth(ctx, "outsider", "corp", nil); err == nil {
+		t.Fatal("expected non-member to be rejected")
+	}
+	if _, err := o.Auth(ctx, "invalid", "corp", nil); err == nil {
+		t.Fatal("expected invalid code to be rejected")
+	}
+	if tokenRequests != 1 {
+		t.Fatalf("app access token should be cached, requested %d times", tokenRequests)
+	}
+}
+
+// staticDriver 返回固定身份的 driver, subject 使用 provider 的 clientId
+type staticDriver struct {
+	clientID string
+}
+
+func (d *staticDriver) AuthCodeURL(_ context.Context, params *oauth.AuthParams) (string, error) {
+	return "https://idp.example.com/authorize?client_id=" + d.clientID + "&state=" + params.State, nil
+}
+
+func (d *staticDriver) Exchange(_ context.Context, code string, _ *oauth.AuthParams) (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: code}, nil
+}
+
+func (d *staticDriver) Identity(_ context.Context, token *oauth2.Token) (*oauth.Identity, error) {
+	return &oauth.Identity{Subject: d.clientID + "/" + token.AccessToken, Name: token.AccessToken}, nil
+}
+
+func init() {
+	oauth.RegisterDriver("static", func(_ string, config *oauth.OAuth2ProviderConfig) (oauth.ProviderDriver, error) {
+		return &staticDriver{clientID: config.ClientId}, nil
+	})
+}
+
+func TestRegisterDriver(t *testing.T) {
+	o := newProviders(t, map[string]any{
+		"realm-a": map[string]any{"type": "static", "clientId": "a"},
+		"realm-b": map[string]any{"type": "static", "clientId": "b"},
+		"kc-dev":  map[string]any{"type": oauth.ProviderTypeKeycloak, "userInfoUrl": "http://localhost/dev/userinfo"},
+		"kc-prod": map[string]any{"type": oauth.ProviderTypeKeycloak, "userInfoUrl": "http://localhost/prod/userinfo"},
+	})
+	ctx := context.Background()
+	for _, provider := range []string{"realm-a", "realm-b"} {
+		token, err := o.Auth(ctx, "alice", provider, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user, err := o.Identity(ctx, token, provider)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 同一类型的 provider 各自使用自己的配置
+		if user.Provider != provider || user.Type != "static" || user.Subject != provider[len(provider)-1:]+"/alice" || user.Claims == nil {
+			t.Fatalf("unexpected identity %+v", user)
+		}
+	}
+	if _, err := o.Auth(ctx, "", "realm-c", nil); err == nil {
+		t.Fatal("expected unknown provider to be rejected")
+	}
+
+	viper.Set("oauth2.providers", map[string]any{"corp": map[string]any{"type": "unknown"}})
+	if _, err := oauth.NewOAuth2(); err == nil {
+		t.Fatal("expected unsupported type to be rejected")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected duplicate driver registration to panic")
+		}
+	}()
+	oauth.RegisterDriver(oauth.ProviderTypeKeycloak, nil)
+}
@@ -0,0 +1,116 @@
+package password_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/pkg/password"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	denylist := filepath.Join(t.TempDir(), "common.txt")
+	if err := os.WriteFile(denylist, []byte("# common\nPassw0rd!Passw0rd\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("password.policy", map[string]any{
+		"minLength":     10,
+		"requireUpper":  true,
+		"requireLower":  true,
+		"requireDigit":  true,
+		"requireSymbol": true,
+		"denylistFile":  denylist,
+	})
+	defer viper.Set("password.policy", nil)
+
+	policy, err := password.NewPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		userInfo []string
+		rule     string
+	}{
+		{"Ab1!", nil, password.RuleMinLength},
+		{"abcdefgh1!", nil, password.RuleUpper},
+		{"ABCDEFGH1!", nil, password.RuleLower},
+		{"Abcdefghi!", nil, password.RuleDigit},
+		{"Abcdefghi1", nil, password.RuleSymbol},
+		{"Xx1!Alice-2024", []string{"alice@example.com", "bob"}, password.RuleUserInfo},
+		{"Xx1!qqBOB-2024", []string{"alice@example.com", "bob"}, password.RuleUserInfo},
+		{"PASSW0RD!passw0rd", nil, password.RuleCommon},
+		{"Tr0ub4dor&3-horse", []string{"alice@example.com", "bo"}, ""},
+	}
+	for _, tt := range tests {
+		err := policy.Validate(tt.password, tt.userInfo...)
+		if tt.rule == "" {
+			if err != nil {
+				t.Errorf("Validate(%q) = %v, want nil", tt.password, err)
+			}
+			continue
+		}
+		var policyErr *password.PolicyError
+		if !errors.As(err, &policyErr) || policyErr.Rule != tt.rule {
+			t.Errorf("Validate(%q) = %v, want rule %s", tt.password, err, tt.rule)
+		}
+	}
+}
+
+func TestHasher(t *testing.T) {
+	bcryptHasher, err := password.NewHasherWithConfig(password.HasherConfig{Algorithm: password.AlgorithmBcrypt, BcryptCost: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := password.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	argonHasher, err := password.NewHasherWithConfig(password.HasherConfig{Algorithm: password.AlgorithmArgon2id, Argon2id: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bcryptHash, err := bcryptHasher.Hash("secret-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argonHash, err := argonHasher.Hash("secret-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(argonHash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected argon2id hash %s", argonHash)
+	}
+
+	// 两种算法的哈希都可以被任意配置的 Hasher 校验
+	for _, h := range []password.Hasher{bcryptHasher, argonHasher} {
+		for _, hash := range []string{bcryptHash, argonHash} {
+			if !h.Verify("secret-password", hash) {
+				t.Errorf("Verify(%s) = false, want true", hash)
+			}
+			if h.Verify("wrong-password", hash) {
+				t.Errorf("Verify(%s) with wrong password = true", hash)
+			}
+		}
+	}
+	if argonHasher.Verify("secret-password", "$argon2id$v=19$m=1024$bad") {
+		t.Error("Verify malformed hash = true")
+	}
+
+	if bcryptHasher.NeedsRehash(bcryptHash) || argonHasher.NeedsRehash(argonHash) {
+		t.Error("hash with current parameters should not need rehash")
+	}
+	if !argonHasher.NeedsRehash(bcryptHash) || !bcryptHasher.NeedsRehash(argonHash) {
+		t.Error("hash with another algorithm should need rehash")
+	}
+	params.Iterations = 2
+	stronger, err := password.NewHasherWithConfig(password.HasherConfig{Algorithm: password.AlgorithmArgon2id, Argon2id: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stronger.NeedsRehash(argonHash) {
+		t.Error("hash with weaker parameters should need rehash")
+	}
+}
@@ -0,0 +1,83 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/pkg/ratelimit"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	limiter, cleanup := ratelimit.NewMemoryLimiter()
+	defer cleanup()
+
+	ctx := context.Background()
+	for i := range 3 {
+		res, err := limiter.Allow(ctx, "user:1", 3, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != 2-i {
+			t.Fatalf("request %d: unexpected result %+v", i, res)
+		}
+	}
+
+	res, err := limiter.Allow(ctx, "user:1", 3, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.Remaining != 0 || res.ResetAfter <= 0 || res.ResetAfter > time.Minute {
+		t.Fatalf("expected request to be limited, got %+v", res)
+	}
+
+	if res, _ := limiter.Allow(ctx, "user:2", 3, time.Minute); !res.Allowed {
+		t.Fatal("other keys should not be limited")
+	}
+}
+
+func TestMemoryLimiterWindow(t *testing.T) {
+	limiter, cleanup := ratelimit.NewMemoryLimiter()
+	defer cleanup()
+
+	ctx := context.Background()
+	if res, _ := limiter.Allow(ctx, "ip:127.0.0.1", 1, 50*time.Millisecond); !res.Allowed {
+		t.Fatal("first request should be allowed")
+	}
+	if res, _ := limiter.Allow(ctx, "ip:127.0.0.1", 1, 50*time.Millisecond); res.Allowed {
+		t.Fatal("second request should be limited")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if res, _ := limiter.Allow(ctx, "ip:127.0.0.1", 1, 50*time.Millisecond); !res.Allowed {
+		t.Fatal("request should be allowed after the window")
+	}
+}
+
+func TestRateLimiterMatch(t *testing.T) {
+	viper.Set("rateLimit.enable", true)
+	viper.Set("rateLimit.backend", "memory")
+	viper.Set("rateLimit.groups", []map[string]any{
+		{"prefix": "/api/v1", "limit": 100, "window": "1m"},
+		{"prefix": "/api/v1/user/login", "limit": 5, "window": "1m", "key": "ip"},
+	})
+	defer viper.Set("rateLimit.enable", false)
+
+	r, cleanup, err := ratelimit.NewRateLimiter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	rule := r.Match("/api/v1/user/login")
+	if rule == nil || rule.Limit != 5 || rule.Key != ratelimit.KeyIP || rule.Window != time.Minute {
+		t.Fatalf("unexpected login rule %+v", rule)
+	}
+	rule = r.Match("/api/v1/role")
+	if rule == nil || rule.Limit != 100 || rule.Key != ratelimit.KeyUser {
+		t.Fatalf("unexpected default rule %+v", rule)
+	}
+	if r.Match("/swagger/index.html") != nil {
+		t.Fatal("unmatched path should not be limited")
+	}
+}
@@ -0,0 +1,99 @@
+package role_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	casbinv2 "github.com/casbin/casbin/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/audit"
+	"github.com/yiran15/api-server/pkg/casbin"
+	v1 "github.com/yiran15/api-server/service/v1"
+	"github.com/yiran15/api-server/store"
+	"gorm.io/gorm"
+)
+
+type nopRecorder struct{}
+
+func (nopRecorder) Record(context.Context, *audit.Entry, error) {}
+
+func newRoleService(t *testing.T) (v1.RoleServicer, store.RoleStorer) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Role{}, &model.Api{}, &model.CasbinRule{}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := casbin.NewModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer, err := casbinv2.NewSyncedEnforcer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := store.NewDBProvider(db)
+	roleStore := store.NewRoleStore(provider)
+	svc := v1.NewRoleService(roleStore, store.NewApiStore(provider), store.NewCasbinStore(provider), casbin.NewCasbinManager(enforcer, nil), store.NewTxManager(db), nopRecorder{})
+	return svc, roleStore
+}
+
+// createChain 创建 r1 <- r2 <- ... <- rn 的继承链, 返回角色 id
+func createChain(t *testing.T, svc v1.RoleServicer, roleStore store.RoleStorer, prefix string, n int) []int64 {
+	t.Helper()
+	ctx := context.Background()
+	ids := make([]int64, 0, n)
+	for i := 1; i <= n; i++ {
+		req := &apitypes.RoleCreateRequest{Name: fmt.Sprintf("%s%d", prefix, i)}
+		if len(ids) > 0 {
+			req.ParentRoles = []int64{ids[len(ids)-1]}
+		}
+		if err := svc.CreateRole(ctx, req); err != nil {
+			t.Fatalf("create role %s failed: %v", req.Name, err)
+		}
+		role, err := roleStore.Query(ctx, store.Where("name", req.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, role.ID)
+	}
+	return ids
+}
+
+func TestRoleInheritanceCycle(t *testing.T) {
+	svc, roleStore := newRoleService(t)
+	ids := createChain(t, svc, roleStore, "r", 3)
+
+	for _, parent := range []int64{ids[0], ids[2]} {
+		err := svc.UpdateRole(context.Background(), &apitypes.RoleUpdateRequest{IDRequest: &apitypes.IDRequest{ID: parent}, ParentRoles: []int64{ids[2]}})
+		if err == nil || !strings.Contains(err.Error(), "cycle") {
+			t.Fatalf("role %d inheriting its descendant should be rejected, got %v", parent, err)
+		}
+	}
+}
+
+func TestRoleInheritanceDepth(t *testing.T) {
+	svc, roleStore := newRoleService(t)
+	ctx := context.Background()
+	ids := createChain(t, svc, roleStore, "r", 10)
+
+	if err := svc.CreateRole(ctx, &apitypes.RoleCreateRequest{Name: "r11", ParentRoles: []int64{ids[9]}}); err == nil {
+		t.Fatal("creating the 11th level should be rejected")
+	}
+
+	// r1 已有 9 层子角色, 再继承 6 层的角色链时总层数超过限制; r6 有 4 层子角色, 继承 5 层的角色链时刚好 10 层
+	chain := createChain(t, svc, roleStore, "a", 6)
+	if err := svc.UpdateRole(ctx, &apitypes.RoleUpdateRequest{IDRequest: &apitypes.IDRequest{ID: ids[0]}, ParentRoles: []int64{chain[5]}}); err == nil {
+		t.Fatal("inheritance exceeding the limit through existing descendants should be rejected")
+	}
+	if err := svc.UpdateRole(ctx, &apitypes.RoleUpdateRequest{IDRequest: &apitypes.IDRequest{ID: ids[5]}, ParentRoles: []int64{chain[4]}}); err != nil {
+		t.Fatalf("inheritance within the limit should be allowed, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package scim_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/yiran15/api-server/pkg/scim"
+)
+
+var columns = scim.Columns{
+	"username":     {Name: "name"},
+	"emails.value": {Name: "email"},
+	"emails.type":  {SQL: func(op string, v any) (string, []any, error) { return "1 = 1", nil, nil }},
+	"active": {SQL: func(op string, v any) (string, []any, error) {
+		return "status = ?", []any{v}, nil
+	}},
+	"urn:ietf:params:scim:schemas:extension:enterprise:2.0:user.department": {Name: "department"},
+	"meta.lastmodified": {Name: "updated_at", Value: func(v any) (any, error) { return "t:" + v.(string), nil }},
+}
+
+func TestFilterSQL(t *testing.T) {
+	for _, c := range []struct {
+		filter string
+		sql    string
+		args   []any
+	}{
+		{`userName eq "alice"`, "name = ?", []any{"alice"}},
+		{`USERNAME Eq "a\"b"`, "name = ?", []any{`a"b`}},
+		{`userName sw "al%"`, "name LIKE ?", []any{`al\%%`}},
+		{`userName pr`, "(name IS NOT NULL AND name <> '')", nil},
+		{`userName eq null`, "name IS NULL", nil},
+		{`active eq true and (userName co "a" or userName ew "z")`, "(status = ? AND (name LIKE ? OR name LIKE ?))", []any{true, "%a%", "%z"}},
+		{`userName eq "a" or userName eq "b" and active eq false`, "(name = ? OR (name = ? AND status = ?))", []any{"a", "b", false}},
+		{`not (userName eq "a")`, "NOT (name = ?)", []any{"a"}},
+		{`emails[type eq "work" and value co "@example.com"]`, "(1 = 1 AND email LIKE ?)", []any{"%@example.com%"}},
+		{`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department eq "sre"`, "department = ?", []any{"sre"}},
+		{`meta.lastModified gt "2024-01-01T00:00:00Z"`, "updated_at > ?", []any{"t:2024-01-01T00:00:00Z"}},
+	} {
+		expr, err := scim.ParseFilter(c.filter)
+		if err != nil {
+			t.Errorf("ParseFilter(%s) error: %v", c.filter, err)
+			continue
+		}
+		sql, args, err := columns.SQL(expr)
+		if err != nil {
+			t.Errorf("SQL(%s) error: %v", c.filter, err)
+			continue
+		}
+		if sql != c.sql || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("SQL(%s) = %s %v, want %s %v", c.filter, sql, args, c.sql, c.args)
+		}
+	}
+
+	for _, filter := range []string{
+		`userName`,
+		`userName eq`,
+		`userName foo "a"`,
+		`(userName eq "a"`,
+		`userName eq "a`,
+		`userName eq "a" garbage`,
+		`title eq "a"`,
+		`userName co true`,
+	} {
+		expr, err := scim.ParseFilter(filter)
+		if err == nil {
+			_, _, err = columns.SQL(expr)
+		}
+		var scimErr *scim.Error
+		if !errors.As(err, &scimErr) || scimErr.ScimType != scim.ErrInvalidFilter || scimErr.Code() != http.StatusBadRequest {
+			t.Errorf("filter %s: expected invalidFilter, got %v", filter, err)
+		}
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	resource := map[string]any{}
+	_ = json.Unmarshal([]byte(`{
+		"userName": "alice",
+		"active": true,
+		"name": {"formatted": "Alice"},
+		"emails": [{"value": "alice@example.com", "type": "work", "primary": true}],
+		"members": [{"value": "1"}, {"value": "2"}, {"value": "3"}]
+	}`), &resource)
+
+	ops := []scim.PatchOperation{
+		{Op: "Replace", Path: "active", Value: json.RawMessage(`"False"`)},
+		{Op: "replace", Path: "name.givenName", Value: json.RawMessage(`"Ally"`)},
+		{Op: "replace", Path: `emails[type eq "work"].value`, Value: json.RawMessage(`"ally@example.com"`)},
+		{Op: "add", Path: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department", Value: json.RawMessage(`"sre"`)},
+		{Op: "add", Path: "members", Value: json.RawMessage(`[{"value": "3"}, {"value": "4"}]`)},
+		{Op: "remove", Path: `members[value eq "1"]`},
+		{Op: "Remove", Path: "members", Value: json.RawMessage(`[{"value": "2"}]`)},
+		{Op: "add", Value: json.RawMessage(`{"displayName": "Ally", "nickName": "al"}`)},
+		{Op: "remove", Path: "nickName"},
+	}
+	if err := scim.ApplyPatch(resource, ops); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{}
+	_ = json.Unmarshal([]byte(`{
+		"userName": "alice",
+		"displayName": "Ally",
+		"active": "False",
+		"name": {"formatted": "Alice", "givenName": "Ally"},
+		"emails": [{"value": "ally@example.com", "type": "work", "primary": true}],
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"department": "sre"},
+		"members": [{"value": "3"}, {"value": "4"}]
+	}`), &want)
+	if !reflect.DeepEqual(resource, want) {
+		got, _ := json.Marshal(resource)
+		t.Fatalf("unexpected resource %s", got)
+	}
+
+	user := &scim.User{}
+	data, _ := json.Marshal(resource)
+	if err := json.Unmarshal(data, user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Active == nil || *user.Active || user.Enterprise == nil || user.Enterprise.Department != "sre" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+
+	for _, c := range []struct {
+		op       scim.PatchOperation
+		scimType string
+	}{
+		{scim.PatchOperation{Op: "move", Path: "active"}, scim.ErrInvalidSyntax},
+		{scim.PatchOperation{Op: "remove"}, scim.ErrNoTarget},
+		{scim.PatchOperation{Op: "replace", Path: `members[value eq "9"]`, Value: json.RawMessage(`{"value": "10"}`)}, scim.ErrNoTarget},
+		{scim.PatchOperation{Op: "replace", Path: `members[value eq "3"`, Value: json.RawMessage(`{}`)}, scim.ErrInvalidPath},
+		{scim.PatchOperation{Op: "replace", Path: "userName"}, scim.ErrInvalidValue},
+	} {
+		err := scim.ApplyPatch(resource, []scim.PatchOperation{c.op})
+		var scimErr *scim.Error
+		if !errors.As(err, &scimErr) || scimErr.ScimType != c.scimType {
+			t.Errorf("%+v: expected %s, got %v", c.op, c.scimType, err)
+		}
+	}
+}
+
+func TestListRequest(t *testing.T) {
+	count := 500
+	req := &scim.ListRequest{Count: &count, ExcludedAttributes: "meta, Members"}
+	if start, n := req.Page(); start != 1 || n != scim.MaxCount {
+		t.Fatalf("unexpected page %d %d", start, n)
+	}
+	if !req.Excluded("members") || req.Excluded("displayName") {
+		t.Fatal("unexpected excluded attributes")
+	}
+}
@@ -0,0 +1,108 @@
+package session_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/yiran15/api-server/pkg/session"
+	"github.com/yiran15/api-server/store"
+	"github.com/yiran15/api-server/test/memcache"
+)
+
+// revokingCache 在替换会话前删除会话, 模拟 Touch 与注销会话并发
+type revokingCache struct {
+	*memcache.Cache
+}
+
+func (c *revokingCache) CompareAndSwap(ctx context.Context, cacheType store.CacheType, cacheKey any, oldValue, newValue string, expireTime *time.Duration) (bool, error) {
+	if err := c.DelKey(ctx, cacheType, cacheKey); err != nil {
+		return false, err
+	}
+	return c.Cache.CompareAndSwap(ctx, cacheType, cacheKey, oldValue, newValue, expireTime)
+}
+
+func newManager(t *testing.T, cacheStore store.CacheStorer) session.Manager {
+	t.Helper()
+	viper.Set("jwt.refreshExpireTime", "24h")
+	m, err := session.NewManager(cacheStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func newSession(id string, userID int64, lastSeen time.Time) *session.Session {
+	return &session.Session{
+		ID:         id,
+		UserID:     userID,
+		IP:         "127.0.0.1",
+		CreatedAt:  lastSeen,
+		LastSeenAt: lastSeen,
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	ctx := context.Background()
+	m := newManager(t, memcache.New())
+	now := time.Now()
+	for _, s := range []*session.Session{newSession("s1", 1, now.Add(-time.Hour)), newSession("s2", 1, now.Add(-time.Minute))} {
+		if err := m.Save(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ok, err := m.Touch(ctx, 1, "s1", "10.0.0.1", "curl"); err != nil || !ok {
+		t.Fatalf("Touch(s1) = %v, %v, want true", ok, err)
+	}
+	if ok, _ := m.Touch(ctx, 2, "s1", "10.0.0.1", "curl"); ok {
+		t.Fatal("session of other user should not be touched")
+	}
+	s, err := m.Get(ctx, "s1")
+	if err != nil || s == nil || s.IP != "10.0.0.1" || s.UserAgent != "curl" {
+		t.Fatalf("Touch should update client info, got %+v, %v", s, err)
+	}
+
+	sessions, err := m.List(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "s1" {
+		t.Fatalf("sessions should be ordered by last seen, got %+v", sessions)
+	}
+
+	if err := m.Delete(ctx, 1, "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := m.Touch(ctx, 1, "s1", "10.0.0.1", "curl"); ok {
+		t.Fatal("deleted session should not be touched")
+	}
+	if err := m.DeleteAll(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if sessions, _ := m.List(ctx, 1); len(sessions) != 0 {
+		t.Fatalf("all sessions should be deleted, got %d", len(sessions))
+	}
+}
+
+func TestTouchConcurrentRevoke(t *testing.T) {
+	ctx := context.Background()
+	cache := &revokingCache{Cache: memcache.New()}
+	m := newManager(t, cache)
+	if err := m.Save(ctx, newSession("s1", 1, time.Now().Add(-time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := m.Touch(ctx, 1, "s1", "10.0.0.1", "curl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("session revoked during touch should not be reported as active")
+	}
+	if s, _ := m.Get(ctx, "s1"); s != nil {
+		t.Fatal("revoked session should not be written back")
+	}
+}
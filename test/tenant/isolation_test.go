@@ -0,0 +1,136 @@
+package tenant_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/yiran15/api-server/base/apitypes"
+	"github.com/yiran15/api-server/base/constant"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/audit"
+	"github.com/yiran15/api-server/pkg/jwt"
+	v1 "github.com/yiran15/api-server/service/v1"
+	"github.com/yiran15/api-server/store"
+	"github.com/yiran15/api-server/test/memcache"
+	"gorm.io/gorm"
+)
+
+const (
+	tenantX int64 = 2
+	tenantY int64 = 3
+	// member 属于租户 X, outsider 只属于默认租户
+	member   int64 = 1
+	outsider int64 = 2
+)
+
+type nopRecorder struct{}
+
+func (nopRecorder) Record(context.Context, *audit.Entry, error) {}
+
+func newServices(t *testing.T) (*gorm.DB, v1.UserServicer, v1.TenantServicer) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.User{}, &model.Role{}, &model.Api{}, &model.Tenant{}, &model.UserIdentity{}, &model.PersonalAccessToken{}, &model.FeiShuUser{}); err != nil {
+		t.Fatal(err)
+	}
+	users := []*model.User{
+		{ID: member, Name: "member", Email: "member@example.com", Status: helper.Int(model.UserStatusActive)},
+		{ID: outsider, Name: "outsider", Email: "outsider@example.com", Status: helper.Int(model.UserStatusActive)},
+	}
+	tenants := []*model.Tenant{{ID: model.DefaultTenantID, Name: "default"}, {ID: tenantX, Name: "x", Users: users[:1]}, {ID: tenantY, Name: "y"}}
+	if err := db.Create(users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(tenants).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	provider := store.NewDBProvider(db)
+	userStore, tenantStore := store.NewUserStore(provider), store.NewTenantStore(provider)
+	tx := store.NewTxManager(db)
+	userSvc := v1.NewUserService(userStore, store.NewRoleStore(provider), tenantStore, memcache.New(), tx, nil, nil, store.NewPersonalAccessTokenStore(provider), nil, nil, nil, nil, nil, nopRecorder{}, nil, nil, store.NewFeiShuUserStore(provider), store.NewUserIdentityStore(provider), nil, nil)
+	tenantSvc := v1.NewTenantService(tenantStore, userStore, store.NewRoleStore(provider), memcache.New(), tx, nil, nil, userSvc, nopRecorder{})
+	return db, userSvc, tenantSvc
+}
+
+func tenantContext(tenantID int64) context.Context {
+	return jwt.ContextWithTenant(context.Background(), tenantID)
+}
+
+func TestTenantUserIsolation(t *testing.T) {
+	db, svc, _ := newServices(t)
+	ctx := tenantContext(tenantX)
+
+	if _, err := svc.QueryUser(ctx, &apitypes.IDRequest{ID: member}); err != nil {
+		t.Fatalf("member of tenant should be found, got %v", err)
+	}
+	if _, err := svc.QueryUser(ctx, &apitypes.IDRequest{ID: outsider}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("user outside tenant should not be found, got %v", err)
+	}
+	if _, err := svc.QueryUser(tenantContext(model.DefaultTenantID), &apitypes.IDRequest{ID: outsider}); err != nil {
+		t.Fatalf("default tenant should find all users, got %v", err)
+	}
+
+	list, err := svc.ListUser(ctx, &apitypes.UserListRequest{Pagination: &apitypes.Pagination{Page: 1, PageSize: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 1 || list.List[0].ID != member {
+		t.Fatalf("tenant should only list its members, got %d users", list.Total)
+	}
+
+	if err := svc.UpdateUserByAdmin(ctx, &apitypes.UserUpdateAdminRequest{ID: outsider, Status: model.UserStatusDisabled}); err == nil {
+		t.Fatal("user outside tenant should not be disabled")
+	}
+	if err := svc.DeleteUser(ctx, &apitypes.IDRequest{ID: outsider}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("user outside tenant should not be deleted, got %v", err)
+	}
+	if err := svc.RevokeUserTokens(ctx, &apitypes.IDRequest{ID: outsider}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("tokens of user outside tenant should not be revoked, got %v", err)
+	}
+
+	var user model.User
+	if err := db.First(&user, outsider).Error; err != nil {
+		t.Fatalf("user outside tenant should still exist, got %v", err)
+	}
+	if *user.Status != model.UserStatusActive {
+		t.Fatalf("user outside tenant should still be active, got status %d", *user.Status)
+	}
+}
+
+func TestTenantManageIsolation(t *testing.T) {
+	_, _, svc := newServices(t)
+	ctx := tenantContext(tenantX)
+
+	if err := svc.UpdateTenant(ctx, &apitypes.TenantUpdateRequest{IDRequest: &apitypes.IDRequest{ID: tenantY}, Name: "stolen"}); !errors.Is(err, constant.ErrNoPermission) {
+		t.Fatalf("other tenant should not be updated, got %v", err)
+	}
+	if err := svc.AddUsers(ctx, &apitypes.TenantAddUsersRequest{IDRequest: &apitypes.IDRequest{ID: tenantY}, UsersID: []int64{member}}); !errors.Is(err, constant.ErrNoPermission) {
+		t.Fatalf("users should not be added to other tenant, got %v", err)
+	}
+	if err := svc.RemoveUser(ctx, &apitypes.TenantRemoveUserRequest{ID: tenantY, UserID: member}); !errors.Is(err, constant.ErrNoPermission) {
+		t.Fatalf("users should not be removed from other tenant, got %v", err)
+	}
+
+	if err := svc.UpdateTenant(ctx, &apitypes.TenantUpdateRequest{IDRequest: &apitypes.IDRequest{ID: tenantX}, Name: "x2"}); err != nil {
+		t.Fatalf("current tenant should be updated, got %v", err)
+	}
+	if err := svc.UpdateTenant(tenantContext(model.DefaultTenantID), &apitypes.TenantUpdateRequest{IDRequest: &apitypes.IDRequest{ID: tenantY}, Name: "y2"}); err != nil {
+		t.Fatalf("default tenant should manage all tenants, got %v", err)
+	}
+
+	list, err := svc.ListTenant(ctx, &apitypes.TenantListRequest{Pagination: &apitypes.Pagination{Page: 1, PageSize: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 1 || list.List[0].ID != tenantX {
+		t.Fatalf("tenant should only list itself, got %d tenants", list.Total)
+	}
+}
@@ -0,0 +1,32 @@
+package tenant_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/yiran15/api-server/model"
+)
+
+func TestRolesInTenant(t *testing.T) {
+	// 没有租户前缀的缓存是启用多租户前写入的, 属于默认租户
+	cached := []string{"1:admin", "2:admin", "2:ops", "readOnly", "x:y"}
+
+	tests := []struct {
+		tenantID int64
+		want     []string
+	}{
+		{model.DefaultTenantID, []string{"admin", "readOnly", "x:y"}},
+		{2, []string{"admin", "ops"}},
+		{3, []string{}},
+	}
+	for _, tt := range tests {
+		if got := model.RolesInTenant(cached, tt.tenantID); !slices.Equal(got, tt.want) {
+			t.Errorf("RolesInTenant(%d) = %v, want %v", tt.tenantID, got, tt.want)
+		}
+	}
+
+	role := &model.Role{Name: "ops", TenantID: 2}
+	if id, name := model.ParseScopedRoleName(role.ScopedName()); id != 2 || name != "ops" {
+		t.Errorf("ParseScopedRoleName(%s) = %d, %s", role.ScopedName(), id, name)
+	}
+}
@@ -0,0 +1,63 @@
+package totp_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yiran15/api-server/pkg/totp"
+)
+
+// RFC 6238 附录 B 的 SHA1 测试密钥 "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFCVectors(t *testing.T) {
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for ts, want := range cases {
+		got, err := totp.Code(rfcSecret, totp.Step(time.Unix(ts, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("time %d: want %s, got %s", ts, want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	code, err := totp.Code(secret, totp.Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step, ok := totp.Validate(secret, code, now)
+	if !ok || step != totp.Step(now) {
+		t.Fatalf("expected code valid at current step, got %d %v", step, ok)
+	}
+	if _, ok := totp.Validate(secret, code, now.Add(totp.Period)); !ok {
+		t.Fatal("expected code valid within skew")
+	}
+	if _, ok := totp.Validate(secret, code, now.Add(3*totp.Period)); ok {
+		t.Fatal("expected code invalid outside skew")
+	}
+	if _, ok := totp.Validate(secret, "12345", now); ok {
+		t.Fatal("expected short code invalid")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := totp.URI("api-server", "admin@qqlx.net", rfcSecret)
+	if !strings.HasPrefix(uri, "otpauth://totp/api-server:admin@qqlx.net?") || !strings.Contains(uri, "secret="+rfcSecret) {
+		t.Fatalf("unexpected uri: %s", uri)
+	}
+}
@@ -0,0 +1,100 @@
+package watcher_test
+
+import (
+	"testing"
+
+	casbinv2 "github.com/casbin/casbin/v2"
+	"github.com/yiran15/api-server/base/helper"
+	"github.com/yiran15/api-server/model"
+	"github.com/yiran15/api-server/pkg/casbin"
+)
+
+const (
+	dom      = "1"
+	otherDom = "2"
+)
+
+func newEnforcer(t *testing.T) *casbinv2.SyncedEnforcer {
+	m, err := casbin.NewModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer, err := casbinv2.NewSyncedEnforcer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enforcer
+}
+
+func enforce(t *testing.T, enforcer *casbinv2.SyncedEnforcer, sub, dom, obj, act string, want bool) {
+	t.Helper()
+	ok, err := enforcer.Enforce(sub, dom, obj, act)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok != want {
+		t.Errorf("Enforce(%s, %s, %s, %s) = %v, want %v", sub, dom, obj, act, ok, want)
+	}
+}
+
+func TestMessageApply(t *testing.T) {
+	enforcer := newEnforcer(t)
+
+	steps := []*casbin.Message{
+		{Op: casbin.OpUpdate, Added: [][]string{{"admin", dom, "/api/v1/user/:id", "GET", "allow"}, {"admin", dom, "/api/v1/role", "*", "allow"}}},
+		{Op: casbin.OpUpdate, Sec: "g", PType: "g", Added: [][]string{{"alice", "admin", dom}}},
+		// 重复的变更不影响结果
+		{Op: casbin.OpUpdate, Added: [][]string{{"admin", dom, "/api/v1/role", "*", "allow"}}},
+	}
+	for _, msg := range steps {
+		if err := msg.Apply(enforcer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	enforce(t, enforcer, "alice", dom, "/api/v1/user/1", "GET", true)
+	enforce(t, enforcer, "alice", dom, "/api/v1/role", "DELETE", true)
+	enforce(t, enforcer, "alice", otherDom, "/api/v1/role", "DELETE", false)
+
+	rename := &casbin.Message{
+		Op:      casbin.OpUpdate,
+		Removed: [][]string{{"admin", dom, "/api/v1/user/:id", "GET", "allow"}, {"admin", dom, "/api/v1/missing", "GET", "allow"}},
+		Added:   [][]string{{"admin", dom, "/api/v1/api", "GET", "allow"}},
+	}
+	if err := rename.Apply(enforcer); err != nil {
+		t.Fatal(err)
+	}
+	enforce(t, enforcer, "alice", dom, "/api/v1/user/1", "GET", false)
+	enforce(t, enforcer, "alice", dom, "/api/v1/api", "GET", true)
+
+	revoke := &casbin.Message{Op: casbin.OpRemoveFiltered, Sec: "g", PType: "g", FieldValues: []string{"alice"}}
+	if err := revoke.Apply(enforcer); err != nil {
+		t.Fatal(err)
+	}
+	enforce(t, enforcer, "alice", dom, "/api/v1/api", "GET", false)
+
+	if err := (&casbin.Message{Op: casbin.OpReload}).Apply(enforcer); err == nil {
+		t.Error("reload should not be applied incrementally")
+	}
+}
+
+func TestUpdatePolicies(t *testing.T) {
+	enforcer := newEnforcer(t)
+	manager := casbin.NewCasbinManager(enforcer, nil)
+
+	readOnly := &model.CasbinRule{PType: helper.String("p"), V0: helper.String("readOnly"), V1: helper.String(dom), V2: helper.String("/api/v1/*"), V3: helper.String("GET"), V4: helper.String(model.EffectAllow)}
+	inherit := &model.CasbinRule{PType: helper.String("g"), V0: helper.String("ops-admin"), V1: helper.String("readOnly"), V2: helper.String(dom)}
+	// 其他租户的同名角色没有权限
+	other := &model.CasbinRule{PType: helper.String("g"), V0: helper.String("ops-admin"), V1: helper.String("readOnly"), V2: helper.String(otherDom)}
+	if err := manager.UpdatePolicies(nil, []*model.CasbinRule{readOnly, inherit, other}); err != nil {
+		t.Fatal(err)
+	}
+	enforce(t, enforcer, "ops-admin", dom, "/api/v1/role", "GET", true)
+	enforce(t, enforcer, "ops-admin", dom, "/api/v1/role", "POST", false)
+	enforce(t, enforcer, "ops-admin", otherDom, "/api/v1/role", "GET", false)
+
+	if err := manager.UpdatePolicies([]*model.CasbinRule{inherit}, nil); err != nil {
+		t.Fatal(err)
+	}
+	enforce(t, enforcer, "ops-admin", dom, "/api/v1/role", "GET", false)
+	enforce(t, enforcer, "readOnly", dom, "/api/v1/role", "GET", true)
+}